@@ -11,6 +11,7 @@ import (
 	pb "github.com/b2network/b2-indexer/api/protobuf"
 	"github.com/b2network/b2-indexer/api/protobuf/vo"
 	"github.com/b2network/b2-indexer/internal/app/exceptions"
+	"github.com/b2network/b2-indexer/internal/config"
 	"github.com/b2network/b2-indexer/internal/model"
 	"github.com/b2network/b2-indexer/pkg/log"
 	sinohopeType "github.com/b2network/b2-indexer/pkg/sinohope/types"
@@ -41,12 +42,12 @@ func (s *notifyServer) TransactionNotify(ctx context.Context, req *vo.Transactio
 		logger.Errorf("GetDBContext err:%v", err.Error())
 		return ErrorTransactionNotify(exceptions.SystemError, "system error"), nil
 	}
-	listenAddress, err := GetListenAddress(ctx)
+	listenAddresses, err := GetListenAddresses(ctx)
 	if err != nil {
-		logger.Errorf("GetListenAddress err:%v", err.Error())
+		logger.Errorf("GetListenAddresses err:%v", err.Error())
 		return ErrorTransactionNotify(exceptions.SystemError, "system error"), nil
 	}
-	logger.Infof("listen address config:%v", listenAddress)
+	logger.Infof("listen address config:%v", listenAddresses)
 	httpCfg, err := GetHTTPConfig(ctx)
 	if err != nil {
 		logger.Errorf("GetHttpConfig err:%v", err.Error())
@@ -86,7 +87,14 @@ func (s *notifyServer) TransactionNotify(ctx context.Context, req *vo.Transactio
 		logger.Errorf("request detail empty")
 		return ErrorTransactionNotify(exceptions.RequestDetailParameter, "request detail check err"), nil
 	}
-	if requestDetail.To != listenAddress {
+	var listenAddress *config.ListenAddress
+	for i := range listenAddresses {
+		if listenAddresses[i].Address == requestDetail.To {
+			listenAddress = &listenAddresses[i]
+			break
+		}
+	}
+	if listenAddress == nil {
 		logger.Errorf("request detail to address not eq listen address")
 		return ErrorTransactionNotify(exceptions.RequestDetailToMismatch, "request detail to mismatch"), nil
 	}
@@ -126,6 +134,10 @@ func (s *notifyServer) TransactionNotify(ctx context.Context, req *vo.Transactio
 				fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().BtcTxHash),
 				requestDetail.TxHash,
 			).
+			Where(
+				fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().BtcTo),
+				requestDetail.To,
+			).
 			First(&deposit).Error
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -134,6 +146,7 @@ func (s *notifyServer) TransactionNotify(ctx context.Context, req *vo.Transactio
 					BtcFrom:        requestDetail.From,
 					BtcTos:         string("{}"),
 					BtcTo:          requestDetail.To,
+					BtcToLabel:     listenAddress.Label,
 					BtcValue:       amount,
 					BtcFroms:       string("{}"),
 					B2TxStatus:     model.DepositB2TxStatusPending,
@@ -160,10 +173,11 @@ func (s *notifyServer) TransactionNotify(ctx context.Context, req *vo.Transactio
 			if deposit.BtcValue != amount {
 				return errors.New("amount not match")
 			}
-			updateFields := map[string]interface{}{
-				model.Deposit{}.Column().CallbackStatus: model.CallbackStatusSuccess,
-			}
-			err = tx.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(updateFields).Error
+			err = model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To:     map[string]int{model.Deposit{}.Column().CallbackStatus: model.CallbackStatusSuccess},
+				Actor:  model.DepositActorCallback,
+				Reason: "custody transaction notify",
+			})
 			if err != nil {
 				return err
 			}
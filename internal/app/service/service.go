@@ -77,12 +77,12 @@ func GetDBContext(ctx context.Context) (*gorm.DB, error) {
 	return nil, fmt.Errorf("db context not set")
 }
 
-func GetListenAddress(ctx context.Context) (string, error) {
+func GetListenAddresses(ctx context.Context) ([]config.ListenAddress, error) {
 	if v := ctx.Value(types.ListenAddressContextKey); v != nil {
-		serverCtx := v.(string)
+		serverCtx := v.([]config.ListenAddress)
 		return serverCtx, nil
 	}
-	return "", fmt.Errorf("address context not set")
+	return nil, fmt.Errorf("address context not set")
 }
 
 func GetHTTPConfig(ctx context.Context) (*config.HTTPConfig, error) {
@@ -14,7 +14,10 @@ import (
 )
 
 const (
-	FlagHome = "home"
+	FlagHome   = "home"
+	FlagFrom   = "from"
+	FlagTo     = "to"
+	FlagDryRun = "dry-run"
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -41,6 +44,9 @@ func rootCmd() *cobra.Command {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.AddCommand(startCmd())
 	rootCmd.AddCommand(startHTTPServer())
+	rootCmd.AddCommand(reindexCmd())
+	rootCmd.AddCommand(heldCmd())
+	rootCmd.AddCommand(deadLetterCmd())
 	rootCmd.AddCommand(sinohopeCmd.Sinohope())
 	rootCmd.AddCommand(gvsmCmd.Gvsm())
 	rootCmd.AddCommand(cryptoCmd.Crypto())
@@ -69,6 +75,153 @@ func startCmd() *cobra.Command {
 	return cmd
 }
 
+func reindexCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "reindex bitcoin deposits in a block range",
+		Long: "reindex parses blocks in [from, to] and reports new, existing and mismatched deposits, " +
+			"missing deposits are inserted unless dry run, the live index cursor is not moved",
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			home, err := cmd.Flags().GetString(FlagHome)
+			if err != nil {
+				return err
+			}
+			return server.InterceptConfigsPreRunHandler(cmd, home)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			from, err := cmd.Flags().GetInt64(FlagFrom)
+			if err != nil {
+				return err
+			}
+			to, err := cmd.Flags().GetInt64(FlagTo)
+			if err != nil {
+				return err
+			}
+			dryRun, err := cmd.Flags().GetBool(FlagDryRun)
+			if err != nil {
+				return err
+			}
+			return server.Reindex(GetServerContextFromCmd(cmd), cmd, from, to, dryRun)
+		},
+	}
+	cmd.Flags().String(FlagHome, "", "The application home directory")
+	cmd.Flags().Int64(FlagFrom, 0, "The first block height to reindex")
+	cmd.Flags().Int64(FlagTo, 0, "The last block height to reindex")
+	cmd.Flags().Bool(FlagDryRun, false, "Report only, do not insert missing deposits")
+	_ = cmd.MarkFlagRequired(FlagFrom)
+	_ = cmd.MarkFlagRequired(FlagTo)
+	return cmd
+}
+
+func heldCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "held",
+		Short: "review deposits held by the deposit amount policy",
+	}
+	cmd.AddCommand(heldListCmd())
+	cmd.AddCommand(heldUpdateCmd("release", "release held deposits of a btc tx to bridge", false))
+	cmd.AddCommand(heldUpdateCmd("reject", "reject held deposits of a btc tx", true))
+	return cmd
+}
+
+func heldListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list held deposits",
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			home, err := cmd.Flags().GetString(FlagHome)
+			if err != nil {
+				return err
+			}
+			return server.InterceptConfigsPreRunHandler(cmd, home)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return server.ListHeld(cmd)
+		},
+	}
+	cmd.Flags().String(FlagHome, "", "The application home directory")
+	return cmd
+}
+
+func heldUpdateCmd(use string, short string, reject bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use + " [btc-tx-hash]",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			home, err := cmd.Flags().GetString(FlagHome)
+			if err != nil {
+				return err
+			}
+			return server.InterceptConfigsPreRunHandler(cmd, home)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, err := cmd.Flags().GetString(FlagTo)
+			if err != nil {
+				return err
+			}
+			return server.ReleaseHeld(cmd, args[0], to, reject)
+		},
+	}
+	cmd.Flags().String(FlagHome, "", "The application home directory")
+	cmd.Flags().String(FlagTo, "", "The listened btc to address, all held deposits of the tx if empty")
+	return cmd
+}
+
+func deadLetterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dead-letter",
+		Short: "review deposits exhausted the retry budget",
+	}
+	cmd.AddCommand(deadLetterListCmd())
+	cmd.AddCommand(deadLetterRetryCmd())
+	return cmd
+}
+
+func deadLetterListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list dead letter deposits",
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			home, err := cmd.Flags().GetString(FlagHome)
+			if err != nil {
+				return err
+			}
+			return server.InterceptConfigsPreRunHandler(cmd, home)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return server.ListDeadLetter(cmd)
+		},
+	}
+	cmd.Flags().String(FlagHome, "", "The application home directory")
+	return cmd
+}
+
+func deadLetterRetryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retry [btc-tx-hash]",
+		Short: "send dead letter deposits of a btc tx again with a new retry budget",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			home, err := cmd.Flags().GetString(FlagHome)
+			if err != nil {
+				return err
+			}
+			return server.InterceptConfigsPreRunHandler(cmd, home)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, err := cmd.Flags().GetString(FlagTo)
+			if err != nil {
+				return err
+			}
+			return server.RetryDeadLetter(cmd, args[0], to)
+		},
+	}
+	cmd.Flags().String(FlagHome, "", "The application home directory")
+	cmd.Flags().String(FlagTo, "", "The listened btc to address, all dead letter deposits of the tx if empty")
+	return cmd
+}
+
 // GetServerContextFromCmd returns a Context from a command or an empty Context
 // if it has not been set.
 func GetServerContextFromCmd(cmd *cobra.Command) *server.Context {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path"
 	"strings"
@@ -38,24 +39,177 @@ type BitcoinConfig struct {
 	RPCPass string `mapstructure:"rpc-pass" env:"BITCOIN_RPC_PASS"`
 	// DisableTLS defines the bitcoin whether tls is required
 	DisableTLS bool `mapstructure:"disable-tls" env:"BITCOIN_DISABLE_TLS" envDefault:"true"`
+	// Backend defines the indexer bitcoin data source, rpc or esplora, default rpc
+	Backend string `mapstructure:"backend" env:"BITCOIN_BACKEND"`
+	// EsploraURL defines the esplora compatible rest api url, required if backend is esplora
+	EsploraURL string `mapstructure:"esplora-url" env:"BITCOIN_ESPLORA_URL"`
 	// WalletName defines the bitcoin wallet name
 	WalletName string `mapstructure:"wallet-name" env:"BITCOIN_WALLET_NAME"`
 	// EnableIndexer defines whether to enable the indexer
 	EnableIndexer bool `mapstructure:"enable-indexer" env:"BITCOIN_ENABLE_INDEXER"`
 	// IndexerListenAddress defines the address to listen on
 	IndexerListenAddress string `mapstructure:"indexer-listen-address" env:"BITCOIN_INDEXER_LISTEN_ADDRESS"`
+	// IndexerListenAddresses defines more addresses to listen on, format: label:address
+	IndexerListenAddresses []string `mapstructure:"indexer-listen-addresses" env:"BITCOIN_INDEXER_LISTEN_ADDRESSES"`
 	// IndexerListenTargetConfirmations defines the number of confirmations to listen on
 	IndexerListenTargetConfirmations uint64 `mapstructure:"indexer-listen-target-confirmations" env:"BITCOIN_INDEXER_LISTEN_TARGET_CONFIRMATIONS" envDefault:"1"`
+	// IndexerPrefetchBlocks defines the number of blocks fetched ahead concurrently, 0 disable prefetch
+	// each prefetch goroutine has its own rpc client, rpc requests of a client are sent one at a time
+	IndexerPrefetchBlocks int `mapstructure:"indexer-prefetch-blocks" env:"BITCOIN_INDEXER_PREFETCH_BLOCKS"`
+	// IndexerRPCBatchSize defines the number of prevout txs fetched per batched json-rpc request, 0 disable batch
+	IndexerRPCBatchSize int `mapstructure:"indexer-rpc-batch-size" env:"BITCOIN_INDEXER_RPC_BATCH_SIZE"`
+	// IndexerTxCacheSize defines the number of recently seen txs cached, 0 disable cache
+	IndexerTxCacheSize int `mapstructure:"indexer-tx-cache-size" env:"BITCOIN_INDEXER_TX_CACHE_SIZE"`
+	// IndexerBlockPrevouts defines whether to fetch blocks by getblock verbosity 3, prevouts are returned
+	// with the block instead of fetched by tx, requires bitcoin core 23.0 or later
+	IndexerBlockPrevouts bool `mapstructure:"indexer-block-prevouts" env:"BITCOIN_INDEXER_BLOCK_PREVOUTS"`
+	// IndexerZMQBlockEndpoint defines the bitcoin core zmqpubhashblock endpoint, e.g. tcp://127.0.0.1:28332, empty disable
+	IndexerZMQBlockEndpoint string `mapstructure:"indexer-zmq-block-endpoint" env:"BITCOIN_INDEXER_ZMQ_BLOCK_ENDPOINT"`
+	// IndexerEnableMempool defines whether to track unconfirmed deposits in mempool
+	IndexerEnableMempool bool `mapstructure:"indexer-enable-mempool" env:"BITCOIN_INDEXER_ENABLE_MEMPOOL"`
+	// IndexerFromAttribution defines how the l2 user is attributed from tx inputs, first, largest or reject-multiple, default first
+	IndexerFromAttribution string `mapstructure:"indexer-from-attribution" env:"BITCOIN_INDEXER_FROM_ATTRIBUTION"`
+	// IndexerTxTypeActions defines the action of classified tx types, format: type:action, action skip, quarantine or deposit,
+	// unset tx types are quarantined
+	IndexerTxTypeActions []string `mapstructure:"indexer-tx-type-actions" env:"BITCOIN_INDEXER_TX_TYPE_ACTIONS"`
 	// Bridge defines the bridge config
 	Bridge BridgeConfig `mapstructure:"bridge"`
 	Eps    EpsConfig    `mapstructure:"eps"`
 }
 
+// ListenAddress defines a listened bitcoin address and its label
+type ListenAddress struct {
+	Label   string
+	Address string
+}
+
+const (
+	// BitcoinBackendRPC bitcoin core json-rpc backend, requires txindex
+	BitcoinBackendRPC = "rpc"
+	// BitcoinBackendEsplora esplora compatible rest api backend
+	BitcoinBackendEsplora = "esplora"
+)
+
+const (
+	// FromAttributionFirst attribute deposit to the first input address
+	FromAttributionFirst = "first"
+	// FromAttributionLargest attribute deposit to the input address with the largest total spent value
+	FromAttributionLargest = "largest"
+	// FromAttributionRejectMultiple reject deposit if inputs are owned by multiple distinct addresses
+	FromAttributionRejectMultiple = "reject-multiple"
+)
+
+const (
+	// TxTypeActionSkip classified tx is not recorded
+	TxTypeActionSkip = "skip"
+	// TxTypeActionQuarantine classified tx is recorded for review, not processed
+	TxTypeActionQuarantine = "quarantine"
+	// TxTypeActionDeposit classified tx is processed as deposit
+	TxTypeActionDeposit = "deposit"
+)
+
+const (
+	// AAResolverParticle resolve aa smart accounts by the particle api
+	AAResolverParticle = "particle"
+	// AAResolverLocal derive aa smart accounts locally from the factory params
+	AAResolverLocal = "local"
+	// AAResolverCrossCheck resolve by the particle api and compare with the locally derived smart accounts
+	AAResolverCrossCheck = "cross-check"
+)
+
+// DefaultListenAddressLabel is the label of IndexerListenAddress
+const DefaultListenAddressLabel = "default"
+
+// ListenAddresses returns all listened addresses, IndexerListenAddress is labeled default
+func (c *BitcoinConfig) ListenAddresses() ([]ListenAddress, error) {
+	listenAddresses := make([]ListenAddress, 0, len(c.IndexerListenAddresses)+1)
+	if c.IndexerListenAddress != "" {
+		listenAddresses = append(listenAddresses, ListenAddress{
+			Label:   DefaultListenAddressLabel,
+			Address: c.IndexerListenAddress,
+		})
+	}
+	for _, v := range c.IndexerListenAddresses {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		label, address, found := strings.Cut(v, ":")
+		label = strings.TrimSpace(label)
+		address = strings.TrimSpace(address)
+		if !found || label == "" || address == "" {
+			return nil, fmt.Errorf("invalid listen address %s, format: label:address", v)
+		}
+		listenAddresses = append(listenAddresses, ListenAddress{
+			Label:   label,
+			Address: address,
+		})
+	}
+	labels := make(map[string]struct{}, len(listenAddresses))
+	addresses := make(map[string]struct{}, len(listenAddresses))
+	for _, v := range listenAddresses {
+		if _, ok := labels[v.Label]; ok {
+			return nil, fmt.Errorf("duplicate listen address label %s", v.Label)
+		}
+		if _, ok := addresses[v.Address]; ok {
+			return nil, fmt.Errorf("duplicate listen address %s", v.Address)
+		}
+		labels[v.Label] = struct{}{}
+		addresses[v.Address] = struct{}{}
+	}
+	return listenAddresses, nil
+}
+
+// TxTypeActions returns the action of classified tx types
+func (c *BitcoinConfig) TxTypeActions() (map[string]string, error) {
+	actions := make(map[string]string, len(c.IndexerTxTypeActions))
+	for _, v := range c.IndexerTxTypeActions {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		txType, action, found := strings.Cut(v, ":")
+		txType = strings.TrimSpace(txType)
+		action = strings.TrimSpace(action)
+		if !found || txType == "" || action == "" {
+			return nil, fmt.Errorf("invalid tx type action %s, format: type:action", v)
+		}
+		switch action {
+		case TxTypeActionSkip, TxTypeActionQuarantine, TxTypeActionDeposit:
+		default:
+			return nil, fmt.Errorf("invalid tx type action %s, action: skip, quarantine or deposit", v)
+		}
+		if _, ok := actions[txType]; ok {
+			return nil, fmt.Errorf("duplicate tx type %s", txType)
+		}
+		actions[txType] = action
+	}
+	return actions, nil
+}
+
 type BridgeConfig struct {
 	// EthRPCURL defines the ethereum rpc url, b2 rollup rpc
 	EthRPCURL string `mapstructure:"eth-rpc-url" env:"BITCOIN_BRIDGE_ETH_RPC_URL"`
+	// EthRPCURLs defines failover eth rpc urls
+	EthRPCURLs []string `mapstructure:"eth-rpc-urls" env:"BITCOIN_BRIDGE_ETH_RPC_URLS"`
+	// EthRPCTimeout defines the eth rpc request timeout in seconds, 0 use the default 30s
+	EthRPCTimeout int64 `mapstructure:"eth-rpc-timeout" env:"BITCOIN_BRIDGE_ETH_RPC_TIMEOUT"`
+	// EthRPCRetry defines the retry times of eth rpc requests failed by transient errors, 0 disable
+	EthRPCRetry int `mapstructure:"eth-rpc-retry" env:"BITCOIN_BRIDGE_ETH_RPC_RETRY"`
 	// EthPrivKey defines the invoke ethereum private key
 	EthPrivKey string `mapstructure:"eth-priv-key" env:"BITCOIN_BRIDGE_ETH_PRIV_KEY"`
+	// RemoteSignerURL defines the eth_signTransaction remote signer url, the eth priv key is not loaded if set
+	RemoteSignerURL string `mapstructure:"remote-signer-url" env:"BITCOIN_BRIDGE_REMOTE_SIGNER_URL"`
+	// RemoteSignerAddress defines the address signing txs by the remote signer
+	RemoteSignerAddress string `mapstructure:"remote-signer-address" env:"BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESS"`
+	// EthPrivKeys defines more signer priv keys for rotation, decrypted as the eth priv key
+	EthPrivKeys []string `mapstructure:"eth-priv-keys" env:"BITCOIN_BRIDGE_ETH_PRIV_KEYS"`
+	// RemoteSignerAddresses defines more remote signer addresses for rotation
+	RemoteSignerAddresses []string `mapstructure:"remote-signer-addresses" env:"BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESSES"`
+	// ActiveSigner defines the manual cutover signer address of new txs, empty use the rotation schedule
+	ActiveSigner string `mapstructure:"active-signer" env:"BITCOIN_BRIDGE_ACTIVE_SIGNER"`
+	// SignerRotationInterval defines the signer rotation interval in seconds, 0 disable
+	SignerRotationInterval int64 `mapstructure:"signer-rotation-interval" env:"BITCOIN_BRIDGE_SIGNER_ROTATION_INTERVAL"`
+	// SignerMinBalance defines the signer gas balance alert threshold in satoshi, 0 disable
+	SignerMinBalance int64 `mapstructure:"signer-min-balance" env:"BITCOIN_BRIDGE_SIGNER_MIN_BALANCE"`
 	// ContractAddress defines the l1 -> l2 bridge contract address
 	ContractAddress string `mapstructure:"contract-address" env:"BITCOIN_BRIDGE_CONTRACT_ADDRESS"`
 	// ABI defines the l1 -> l2 bridge contract abi
@@ -76,6 +230,10 @@ type BridgeConfig struct {
 	GasPriceMultiple int64 `mapstructure:"gas-price-multiple" env:"BITCOIN_BRIDGE_GAS_PRICE_MULTIPLE" envDefault:"2"`
 	// B2ExplorerURL defines the b2 explorer url, TODO: temp use explorer gas prices
 	B2ExplorerURL string `mapstructure:"b2-explorer-url" env:"BITCOIN_BRIDGE_B2_EXPLORER_URL"`
+	// EnableLegacyTx defines whether to send legacy gas price txs instead of eip-1559 dynamic fee txs
+	EnableLegacyTx bool `mapstructure:"enable-legacy-tx" env:"BITCOIN_BRIDGE_ENABLE_LEGACY_TX"`
+	// PriceBumpPercent defines the min fee bump percent of replacement txs, 0 use the geth default 10
+	PriceBumpPercent int64 `mapstructure:"price-bump-percent" env:"BITCOIN_BRIDGE_PRICE_BUMP_PERCENT"`
 	// EnableListener defines whether to enable the listener
 	EnableWithdrawListener bool `mapstructure:"enable-withdraw-listener" env:"BITCOIN_BRIDGE_WITHDRAW_ENABLE_LISTENER"`
 	// Deposit defines the deposit event hash
@@ -102,6 +260,39 @@ type BridgeConfig struct {
 	LocalDecryptKey string `mapstructure:"local-decrypt-key" env:"BITCOIN_BRIDGE_LOCAL_DECRYPT_KEY"`
 	// LocalAesAlg defines the bridge server local dec alg, rsa aes
 	LocalDecryptAlg string `mapstructure:"local-decrypt-alg" env:"BITCOIN_BRIDGE_LOCAL_DECRYPT_ALG"`
+	// DepositMaxInFlight defines the max number of unmined deposit txs, deposits are sent one by one if less than 2
+	DepositMaxInFlight int `mapstructure:"deposit-max-in-flight" env:"BITCOIN_BRIDGE_DEPOSIT_MAX_IN_FLIGHT"`
+	// DepositBatchSize defines the max number of deposits in one batchDeposit call, deposits are sent one by one if less than 2
+	DepositBatchSize int `mapstructure:"deposit-batch-size" env:"BITCOIN_BRIDGE_DEPOSIT_BATCH_SIZE"`
+	// DepositMinAmount defines the min deposit amount in satoshi, smaller deposits are held, 0 disable
+	DepositMinAmount int64 `mapstructure:"deposit-min-amount" env:"BITCOIN_BRIDGE_DEPOSIT_MIN_AMOUNT"`
+	// DepositMaxAmount defines the max single deposit amount in satoshi, larger deposits are held, 0 disable
+	DepositMaxAmount int64 `mapstructure:"deposit-max-amount" env:"BITCOIN_BRIDGE_DEPOSIT_MAX_AMOUNT"`
+	// DepositDailyCap defines the max deposit amount in satoshi per btc from address in 24 hours, 0 disable
+	DepositDailyCap int64 `mapstructure:"deposit-daily-cap" env:"BITCOIN_BRIDGE_DEPOSIT_DAILY_CAP"`
+	// AAResolver defines how aa smart accounts are resolved, particle, local or cross-check, default particle
+	AAResolver string `mapstructure:"aa-resolver" env:"BITCOIN_BRIDGE_AA_RESOLVER"`
+	// AAFactoryAddress defines the aa account factory address, required by the local and cross-check resolver
+	AAFactoryAddress string `mapstructure:"aa-factory-address" env:"BITCOIN_BRIDGE_AA_FACTORY_ADDRESS"`
+	// AAAccountImplementation defines the aa account implementation address behind the proxy
+	AAAccountImplementation string `mapstructure:"aa-account-implementation" env:"BITCOIN_BRIDGE_AA_ACCOUNT_IMPLEMENTATION"`
+	// AAProxyCreationCode defines the hex creation bytecode of the aa account proxy deployed by the factory
+	AAProxyCreationCode string `mapstructure:"aa-proxy-creation-code" env:"BITCOIN_BRIDGE_AA_PROXY_CREATION_CODE"`
+	// AAAccountIndex defines the aa account index, the create2 salt of the factory
+	AAAccountIndex int64 `mapstructure:"aa-account-index" env:"BITCOIN_BRIDGE_AA_ACCOUNT_INDEX"`
+	// AACheckPubKey defines a btc public key registered in particle, the local resolver is not started
+	// unless the derived smart account of the pubkey matches particle, required by the local resolver
+	AACheckPubKey string `mapstructure:"aa-check-pubkey" env:"BITCOIN_BRIDGE_AA_CHECK_PUBKEY"`
+	// AANotFoundTTL defines the seconds to cache aa address not found, 0 use 600
+	AANotFoundTTL int64 `mapstructure:"aa-not-found-ttl" env:"BITCOIN_BRIDGE_AA_NOT_FOUND_TTL"`
+	// AssetDecimals defines the decimals of the bridged asset on b2, 0 use 18, amounts are converted from satoshi
+	AssetDecimals uint `mapstructure:"asset-decimals" env:"BITCOIN_BRIDGE_ASSET_DECIMALS"`
+	// DryRun defines whether to simulate deposits by eth_call and record the would-be txs instead of broadcasting
+	DryRun bool `mapstructure:"dry-run" env:"BITCOIN_BRIDGE_DRY_RUN"`
+	// DepositMaxRetry defines the retry budget of a failed deposit before dead letter, 0 use 10
+	DepositMaxRetry int `mapstructure:"deposit-max-retry" env:"BITCOIN_BRIDGE_DEPOSIT_MAX_RETRY"`
+	// DepositRetryBackoff defines the seconds before the first retry of a failed deposit, doubled on each retry, 0 use 20
+	DepositRetryBackoff int64 `mapstructure:"deposit-retry-backoff" env:"BITCOIN_BRIDGE_DEPOSIT_RETRY_BACKOFF"`
 }
 
 // TODO: @robertcc0410 env prefix, mapstructure and env,  env prefix in the rule must be the same
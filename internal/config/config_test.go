@@ -19,16 +19,39 @@ func TestBitcoinConfig(t *testing.T) {
 	os.Unsetenv("BITCOIN_RPC_USER")
 	os.Unsetenv("BITCOIN_RPC_PASS")
 	os.Unsetenv("BITCOIN_DISABLE_TLS")
+	os.Unsetenv("BITCOIN_BACKEND")
+	os.Unsetenv("BITCOIN_ESPLORA_URL")
 	os.Unsetenv("BITCOIN_WALLET_NAME")
 	os.Unsetenv("BITCOIN_ENABLE_INDEXER")
 	os.Unsetenv("BITCOIN_INDEXER_LISTEN_ADDRESS")
+	os.Unsetenv("BITCOIN_INDEXER_LISTEN_ADDRESSES")
 	os.Unsetenv("BITCOIN_INDEXER_LISTEN_TARGET_CONFIRMATIONS")
+	os.Unsetenv("BITCOIN_INDEXER_PREFETCH_BLOCKS")
+	os.Unsetenv("BITCOIN_INDEXER_RPC_BATCH_SIZE")
+	os.Unsetenv("BITCOIN_INDEXER_TX_CACHE_SIZE")
+	os.Unsetenv("BITCOIN_INDEXER_BLOCK_PREVOUTS")
+	os.Unsetenv("BITCOIN_INDEXER_ZMQ_BLOCK_ENDPOINT")
+	os.Unsetenv("BITCOIN_INDEXER_ENABLE_MEMPOOL")
+	os.Unsetenv("BITCOIN_INDEXER_FROM_ATTRIBUTION")
+	os.Unsetenv("BITCOIN_INDEXER_TX_TYPE_ACTIONS")
 	os.Unsetenv("BITCOIN_BRIDGE_ETH_RPC_URL")
+	os.Unsetenv("BITCOIN_BRIDGE_ETH_RPC_URLS")
+	os.Unsetenv("BITCOIN_BRIDGE_ETH_RPC_TIMEOUT")
+	os.Unsetenv("BITCOIN_BRIDGE_ETH_RPC_RETRY")
 	os.Unsetenv("BITCOIN_BRIDGE_CONTRACT_ADDRESS")
 	os.Unsetenv("BITCOIN_BRIDGE_ETH_PRIV_KEY")
+	os.Unsetenv("BITCOIN_BRIDGE_REMOTE_SIGNER_URL")
+	os.Unsetenv("BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESS")
+	os.Unsetenv("BITCOIN_BRIDGE_ETH_PRIV_KEYS")
+	os.Unsetenv("BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESSES")
+	os.Unsetenv("BITCOIN_BRIDGE_ACTIVE_SIGNER")
+	os.Unsetenv("BITCOIN_BRIDGE_SIGNER_ROTATION_INTERVAL")
+	os.Unsetenv("BITCOIN_BRIDGE_SIGNER_MIN_BALANCE")
 	os.Unsetenv("BITCOIN_BRIDGE_ABI")
 	os.Unsetenv("BITCOIN_BRIDGE_GAS_LIMIT")
 	os.Unsetenv("BITCOIN_BRIDGE_ENABLE_EOA_TRANSFER")
+	os.Unsetenv("BITCOIN_BRIDGE_ENABLE_LEGACY_TX")
+	os.Unsetenv("BITCOIN_BRIDGE_PRICE_BUMP_PERCENT")
 	os.Unsetenv("BITCOIN_BRIDGE_AA_B2_API")
 	os.Unsetenv("BITCOIN_BRIDGE_AA_PARTICLE_RPC")
 	os.Unsetenv("BITCOIN_BRIDGE_AA_PARTICLE_PROJECT_ID")
@@ -49,6 +72,22 @@ func TestBitcoinConfig(t *testing.T) {
 	os.Unsetenv("BITCOIN_BRIDGE_VSM_IV")
 	os.Unsetenv("BITCOIN_BRIDGE_LOCAL_DECRYPT_KEY")
 	os.Unsetenv("BITCOIN_BRIDGE_LOCAL_DECRYPT_ALG")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_MAX_IN_FLIGHT")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_BATCH_SIZE")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_MIN_AMOUNT")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_MAX_AMOUNT")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_DAILY_CAP")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_RESOLVER")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_FACTORY_ADDRESS")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_ACCOUNT_IMPLEMENTATION")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_PROXY_CREATION_CODE")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_ACCOUNT_INDEX")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_CHECK_PUBKEY")
+	os.Unsetenv("BITCOIN_BRIDGE_AA_NOT_FOUND_TTL")
+	os.Unsetenv("BITCOIN_BRIDGE_ASSET_DECIMALS")
+	os.Unsetenv("BITCOIN_BRIDGE_DRY_RUN")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_MAX_RETRY")
+	os.Unsetenv("BITCOIN_BRIDGE_DEPOSIT_RETRY_BACKOFF")
 	config, err := config.LoadBitcoinConfig("./testdata")
 	require.NoError(t, err)
 	require.Equal(t, "signet", config.NetworkName)
@@ -57,15 +96,38 @@ func TestBitcoinConfig(t *testing.T) {
 	require.Equal(t, "b2node", config.RPCUser)
 	require.Equal(t, "b2node", config.RPCPass)
 	require.Equal(t, true, config.DisableTLS)
+	require.Equal(t, "esplora", config.Backend)
+	require.Equal(t, "https://blockstream.info/testnet/api", config.EsploraURL)
 	require.Equal(t, "b2node", config.WalletName)
 	require.Equal(t, true, config.EnableIndexer)
 	require.Equal(t, "tb1qfhhxljfajcppfhwa09uxwty5dz4xwfptnqmvtv", config.IndexerListenAddress)
+	require.Equal(t, []string{"vault:tb1pwzv7fv35yl7ypwj8w7al2t8apd6yf4568cs772qjwper74xqc99sk8x7tk"}, config.IndexerListenAddresses)
 	require.Equal(t, uint64(1), config.IndexerListenTargetConfirmations)
+	require.Equal(t, 4, config.IndexerPrefetchBlocks)
+	require.Equal(t, 100, config.IndexerRPCBatchSize)
+	require.Equal(t, 10000, config.IndexerTxCacheSize)
+	require.Equal(t, true, config.IndexerBlockPrevouts)
+	require.Equal(t, "tcp://127.0.0.1:28332", config.IndexerZMQBlockEndpoint)
+	require.Equal(t, true, config.IndexerEnableMempool)
+	require.Equal(t, "largest", config.IndexerFromAttribution)
+	require.Equal(t, []string{"inscription:quarantine", "runes:skip"}, config.IndexerTxTypeActions)
 	require.Equal(t, "localhost:8545", config.Bridge.EthRPCURL)
+	require.Equal(t, []string{"localhost:8546"}, config.Bridge.EthRPCURLs)
+	require.Equal(t, int64(15), config.Bridge.EthRPCTimeout)
+	require.Equal(t, 3, config.Bridge.EthRPCRetry)
 	require.Equal(t, "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2", config.Bridge.ContractAddress)
 	require.Equal(t, "", config.Bridge.EthPrivKey)
+	require.Equal(t, "http://127.0.0.1:9000", config.Bridge.RemoteSignerURL)
+	require.Equal(t, "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2", config.Bridge.RemoteSignerAddress)
+	require.Equal(t, []string{"aaaa", "bbbb"}, config.Bridge.EthPrivKeys)
+	require.Equal(t, []string{"0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6"}, config.Bridge.RemoteSignerAddresses)
+	require.Equal(t, "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2", config.Bridge.ActiveSigner)
+	require.Equal(t, int64(86400), config.Bridge.SignerRotationInterval)
+	require.Equal(t, int64(100000), config.Bridge.SignerMinBalance)
 	require.Equal(t, "abi.json", config.Bridge.ABI)
 	require.Equal(t, false, config.Bridge.EnableEoaTransfer)
+	require.Equal(t, true, config.Bridge.EnableLegacyTx)
+	require.Equal(t, int64(15), config.Bridge.PriceBumpPercent)
 	require.Equal(t, "127.0.0.1:8080/v1/btc/pubkey", config.Bridge.AAB2PI)
 	require.Equal(t, "127.0.0.1:8080", config.Bridge.AAParticleRPC)
 	require.Equal(t, "11111", config.Bridge.AAParticleProjectID)
@@ -86,6 +148,22 @@ func TestBitcoinConfig(t *testing.T) {
 	require.Equal(t, "abc", config.Bridge.VSMIv)
 	require.Equal(t, "aaa", config.Bridge.LocalDecryptKey)
 	require.Equal(t, "aes", config.Bridge.LocalDecryptAlg)
+	require.Equal(t, 8, config.Bridge.DepositMaxInFlight)
+	require.Equal(t, 20, config.Bridge.DepositBatchSize)
+	require.Equal(t, int64(10000), config.Bridge.DepositMinAmount)
+	require.Equal(t, int64(100000000), config.Bridge.DepositMaxAmount)
+	require.Equal(t, int64(500000000), config.Bridge.DepositDailyCap)
+	require.Equal(t, "cross-check", config.Bridge.AAResolver)
+	require.Equal(t, "0x0000000000000000000000000000000000000022", config.Bridge.AAFactoryAddress)
+	require.Equal(t, "0x0000000000000000000000000000000000000011", config.Bridge.AAAccountImplementation)
+	require.Equal(t, "0x6080", config.Bridge.AAProxyCreationCode)
+	require.Equal(t, int64(1), config.Bridge.AAAccountIndex)
+	require.Equal(t, "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", config.Bridge.AACheckPubKey)
+	require.Equal(t, int64(300), config.Bridge.AANotFoundTTL)
+	require.Equal(t, uint(18), config.Bridge.AssetDecimals)
+	require.Equal(t, true, config.Bridge.DryRun)
+	require.Equal(t, 5, config.Bridge.DepositMaxRetry)
+	require.Equal(t, int64(30), config.Bridge.DepositRetryBackoff)
 }
 
 func TestBitcoinConfigEnv(t *testing.T) {
@@ -95,13 +173,34 @@ func TestBitcoinConfigEnv(t *testing.T) {
 	os.Setenv("BITCOIN_RPC_USER", "abc")
 	os.Setenv("BITCOIN_RPC_PASS", "abcd")
 	os.Setenv("BITCOIN_DISABLE_TLS", "false")
+	os.Setenv("BITCOIN_BACKEND", "rpc")
+	os.Setenv("BITCOIN_ESPLORA_URL", "http://127.0.0.1:3000")
 	os.Setenv("BITCOIN_WALLET_NAME", "b2node")
 	os.Setenv("BITCOIN_ENABLE_INDEXER", "false")
 	os.Setenv("BITCOIN_INDEXER_LISTEN_ADDRESS", "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz")
+	os.Setenv("BITCOIN_INDEXER_LISTEN_ADDRESSES", "multisig:tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n,vault:tb1pwzv7fv35yl7ypwj8w7al2t8apd6yf4568cs772qjwper74xqc99sk8x7tk")
 	os.Setenv("BITCOIN_INDEXER_LISTEN_TARGET_CONFIRMATIONS", "2")
+	os.Setenv("BITCOIN_INDEXER_PREFETCH_BLOCKS", "8")
+	os.Setenv("BITCOIN_INDEXER_RPC_BATCH_SIZE", "50")
+	os.Setenv("BITCOIN_INDEXER_TX_CACHE_SIZE", "20000")
+	os.Setenv("BITCOIN_INDEXER_BLOCK_PREVOUTS", "false")
+	os.Setenv("BITCOIN_INDEXER_ZMQ_BLOCK_ENDPOINT", "tcp://127.0.0.1:28333")
+	os.Setenv("BITCOIN_INDEXER_ENABLE_MEMPOOL", "false")
+	os.Setenv("BITCOIN_INDEXER_FROM_ATTRIBUTION", "reject-multiple")
+	os.Setenv("BITCOIN_INDEXER_TX_TYPE_ACTIONS", "brc20_transfer:deposit")
 	os.Setenv("BITCOIN_BRIDGE_ETH_RPC_URL", "127.0.0.1:8545")
+	os.Setenv("BITCOIN_BRIDGE_ETH_RPC_URLS", "127.0.0.1:8546,127.0.0.1:8547")
+	os.Setenv("BITCOIN_BRIDGE_ETH_RPC_TIMEOUT", "10")
+	os.Setenv("BITCOIN_BRIDGE_ETH_RPC_RETRY", "2")
 	os.Setenv("BITCOIN_BRIDGE_CONTRACT_ADDRESS", "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DF22")
 	os.Setenv("BITCOIN_BRIDGE_ETH_PRIV_KEY", "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	os.Setenv("BITCOIN_BRIDGE_REMOTE_SIGNER_URL", "http://signer:9000")
+	os.Setenv("BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESS", "0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6")
+	os.Setenv("BITCOIN_BRIDGE_ETH_PRIV_KEYS", "cccc,dddd")
+	os.Setenv("BITCOIN_BRIDGE_REMOTE_SIGNER_ADDRESSES", "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	os.Setenv("BITCOIN_BRIDGE_ACTIVE_SIGNER", "")
+	os.Setenv("BITCOIN_BRIDGE_SIGNER_ROTATION_INTERVAL", "3600")
+	os.Setenv("BITCOIN_BRIDGE_SIGNER_MIN_BALANCE", "5000")
 	os.Setenv("BITCOIN_BRIDGE_ABI", "aaa.abi")
 	os.Setenv("BITCOIN_BRIDGE_GAS_LIMIT", "23333")
 	os.Setenv("BITCOIN_BRIDGE_ENABLE_EOA_TRANSFER", "true")
@@ -128,6 +227,24 @@ func TestBitcoinConfigEnv(t *testing.T) {
 	os.Setenv("BITCOIN_BRIDGE_VSM_IV", "1111abc")
 	os.Setenv("BITCOIN_BRIDGE_LOCAL_DECRYPT_KEY", "abcd")
 	os.Setenv("BITCOIN_BRIDGE_LOCAL_DECRYPT_ALG", "rsa")
+	os.Setenv("BITCOIN_BRIDGE_ENABLE_LEGACY_TX", "false")
+	os.Setenv("BITCOIN_BRIDGE_PRICE_BUMP_PERCENT", "12")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_MAX_IN_FLIGHT", "4")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_BATCH_SIZE", "50")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_MIN_AMOUNT", "546")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_MAX_AMOUNT", "0")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_DAILY_CAP", "1000000")
+	os.Setenv("BITCOIN_BRIDGE_AA_RESOLVER", "local")
+	os.Setenv("BITCOIN_BRIDGE_AA_FACTORY_ADDRESS", "0x0000000000000000000000000000000000000033")
+	os.Setenv("BITCOIN_BRIDGE_AA_ACCOUNT_IMPLEMENTATION", "0x0000000000000000000000000000000000000044")
+	os.Setenv("BITCOIN_BRIDGE_AA_PROXY_CREATION_CODE", "0x6060")
+	os.Setenv("BITCOIN_BRIDGE_AA_ACCOUNT_INDEX", "2")
+	os.Setenv("BITCOIN_BRIDGE_AA_CHECK_PUBKEY", "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5")
+	os.Setenv("BITCOIN_BRIDGE_AA_NOT_FOUND_TTL", "60")
+	os.Setenv("BITCOIN_BRIDGE_ASSET_DECIMALS", "6")
+	os.Setenv("BITCOIN_BRIDGE_DRY_RUN", "false")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_MAX_RETRY", "3")
+	os.Setenv("BITCOIN_BRIDGE_DEPOSIT_RETRY_BACKOFF", "60")
 
 	config, err := config.LoadBitcoinConfig("./")
 	require.NoError(t, err)
@@ -137,15 +254,41 @@ func TestBitcoinConfigEnv(t *testing.T) {
 	require.Equal(t, "abc", config.RPCUser)
 	require.Equal(t, "abcd", config.RPCPass)
 	require.Equal(t, false, config.DisableTLS)
+	require.Equal(t, "rpc", config.Backend)
+	require.Equal(t, "http://127.0.0.1:3000", config.EsploraURL)
 	require.Equal(t, "b2node", config.WalletName)
 	require.Equal(t, false, config.EnableIndexer)
 	require.Equal(t, "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz", config.IndexerListenAddress)
+	require.Equal(t, []string{
+		"multisig:tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+		"vault:tb1pwzv7fv35yl7ypwj8w7al2t8apd6yf4568cs772qjwper74xqc99sk8x7tk",
+	}, config.IndexerListenAddresses)
 	require.Equal(t, uint64(2), config.IndexerListenTargetConfirmations)
+	require.Equal(t, 8, config.IndexerPrefetchBlocks)
+	require.Equal(t, 50, config.IndexerRPCBatchSize)
+	require.Equal(t, 20000, config.IndexerTxCacheSize)
+	require.Equal(t, false, config.IndexerBlockPrevouts)
+	require.Equal(t, "tcp://127.0.0.1:28333", config.IndexerZMQBlockEndpoint)
+	require.Equal(t, false, config.IndexerEnableMempool)
+	require.Equal(t, "reject-multiple", config.IndexerFromAttribution)
+	require.Equal(t, []string{"brc20_transfer:deposit"}, config.IndexerTxTypeActions)
 	require.Equal(t, "127.0.0.1:8545", config.Bridge.EthRPCURL)
+	require.Equal(t, []string{"127.0.0.1:8546", "127.0.0.1:8547"}, config.Bridge.EthRPCURLs)
+	require.Equal(t, int64(10), config.Bridge.EthRPCTimeout)
+	require.Equal(t, 2, config.Bridge.EthRPCRetry)
 	require.Equal(t, "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DF22", config.Bridge.ContractAddress)
 	require.Equal(t, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", config.Bridge.EthPrivKey)
+	require.Equal(t, "http://signer:9000", config.Bridge.RemoteSignerURL)
+	require.Equal(t, "0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6", config.Bridge.RemoteSignerAddress)
+	require.Equal(t, []string{"cccc", "dddd"}, config.Bridge.EthPrivKeys)
+	require.Equal(t, []string{"0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2"}, config.Bridge.RemoteSignerAddresses)
+	require.Equal(t, "", config.Bridge.ActiveSigner)
+	require.Equal(t, int64(3600), config.Bridge.SignerRotationInterval)
+	require.Equal(t, int64(5000), config.Bridge.SignerMinBalance)
 	require.Equal(t, "aaa.abi", config.Bridge.ABI)
 	require.Equal(t, true, config.Bridge.EnableEoaTransfer)
+	require.Equal(t, false, config.Bridge.EnableLegacyTx)
+	require.Equal(t, int64(12), config.Bridge.PriceBumpPercent)
 	require.Equal(t, "127.1.1.1:1234/v1/btc/xx", config.Bridge.AAB2PI)
 	require.Equal(t, "192.168.1.1/aa", config.Bridge.AAParticleRPC)
 	require.Equal(t, "1234", config.Bridge.AAParticleProjectID)
@@ -166,6 +309,133 @@ func TestBitcoinConfigEnv(t *testing.T) {
 	require.Equal(t, "1111abc", config.Bridge.VSMIv)
 	require.Equal(t, "abcd", config.Bridge.LocalDecryptKey)
 	require.Equal(t, "rsa", config.Bridge.LocalDecryptAlg)
+	require.Equal(t, 4, config.Bridge.DepositMaxInFlight)
+	require.Equal(t, 50, config.Bridge.DepositBatchSize)
+	require.Equal(t, int64(546), config.Bridge.DepositMinAmount)
+	require.Equal(t, int64(0), config.Bridge.DepositMaxAmount)
+	require.Equal(t, int64(1000000), config.Bridge.DepositDailyCap)
+	require.Equal(t, "local", config.Bridge.AAResolver)
+	require.Equal(t, "0x0000000000000000000000000000000000000033", config.Bridge.AAFactoryAddress)
+	require.Equal(t, "0x0000000000000000000000000000000000000044", config.Bridge.AAAccountImplementation)
+	require.Equal(t, "0x6060", config.Bridge.AAProxyCreationCode)
+	require.Equal(t, int64(2), config.Bridge.AAAccountIndex)
+	require.Equal(t, "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5", config.Bridge.AACheckPubKey)
+	require.Equal(t, int64(60), config.Bridge.AANotFoundTTL)
+	require.Equal(t, uint(6), config.Bridge.AssetDecimals)
+	require.Equal(t, false, config.Bridge.DryRun)
+	require.Equal(t, 3, config.Bridge.DepositMaxRetry)
+	require.Equal(t, int64(60), config.Bridge.DepositRetryBackoff)
+}
+
+func TestListenAddresses(t *testing.T) {
+	testCases := []struct {
+		name            string
+		listenAddress   string
+		listenAddresses []string
+		expected        []config.ListenAddress
+		errMsg          string
+	}{
+		{
+			name:          "success: single address",
+			listenAddress: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			expected: []config.ListenAddress{
+				{Label: config.DefaultListenAddressLabel, Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz"},
+			},
+		},
+		{
+			name:            "success: multiple addresses",
+			listenAddress:   "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			listenAddresses: []string{"vault: tb1pwzv7fv35yl7ypwj8w7al2t8apd6yf4568cs772qjwper74xqc99sk8x7tk", ""},
+			expected: []config.ListenAddress{
+				{Label: config.DefaultListenAddressLabel, Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz"},
+				{Label: "vault", Address: "tb1pwzv7fv35yl7ypwj8w7al2t8apd6yf4568cs772qjwper74xqc99sk8x7tk"},
+			},
+		},
+		{
+			name:            "fail: invalid format",
+			listenAddresses: []string{"tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz"},
+			errMsg:          "invalid listen address tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz, format: label:address",
+		},
+		{
+			name:            "fail: duplicate label",
+			listenAddresses: []string{"vault:tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz", "vault:tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n"},
+			errMsg:          "duplicate listen address label vault",
+		},
+		{
+			name:            "fail: duplicate address",
+			listenAddress:   "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			listenAddresses: []string{"vault:tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz"},
+			errMsg:          "duplicate listen address tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.BitcoinConfig{
+				IndexerListenAddress:   tc.listenAddress,
+				IndexerListenAddresses: tc.listenAddresses,
+			}
+			listenAddresses, err := cfg.ListenAddresses()
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, listenAddresses)
+		})
+	}
+}
+
+func TestTxTypeActions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		txTypeActions []string
+		expected      map[string]string
+		errMsg        string
+	}{
+		{
+			name:     "success: empty",
+			expected: map[string]string{},
+		},
+		{
+			name:          "success: multiple actions",
+			txTypeActions: []string{"inscription:skip", " runes : deposit", ""},
+			expected: map[string]string{
+				"inscription": config.TxTypeActionSkip,
+				"runes":       config.TxTypeActionDeposit,
+			},
+		},
+		{
+			name:          "fail: invalid format",
+			txTypeActions: []string{"inscription"},
+			errMsg:        "invalid tx type action inscription, format: type:action",
+		},
+		{
+			name:          "fail: invalid action",
+			txTypeActions: []string{"inscription:burn"},
+			errMsg:        "invalid tx type action inscription:burn, action: skip, quarantine or deposit",
+		},
+		{
+			name:          "fail: duplicate tx type",
+			txTypeActions: []string{"runes:skip", "runes:quarantine"},
+			errMsg:        "duplicate tx type runes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.BitcoinConfig{
+				IndexerTxTypeActions: tc.txTypeActions,
+			}
+			actions, err := cfg.TxTypeActions()
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actions)
+		})
+	}
 }
 
 func TestChainParams(t *testing.T) {
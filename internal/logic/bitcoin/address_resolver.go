@@ -0,0 +1,339 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/aa"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/b2network/b2-indexer/pkg/particle"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAAAddressMismatch the resolved smart account is not the locally derived address
+var ErrAAAddressMismatch = errors.New("aa address mismatch")
+
+// DefaultAANotFoundTTL resolve not found addresses again after the ttl
+const DefaultAANotFoundTTL = 10 * time.Minute
+
+// ResolvedAddress pubkey and smart account of the bitcoin address, Err is set if failed to resolve
+type ResolvedAddress struct {
+	BtcAddress   string
+	Pubkey       string
+	SmartAccount string
+	// Err ErrAAAddressNotFound if the pubkey is not registered
+	Err error
+}
+
+// AddressResolver resolves the aa smart accounts of bitcoin addresses
+type AddressResolver interface {
+	// Resolve returns the resolved addresses by bitcoin address, every address is in the result
+	Resolve(btcAddresses []string) map[string]ResolvedAddress
+}
+
+// ResolveAddress resolve the smart account of the bitcoin address
+func ResolveAddress(resolver AddressResolver, btcAddress string) (string, error) {
+	resolved, ok := resolver.Resolve([]string{btcAddress})[btcAddress]
+	if !ok {
+		return "", fmt.Errorf("address %s not resolved", btcAddress)
+	}
+	if resolved.Err != nil {
+		return "", resolved.Err
+	}
+	return resolved.SmartAccount, nil
+}
+
+// AAResolver resolves pubkeys by the aa api, then smart accounts of all pubkeys in one particle call
+type AAResolver struct {
+	pubKeyAPI string
+	particle  *particle.Particle
+	logger    log.Logger
+}
+
+// NewAAResolver returns a new aa resolver
+func NewAAResolver(pubKeyAPI string, particle *particle.Particle, logger log.Logger) *AAResolver {
+	return &AAResolver{
+		pubKeyAPI: pubKeyAPI,
+		particle:  particle,
+		logger:    logger,
+	}
+}
+
+// resolvePubkeys resolve the pubkeys of the addresses by the aa api,
+// returns the results and the addresses with pubkey in the order of first occurrence
+func resolvePubkeys(pubKeyAPI string, btcAddresses []string, logger log.Logger) (map[string]ResolvedAddress, []string) {
+	result := make(map[string]ResolvedAddress, len(btcAddresses))
+	pubkeyAddresses := make([]string, 0, len(btcAddresses))
+	for _, address := range btcAddresses {
+		if _, ok := result[address]; ok {
+			continue
+		}
+		resolved := ResolvedAddress{BtcAddress: address}
+		pubkeyResp, err := aa.GetPubKey(pubKeyAPI, address)
+		switch {
+		case err != nil:
+			logger.Errorw("get pub key:", "pubkey", pubkeyResp, "address", address)
+			resolved.Err = err
+		case pubkeyResp.Code == aa.AddressNotFoundErrCode:
+			resolved.Err = ErrAAAddressNotFound
+		case pubkeyResp.Code != "0":
+			resolved.Err = fmt.Errorf("get pubkey code err:%v", pubkeyResp)
+		default:
+			logger.Infow("get pub key:", "pubkey", pubkeyResp, "address", address)
+			resolved.Pubkey = pubkeyResp.Data.Pubkey
+			pubkeyAddresses = append(pubkeyAddresses, address)
+		}
+		result[address] = resolved
+	}
+	return result, pubkeyAddresses
+}
+
+// Resolve resolve the addresses, the particle call err is set to all addresses with pubkey
+func (r *AAResolver) Resolve(btcAddresses []string) map[string]ResolvedAddress {
+	result, pubkeyAddresses := resolvePubkeys(r.pubKeyAPI, btcAddresses, r.logger)
+	if len(pubkeyAddresses) == 0 {
+		return result
+	}
+	pubkeys := make([]string, 0, len(pubkeyAddresses))
+	for _, address := range pubkeyAddresses {
+		pubkeys = append(pubkeys, result[address].Pubkey)
+	}
+
+	setErr := func(err error) {
+		for _, address := range pubkeyAddresses {
+			resolved := result[address]
+			resolved.Err = err
+			result[address] = resolved
+		}
+	}
+	aaBtcAccount, err := r.particle.AAGetBTCAccount(pubkeys)
+	if err != nil {
+		setErr(err)
+		return result
+	}
+	if len(aaBtcAccount.Result) != len(pubkeys) {
+		r.logger.Errorw("AAGetBTCAccount", "result", aaBtcAccount)
+		setErr(fmt.Errorf("AAGetBTCAccount result not match"))
+		return result
+	}
+	// results are in the order of the pubkeys
+	for i, account := range aaBtcAccount.Result {
+		resolved := result[pubkeyAddresses[i]]
+		if account.BtcPublicKey != "" && !strings.EqualFold(account.BtcPublicKey, resolved.Pubkey) {
+			resolved.Err = fmt.Errorf("AAGetBTCAccount pubkey not match:%s", account.BtcPublicKey)
+		} else {
+			resolved.SmartAccount = account.SmartAccountAddress
+		}
+		result[pubkeyAddresses[i]] = resolved
+	}
+	r.logger.Infow("AAGetBTCAccount", "result", aaBtcAccount.Result)
+	return result
+}
+
+// LocalAAResolver resolves pubkeys by the aa api and derives the counterfactual smart accounts locally
+type LocalAAResolver struct {
+	pubKeyAPI string
+	account   *aa.SmartAccount
+	logger    log.Logger
+}
+
+// NewLocalAAResolver returns a new local aa resolver
+func NewLocalAAResolver(pubKeyAPI string, account *aa.SmartAccount, logger log.Logger) *LocalAAResolver {
+	return &LocalAAResolver{
+		pubKeyAPI: pubKeyAPI,
+		account:   account,
+		logger:    logger,
+	}
+}
+
+// Resolve resolve the pubkeys and derive the smart accounts
+func (r *LocalAAResolver) Resolve(btcAddresses []string) map[string]ResolvedAddress {
+	result, pubkeyAddresses := resolvePubkeys(r.pubKeyAPI, btcAddresses, r.logger)
+	for _, address := range pubkeyAddresses {
+		resolved := result[address]
+		smartAccount, err := r.account.Address(resolved.Pubkey)
+		if err != nil {
+			resolved.Err = err
+		} else {
+			resolved.SmartAccount = smartAccount.Hex()
+		}
+		result[address] = resolved
+	}
+	return result
+}
+
+// CheckSmartAccount compares the locally derived smart account of the pubkey with particle,
+// the err is returned if particle fails or the addresses do not match
+func CheckSmartAccount(particle *particle.Particle, account *aa.SmartAccount, pubkey string) error {
+	if pubkey == "" {
+		return errors.New("aa check pubkey is empty")
+	}
+	local, err := account.Address(pubkey)
+	if err != nil {
+		return err
+	}
+	aaBtcAccount, err := particle.AAGetBTCAccount([]string{pubkey})
+	if err != nil {
+		return err
+	}
+	if len(aaBtcAccount.Result) != 1 || aaBtcAccount.Result[0].SmartAccountAddress == "" {
+		return fmt.Errorf("AAGetBTCAccount result not match")
+	}
+	resolved := aaBtcAccount.Result[0].SmartAccountAddress
+	if !strings.EqualFold(resolved, local.Hex()) {
+		return fmt.Errorf("%w: pubkey %s, resolved %s, local %s", ErrAAAddressMismatch, pubkey, resolved, local.Hex())
+	}
+	return nil
+}
+
+// CrossCheckResolver derives the smart accounts of the resolver results locally, mismatched
+// addresses are alerted and rejected, the resolver err is returned as is
+type CrossCheckResolver struct {
+	resolver AddressResolver
+	account  *aa.SmartAccount
+	logger   log.Logger
+}
+
+// NewCrossCheckResolver returns a new cross check resolver of the resolver
+func NewCrossCheckResolver(resolver AddressResolver, account *aa.SmartAccount, logger log.Logger) *CrossCheckResolver {
+	return &CrossCheckResolver{
+		resolver: resolver,
+		account:  account,
+		logger:   logger,
+	}
+}
+
+// Resolve resolve the addresses and compare with the local smart accounts
+func (r *CrossCheckResolver) Resolve(btcAddresses []string) map[string]ResolvedAddress {
+	result := r.resolver.Resolve(btcAddresses)
+	for address, resolved := range result {
+		// not cross checked, the address is not resolved
+		if resolved.Err != nil {
+			continue
+		}
+		local, err := r.account.Address(resolved.Pubkey)
+		switch {
+		case err != nil:
+			r.logger.Errorw("derive aa address err", "error", err, "address", address, "pubkey", resolved.Pubkey)
+			resolved.Err = err
+		case !strings.EqualFold(resolved.SmartAccount, local.Hex()):
+			r.logger.Errorw("aa address mismatch alert",
+				"address", address,
+				"pubkey", resolved.Pubkey,
+				"resolved", resolved.SmartAccount,
+				"local", local.Hex())
+			resolved.Err = fmt.Errorf("%w: resolved %s, local %s", ErrAAAddressMismatch, resolved.SmartAccount, local.Hex())
+		}
+		result[address] = resolved
+	}
+	return result
+}
+
+// CachedAddressResolver caches resolved addresses in db, not found addresses are cached for the ttl,
+// other errs are not cached
+type CachedAddressResolver struct {
+	resolver    AddressResolver
+	db          *gorm.DB
+	notFoundTTL time.Duration
+	logger      log.Logger
+}
+
+// NewCachedAddressResolver returns a new cached resolver of the resolver, 0 ttl use the default ttl
+func NewCachedAddressResolver(
+	resolver AddressResolver,
+	db *gorm.DB,
+	notFoundTTL time.Duration,
+	logger log.Logger,
+) *CachedAddressResolver {
+	if notFoundTTL <= 0 {
+		notFoundTTL = DefaultAANotFoundTTL
+	}
+	return &CachedAddressResolver{
+		resolver:    resolver,
+		db:          db,
+		notFoundTTL: notFoundTTL,
+		logger:      logger,
+	}
+}
+
+// CachedAddress returns the resolved address of the cache, false if not cached or expired
+func CachedAddress(cached model.AAAddress, now time.Time) (ResolvedAddress, bool) {
+	resolved := ResolvedAddress{
+		BtcAddress:   cached.BtcAddress,
+		Pubkey:       cached.Pubkey,
+		SmartAccount: cached.SmartAccount,
+	}
+	switch cached.Status {
+	case model.AAAddressStatusFound:
+		return resolved, cached.SmartAccount != ""
+	case model.AAAddressStatusNotFound:
+		resolved.Err = ErrAAAddressNotFound
+		return resolved, now.Before(cached.ExpiredAt)
+	default:
+		return resolved, false
+	}
+}
+
+// Resolve resolve the addresses not cached in one batch and cache the results
+func (r *CachedAddressResolver) Resolve(btcAddresses []string) map[string]ResolvedAddress {
+	result := make(map[string]ResolvedAddress, len(btcAddresses))
+	now := time.Now()
+	var cached []model.AAAddress
+	err := r.db.
+		Where(fmt.Sprintf("%s IN (?)", model.AAAddress{}.Column().BtcAddress), btcAddresses).
+		Find(&cached).Error
+	if err != nil {
+		r.logger.Errorw("find aa address cache err", "error", err)
+	}
+	for _, v := range cached {
+		if resolved, ok := CachedAddress(v, now); ok {
+			result[v.BtcAddress] = resolved
+		}
+	}
+	missed := make([]string, 0, len(btcAddresses))
+	for _, address := range btcAddresses {
+		if _, ok := result[address]; !ok {
+			missed = append(missed, address)
+		}
+	}
+	if len(missed) == 0 {
+		return result
+	}
+
+	r.logger.Infow("resolve aa address", "cached", len(result), "missed", len(missed))
+	for address, resolved := range r.resolver.Resolve(missed) {
+		result[address] = resolved
+		entry := model.AAAddress{
+			BtcAddress:   address,
+			Pubkey:       resolved.Pubkey,
+			SmartAccount: resolved.SmartAccount,
+		}
+		switch {
+		case resolved.Err == nil:
+			entry.Status = model.AAAddressStatusFound
+		case errors.Is(resolved.Err, ErrAAAddressNotFound):
+			entry.Status = model.AAAddressStatusNotFound
+			entry.ExpiredAt = now.Add(r.notFoundTTL)
+		default:
+			continue
+		}
+		err := r.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: model.AAAddress{}.Column().BtcAddress}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				model.AAAddress{}.Column().Pubkey,
+				model.AAAddress{}.Column().SmartAccount,
+				model.AAAddress{}.Column().Status,
+				model.AAAddress{}.Column().ExpiredAt,
+				"updated_at",
+			}),
+		}).Create(&entry).Error
+		if err != nil {
+			r.logger.Errorw("save aa address cache err", "error", err, "address", address)
+		}
+	}
+	return result
+}
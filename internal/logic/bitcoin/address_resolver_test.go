@@ -0,0 +1,271 @@
+package bitcoin_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/aa"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/b2network/b2-indexer/pkg/particle"
+	"github.com/stretchr/testify/require"
+)
+
+// testAAServer aa pubkey api and particle rpc stand-in, smart account of pubkey "pk-x" is "sa-x"
+// if not in smartAccounts
+type testAAServer struct {
+	pubkeys          map[string]string
+	smartAccounts    map[string]string
+	particleDown     atomic.Bool
+	pubkeyRequests   atomic.Int64
+	particleRequests atomic.Int64
+	// particleBatches pubkeys of each particle request
+	particleBatches [][]string
+}
+
+func newTestAAResolver(t *testing.T, server *testAAServer) *bitcoin.AAResolver {
+	aaServer, p := newTestAAServer(t, server)
+	return bitcoin.NewAAResolver(aaServer.URL, p, log.NewNopLogger())
+}
+
+func newTestAAServer(t *testing.T, server *testAAServer) (*httptest.Server, *particle.Particle) {
+	aaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.pubkeyRequests.Add(1)
+		address := strings.TrimPrefix(r.URL.Path, "/v1/btc/pubkey/")
+		pubkey, ok := server.pubkeys[address]
+		if !ok {
+			fmt.Fprint(w, `{"code":"1001","message":"address not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"code":"0","data":{"pubkey":"%s"}}`, pubkey)
+	}))
+	t.Cleanup(aaServer.Close)
+	particleServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.particleRequests.Add(1)
+		if server.particleDown.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var req struct {
+			Params []particle.AAGetBTCAccountReqParams `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		batch := make([]string, 0, len(req.Params))
+		results := make([]map[string]string, 0, len(req.Params))
+		for _, v := range req.Params {
+			batch = append(batch, v.BtcPublicKey)
+			smartAccount, ok := server.smartAccounts[v.BtcPublicKey]
+			if !ok {
+				smartAccount = strings.Replace(v.BtcPublicKey, "pk-", "sa-", 1)
+			}
+			results = append(results, map[string]string{
+				"btcPublicKey":        v.BtcPublicKey,
+				"smartAccountAddress": smartAccount,
+			})
+		}
+		server.particleBatches = append(server.particleBatches, batch)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "result": results})
+	}))
+	t.Cleanup(particleServer.Close)
+
+	p, err := particle.NewParticle(particleServer.URL, "1111", "", 1102)
+	require.NoError(t, err)
+	return aaServer, p
+}
+
+func TestAAResolver(t *testing.T) {
+	server := &testAAServer{
+		pubkeys: map[string]string{
+			"tb1-a": "pk-a",
+			"tb1-b": "pk-b",
+		},
+	}
+	resolver := newTestAAResolver(t, server)
+
+	resolved := resolver.Resolve([]string{"tb1-a", "tb1-b", "tb1-c", "tb1-a"})
+	require.Len(t, resolved, 3)
+	require.Equal(t, bitcoin.ResolvedAddress{BtcAddress: "tb1-a", Pubkey: "pk-a", SmartAccount: "sa-a"}, resolved["tb1-a"])
+	require.Equal(t, "sa-b", resolved["tb1-b"].SmartAccount)
+	require.NoError(t, resolved["tb1-b"].Err)
+	require.ErrorIs(t, resolved["tb1-c"].Err, bitcoin.ErrAAAddressNotFound)
+	// one pubkey request per address, smart accounts in one particle request
+	require.Equal(t, int64(3), server.pubkeyRequests.Load())
+	require.Equal(t, int64(1), server.particleRequests.Load())
+	require.Equal(t, [][]string{{"pk-a", "pk-b"}}, server.particleBatches)
+
+	// no particle request if all addresses not found
+	resolved = resolver.Resolve([]string{"tb1-c"})
+	require.ErrorIs(t, resolved["tb1-c"].Err, bitcoin.ErrAAAddressNotFound)
+	require.Equal(t, int64(1), server.particleRequests.Load())
+
+	smartAccount, err := bitcoin.ResolveAddress(resolver, "tb1-b")
+	require.NoError(t, err)
+	require.Equal(t, "sa-b", smartAccount)
+	_, err = bitcoin.ResolveAddress(resolver, "tb1-c")
+	require.ErrorIs(t, err, bitcoin.ErrAAAddressNotFound)
+}
+
+const (
+	// testPubKeyA testPubKeyB public keys of the private key 1 and 2
+	testPubKeyA = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	testPubKeyB = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+)
+
+func newTestSmartAccount(t *testing.T) *aa.SmartAccount {
+	account, err := aa.NewSmartAccount(
+		"0x0000000000000000000000000000000000000022",
+		"0x0000000000000000000000000000000000000011",
+		"0x6080",
+		0)
+	require.NoError(t, err)
+	return account
+}
+
+func TestLocalAAResolver(t *testing.T) {
+	server := &testAAServer{
+		pubkeys: map[string]string{
+			"tb1-a": testPubKeyA,
+			"tb1-x": "pk-x",
+		},
+	}
+	aaServer, _ := newTestAAServer(t, server)
+	account := newTestSmartAccount(t)
+	resolver := bitcoin.NewLocalAAResolver(aaServer.URL, account, log.NewNopLogger())
+
+	expected, err := account.Address(testPubKeyA)
+	require.NoError(t, err)
+	resolved := resolver.Resolve([]string{"tb1-a", "tb1-c", "tb1-x"})
+	require.Len(t, resolved, 3)
+	require.Equal(t, bitcoin.ResolvedAddress{BtcAddress: "tb1-a", Pubkey: testPubKeyA, SmartAccount: expected.Hex()}, resolved["tb1-a"])
+	require.ErrorIs(t, resolved["tb1-c"].Err, bitcoin.ErrAAAddressNotFound)
+	require.ErrorIs(t, resolved["tb1-x"].Err, aa.ErrInvalidPubKey)
+	// no particle request
+	require.Equal(t, int64(0), server.particleRequests.Load())
+}
+
+func TestCrossCheckResolver(t *testing.T) {
+	account := newTestSmartAccount(t)
+	localA, err := account.Address(testPubKeyA)
+	require.NoError(t, err)
+	localB, err := account.Address(testPubKeyB)
+	require.NoError(t, err)
+	server := &testAAServer{
+		pubkeys: map[string]string{
+			"tb1-a": testPubKeyA,
+			"tb1-b": testPubKeyB,
+		},
+		smartAccounts: map[string]string{
+			// particle returns lower case addresses
+			testPubKeyA: strings.ToLower(localA.Hex()),
+			testPubKeyB: "0x00000000000000000000000000000000000000bb",
+		},
+	}
+	resolver := bitcoin.NewCrossCheckResolver(newTestAAResolver(t, server), account, log.NewNopLogger())
+
+	resolved := resolver.Resolve([]string{"tb1-a", "tb1-b", "tb1-c"})
+	require.NoError(t, resolved["tb1-a"].Err)
+	require.Equal(t, strings.ToLower(localA.Hex()), resolved["tb1-a"].SmartAccount)
+	require.ErrorIs(t, resolved["tb1-b"].Err, bitcoin.ErrAAAddressMismatch)
+	require.ErrorIs(t, resolved["tb1-c"].Err, bitcoin.ErrAAAddressNotFound)
+
+	// the local address is not used if particle is down
+	server.smartAccounts[testPubKeyB] = localB.Hex()
+	server.particleDown.Store(true)
+	db := newTestDB(t)
+	require.NoError(t, db.AutoMigrate(&model.AAAddress{}))
+	cached := bitcoin.NewCachedAddressResolver(resolver, db, 0, log.NewNopLogger())
+	resolved = cached.Resolve([]string{"tb1-b", "tb1-c"})
+	require.Error(t, resolved["tb1-b"].Err)
+	require.Empty(t, resolved["tb1-b"].SmartAccount)
+	require.ErrorIs(t, resolved["tb1-c"].Err, bitcoin.ErrAAAddressNotFound)
+	// the failed address is not cached
+	var count int64
+	require.NoError(t, db.Model(&model.AAAddress{}).
+		Where(fmt.Sprintf("%s = ?", model.AAAddress{}.Column().BtcAddress), "tb1-b").Count(&count).Error)
+	require.Equal(t, int64(0), count)
+
+	server.particleDown.Store(false)
+	resolved = cached.Resolve([]string{"tb1-b"})
+	require.NoError(t, resolved["tb1-b"].Err)
+	require.Equal(t, localB.Hex(), resolved["tb1-b"].SmartAccount)
+}
+
+func TestCheckSmartAccount(t *testing.T) {
+	account := newTestSmartAccount(t)
+	localA, err := account.Address(testPubKeyA)
+	require.NoError(t, err)
+	server := &testAAServer{
+		smartAccounts: map[string]string{
+			testPubKeyA: strings.ToLower(localA.Hex()),
+			testPubKeyB: "0x00000000000000000000000000000000000000bb",
+		},
+	}
+	_, p := newTestAAServer(t, server)
+
+	require.NoError(t, bitcoin.CheckSmartAccount(p, account, testPubKeyA))
+	require.ErrorIs(t, bitcoin.CheckSmartAccount(p, account, testPubKeyB), bitcoin.ErrAAAddressMismatch)
+	require.ErrorIs(t, bitcoin.CheckSmartAccount(p, account, "pk-x"), aa.ErrInvalidPubKey)
+	require.Error(t, bitcoin.CheckSmartAccount(p, account, ""))
+
+	server.particleDown.Store(true)
+	require.Error(t, bitcoin.CheckSmartAccount(p, account, testPubKeyA))
+}
+
+func TestCachedAddress(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name     string
+		cached   model.AAAddress
+		ok       bool
+		notFound bool
+	}{
+		{
+			name:   "success: found",
+			cached: model.AAAddress{BtcAddress: "tb1-a", Pubkey: "pk-a", SmartAccount: "sa-a", Status: model.AAAddressStatusFound},
+			ok:     true,
+		},
+		{
+			name:     "success: not found in ttl",
+			cached:   model.AAAddress{BtcAddress: "tb1-c", Status: model.AAAddressStatusNotFound, ExpiredAt: now.Add(time.Minute)},
+			ok:       true,
+			notFound: true,
+		},
+		{
+			name:   "fail: not found expired",
+			cached: model.AAAddress{BtcAddress: "tb1-c", Status: model.AAAddressStatusNotFound, ExpiredAt: now.Add(-time.Minute)},
+		},
+		{
+			name:   "fail: found without smart account",
+			cached: model.AAAddress{BtcAddress: "tb1-a", Pubkey: "pk-a", Status: model.AAAddressStatusFound},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolved, ok := bitcoin.CachedAddress(tc.cached, now)
+			require.Equal(t, tc.ok, ok)
+			if !ok {
+				return
+			}
+			require.Equal(t, tc.cached.BtcAddress, resolved.BtcAddress)
+			if tc.notFound {
+				require.ErrorIs(t, resolved.Err, bitcoin.ErrAAAddressNotFound)
+				return
+			}
+			require.NoError(t, resolved.Err)
+			require.Equal(t, tc.cached.SmartAccount, resolved.SmartAccount)
+			require.Equal(t, tc.cached.Pubkey, resolved.Pubkey)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/types"
+)
+
+// FromAttributionRejected attribution decision, inputs are owned by multiple distinct addresses
+const FromAttributionRejected = "rejected"
+
+var ErrFromAttribution = errors.New("from attribution err")
+
+// SetFromAttribution set how the l2 user is attributed from tx inputs, default first
+func (p *txParser) SetFromAttribution(policy string) error {
+	switch policy {
+	case "":
+		p.fromAttribution = config.FromAttributionFirst
+	case config.FromAttributionFirst, config.FromAttributionLargest, config.FromAttributionRejectMultiple:
+		p.fromAttribution = policy
+	default:
+		return fmt.Errorf("%w:unsupported policy %s", ErrFromAttribution, policy)
+	}
+	return nil
+}
+
+// AttributeFrom attribute the l2 user address from tx inputs by policy,
+// returns the address and the decision, the decision is the policy or rejected
+func AttributeFrom(policy string, froms []types.BitcoinFrom) (string, string, error) {
+	if len(froms) == 0 {
+		return "", "", fmt.Errorf("%w:from empty", ErrFromAttribution)
+	}
+	// total spent value per distinct address, in input order
+	addresses := make([]string, 0, len(froms))
+	values := make(map[string]int64, len(froms))
+	for _, v := range froms {
+		if _, ok := values[v.Address]; !ok {
+			addresses = append(addresses, v.Address)
+		}
+		values[v.Address] += v.Value
+	}
+
+	switch policy {
+	case "", config.FromAttributionFirst:
+		return froms[0].Address, config.FromAttributionFirst, nil
+	case config.FromAttributionLargest:
+		// ties are attributed to the first input address
+		largest := addresses[0]
+		for _, v := range addresses[1:] {
+			if values[v] > values[largest] {
+				largest = v
+			}
+		}
+		return largest, config.FromAttributionLargest, nil
+	case config.FromAttributionRejectMultiple:
+		if len(addresses) > 1 {
+			return froms[0].Address, FromAttributionRejected, nil
+		}
+		return froms[0].Address, config.FromAttributionRejectMultiple, nil
+	default:
+		return "", "", fmt.Errorf("%w:unsupported policy %s", ErrFromAttribution, policy)
+	}
+}
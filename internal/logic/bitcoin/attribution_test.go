@@ -0,0 +1,108 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeFrom(t *testing.T) {
+	single := []types.BitcoinFrom{
+		{Address: "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", Value: 1000, TxID: "aa", Vout: 0},
+		{Address: "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", Value: 2000, TxID: "aa", Vout: 1},
+	}
+	// coinjoin like, the second owner contributes most in total
+	multiple := []types.BitcoinFrom{
+		{Address: "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", Value: 3000, TxID: "aa", Vout: 0},
+		{Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz", Value: 2000, TxID: "bb", Vout: 0},
+		{Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz", Value: 2000, TxID: "bb", Vout: 1},
+	}
+	tie := []types.BitcoinFrom{
+		{Address: "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", Value: 2000, TxID: "aa", Vout: 0},
+		{Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz", Value: 2000, TxID: "bb", Vout: 0},
+	}
+
+	testCases := []struct {
+		name     string
+		policy   string
+		froms    []types.BitcoinFrom
+		address  string
+		decision string
+		err      error
+	}{
+		{
+			name:     "success: default first",
+			policy:   "",
+			froms:    multiple,
+			address:  "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+			decision: config.FromAttributionFirst,
+		},
+		{
+			name:     "success: largest",
+			policy:   config.FromAttributionLargest,
+			froms:    multiple,
+			address:  "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			decision: config.FromAttributionLargest,
+		},
+		{
+			name:     "success: largest tie",
+			policy:   config.FromAttributionLargest,
+			froms:    tie,
+			address:  "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+			decision: config.FromAttributionLargest,
+		},
+		{
+			name:     "success: reject multiple single owner",
+			policy:   config.FromAttributionRejectMultiple,
+			froms:    single,
+			address:  "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+			decision: config.FromAttributionRejectMultiple,
+		},
+		{
+			name:     "success: reject multiple owners",
+			policy:   config.FromAttributionRejectMultiple,
+			froms:    multiple,
+			address:  "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+			decision: bitcoin.FromAttributionRejected,
+		},
+		{
+			name:   "fail: from empty",
+			policy: config.FromAttributionFirst,
+			err:    bitcoin.ErrFromAttribution,
+		},
+		{
+			name:   "fail: unsupported policy",
+			policy: "random",
+			froms:  single,
+			err:    bitcoin.ErrFromAttribution,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			address, decision, err := bitcoin.AttributeFrom(tc.policy, tc.froms)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.address, address)
+			require.Equal(t, tc.decision, decision)
+		})
+	}
+}
+
+func TestSetFromAttribution(t *testing.T) {
+	indexer := mockEsploraIndexer(t, 1)
+	require.NoError(t, indexer.SetFromAttribution(config.FromAttributionRejectMultiple))
+	require.True(t, errors.Is(indexer.SetFromAttribution("random"), bitcoin.ErrFromAttribution))
+
+	results, _, err := indexer.ParseBlock(esploraBlockHeight, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, config.FromAttributionRejectMultiple, results[0].FromAttribution)
+}
@@ -3,7 +3,6 @@ package bitcoin
 import (
 	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -13,18 +12,18 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/b2network/b2-indexer/internal/config"
 	b2types "github.com/b2network/b2-indexer/internal/types"
 	"github.com/b2network/b2-indexer/pkg/aa"
+	"github.com/b2network/b2-indexer/pkg/amount"
 	b2crypto "github.com/b2network/b2-indexer/pkg/crypto"
 	"github.com/b2network/b2-indexer/pkg/log"
 	"github.com/b2network/b2-indexer/pkg/particle"
 	"github.com/b2network/b2-indexer/pkg/vsm"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -47,20 +46,34 @@ var (
 // Bridge bridge
 // TODO: only L1 -> L2, More calls may be supported later
 type Bridge struct {
-	EthRPCURL            string
-	EthPrivKey           *ecdsa.PrivateKey
-	ContractAddress      common.Address
-	ABI                  string
+	EthRPCURL string
+	// Signer active signer of new txs, the in memory key or a remote signer
+	Signer Signer
+	// signers all signers, retired signers replace their in-flight txs
+	signers         *SignerSet
+	signerMu        sync.RWMutex
+	ContractAddress common.Address
+	ABI             string
+	// contractAbi parsed ABI, decodes contract reverts
+	contractAbi          abi.ABI
 	BaseGasPriceMultiple int64
 	B2ExplorerURL        string
 	logger               log.Logger
-	// particle aa
-	particle     *particle.Particle
+	// resolver resolves aa addresses of bitcoin addresses
+	resolver     AddressResolver
 	bitcoinParam *chaincfg.Params
 	// eoa transfer switch
 	enableEoaTransfer bool
 	// aa server
 	AAPubKeyAPI string
+	// enableLegacyTx send legacy txs instead of eip-1559 dynamic fee txs
+	enableLegacyTx bool
+	// priceBumpPercent min fee bump percent of replacement txs
+	priceBumpPercent int64
+	// client long-lived eth client, requests fail over between the rpc urls
+	client *ethclient.Client
+	// asset decimals of the bridged asset
+	asset amount.Asset
 }
 type B2ExplorerStatus struct {
 	GasPrices struct {
@@ -88,6 +101,10 @@ func NewBridge(bridgeCfg config.BridgeConfig, abiFileDir string, log log.Logger,
 	} else {
 		ABI = string(abiFile)
 	}
+	contractAbi, err := abi.JSON(bytes.NewReader([]byte(ABI)))
+	if err != nil {
+		return nil, fmt.Errorf("parse abi err:%w", err)
+	}
 
 	newParticle, err := particle.NewParticle(
 		bridgeCfg.AAParticleRPC,
@@ -97,7 +114,102 @@ func NewBridge(bridgeCfg config.BridgeConfig, abiFileDir string, log log.Logger,
 	if err != nil {
 		return nil, err
 	}
-	ethPrivKey := bridgeCfg.EthPrivKey
+	resolver, err := newAddressResolver(bridgeCfg, newParticle, log)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewEthClient(bridgeCfg, log)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := amount.NewAsset(bridgeCfg.AssetDecimals)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := newSignerSet(bridgeCfg, log)
+	if err != nil {
+		return nil, err
+	}
+	signer := signers.Active(time.Now())
+	log.Infof("active eth address: %s", signer.Address())
+	return &Bridge{
+		EthRPCURL:            rpcURL.String(),
+		ContractAddress:      common.HexToAddress(bridgeCfg.ContractAddress),
+		Signer:               signer,
+		signers:              signers,
+		client:               client,
+		asset:                asset,
+		ABI:                  ABI,
+		contractAbi:          contractAbi,
+		logger:               log,
+		resolver:             resolver,
+		bitcoinParam:         bitcoinParam,
+		enableEoaTransfer:    bridgeCfg.EnableEoaTransfer,
+		AAPubKeyAPI:          bridgeCfg.AAB2PI,
+		BaseGasPriceMultiple: bridgeCfg.GasPriceMultiple,
+		B2ExplorerURL:        bridgeCfg.B2ExplorerURL,
+		enableLegacyTx:       bridgeCfg.EnableLegacyTx,
+		priceBumpPercent:     bridgeCfg.PriceBumpPercent,
+	}, nil
+}
+
+// newAddressResolver returns the aa resolver of the resolver mode
+func newAddressResolver(bridgeCfg config.BridgeConfig, particle *particle.Particle, log log.Logger) (AddressResolver, error) {
+	particleResolver := NewAAResolver(bridgeCfg.AAB2PI, particle, log)
+	switch bridgeCfg.AAResolver {
+	case "", config.AAResolverParticle:
+		return particleResolver, nil
+	case config.AAResolverLocal, config.AAResolverCrossCheck:
+	default:
+		return nil, fmt.Errorf("invalid aa resolver %s, particle, local or cross-check", bridgeCfg.AAResolver)
+	}
+	account, err := aa.NewSmartAccount(
+		bridgeCfg.AAFactoryAddress,
+		bridgeCfg.AAAccountImplementation,
+		bridgeCfg.AAProxyCreationCode,
+		bridgeCfg.AAAccountIndex)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("aa resolver: %s, factory: %s, index: %d", bridgeCfg.AAResolver, bridgeCfg.AAFactoryAddress, bridgeCfg.AAAccountIndex)
+	if bridgeCfg.AAResolver == config.AAResolverLocal {
+		// the derivation is verified against particle once, particle is not called afterward
+		if err := CheckSmartAccount(particle, account, bridgeCfg.AACheckPubKey); err != nil {
+			return nil, fmt.Errorf("aa local resolver self check: %w", err)
+		}
+		log.Infof("aa local resolver self check passed, pubkey: %s", bridgeCfg.AACheckPubKey)
+		return NewLocalAAResolver(bridgeCfg.AAB2PI, account, log), nil
+	}
+	return NewCrossCheckResolver(particleResolver, account, log), nil
+}
+
+// newSignerSet load the remote signers or the private keys
+func newSignerSet(bridgeCfg config.BridgeConfig, log log.Logger) (*SignerSet, error) {
+	signers := make([]Signer, 0, 1+len(bridgeCfg.RemoteSignerAddresses)+len(bridgeCfg.EthPrivKeys))
+	if bridgeCfg.RemoteSignerURL != "" {
+		for _, address := range append([]string{bridgeCfg.RemoteSignerAddress}, bridgeCfg.RemoteSignerAddresses...) {
+			signer, err := NewRemoteSigner(bridgeCfg.RemoteSignerURL, address)
+			if err != nil {
+				return nil, err
+			}
+			log.Infof("load remote signer: %s, eth address: %s", bridgeCfg.RemoteSignerURL, signer.Address())
+			signers = append(signers, signer)
+		}
+	} else {
+		for _, ethPrivKey := range append([]string{bridgeCfg.EthPrivKey}, bridgeCfg.EthPrivKeys...) {
+			signer, err := newLocalSigner(bridgeCfg, ethPrivKey)
+			if err != nil {
+				return nil, err
+			}
+			log.Infof("load eth address: %s", signer.Address())
+			signers = append(signers, signer)
+		}
+	}
+	return NewSignerSet(signers, bridgeCfg.ActiveSigner, time.Duration(bridgeCfg.SignerRotationInterval)*time.Second)
+}
+
+// newLocalSigner load the private key, decrypted by vsm and the local key if vsm enabled
+func newLocalSigner(bridgeCfg config.BridgeConfig, ethPrivKey string) (*LocalSigner, error) {
 	if bridgeCfg.EnableVSM {
 		tassInputData, err := hex.DecodeString(ethPrivKey)
 		if err != nil {
@@ -137,26 +249,14 @@ func NewBridge(bridgeCfg config.BridgeConfig, abiFileDir string, log log.Logger,
 	if err != nil {
 		return nil, err
 	}
-	log.Infof("load eth address: %s", crypto.PubkeyToAddress(privateKey.PublicKey))
-	return &Bridge{
-		EthRPCURL:            rpcURL.String(),
-		ContractAddress:      common.HexToAddress(bridgeCfg.ContractAddress),
-		EthPrivKey:           privateKey,
-		ABI:                  ABI,
-		logger:               log,
-		particle:             newParticle,
-		bitcoinParam:         bitcoinParam,
-		enableEoaTransfer:    bridgeCfg.EnableEoaTransfer,
-		AAPubKeyAPI:          bridgeCfg.AAB2PI,
-		BaseGasPriceMultiple: bridgeCfg.GasPriceMultiple,
-		B2ExplorerURL:        bridgeCfg.B2ExplorerURL,
-	}, nil
+	return NewLocalSigner(privateKey), nil
 }
 
-// Deposit to ethereum
+// Deposit to ethereum, hash is the contract deposit uuid, see DepositUUID
 func (b *Bridge) Deposit(
 	hash string,
 	bitcoinAddress b2types.BitcoinFrom,
+	evmAddress string,
 	amount int64,
 	oldTx *types.Transaction,
 	nonce uint64,
@@ -172,9 +272,9 @@ func (b *Bridge) Deposit(
 
 	ctx := context.Background()
 
-	toAddress, err := b.BitcoinAddressToEthAddress(bitcoinAddress)
+	toAddress, err := b.toEthAddress(bitcoinAddress, evmAddress)
 	if err != nil {
-		return nil, nil, "", "", fmt.Errorf("btc address to eth address err:%w", err)
+		return nil, nil, "", "", err
 	}
 
 	data, err := b.ABIPack(b.ABI, "deposit", common.HexToHash(hash), common.HexToAddress(toAddress), new(big.Int).SetInt64(amount))
@@ -183,14 +283,15 @@ func (b *Bridge) Deposit(
 	}
 
 	if oldTx != nil {
-		tx, err := b.retrySendTransaction(ctx, oldTx, b.EthPrivKey, resetNonce)
+		signer := b.txSigner(oldTx)
+		tx, err := b.retrySendTransaction(ctx, oldTx, signer, resetNonce)
 		if err != nil {
 			return nil, nil, toAddress, "", err
 		}
-		return tx, oldTx.Data(), toAddress, b.FromAddress(), nil
+		return tx, oldTx.Data(), toAddress, signer.Address().String(), nil
 	}
 
-	tx, err := b.sendTransaction(ctx, b.EthPrivKey, b.ContractAddress, data, new(big.Int).SetInt64(0), nonce, resetNonce)
+	tx, err := b.sendTransaction(ctx, b.activeSigner(), b.ContractAddress, data, new(big.Int).SetInt64(0), nonce, resetNonce)
 	if err != nil {
 		return nil, nil, toAddress, "", err
 	}
@@ -201,7 +302,8 @@ func (b *Bridge) Deposit(
 // Transfer to ethereum
 // TODO: temp handle, future remove
 func (b *Bridge) Transfer(bitcoinAddress b2types.BitcoinFrom,
-	amount int64,
+	evmAddress string,
+	btcValue int64,
 	oldTx *types.Transaction,
 	nonce uint64,
 	resetNonce bool,
@@ -212,29 +314,34 @@ func (b *Bridge) Transfer(bitcoinAddress b2types.BitcoinFrom,
 
 	ctx := context.Background()
 
-	toAddress, err := b.BitcoinAddressToEthAddress(bitcoinAddress)
+	toAddress, err := b.toEthAddress(bitcoinAddress, evmAddress)
 	if err != nil {
-		return nil, "", fmt.Errorf("btc address to eth address err:%w", err)
+		return nil, "", err
 	}
 
 	if oldTx != nil {
+		signer := b.txSigner(oldTx)
 		receipt, err := b.retrySendTransaction(ctx,
 			oldTx,
-			b.EthPrivKey,
+			signer,
 			resetNonce,
 		)
 		if err != nil {
 			return nil, "", err
 		}
 
-		return receipt, b.FromAddress(), nil
+		return receipt, signer.Address().String(), nil
 	}
 
+	value, err := b.asset.ToWei(amount.Sats(btcValue))
+	if err != nil {
+		return nil, "", err
+	}
 	receipt, err := b.sendTransaction(ctx,
-		b.EthPrivKey,
+		b.activeSigner(),
 		common.HexToAddress(toAddress),
 		nil,
-		new(big.Int).Mul(new(big.Int).SetInt64(amount), new(big.Int).SetInt64(10000000000)),
+		value,
 		nonce,
 		resetNonce,
 	)
@@ -245,71 +352,31 @@ func (b *Bridge) Transfer(bitcoinAddress b2types.BitcoinFrom,
 	return receipt, b.FromAddress(), nil
 }
 
-func (b *Bridge) sendTransaction(ctx context.Context, fromPriv *ecdsa.PrivateKey,
+func (b *Bridge) sendTransaction(ctx context.Context, signer Signer,
 	toAddress common.Address, data []byte, value *big.Int, oldNonce uint64, resetNonce bool,
 ) (*types.Transaction, error) {
 	txLock.Lock()
 	defer txLock.Unlock()
-	client, err := ethclient.Dial(b.EthRPCURL)
-	if err != nil {
-		return nil, err
-	}
-	fromAddress := crypto.PubkeyToAddress(fromPriv.PublicKey)
-	nonce, err := client.PendingNonceAt(ctx, fromAddress)
-	if err != nil {
-		return nil, err
-	}
-	if oldNonce != 0 && !resetNonce {
-		// check oldNonce to height
-		// If db sets a nonce that is too high, the pending nonce will be too large
-		// There is virtually no transaction in between
-		// Manual handling may be required at this time
-		var latestTxCount hexutil.Uint64
-		err := client.Client().CallContext(ctx, &latestTxCount, "eth_getTransactionCount", fromAddress, "latest")
+	client := b.client
+	fromAddress := signer.Address()
+	// the nonce is allocated by the nonce manager, reset nonce use the pending nonce
+	nonce := oldNonce
+	var err error
+	if resetNonce {
+		nonce, err = client.PendingNonceAt(ctx, fromAddress)
 		if err != nil {
 			return nil, err
 		}
-		if oldNonce > uint64(latestTxCount) {
-			return nil, ErrOldNonceToHeight
-		}
-		nonce = oldNonce
 	}
-	gasPrice, err := client.SuggestGasPrice(ctx)
+	fee, err := b.suggestFee(ctx, client)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: temp fix
-	// first use b2 explorer stats gas price
-	// if fail, use base gas price
-	newGasPrice, err := b.gasPrices()
-	if err != nil {
-		b.logger.Errorf("get price err:%v", err.Error())
-		if b.BaseGasPriceMultiple != 0 {
-			gasPrice.Mul(gasPrice, big.NewInt(b.BaseGasPriceMultiple))
-		}
-	} else {
-		if newGasPrice.Cmp(big.NewInt(0)) == 0 {
-			if b.BaseGasPriceMultiple != 0 {
-				gasPrice.Mul(gasPrice, big.NewInt(b.BaseGasPriceMultiple))
-			}
-		} else {
-			gasPrice = newGasPrice
-		}
-	}
-
-	actualGasPrice := new(big.Int).Set(gasPrice)
-	b.logger.Infof("gas price:%v", new(big.Float).Quo(new(big.Float).SetInt(actualGasPrice), big.NewFloat(1e9)).String())
-	b.logger.Infof("gas price:%v", actualGasPrice.String())
+	b.logFee(fee)
 	b.logger.Infof("nonce:%v", nonce)
 	b.logger.Infof("from address:%v", fromAddress)
-	callMsg := ethereum.CallMsg{
-		From:     fromAddress,
-		To:       &toAddress,
-		Value:    value,
-		GasPrice: actualGasPrice,
-	}
+	callMsg := fee.callMsg(fromAddress, &toAddress, value, data)
 	if data != nil {
-		callMsg.Data = data
 		b.logger.Infof("data:%v", hexutil.Encode(data))
 	}
 
@@ -319,42 +386,18 @@ func (b *Bridge) sendTransaction(ctx context.Context, fromPriv *ecdsa.PrivateKey
 		// Other errors may occur that need to be handled
 		// The estimated gas cannot block the sending of a transaction
 		b.logger.Errorw("estimate gas err", "error", err.Error())
-		if strings.Contains(err.Error(), ErrBridgeDepositTxHashExist.Error()) {
-			return nil, ErrBridgeDepositTxHashExist
-		}
-
-		if strings.Contains(err.Error(), ErrBridgeDepositContractInsufficientBalance.Error()) {
-			return nil, ErrBridgeDepositContractInsufficientBalance
-		}
-
-		if strings.Contains(err.Error(), ErrBridgeFromGasInsufficient.Error()) {
-			return nil, ErrBridgeFromGasInsufficient
-		}
-
-		// estimate gas err, return, try again
-		return nil, err
+		// contract reverts are decoded by the abi, others return and try again
+		return nil, b.callError(err)
 	}
 	gas *= 2
-	legacyTx := types.LegacyTx{
-		Nonce:    nonce,
-		To:       &toAddress,
-		Value:    value,
-		Gas:      gas,
-		GasPrice: actualGasPrice,
-	}
-
-	if data != nil {
-		legacyTx.Data = data
-	}
-
-	tx := types.NewTx(&legacyTx)
 
 	chainID, err := client.ChainID(ctx)
 	if err != nil {
 		return nil, err
 	}
+	tx := types.NewTx(fee.txData(chainID, nonce, &toAddress, value, gas, data))
 	// sign tx
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), fromPriv)
+	signedTx, err := signer.SignTx(ctx, tx, chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -362,7 +405,7 @@ func (b *Bridge) sendTransaction(ctx context.Context, fromPriv *ecdsa.PrivateKey
 	// send tx
 	err = client.SendTransaction(ctx, signedTx)
 	if err != nil {
-		return nil, err
+		return nil, NodeError(err)
 	}
 
 	return signedTx, nil
@@ -371,19 +414,16 @@ func (b *Bridge) sendTransaction(ctx context.Context, fromPriv *ecdsa.PrivateKey
 func (b *Bridge) retrySendTransaction(
 	ctx context.Context,
 	oldTx *types.Transaction,
-	fromPriv *ecdsa.PrivateKey,
+	signer Signer,
 	resetNonce bool,
 ) (*types.Transaction, error) {
 	txLock.Lock()
 	defer txLock.Unlock()
-	client, err := ethclient.Dial(b.EthRPCURL)
-	if err != nil {
-		return nil, err
-	}
-	fromAddress := crypto.PubkeyToAddress(fromPriv.PublicKey)
+	client := b.client
+	fromAddress := signer.Address()
 	nonce := oldTx.Nonce()
 	var latestTxCount hexutil.Uint64
-	err = client.Client().CallContext(ctx, &latestTxCount, "eth_getTransactionCount", fromAddress, "latest")
+	err := client.Client().CallContext(ctx, &latestTxCount, "eth_getTransactionCount", fromAddress, "latest")
 	if err != nil {
 		return nil, err
 	}
@@ -394,24 +434,17 @@ func (b *Bridge) retrySendTransaction(
 		return nil, ErrOldNonceToHeight
 	}
 
-	gasPrice := oldTx.GasPrice()
-	// set new gas price: newGasPrice = oldGasPrice * 2
-	gasPrice.Mul(gasPrice, big.NewInt(2))
-
-	log.Infof("new gas price:%v", new(big.Float).Quo(new(big.Float).SetInt(gasPrice), big.NewFloat(1e9)).String())
-	log.Infof("new gas price:%v", gasPrice.String())
+	suggestedFee, err := b.suggestFee(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	// replacement tx must raise the fee by the node price bump
+	fee := BumpFee(oldTx, suggestedFee, b.priceBumpPercent)
+	b.logFee(fee)
 	log.Infof("nonce:%v", nonce)
 	log.Infof("from address:%v", fromAddress)
 
-	callMsg := ethereum.CallMsg{
-		From:     fromAddress,
-		To:       oldTx.To(),
-		Value:    oldTx.Value(),
-		GasPrice: gasPrice,
-	}
-	if oldTx.Data() != nil {
-		callMsg.Data = oldTx.Data()
-	}
+	callMsg := fee.callMsg(fromAddress, oldTx.To(), oldTx.Value(), oldTx.Data())
 
 	// use eth_estimateGas only check deposit err
 	gas, err := client.EstimateGas(ctx, callMsg)
@@ -419,42 +452,18 @@ func (b *Bridge) retrySendTransaction(
 		// Other errors may occur that need to be handled
 		// The estimated gas cannot block the sending of a transaction
 		b.logger.Errorw("estimate gas err", "error", err.Error())
-		if strings.Contains(err.Error(), ErrBridgeDepositTxHashExist.Error()) {
-			return nil, ErrBridgeDepositTxHashExist
-		}
-
-		if strings.Contains(err.Error(), ErrBridgeDepositContractInsufficientBalance.Error()) {
-			return nil, ErrBridgeDepositContractInsufficientBalance
-		}
-
-		if strings.Contains(err.Error(), ErrBridgeFromGasInsufficient.Error()) {
-			return nil, ErrBridgeFromGasInsufficient
-		}
-
-		// estimate gas err, return, try again
-		return nil, err
+		// contract reverts are decoded by the abi, others return and try again
+		return nil, b.callError(err)
 	}
 	gas *= 2
-	newlegacyTx := types.LegacyTx{
-		Nonce:    nonce,
-		To:       oldTx.To(),
-		Value:    oldTx.Value(),
-		Gas:      gas,
-		GasPrice: gasPrice,
-	}
-
-	if oldTx.Data() != nil {
-		newlegacyTx.Data = oldTx.Data()
-	}
-
-	tx := types.NewTx(&newlegacyTx)
 
 	chainID, err := client.ChainID(ctx)
 	if err != nil {
 		return nil, err
 	}
+	tx := types.NewTx(fee.txData(chainID, nonce, oldTx.To(), oldTx.Value(), gas, oldTx.Data()))
 	// sign tx
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), fromPriv)
+	signedTx, err := signer.SignTx(ctx, tx, chainID)
 	if err != nil {
 		return nil, err
 	}
@@ -462,12 +471,22 @@ func (b *Bridge) retrySendTransaction(
 	// send tx
 	err = client.SendTransaction(ctx, signedTx)
 	if err != nil {
-		return nil, err
+		return nil, NodeError(err)
 	}
 
 	return signedTx, nil
 }
 
+// callError decode the contract revert of the call err
+func (b *Bridge) callError(err error) error {
+	callErr := CallError(b.contractAbi, err)
+	var revertErr *RevertError
+	if errors.As(callErr, &revertErr) {
+		b.logger.Errorw("contract revert", "kind", revertErr.Kind, "error", revertErr.Error())
+	}
+	return callErr
+}
+
 // ABIPack the given method name to conform the ABI. Method call's data
 func (b *Bridge) ABIPack(abiData string, method string, args ...interface{}) ([]byte, error) {
 	contractAbi, err := abi.JSON(bytes.NewReader([]byte(abiData)))
@@ -477,40 +496,48 @@ func (b *Bridge) ABIPack(abiData string, method string, args ...interface{}) ([]
 	return contractAbi.Pack(method, args...)
 }
 
-// BitcoinAddressToEthAddress bitcoin address to eth address
-func (b *Bridge) BitcoinAddressToEthAddress(bitcoinAddress b2types.BitcoinFrom) (string, error) {
-	pubkeyResp, err := aa.GetPubKey(b.AAPubKeyAPI, bitcoinAddress.Address)
-	if err != nil {
-		b.logger.Errorw("get pub key:", "pubkey", pubkeyResp, "address", bitcoinAddress.Address)
-		return "", err
-	}
-	if pubkeyResp.Code != "0" {
-		if pubkeyResp.Code == aa.AddressNotFoundErrCode {
-			return "", ErrAAAddressNotFound
+// toEthAddress use the evm address if set, else resolve the aa address by bitcoin address
+func (b *Bridge) toEthAddress(bitcoinAddress b2types.BitcoinFrom, evmAddress string) (string, error) {
+	if evmAddress != "" {
+		if !common.IsHexAddress(evmAddress) {
+			return "", fmt.Errorf("invalid evm address:%s", evmAddress)
 		}
-		return "", fmt.Errorf("get pubkey code err:%v", pubkeyResp)
+		return common.HexToAddress(evmAddress).Hex(), nil
 	}
-
-	b.logger.Infow("get pub key:", "pubkey", pubkeyResp, "address", bitcoinAddress.Address)
-	aaBtcAccount, err := b.particle.AAGetBTCAccount([]string{pubkeyResp.Data.Pubkey})
+	toAddress, err := b.BitcoinAddressToEthAddress(bitcoinAddress)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("btc address to eth address err:%w", err)
 	}
+	return toAddress, nil
+}
 
-	if len(aaBtcAccount.Result) != 1 {
-		b.logger.Errorw("AAGetBTCAccount", "result", aaBtcAccount)
-		return "", fmt.Errorf("AAGetBTCAccount result not match")
+// BitcoinAddressToEthAddress bitcoin address to eth address
+func (b *Bridge) BitcoinAddressToEthAddress(bitcoinAddress b2types.BitcoinFrom) (string, error) {
+	return ResolveAddress(b.resolver, bitcoinAddress.Address)
+}
+
+// ResolveAddresses resolve aa addresses of the bitcoin addresses in one batch, returns the resolve errs
+func (b *Bridge) ResolveAddresses(btcAddresses []string) map[string]error {
+	errs := make(map[string]error, len(btcAddresses))
+	for address, resolved := range b.resolver.Resolve(btcAddresses) {
+		errs[address] = resolved.Err
 	}
-	b.logger.Infow("AAGetBTCAccount", "result", aaBtcAccount.Result[0])
-	return aaBtcAccount.Result[0].SmartAccountAddress, nil
+	return errs
+}
+
+// AddressResolver returns the aa address resolver
+func (b *Bridge) AddressResolver() AddressResolver {
+	return b.resolver
+}
+
+// SetAddressResolver set the aa address resolver, e.g. the db cached resolver
+func (b *Bridge) SetAddressResolver(resolver AddressResolver) {
+	b.resolver = resolver
 }
 
 // WaitMined wait tx mined
 func (b *Bridge) WaitMined(ctx context.Context, tx *types.Transaction, _ []byte) (*types.Receipt, error) {
-	client, err := ethclient.Dial(b.EthRPCURL)
-	if err != nil {
-		return nil, err
-	}
+	client := b.client
 
 	receipt, err := bind.WaitMined(ctx, client, tx)
 	if err != nil {
@@ -524,10 +551,7 @@ func (b *Bridge) WaitMined(ctx context.Context, tx *types.Transaction, _ []byte)
 }
 
 func (b *Bridge) TransactionReceipt(hash string) (*types.Receipt, error) {
-	client, err := ethclient.Dial(b.EthRPCURL)
-	if err != nil {
-		return nil, err
-	}
+	client := b.client
 
 	receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(hash))
 	if err != nil {
@@ -537,10 +561,7 @@ func (b *Bridge) TransactionReceipt(hash string) (*types.Receipt, error) {
 }
 
 func (b *Bridge) TransactionByHash(hash string) (*types.Transaction, bool, error) {
-	client, err := ethclient.Dial(b.EthRPCURL)
-	if err != nil {
-		return nil, false, err
-	}
+	client := b.client
 
 	tx, isPending, err := client.TransactionByHash(context.Background(), common.HexToHash(hash))
 	if err != nil {
@@ -549,10 +570,50 @@ func (b *Bridge) TransactionByHash(hash string) (*types.Transaction, bool, error
 	return tx, isPending, nil
 }
 
+// NonceAt returns the nonce of the address at the latest block, including pending txs if pending
+func (b *Bridge) NonceAt(address string, pending bool) (uint64, error) {
+	client := b.client
+	if pending {
+		return client.PendingNonceAt(context.Background(), common.HexToAddress(address))
+	}
+	return client.NonceAt(context.Background(), common.HexToAddress(address), nil)
+}
+
+// SelfTransfer send no-op zero value transfer from the signer address to itself, used to fill nonce gap
+func (b *Bridge) SelfTransfer(address string, nonce uint64) (*types.Transaction, error) {
+	signer, ok := b.signers.Signer(common.HexToAddress(address))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSignerNotFound, address)
+	}
+	return b.sendTransaction(context.Background(),
+		signer,
+		signer.Address(),
+		nil,
+		new(big.Int).SetInt64(0),
+		nonce,
+		false,
+	)
+}
+
+func (b *Bridge) logFee(fee TxFee) {
+	if fee.IsDynamic() {
+		b.logger.Infof("gas tip cap:%v", fee.GasTipCap.String())
+		b.logger.Infof("gas fee cap:%v", fee.GasFeeCap.String())
+		return
+	}
+	b.logger.Infof("gas price:%v", new(big.Float).Quo(new(big.Float).SetInt(fee.GasPrice), big.NewFloat(1e9)).String())
+	b.logger.Infof("gas price:%v", fee.GasPrice.String())
+}
+
 func (b *Bridge) EnableEoaTransfer() bool {
 	return b.enableEoaTransfer
 }
 
+// Asset returns the decimals of the bridged asset
+func (b *Bridge) Asset() amount.Asset {
+	return b.asset
+}
+
 func (b *Bridge) gasPrices() (*big.Int, error) {
 	client := resty.New()
 	resp, err := client.R().
@@ -578,9 +639,64 @@ func (b *Bridge) gasPrices() (*big.Int, error) {
 	return gasPriceInt, nil
 }
 
+// FromAddress returns the active signer address
 func (b *Bridge) FromAddress() string {
-	fromAddress := crypto.PubkeyToAddress(b.EthPrivKey.PublicKey)
-	return fromAddress.String()
+	return b.activeSigner().Address().String()
+}
+
+func (b *Bridge) activeSigner() Signer {
+	b.signerMu.RLock()
+	defer b.signerMu.RUnlock()
+	return b.Signer
+}
+
+// txSigner returns the signer of the tx, the active signer if the sender is not a bridge signer
+func (b *Bridge) txSigner(tx *types.Transaction) Signer {
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err == nil {
+		if signer, ok := b.signers.Signer(from); ok {
+			return signer
+		}
+	}
+	b.logger.Warnw("tx signer not found, use the active signer", "tx", tx.Hash().String(), "from", from.String())
+	return b.activeSigner()
+}
+
+// RotateSigner switch the active signer by the manual cutover or the rotation schedule,
+// returns the active signer address
+func (b *Bridge) RotateSigner() string {
+	active := b.signers.Active(time.Now())
+	b.signerMu.Lock()
+	defer b.signerMu.Unlock()
+	if active.Address() != b.Signer.Address() {
+		b.logger.Warnw("bridge signer rotated", "old", b.Signer.Address().String(), "new", active.Address().String())
+		b.Signer = active
+	}
+	return b.Signer.Address().String()
+}
+
+// HasSigner whether the address is a bridge signer, active or retired
+func (b *Bridge) HasSigner(address string) bool {
+	if !common.IsHexAddress(address) {
+		return false
+	}
+	_, ok := b.signers.Signer(common.HexToAddress(address))
+	return ok
+}
+
+// SignerAddresses returns addresses of all bridge signers
+func (b *Bridge) SignerAddresses() []string {
+	addresses := make([]string, 0, len(b.signers.Signers()))
+	for _, signer := range b.signers.Signers() {
+		addresses = append(addresses, signer.Address().String())
+	}
+	return addresses
+}
+
+// BalanceAt returns the gas balance of the address at the latest block
+func (b *Bridge) BalanceAt(address string) (*big.Int, error) {
+	client := b.client
+	return client.BalanceAt(context.Background(), common.HexToAddress(address), nil)
 }
 
 func has0xPrefix(input string) bool {
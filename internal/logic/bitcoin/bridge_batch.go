@@ -0,0 +1,139 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	b2types "github.com/b2network/b2-indexer/internal/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// BatchDepositMethod batchDeposit(bytes32[] deposit_uuids, address[] b2_to_addresses, uint256[] btc_amounts)
+	BatchDepositMethod = "batchDeposit"
+	// DepositEventName DepositEvent(address indexed caller, address indexed to_address, uint256 amount, bytes32 deposit_uuid)
+	DepositEventName = "DepositEvent"
+)
+
+var ErrBatchDepositEmpty = errors.New("batch deposit empty")
+
+// HasABIMethod whether the abi has the method
+func HasABIMethod(abiData string, method string) bool {
+	contractAbi, err := abi.JSON(bytes.NewReader([]byte(abiData)))
+	if err != nil {
+		return false
+	}
+	_, ok := contractAbi.Methods[method]
+	return ok
+}
+
+// DepositEventUUIDs returns the deposit uuids of the contract deposit event logs
+func DepositEventUUIDs(abiData string, contract common.Address, logs []*types.Log) (map[common.Hash]struct{}, error) {
+	contractAbi, err := abi.JSON(bytes.NewReader([]byte(abiData)))
+	if err != nil {
+		return nil, err
+	}
+	event, ok := contractAbi.Events[DepositEventName]
+	if !ok {
+		return nil, fmt.Errorf("abi event %s not found", DepositEventName)
+	}
+	uuids := make(map[common.Hash]struct{})
+	for _, vlog := range logs {
+		if vlog.Address != contract || len(vlog.Topics) == 0 || vlog.Topics[0] != event.ID {
+			continue
+		}
+		data := make(map[string]interface{})
+		err := event.Inputs.NonIndexed().UnpackIntoMap(data, vlog.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unpack deposit event err:%w", err)
+		}
+		uuid, ok := data["deposit_uuid"].([32]byte)
+		if !ok {
+			return nil, fmt.Errorf("deposit event uuid type err:%T", data["deposit_uuid"])
+		}
+		uuids[common.Hash(uuid)] = struct{}{}
+	}
+	return uuids, nil
+}
+
+// SupportBatchDeposit whether the contract abi has the batch deposit method
+func (b *Bridge) SupportBatchDeposit() bool {
+	return HasABIMethod(b.ABI, BatchDepositMethod)
+}
+
+// BatchDeposit deposit items in one tx, items failed to resolve the to address are skipped
+// and the err is set to the item
+func (b *Bridge) BatchDeposit(items []*b2types.BatchDepositItem, nonce uint64) (*types.Transaction, string, error) {
+	uuids := make([][32]byte, 0, len(items))
+	toAddresses := make([]common.Address, 0, len(items))
+	amounts := make([]*big.Int, 0, len(items))
+	// aa addresses of the batch are resolved in one call
+	btcAddresses := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.From.Address != "" && item.EvmAddress == "" {
+			btcAddresses = append(btcAddresses, item.From.Address)
+		}
+	}
+	resolved := make(map[string]ResolvedAddress)
+	if len(btcAddresses) > 0 {
+		resolved = b.resolver.Resolve(btcAddresses)
+	}
+	for _, item := range items {
+		if item.From.Address == "" {
+			item.Err = fmt.Errorf("bitcoin address is empty")
+			continue
+		}
+		var toAddress string
+		var err error
+		if account, ok := resolved[item.From.Address]; ok && item.EvmAddress == "" {
+			toAddress, err = account.SmartAccount, account.Err
+			if err != nil {
+				err = fmt.Errorf("btc address to eth address err:%w", err)
+			}
+		} else {
+			toAddress, err = b.toEthAddress(item.From, item.EvmAddress)
+		}
+		if err != nil {
+			item.Err = err
+			continue
+		}
+		item.ToAddress = toAddress
+		uuids = append(uuids, item.UUID)
+		toAddresses = append(toAddresses, common.HexToAddress(toAddress))
+		amounts = append(amounts, new(big.Int).SetInt64(item.Amount))
+	}
+	if len(uuids) == 0 {
+		return nil, "", ErrBatchDepositEmpty
+	}
+
+	data, err := b.ABIPack(b.ABI, BatchDepositMethod, uuids, toAddresses, amounts)
+	if err != nil {
+		return nil, "", fmt.Errorf("abi pack err:%w", err)
+	}
+	tx, err := b.sendTransaction(context.Background(), b.activeSigner(), b.ContractAddress, data, new(big.Int).SetInt64(0), nonce, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return tx, b.FromAddress(), nil
+}
+
+// ReplaceTransaction replace the pending tx by its signer with a raised fee, e.g. a stuck batch deposit tx,
+// returns the signer address
+func (b *Bridge) ReplaceTransaction(oldTx *types.Transaction) (*types.Transaction, string, error) {
+	signer := b.txSigner(oldTx)
+	tx, err := b.retrySendTransaction(context.Background(), oldTx, signer, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return tx, signer.Address().String(), nil
+}
+
+// DepositEventUUIDs returns the deposit uuids of the deposit events in the receipt
+func (b *Bridge) DepositEventUUIDs(receipt *types.Receipt) (map[common.Hash]struct{}, error) {
+	return DepositEventUUIDs(b.ABI, b.ContractAddress, receipt.Logs)
+}
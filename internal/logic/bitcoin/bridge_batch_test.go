@@ -0,0 +1,261 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+const batchDepositMethodAbi = `{
+      "inputs": [
+        {"internalType": "bytes32[]", "name": "deposit_uuids", "type": "bytes32[]"},
+        {"internalType": "address[]", "name": "b2_to_addresses", "type": "address[]"},
+        {"internalType": "uint256[]", "name": "btc_amounts", "type": "uint256[]"}
+      ],
+      "name": "batchDeposit",
+      "outputs": [],
+      "stateMutability": "nonpayable",
+      "type": "function"
+    },`
+
+var batchDepositAbi = strings.Replace(config.DefaultDepositAbi, "[", "["+batchDepositMethodAbi, 1)
+
+func TestHasABIMethod(t *testing.T) {
+	require.False(t, bitcoin.HasABIMethod(config.DefaultDepositAbi, bitcoin.BatchDepositMethod))
+	require.True(t, bitcoin.HasABIMethod(config.DefaultDepositAbi, "deposit"))
+	require.True(t, bitcoin.HasABIMethod(batchDepositAbi, bitcoin.BatchDepositMethod))
+	require.False(t, bitcoin.HasABIMethod("invalid", bitcoin.BatchDepositMethod))
+}
+
+func TestBatchDepositABIPack(t *testing.T) {
+	b := &bitcoin.Bridge{}
+	data, err := b.ABIPack(batchDepositAbi, bitcoin.BatchDepositMethod,
+		[][32]byte{common.HexToHash("0x01"), common.HexToHash("0x02")},
+		[]common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")},
+		[]*big.Int{big.NewInt(1000), big.NewInt(2000)},
+	)
+	require.NoError(t, err)
+	contractAbi, err := abi.JSON(strings.NewReader(batchDepositAbi))
+	require.NoError(t, err)
+	require.Equal(t, contractAbi.Methods[bitcoin.BatchDepositMethod].ID, data[:4])
+
+	_, err = b.ABIPack(config.DefaultDepositAbi, bitcoin.BatchDepositMethod)
+	require.Error(t, err)
+}
+
+func TestDepositEventUUIDs(t *testing.T) {
+	contractAbi, err := abi.JSON(strings.NewReader(config.DefaultDepositAbi))
+	require.NoError(t, err)
+	event := contractAbi.Events[bitcoin.DepositEventName]
+	contract := common.HexToAddress("0xe55De8DD6fF4E5c6e2b9E4F1Df2d8A4d8f1B2a3C")
+	depositLog := func(address common.Address, uuid common.Hash) *types.Log {
+		data, err := event.Inputs.NonIndexed().Pack(big.NewInt(1e13), uuid)
+		require.NoError(t, err)
+		return &types.Log{
+			Address: address,
+			Topics: []common.Hash{
+				event.ID,
+				common.BytesToHash(common.HexToAddress("0x01").Bytes()),
+				common.BytesToHash(common.HexToAddress("0x02").Bytes()),
+			},
+			Data: data,
+		}
+	}
+	uuid1 := common.HexToHash("2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7")
+	uuid2 := common.HexToHash("0x0f0e0d0c0b0a09080706050403020100f0e0d0c0b0a090807060504030201000")
+
+	testCases := []struct {
+		name  string
+		logs  []*types.Log
+		uuids map[common.Hash]struct{}
+	}{
+		{
+			name:  "success: no logs",
+			uuids: map[common.Hash]struct{}{},
+		},
+		{
+			name: "success: deposit events",
+			logs: []*types.Log{depositLog(contract, uuid1), depositLog(contract, uuid2)},
+			uuids: map[common.Hash]struct{}{
+				uuid1: {},
+				uuid2: {},
+			},
+		},
+		{
+			name: "success: skip other contract and event",
+			logs: []*types.Log{
+				depositLog(common.HexToAddress("0x03"), uuid1),
+				{Address: contract, Topics: []common.Hash{common.HexToHash("0x04")}},
+				{Address: contract},
+				depositLog(contract, uuid2),
+			},
+			uuids: map[common.Hash]struct{}{
+				uuid2: {},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uuids, err := bitcoin.DepositEventUUIDs(config.DefaultDepositAbi, contract, tc.logs)
+			require.NoError(t, err)
+			require.Equal(t, tc.uuids, uuids)
+		})
+	}
+
+	_, err = bitcoin.DepositEventUUIDs("[]", contract, nil)
+	require.Error(t, err)
+}
+
+func TestBatchDeposits(t *testing.T) {
+	deposits := make([]model.Deposit, 5)
+	for i := range deposits {
+		deposits[i].ID = int64(i + 1)
+	}
+
+	testCases := []struct {
+		name      string
+		batchSize int
+		lens      []int
+	}{
+		{
+			name:      "success: exact",
+			batchSize: 5,
+			lens:      []int{5},
+		},
+		{
+			name:      "success: remainder",
+			batchSize: 2,
+			lens:      []int{2, 2, 1},
+		},
+		{
+			name:      "success: invalid batch size",
+			batchSize: 0,
+			lens:      []int{1, 1, 1, 1, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := bitcoin.BatchDeposits(deposits, tc.batchSize)
+			require.Len(t, batches, len(tc.lens))
+			id := int64(1)
+			for i, batch := range batches {
+				require.Len(t, batch, tc.lens[i])
+				for _, v := range batch {
+					require.Equal(t, id, v.ID)
+					id++
+				}
+			}
+		})
+	}
+}
+
+func TestSplitDepositsByEvent(t *testing.T) {
+	deposits := []model.Deposit{
+		{BtcTxHash: "2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"},
+		{BtcTxHash: "0f0e0d0c0b0a09080706050403020100f0e0d0c0b0a090807060504030201000"},
+		{BtcTxHash: "1111111111111111111111111111111111111111111111111111111111111111"},
+	}
+	uuids := map[common.Hash]struct{}{
+		common.HexToHash("0x2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"): {},
+		common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111"): {},
+	}
+	succeeded, failed := bitcoin.SplitDepositsByEvent(deposits, uuids)
+	require.Equal(t, []model.Deposit{deposits[0], deposits[2]}, succeeded)
+	require.Equal(t, []model.Deposit{deposits[1]}, failed)
+}
+
+func TestUnconfirmedBatches(t *testing.T) {
+	batchTxHash := "0x4e3a3754410177e6937ef1f84bba68ea139e8d1a2258c5f85db9f1cd715a1bdd"
+	deposits := []model.Deposit{
+		{Base: model.Base{ID: 1}, BtcTxHash: "aa", B2TxHash: batchTxHash, B2TxNonce: 7},
+		{Base: model.Base{ID: 2}, BtcTxHash: "bb", B2TxHash: "0x01", B2TxNonce: 8},
+		{Base: model.Base{ID: 3}, BtcTxHash: "cc", B2TxHash: batchTxHash, B2TxNonce: 7},
+		{Base: model.Base{ID: 4}, BtcTxHash: "dd"},
+		{Base: model.Base{ID: 5}, BtcTxHash: "ee"},
+	}
+
+	batches := bitcoin.UnconfirmedBatches(deposits)
+	require.Equal(t, [][]model.Deposit{
+		// the two deposits of the batch deposit tx are handled together
+		{deposits[0], deposits[2]},
+		{deposits[1]},
+		// deposits without tx hash are not grouped
+		{deposits[3]},
+		{deposits[4]},
+	}, batches)
+
+	// the batch is sent again in one tx with both deposits
+	items := bitcoin.BatchDepositItems(batches[0])
+	require.Len(t, items, 2)
+	require.Equal(t, bitcoin.DepositUUID(deposits[0]), items[0].UUID)
+	require.Equal(t, bitcoin.DepositUUID(deposits[2]), items[1].UUID)
+}
+
+func TestHandleBatches(t *testing.T) {
+	batches := bitcoin.BatchDeposits([]model.Deposit{
+		{Base: model.Base{ID: 1}},
+		{Base: model.Base{ID: 2}},
+		{Base: model.Base{ID: 3}},
+		{Base: model.Base{ID: 4}},
+		{Base: model.Base{ID: 5}},
+	}, 2)
+
+	testCases := []struct {
+		name    string
+		errs    map[int64]error
+		handled []int64
+		err     error
+	}{
+		{
+			name:    "success: all batches",
+			handled: []int64{1, 3, 5},
+		},
+		{
+			name:    "success: failed batch followed by a good one",
+			errs:    map[int64]error{1: errors.New("wait mined err"), 3: bitcoin.ErrBatchDepositEmpty},
+			handled: []int64{1, 3, 5},
+		},
+		{
+			name:    "fail: server stop",
+			errs:    map[int64]error{3: bitcoin.ErrServerStop},
+			handled: []int64{1, 3},
+			err:     bitcoin.ErrServerStop,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handled := make([]int64, 0)
+			err := bitcoin.HandleBatches(make(chan struct{}), batches, time.Millisecond,
+				func(batch []model.Deposit) error {
+					handled = append(handled, batch[0].ID)
+					return tc.errs[batch[0].ID]
+				}, log.NewNopLogger())
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.handled, handled)
+		})
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	handled := 0
+	err := bitcoin.HandleBatches(stop, batches, time.Hour, func([]model.Deposit) error {
+		handled++
+		return nil
+	}, log.NewNopLogger())
+	require.ErrorIs(t, err, bitcoin.ErrServerStop)
+	require.Equal(t, 1, handled)
+}
@@ -0,0 +1,434 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+)
+
+// SetBatchSize set the max number of deposits in one batch deposit tx,
+// deposits are handled one by one if less than 2 or the contract abi lacks the batch method
+func (bis *BridgeDepositService) SetBatchSize(batchSize int) {
+	bis.batchSize = 0
+	if batchSize < 2 {
+		return
+	}
+	if !bis.bridge.SupportBatchDeposit() {
+		bis.log.Warnw("contract abi lacks batch deposit method, deposits are sent one by one",
+			"method", BatchDepositMethod)
+		return
+	}
+	bis.batchSize = batchSize
+}
+
+// HandleBatchDeposits handle deposits in batches of batch size
+func (bis *BridgeDepositService) HandleBatchDeposits(deposits []model.Deposit) error {
+	return HandleBatches(bis.stopChan, BatchDeposits(deposits, bis.batchSize), HandleDepositTimeout,
+		bis.HandleBatchDeposit, bis.log)
+}
+
+// HandleBatches handle the batches in order and wait between batches, a failed batch is logged and its
+// deposits are retried after the backoff, the following batches are not blocked. returns ErrServerStop if stopped
+func HandleBatches(
+	stop <-chan struct{},
+	batches [][]model.Deposit,
+	wait time.Duration,
+	handle func([]model.Deposit) error,
+	logger log.Logger,
+) error {
+	for _, batch := range batches {
+		err := handle(batch)
+		if err != nil {
+			if errors.Is(err, ErrServerStop) {
+				return err
+			}
+			logger.Errorw("handle batch deposit failed", "error", err, "num", len(batch))
+		}
+		timeoutTicker := time.NewTicker(wait)
+		select {
+		case <-stop:
+			timeoutTicker.Stop()
+			logger.Warnf("handle batch deposit stopping...")
+			return ErrServerStop
+		case <-timeoutTicker.C:
+		}
+	}
+	return nil
+}
+
+// BatchDeposits split deposits into batches of at most batchSize deposits
+func BatchDeposits(deposits []model.Deposit, batchSize int) [][]model.Deposit {
+	batches := make([][]model.Deposit, 0)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	for start := 0; start < len(deposits); start += batchSize {
+		end := start + batchSize
+		if end > len(deposits) {
+			end = len(deposits)
+		}
+		batches = append(batches, deposits[start:end])
+	}
+	return batches
+}
+
+// HandleBatchDeposit send the deposits in one batch deposit tx and wait mined,
+// deposits failed in the batch are sent again by single deposit call
+func (bis *BridgeDepositService) HandleBatchDeposit(deposits []model.Deposit) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			bis.log.Errorw("panic err", r)
+			err = fmt.Errorf("handle batch deposit panic: %v", r)
+		}
+	}()
+
+	// unconfirmed deposits are handled next time
+	confirmed := make([]model.Deposit, 0, len(deposits))
+	for _, deposit := range deposits {
+		err := bis.btcIndexer.CheckConfirmations(deposit.BtcTxHash)
+		if err != nil {
+			bis.log.Errorw("check btc tx confirmations err", "btcTxHash", deposit.BtcTxHash, "err", err)
+			continue
+		}
+		confirmed = append(confirmed, deposit)
+	}
+	if len(confirmed) == 0 {
+		return nil
+	}
+	if len(confirmed) == 1 {
+		return bis.HandleDeposit(confirmed[0], nil)
+	}
+	return bis.sendBatchDeposit(confirmed, confirmed[0].ID)
+}
+
+// BatchDepositItems returns the batch deposit items of the deposits
+func BatchDepositItems(deposits []model.Deposit) []*types.BatchDepositItem {
+	items := make([]*types.BatchDepositItem, 0, len(deposits))
+	for _, deposit := range deposits {
+		items = append(items, &types.BatchDepositItem{
+			UUID:       DepositUUID(deposit),
+			From:       types.BitcoinFrom{Address: deposit.BtcFrom},
+			EvmAddress: deposit.BtcMemoAddress,
+			Amount:     deposit.BtcValue,
+		})
+	}
+	return items
+}
+
+// sendBatchDeposit send the confirmed deposits in one batch deposit tx with the nonce owned by ownerID and wait mined
+func (bis *BridgeDepositService) sendBatchDeposit(confirmed []model.Deposit, ownerID int64) error {
+	signer := bis.bridge.FromAddress()
+	nonce, err := bis.nonceManager.Allocate(signer, ownerID, model.BridgeNonceTxTypeBatchDeposit)
+	if err != nil {
+		return err
+	}
+	items := BatchDepositItems(confirmed)
+	b2Tx, fromAddress, err := bis.bridge.BatchDeposit(items, nonce)
+
+	batched, err1 := bis.skipBatchDepositItems(confirmed, items)
+	if err1 != nil {
+		return err1
+	}
+	if err != nil {
+		bis.releaseNonce(signer, nonce, nil, err)
+		if errors.Is(err, ErrBatchDepositEmpty) {
+			return nil
+		}
+		// a single deposit may fail the whole batch, e.g. tx hash exist
+		bis.log.Errorw("invoke batch deposit send tx err, fall back to single deposit",
+			"error", err.Error(),
+			"num", len(batched))
+		return bis.handleSingleDeposits(batched)
+	}
+	if err := bis.nonceManager.MarkSent(signer, b2Tx.Nonce(), b2Tx.Hash().String()); err != nil {
+		bis.log.Errorw("mark nonce sent err", "error", err, "nonce", b2Tx.Nonce())
+	}
+
+	err = bis.recordBatchDeposit(b2Tx, fromAddress, batched, "batch deposit tx sent")
+	if err != nil {
+		return err
+	}
+	bis.log.Infow("invoke batch deposit send tx success, wait confirm",
+		"b2TxHash", b2Tx.Hash().String(), "num", len(batched))
+	return bis.waitBatchDeposit(b2Tx, batched)
+}
+
+// recordBatchDeposit record the sent batch deposit tx to all deposits of the batch in one db tx
+func (bis *BridgeDepositService) recordBatchDeposit(b2Tx *ethTypes.Transaction, fromAddress string, deposits []model.Deposit, reason string) error {
+	return bis.db.Transaction(func(tx *gorm.DB) error {
+		for i := range deposits {
+			deposits[i].B2TxStatus = model.DepositB2TxStatusWaitMined
+			deposits[i].B2TxHash = b2Tx.Hash().String()
+			deposits[i].B2TxNonce = b2Tx.Nonce()
+			deposits[i].B2TxFrom = fromAddress
+			err := model.TransitDeposit(tx, deposits[i].ID, model.DepositTransition{
+				To: map[string]int{
+					model.Deposit{}.Column().B2TxStatus: deposits[i].B2TxStatus,
+				},
+				Updates: map[string]interface{}{
+					model.Deposit{}.Column().B2TxHash:         deposits[i].B2TxHash,
+					model.Deposit{}.Column().BtcFromAAAddress: deposits[i].BtcFromAAAddress,
+					model.Deposit{}.Column().B2TxNonce:        deposits[i].B2TxNonce,
+					model.Deposit{}.Column().B2TxFrom:         fromAddress,
+				},
+				Actor:  model.DepositActorBridge,
+				Reason: reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// skipBatchDepositItems record the status of deposits not in the batch, returns deposits in the batch
+func (bis *BridgeDepositService) skipBatchDepositItems(deposits []model.Deposit, items []*types.BatchDepositItem) ([]model.Deposit, error) {
+	batched := make([]model.Deposit, 0, len(deposits))
+	for i, item := range items {
+		deposit := deposits[i]
+		if item.Err == nil {
+			deposit.BtcFromAAAddress = item.ToAddress
+			batched = append(batched, deposit)
+			continue
+		}
+		var err error
+		if errors.Is(item.Err, ErrAAAddressNotFound) {
+			bis.log.Warnw("invoke batch deposit aa address not found",
+				"error", item.Err.Error(),
+				"btcTxHash", deposit.BtcTxHash)
+			err = bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus,
+				model.DepositB2TxStatusAAAddressNotFound, item.Err.Error(), nil)
+		} else {
+			bis.log.Errorw("invoke batch deposit to address err",
+				"error", item.Err.Error(),
+				"btcTxHash", deposit.BtcTxHash)
+			err = bis.retryDeposit(&deposit, item.Err.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batched, nil
+}
+
+// waitBatchDeposit wait the batch deposit tx mined, deposits without deposit event are sent again one by one
+func (bis *BridgeDepositService) waitBatchDeposit(b2Tx *ethTypes.Transaction, deposits []model.Deposit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), WaitMinedTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-bis.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	receipt, err := bis.bridge.WaitMined(ctx, b2Tx, nil)
+	if err != nil && !errors.Is(err, ErrBridgeWaitMinedStatus) {
+		select {
+		case <-bis.stopChan:
+			// handled by UnconfirmedDeposit after restart
+			return ErrServerStop
+		default:
+		}
+		status := model.DepositB2TxStatusWaitMinedFailed
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = model.DepositB2TxStatusContextDeadlineExceeded
+		}
+		bis.log.Errorw("invoke batch deposit wait mined err",
+			"error", err.Error(),
+			"b2TxHash", b2Tx.Hash().String())
+		dbErr := bis.transitDeposits(deposits, status, err.Error())
+		if dbErr != nil {
+			return dbErr
+		}
+		return fmt.Errorf("wait mined err b2_tx_status: %v", status)
+	}
+	return bis.handleBatchReceipt(b2Tx.Hash().String(), receipt, deposits)
+}
+
+// handleBatchReceipt record the deposits with deposit event in the mined batch deposit tx,
+// others are sent again one by one
+func (bis *BridgeDepositService) handleBatchReceipt(b2TxHash string, receipt *ethTypes.Receipt, deposits []model.Deposit) error {
+	failed := deposits
+	if receipt.Status == 1 {
+		uuids, err := bis.bridge.DepositEventUUIDs(receipt)
+		if err != nil {
+			return err
+		}
+		var succeeded []model.Deposit
+		succeeded, failed = SplitDepositsByEvent(deposits, uuids)
+		if len(succeeded) > 0 {
+			err = bis.transitDeposits(succeeded, model.DepositB2TxStatusSuccess, "deposit event found in batch deposit tx")
+			if err != nil {
+				return err
+			}
+		}
+		bis.log.Infow("handle batch deposit success",
+			"b2TxHash", b2TxHash, "success", len(succeeded), "failed", len(failed))
+	} else {
+		bis.log.Errorw("invoke batch deposit wait mined err, status != 1",
+			"b2TxHash", b2TxHash, "receipt", receipt)
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return bis.handleSingleDeposits(failed)
+}
+
+// UnconfirmedBatches group unconfirmed deposits by b2 tx, deposits of a batch deposit tx share the tx hash.
+// deposits without tx hash are alone, groups are in the order of their first deposit
+func UnconfirmedBatches(deposits []model.Deposit) [][]model.Deposit {
+	batches := make([][]model.Deposit, 0, len(deposits))
+	index := make(map[string]int)
+	for _, deposit := range deposits {
+		if deposit.B2TxHash == "" {
+			batches = append(batches, []model.Deposit{deposit})
+			continue
+		}
+		i, ok := index[deposit.B2TxHash]
+		if !ok {
+			index[deposit.B2TxHash] = len(batches)
+			batches = append(batches, []model.Deposit{deposit})
+			continue
+		}
+		batches[i] = append(batches[i], deposit)
+	}
+	return batches
+}
+
+// HandleUnconfirmedBatchDeposit handle the deposits sharing one unconfirmed batch deposit tx,
+// the batch tx is replaced or sent again once and all deposits of the batch are updated together
+// 1. tx mined, update status by deposit events
+// 2. tx not mined, isPending, replace the tx with a raised fee
+// 3. tx not mined, tx not mempool, send the batch again with the nonce owned by the batch
+func (bis *BridgeDepositService) HandleUnconfirmedBatchDeposit(deposits []model.Deposit) error {
+	b2TxHash := deposits[0].B2TxHash
+	receipt, err := bis.bridge.TransactionReceipt(b2TxHash)
+	if err == nil {
+		// case 1
+		return bis.handleBatchReceipt(b2TxHash, receipt, deposits)
+	}
+	bis.log.Errorw("batch deposit TransactionReceipt err", "error", err, "b2TxHash", b2TxHash)
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	tx, isPending, err := bis.bridge.TransactionByHash(b2TxHash)
+	if err != nil {
+		if errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "not found") {
+			// case 3
+			bis.log.Errorw("batch deposit TransactionByHash not found, send the batch again", "b2TxHash", b2TxHash)
+			return bis.resendBatchDeposit(deposits)
+		}
+		return err
+	}
+	if !isPending {
+		// mined, the receipt is checked next time
+		return nil
+	}
+	// case 2
+	bis.log.Warnw("batch deposit tx is pending retry", "old", tx, "num", len(deposits))
+	replaceable := bis.bridge.HasSigner(deposits[0].B2TxFrom)
+	for _, deposit := range deposits {
+		// nonce too low, the nonce manager allocates a new nonce
+		if deposit.B2TxStatus == model.DepositB2TxStatusNonceToLow {
+			replaceable = false
+		}
+	}
+	if !replaceable {
+		return bis.resendBatchDeposit(deposits)
+	}
+	b2Tx, fromAddress, err := bis.bridge.ReplaceTransaction(tx)
+	if err != nil {
+		bis.log.Errorw("replace batch deposit tx err", "error", err, "b2TxHash", b2TxHash)
+		if errors.Is(err, ErrNodeNonceTooLow) {
+			return bis.transitDeposits(deposits, model.DepositB2TxStatusNonceToLow, err.Error())
+		}
+		return err
+	}
+	if err := bis.nonceManager.MarkSent(fromAddress, b2Tx.Nonce(), b2Tx.Hash().String()); err != nil {
+		bis.log.Errorw("mark nonce sent err", "error", err, "nonce", b2Tx.Nonce())
+	}
+	err = bis.recordBatchDeposit(b2Tx, fromAddress, deposits, "batch deposit tx replaced")
+	if err != nil {
+		return err
+	}
+	return bis.waitBatchDeposit(b2Tx, deposits)
+}
+
+// resendBatchDeposit send the deposits of the batch again in one tx, the nonce owned by the batch is reused
+// if it is not consumed
+func (bis *BridgeDepositService) resendBatchDeposit(deposits []model.Deposit) error {
+	ownerID := deposits[0].ID
+	owner, err := bis.nonceManager.Owner(deposits[0].B2TxFrom, deposits[0].B2TxNonce)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && owner.TxType == model.BridgeNonceTxTypeBatchDeposit && owner.DepositID != 0 {
+		ownerID = owner.DepositID
+	}
+	return bis.sendBatchDeposit(deposits, ownerID)
+}
+
+// handleSingleDeposits send deposits one by one, used for deposits failed in the batch
+func (bis *BridgeDepositService) handleSingleDeposits(deposits []model.Deposit) error {
+	for _, deposit := range deposits {
+		bis.log.Warnw("batch deposit failed, send by single deposit", "btcTxHash", deposit.BtcTxHash)
+		err := bis.HandleDeposit(deposit, nil)
+		if err != nil {
+			if errors.Is(err, ErrServerStop) {
+				return err
+			}
+			// the failed deposit is retried after the backoff, the following deposits are not blocked
+			bis.log.Errorw("handle single deposit failed", "error", err, "btcTxHash", deposit.BtcTxHash)
+		}
+		timeoutTicker := time.NewTicker(HandleDepositTimeout)
+		select {
+		case <-bis.stopChan:
+			return ErrServerStop
+		case <-timeoutTicker.C:
+		}
+	}
+	return nil
+}
+
+// transitDeposits change b2 tx status of the batched deposits in one db tx
+func (bis *BridgeDepositService) transitDeposits(deposits []model.Deposit, status int, reason string) error {
+	return bis.db.Transaction(func(tx *gorm.DB) error {
+		for _, deposit := range deposits {
+			err := model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To:     map[string]int{model.Deposit{}.Column().B2TxStatus: status},
+				Actor:  model.DepositActorBridge,
+				Reason: reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SplitDepositsByEvent split deposits by whether the deposit event of the uuid is emitted
+func SplitDepositsByEvent(deposits []model.Deposit, uuids map[common.Hash]struct{}) ([]model.Deposit, []model.Deposit) {
+	succeeded := make([]model.Deposit, 0, len(deposits))
+	failed := make([]model.Deposit, 0)
+	for _, deposit := range deposits {
+		if _, ok := uuids[DepositUUID(deposit)]; ok {
+			succeeded = append(succeeded, deposit)
+		} else {
+			failed = append(failed, deposit)
+		}
+	}
+	return succeeded, failed
+}
@@ -0,0 +1,108 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// DryRunResultSuccess dry run result of deposits simulated without revert
+const DryRunResultSuccess = "success"
+
+// DryRunTx would-be deposit tx recorded in dry run mode
+type DryRunTx struct {
+	From string `json:"from"`
+	// Tx unsigned tx
+	Tx *ethTypes.Transaction `json:"tx"`
+}
+
+// SetDryRun deposits are simulated by eth_call and the would-be txs are recorded instead of broadcasting,
+// nonce gaps, unconfirmed deposits and eoa transfers are not handled in dry run mode.
+// simulated deposits stay pending and are sent once dry run is disabled
+func (bis *BridgeDepositService) SetDryRun(dryRun bool) {
+	bis.dryRun = dryRun
+	if dryRun {
+		bis.log.Warnw("bridge deposit dry run mode, deposit txs are simulated and not broadcast")
+	}
+}
+
+// DryRunDeposits simulate pending deposits not simulated yet
+func (bis *BridgeDepositService) DryRunDeposits() error {
+	var deposits []model.Deposit
+	err := bis.pendingDepositsQuery().
+		Where(fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().B2DryRunResult), "").
+		Find(&deposits).Error
+	if err != nil {
+		return err
+	}
+	bis.log.Infow("start dry run deposit", "deposit batch num", len(deposits))
+	bis.resolveAddresses(deposits)
+	for _, deposit := range deposits {
+		err = bis.DryRunDeposit(deposit)
+		if err != nil {
+			bis.log.Errorw("dry run deposit failed", "error", err, "deposit", deposit)
+			return err
+		}
+		select {
+		case <-bis.stopChan:
+			bis.log.Warnf("dry run deposit stopping...")
+			return ErrServerStop
+		default:
+		}
+	}
+	return nil
+}
+
+// DryRunDeposit check confirmations, resolve the to address and simulate the deposit tx,
+// the would-be tx and the simulation result are recorded to the deposit, the b2 tx status is not changed
+func (bis *BridgeDepositService) DryRunDeposit(deposit model.Deposit) error {
+	err := bis.btcIndexer.CheckConfirmations(deposit.BtcTxHash)
+	if err != nil {
+		bis.log.Errorw("check btc tx confirmations err", "tx hash:", deposit.BtcTxHash, "err:", err)
+		return err
+	}
+
+	simulation, err := bis.bridge.SimulateDeposit(DepositUUID(deposit).Hex(), types.BitcoinFrom{
+		Address: deposit.BtcFrom,
+	}, deposit.BtcMemoAddress, deposit.BtcValue)
+	if err != nil {
+		// aa resolution failure is the dry run result, other errs are retried
+		if !errors.Is(err, ErrAAAddressNotFound) {
+			return err
+		}
+		simulation = &types.DepositSimulation{Err: err}
+	}
+
+	result := DryRunResultSuccess
+	if simulation.Err != nil {
+		result = simulation.Err.Error()
+	}
+	dryRunTx := "{}"
+	if simulation.Tx != nil {
+		txJSON, err := json.Marshal(DryRunTx{From: simulation.From, Tx: simulation.Tx})
+		if err != nil {
+			return err
+		}
+		dryRunTx = string(txJSON)
+	}
+	bis.log.Infow("dry run deposit",
+		"btcTxHash", deposit.BtcTxHash,
+		"toAddress", simulation.ToAddress,
+		"amount", deposit.BtcValue,
+		"result", result,
+		"tx", dryRunTx)
+
+	return model.TransitDeposit(bis.db, deposit.ID, model.DepositTransition{
+		Updates: map[string]interface{}{
+			model.Deposit{}.Column().BtcFromAAAddress: simulation.ToAddress,
+			model.Deposit{}.Column().B2DryRunTx:       dryRunTx,
+			model.Deposit{}.Column().B2DryRunResult:   result,
+		},
+		Actor:  model.DepositActorBridge,
+		Reason: "dry run: " + result,
+	})
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/b2network/b2-indexer/internal/model"
 	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/amount"
 	"github.com/b2network/b2-indexer/pkg/log"
 	"github.com/cometbft/cometbft/libs/service"
 	"github.com/ethereum/go-ethereum"
@@ -24,7 +26,7 @@ const (
 	BatchDepositLimit        = 100
 	WaitMinedTimeout         = 20 * time.Minute
 	HandleDepositTimeout     = 1 * time.Second
-	DepositRetry             = 10 // temp fix, Increase retry times
+	DepositRetry             = 10 // default retry budget of failed deposits
 )
 
 var ErrServerStop = errors.New("server stop")
@@ -33,12 +35,27 @@ var ErrServerStop = errors.New("server stop")
 type BridgeDepositService struct {
 	service.BaseService
 
-	bridge     types.BITCOINBridge
-	btcIndexer types.BITCOINTxIndexer
-	db         *gorm.DB
-	log        log.Logger
-	wg         sync.WaitGroup
-	stopChan   chan struct{}
+	bridge       types.BITCOINBridge
+	btcIndexer   types.BITCOINTxIndexer
+	nonceManager *NonceManager
+	// pipeline submits deposits without waiting mined, nil if deposits are handled one by one
+	pipeline *DepositPipeline
+	// batchSize max deposits in one batch deposit tx, 0 if deposits are sent one by one
+	batchSize int
+	// signerMinBalance signer gas balance alert threshold in wei, nil if disabled
+	signerMinBalance *big.Int
+	// dryRun deposits are simulated and recorded, txs are not broadcast
+	dryRun bool
+	// asset decimals of the bridged asset, deposit values are checked in the asset unit
+	asset amount.Asset
+	// retryPolicy retry budget and backoff of failed deposits
+	retryPolicy DepositRetryPolicy
+	// balanceAlerts last low balance alert time of signers
+	balanceAlerts map[string]time.Time
+	db            *gorm.DB
+	log           log.Logger
+	wg            sync.WaitGroup
+	stopChan      chan struct{}
 }
 
 // NewBridgeDepositService returns a new service instance.
@@ -49,15 +66,33 @@ func NewBridgeDepositService(
 	logger log.Logger,
 ) *BridgeDepositService {
 	is := &BridgeDepositService{
-		bridge:     bridge,
-		btcIndexer: btcIndexer,
-		db:         db,
-		log:        logger,
+		bridge:        bridge,
+		btcIndexer:    btcIndexer,
+		nonceManager:  NewNonceManager(bridge, db, logger),
+		balanceAlerts: make(map[string]time.Time),
+		asset:         amount.DefaultAsset,
+		retryPolicy:   NewDepositRetryPolicy(0, 0),
+		db:            db,
+		log:           logger,
 	}
 	is.BaseService = *service.NewBaseService(nil, BridgeDepositServiceName, is)
 	return is
 }
 
+// SetMaxInFlight set the max number of unmined deposit txs, deposits are handled one by one if less than 2
+func (bis *BridgeDepositService) SetMaxInFlight(maxInFlight int) {
+	if maxInFlight < 2 {
+		bis.pipeline = nil
+		return
+	}
+	bis.pipeline = NewDepositPipeline(maxInFlight)
+}
+
+// SetAsset set the decimals of the bridged asset
+func (bis *BridgeDepositService) SetAsset(asset amount.Asset) {
+	bis.asset = asset
+}
+
 // OnStart
 func (bis *BridgeDepositService) OnStart() error {
 	bis.wg.Add(2)
@@ -71,18 +106,34 @@ func (bis *BridgeDepositService) OnStop() {
 	bis.log.Warnf("bridge deposit service stoping...")
 	close(bis.stopChan)
 	bis.wg.Wait()
+	if bis.pipeline != nil {
+		bis.pipeline.Wait()
+	}
 }
 
 func (bis *BridgeDepositService) Deposit() {
 	defer bis.wg.Done()
 	ticker := time.NewTicker(BatchDepositWaitTimeout)
 	for {
-	DEPOSIT:
 		select {
 		case <-bis.stopChan:
 			bis.log.Warnf("deposit stopping...")
 			return
 		case <-ticker.C:
+			// new txs of the tick are sent by the same signer
+			bis.bridge.RotateSigner()
+			bis.CheckSignerBalance(time.Now())
+
+			if bis.dryRun {
+				err := bis.DryRunDeposits()
+				if err != nil {
+					bis.log.Warnf("dry run deposit err: %s", err)
+					if errors.Is(err, ErrServerStop) {
+						return
+					}
+				}
+				continue
+			}
 
 			if bis.bridge.EnableEoaTransfer() {
 				err := bis.HandleEoaTransfer()
@@ -104,6 +155,14 @@ func (bis *BridgeDepositService) Deposit() {
 				}
 			}
 
+			// fill nonce gaps first, txs with higher nonce are stuck behind gaps
+			// retired signers fill their gaps too
+			for _, address := range bis.bridge.SignerAddresses() {
+				if _, err := bis.nonceManager.FillGaps(address); err != nil {
+					bis.log.Errorw("fill nonce gaps err", "error", err, "address", address)
+				}
+			}
+
 			// Priority processing UnconfirmedDeposit
 			err := bis.UnconfirmedDeposit()
 			if err != nil {
@@ -114,48 +173,38 @@ func (bis *BridgeDepositService) Deposit() {
 				continue
 			}
 
-			// Query condition
-			// 1. tx status is pending
-			// 2. contract insufficient balance
-			// 3. invoke contract from account insufficient balance
-			// 4. callback status is success
-			// 5. listener status is success
-			var deposits []model.Deposit
-			err = bis.db.
-				Where(
-					fmt.Sprintf("%s.%s IN (?)", model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxStatus),
-					[]int{
-						model.DepositB2TxStatusPending,
-						model.DepositB2TxStatusInsufficientBalance,
-						model.DepositB2TxStatusFromAccountGasInsufficient,
-					},
-				).
-				Where(
-					fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().CallbackStatus),
-					model.CallbackStatusSuccess,
-				).
-				Where(
-					fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus),
-					model.ListenerStatusSuccess,
-				).
-				Limit(BatchDepositLimit).
-				Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), model.Deposit{}.Column().BtcBlockNumber)).
-				Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), "id")).
-				Find(&deposits).
-				Error
+			deposits, err := bis.pendingDeposits()
 			if err != nil {
 				bis.log.Errorw("failed find tx from db", "error", err)
 			}
 
 			bis.log.Infow("start handle deposit", "deposit batch num", len(deposits))
+			if bis.batchSize == 0 {
+				bis.resolveAddresses(deposits)
+			}
+			if bis.batchSize > 0 {
+				// failed batches are retried after the backoff, the following batches are not blocked
+				err = bis.HandleBatchDeposits(deposits)
+				if errors.Is(err, ErrServerStop) {
+					return
+				}
+			}
 			for _, deposit := range deposits {
-				err = bis.HandleDeposit(deposit, nil, deposit.B2TxNonce, false)
+				if bis.batchSize > 0 {
+					// handled by batch deposit
+					break
+				}
+				if bis.pipeline != nil {
+					err = bis.SubmitDeposit(deposit)
+				} else {
+					err = bis.HandleDeposit(deposit, nil)
+				}
 				if err != nil {
 					bis.log.Errorw("handle deposit failed", "error", err, "deposit", deposit)
 					if errors.Is(err, ErrServerStop) {
 						return
 					}
-					break DEPOSIT
+					// the failed deposit is retried after the backoff, the following deposits are not blocked
 				}
 				timeoutTicker := time.NewTicker(HandleDepositTimeout)
 				select {
@@ -175,6 +224,10 @@ func (bis *BridgeDepositService) Deposit() {
 					fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxStatus),
 					model.DepositB2TxStatusAAAddressNotFound,
 				).
+				Where(
+					fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus),
+					model.ListenerStatusSuccess,
+				).
 				Limit(BatchDepositLimit).
 				Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), model.Deposit{}.Column().BtcBlockNumber)).
 				Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), "id")).
@@ -184,8 +237,9 @@ func (bis *BridgeDepositService) Deposit() {
 			}
 
 			bis.log.Infow("start handle aa not found deposit", "aa not found deposit batch num", len(aaNotFoundDeposits))
+			bis.resolveAddresses(aaNotFoundDeposits)
 			for _, deposit := range aaNotFoundDeposits {
-				err = bis.HandleDeposit(deposit, nil, deposit.B2TxNonce, false)
+				err = bis.HandleDeposit(deposit, nil)
 				if err != nil {
 					if errors.Is(err, ErrAAAddressNotFound) {
 						bis.log.Warnf("aa address not found")
@@ -195,7 +249,6 @@ func (bis *BridgeDepositService) Deposit() {
 					if errors.Is(err, ErrServerStop) {
 						return
 					}
-					break DEPOSIT
 				}
 				timeoutTicker := time.NewTicker(HandleDepositTimeout)
 				select {
@@ -209,6 +262,76 @@ func (bis *BridgeDepositService) Deposit() {
 	}
 }
 
+// resolveAddresses resolve aa addresses of the deposits without memo address in one batch,
+// resolved addresses are cached by the resolver for the following deposits
+func (bis *BridgeDepositService) resolveAddresses(deposits []model.Deposit) {
+	addresses := make([]string, 0, len(deposits))
+	seen := make(map[string]struct{}, len(deposits))
+	for _, deposit := range deposits {
+		if deposit.BtcMemoAddress != "" || deposit.BtcFrom == "" {
+			continue
+		}
+		if _, ok := seen[deposit.BtcFrom]; ok {
+			continue
+		}
+		seen[deposit.BtcFrom] = struct{}{}
+		addresses = append(addresses, deposit.BtcFrom)
+	}
+	if len(addresses) == 0 {
+		return
+	}
+	failed := 0
+	for _, err := range bis.bridge.ResolveAddresses(addresses) {
+		if err != nil {
+			failed++
+		}
+	}
+	bis.log.Infow("resolve deposit aa addresses", "addresses", len(addresses), "failed", failed)
+}
+
+// pendingDeposits deposits to be sent, ordered by btc block
+func (bis *BridgeDepositService) pendingDeposits() ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	err := bis.pendingDepositsQuery().Find(&deposits).Error
+	return deposits, err
+}
+
+func (bis *BridgeDepositService) pendingDepositsQuery() *gorm.DB {
+	// Query condition
+	// 1. tx status is pending
+	// 2. contract insufficient balance
+	// 3. invoke contract from account insufficient balance
+	// 4. callback status is success
+	// 5. listener status is success
+	// 6. retry backoff elapsed
+	return bis.db.
+		Where(
+			fmt.Sprintf("%s.%s IN (?)", model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxStatus),
+			[]int{
+				model.DepositB2TxStatusPending,
+				model.DepositB2TxStatusInsufficientBalance,
+				model.DepositB2TxStatusFromAccountGasInsufficient,
+			},
+		).
+		Where(
+			fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().CallbackStatus),
+			model.CallbackStatusSuccess,
+		).
+		Where(
+			fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus),
+			model.ListenerStatusSuccess,
+		).
+		Where(
+			fmt.Sprintf("%s.%s IS NULL OR %s.%s <= ?",
+				model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxNextAttempt,
+				model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxNextAttempt),
+			time.Now(),
+		).
+		Limit(BatchDepositLimit).
+		Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), model.Deposit{}.Column().BtcBlockNumber)).
+		Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), "id"))
+}
+
 func (bis *BridgeDepositService) UnconfirmedDeposit() error {
 	var deposits []model.Deposit
 	err := bis.db.
@@ -222,6 +345,18 @@ func (bis *BridgeDepositService) UnconfirmedDeposit() error {
 				model.DepositB2TxStatusNonceToLow,
 			},
 		).
+		// deposit txs sent before the btc tx is reorged are still watched
+		Where(
+			bis.db.Where(
+				fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus),
+				model.ListenerStatusSuccess,
+			).Or(
+				fmt.Sprintf("%s.%s = ? AND %s.%s != ''",
+					model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus,
+					model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxHash),
+				model.ListenerStatusReorged,
+			),
+		).
 		Order(fmt.Sprintf("%s.%s ASC", model.Deposit{}.TableName(), model.Deposit{}.Column().B2TxNonce)).
 		Find(&deposits).Error
 	if err != nil {
@@ -230,12 +365,41 @@ func (bis *BridgeDepositService) UnconfirmedDeposit() error {
 	}
 
 	bis.log.Infow("start handle unconfirmed deposit", "unconfirmed deposit batch num", len(deposits))
+	confirmed := make([]model.Deposit, 0, len(deposits))
 	for _, deposit := range deposits {
-		err = bis.HandleUnconfirmedDeposit(deposit)
+		if deposit.ListenerStatus != model.ListenerStatusReorged {
+			confirmed = append(confirmed, deposit)
+			continue
+		}
+		if bis.pipeline != nil && bis.pipeline.InFlight(deposit.ID) {
+			continue
+		}
+		err = bis.HandleReorgedDeposit(deposit)
 		if err != nil {
-			bis.log.Errorw("handle unconfirmed failed", "error", err, "deposit", deposit)
+			bis.log.Errorw("handle reorged deposit failed", "error", err, "deposit", deposit)
 			return err
 		}
+	}
+	for _, batch := range UnconfirmedBatches(confirmed) {
+		if len(batch) > 1 {
+			// deposits of a batch deposit tx are handled together
+			err = bis.HandleUnconfirmedBatchDeposit(batch)
+			if err != nil {
+				bis.log.Errorw("handle unconfirmed batch failed", "error", err, "b2TxHash", batch[0].B2TxHash)
+				return err
+			}
+		} else {
+			deposit := batch[0]
+			// the pipeline watches its txs
+			if bis.pipeline != nil && bis.pipeline.InFlight(deposit.ID) {
+				continue
+			}
+			err = bis.HandleUnconfirmedDeposit(deposit)
+			if err != nil {
+				bis.log.Errorw("handle unconfirmed failed", "error", err, "deposit", deposit)
+				return err
+			}
+		}
 
 		timeoutTicker := time.NewTicker(HandleDepositTimeout)
 		select {
@@ -248,13 +412,24 @@ func (bis *BridgeDepositService) UnconfirmedDeposit() error {
 	return nil
 }
 
-func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *ethTypes.Transaction, nonce uint64, resetNonce bool) error {
+// HandleDeposit send deposit tx and wait mined, oldTx is replaced if not nil,
+// otherwise the nonce is allocated by the nonce manager
+func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *ethTypes.Transaction) error {
 	defer func() {
 		if err := recover(); err != nil {
 			bis.log.Errorw("panic err", err)
 		}
 	}()
 
+	b2Tx, deposit, err := bis.sendDeposit(deposit, oldTx)
+	if err != nil {
+		return err
+	}
+	return bis.waitDeposit(b2Tx, deposit)
+}
+
+// sendDeposit send deposit tx and record it, returns the deposit with the sent tx
+func (bis *BridgeDepositService) sendDeposit(deposit model.Deposit, oldTx *ethTypes.Transaction) (*ethTypes.Transaction, model.Deposit, error) {
 	if oldTx != nil {
 		bis.log.Warnw("handle old deposit", "old tx:", oldTx)
 	}
@@ -263,14 +438,29 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 	err := bis.btcIndexer.CheckConfirmations(deposit.BtcTxHash)
 	if err != nil {
 		bis.log.Errorw("check btc tx confirmations err", "tx hash:", deposit.B2TxHash, "err:", err)
-		return err
+		return nil, deposit, err
+	}
+
+	signer := bis.bridge.FromAddress()
+	var nonce uint64
+	if oldTx != nil {
+		// the old tx is replaced by its signer
+		signer = deposit.B2TxFrom
+		nonce = oldTx.Nonce()
+	} else {
+		nonce, err = bis.nonceManager.Allocate(signer, deposit.ID, model.BridgeNonceTxTypeDeposit)
+		if err != nil {
+			return nil, deposit, err
+		}
 	}
 
-	// send deposit tx
-	b2Tx, _, aaAddress, fromAddress, err := bis.bridge.Deposit(deposit.BtcTxHash, types.BitcoinFrom{
+	// send deposit tx, the op_return memo address takes precedence over aa address
+	b2Tx, _, aaAddress, fromAddress, err := bis.bridge.Deposit(DepositUUID(deposit).Hex(), types.BitcoinFrom{
 		Address: deposit.BtcFrom,
-	}, deposit.BtcValue, oldTx, nonce, resetNonce)
+	}, deposit.BtcMemoAddress, deposit.BtcValue, oldTx, nonce, false)
 	if err != nil {
+		bis.releaseNonce(signer, nonce, oldTx, err)
+		var revertErr *RevertError
 		switch {
 		case errors.Is(err, ErrBridgeDepositTxHashExist):
 			deposit.B2TxStatus = model.DepositB2TxStatusTxHashExist
@@ -308,7 +498,20 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 				"error", err.Error(),
 				"btcTxHash", deposit.BtcTxHash,
 				"data", deposit)
-		case strings.Contains(err.Error(), "already known"):
+		case errors.As(err, &revertErr):
+			// contract revert not handled by status, e.g. signer unauthorized
+			bis.log.Errorw("invoke deposit send tx contract revert",
+				"error", err.Error(),
+				"kind", revertErr.Kind,
+				"name", revertErr.Name,
+				"reason", revertErr.Reason,
+				"btcTxHash", deposit.BtcTxHash,
+				"data", deposit)
+			if dbErr := bis.retryDeposit(&deposit, err.Error()); dbErr != nil {
+				return nil, deposit, dbErr
+			}
+			return nil, deposit, err
+		case errors.Is(err, ErrNodeAlreadyKnown):
 			bis.log.Errorw("invoke deposit send tx already known",
 				"error", err.Error(),
 				"btcTxHash", deposit.BtcTxHash,
@@ -317,42 +520,34 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 				deposit.B2TxStatus = model.DepositB2TxStatusIsPending
 				bis.log.Infof("b2 tx hash not empty, set status to ispending")
 			}
-		case strings.Contains(err.Error(), "nonce too low"):
+		case errors.Is(err, ErrNodeNonceTooLow):
 			deposit.B2TxStatus = model.DepositB2TxStatusNonceToLow
 			bis.log.Errorw("invoke deposit send tx nonce to low",
 				"error", err.Error(),
 				"btcTxHash", deposit.BtcTxHash,
 				"data", deposit)
 		default:
-			deposit.B2TxRetry++
-			deposit.B2TxStatus = model.DepositB2TxStatusPending
 			bis.log.Errorw("invoke deposit send tx retry",
 				"error", err.Error(),
 				"btcTxHash", deposit.BtcTxHash,
 				"data", deposit)
-			// The call may not succeed due to network reasons. sleep wait for a while
-			err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-				model.Deposit{}.Column().B2TxStatus: deposit.B2TxStatus,
-				model.Deposit{}.Column().B2TxRetry:  deposit.B2TxRetry,
-			}).Error
-			if err != nil {
-				return err
-			}
-			tryTicker := time.NewTicker(DepositErrTimeout)
-			select {
-			case <-bis.stopChan:
-				return ErrServerStop
-			case <-tryTicker.C:
-				return fmt.Errorf("retry handle deposit")
+			// The call may not succeed due to network reasons, retried after the backoff
+			if dbErr := bis.retryDeposit(&deposit, err.Error()); dbErr != nil {
+				return nil, deposit, dbErr
 			}
+			return nil, deposit, err
 		}
-		dbErr := bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-			model.Deposit{}.Column().B2TxStatus: deposit.B2TxStatus,
-		}).Error
+		dbErr := bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, deposit.B2TxStatus,
+			err.Error(), map[string]interface{}{
+				model.Deposit{}.Column().B2TxRetry: deposit.B2TxRetry,
+			})
 		if dbErr != nil {
-			return dbErr
+			return nil, deposit, dbErr
 		}
-		return err
+		return nil, deposit, err
+	}
+	if err := bis.nonceManager.MarkSent(signer, b2Tx.Nonce(), b2Tx.Hash().String()); err != nil {
+		bis.log.Errorw("mark nonce sent err", "error", err, "nonce", b2Tx.Nonce())
 	}
 	deposit.B2TxStatus = model.DepositB2TxStatusWaitMined
 	deposit.B2TxHash = b2Tx.Hash().String()
@@ -361,15 +556,52 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 	updateFields := map[string]interface{}{
 		model.Deposit{}.Column().B2TxHash:         deposit.B2TxHash,
 		model.Deposit{}.Column().BtcFromAAAddress: deposit.BtcFromAAAddress,
-		model.Deposit{}.Column().B2TxStatus:       deposit.B2TxStatus,
 		model.Deposit{}.Column().B2TxNonce:        deposit.B2TxNonce,
 		model.Deposit{}.Column().B2TxFrom:         fromAddress,
 	}
-	err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(updateFields).Error
+	err = bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, deposit.B2TxStatus,
+		"deposit tx sent", updateFields)
 	if err != nil {
-		return err
+		return nil, deposit, err
 	}
+	return b2Tx, deposit, nil
+}
 
+// SubmitDeposit send deposit tx in the pipeline, the tx is watched in a goroutine until mined
+func (bis *BridgeDepositService) SubmitDeposit(deposit model.Deposit) error {
+	return bis.pipeline.Submit(bis.stopChan, deposit.ID,
+		func() (b2Tx *ethTypes.Transaction, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					bis.log.Errorw("panic err", r)
+					err = fmt.Errorf("send deposit panic: %v", r)
+				}
+			}()
+			b2Tx, deposit, err = bis.sendDeposit(deposit, nil)
+			return b2Tx, err
+		},
+		func(b2Tx *ethTypes.Transaction) {
+			ctx, cancel := context.WithTimeout(context.Background(), WaitMinedTimeout)
+			defer cancel()
+			go func() {
+				select {
+				case <-bis.stopChan:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			bis.log.Infow("invoke deposit send tx success, watch in pipeline",
+				"data", deposit, "inFlight", bis.pipeline.Len())
+			// WaitMined records the result, failed txs are handled by UnconfirmedDeposit
+			if err := bis.WaitMined(ctx, b2Tx, deposit); err != nil {
+				bis.log.Errorw("wait tx mined err", "error", err)
+			}
+		},
+	)
+}
+
+// waitDeposit wait the deposit tx mined
+func (bis *BridgeDepositService) waitDeposit(b2Tx *ethTypes.Transaction, deposit model.Deposit) error {
 	bis.log.Infow("invoke deposit send tx success, wait confirm",
 		"data", deposit)
 
@@ -385,6 +617,7 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 		}
 		errCh <- nil
 	}(ctx1, b2Tx, deposit)
+	var err error
 	waitMinedTicker := time.NewTicker(WaitMinedTimeout + (10 * time.Second))
 	for {
 		bis.log.Warn("wait mined")
@@ -411,24 +644,28 @@ func (bis *BridgeDepositService) HandleDeposit(deposit model.Deposit, oldTx *eth
 //
 //nolint:dupl
 func (bis *BridgeDepositService) HandleUnconfirmedDeposit(deposit model.Deposit) error {
-	resetNonce := false
-	// 1. nonce to low, need reset nonce
-	// 2. change from priv
-	if deposit.B2TxStatus == model.DepositB2TxStatusNonceToLow ||
-		!strings.EqualFold(deposit.B2TxFrom, bis.bridge.FromAddress()) {
-		resetNonce = true
-	}
+	// nonce too low, the nonce manager allocates a new nonce
+	// retired signers replace their txs, txs of unknown signers can not be replaced
+	replaceable := deposit.B2TxStatus != model.DepositB2TxStatusNonceToLow &&
+		bis.bridge.HasSigner(deposit.B2TxFrom)
 	txReceipt, err := bis.bridge.TransactionReceipt(deposit.B2TxHash)
 	if err == nil {
 		// case 1
-		updateFields := map[string]interface{}{}
+		status := model.DepositB2TxStatusWaitMinedStatusFailed
 		if txReceipt.Status == 1 {
-			updateFields[model.Deposit{}.Column().B2TxStatus] = model.DepositB2TxStatusSuccess
-		} else {
-			updateFields[model.Deposit{}.Column().B2TxStatus] = model.DepositB2TxStatusWaitMinedStatusFailed
+			// the deposit failed in the batch deposit tx, send again by single deposit call
+			uuids, err := bis.bridge.DepositEventUUIDs(txReceipt)
+			if err != nil {
+				bis.log.Errorw("parse deposit event err", "error", err, "data", deposit)
+			} else if _, ok := uuids[DepositUUID(deposit)]; !ok {
+				bis.log.Warnw("deposit event not found, send by single deposit", "data", deposit)
+				return bis.HandleDeposit(deposit, nil)
+			}
+			status = model.DepositB2TxStatusSuccess
 		}
 
-		dbErr := bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(updateFields).Error
+		dbErr := bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, status,
+			fmt.Sprintf("unconfirmed deposit tx receipt status %d", txReceipt.Status), nil)
 		if dbErr != nil {
 			return dbErr
 		}
@@ -443,19 +680,66 @@ func (bis *BridgeDepositService) HandleUnconfirmedDeposit(deposit model.Deposit)
 			if errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "not found") {
 				// case 3
 				bis.log.Errorf("TransactionByHash not found, try send tx by nonce")
-				return bis.HandleDeposit(deposit, nil, deposit.B2TxNonce, resetNonce)
+				return bis.HandleDeposit(deposit, nil)
 			}
 			return err
 		}
 		if isPending {
 			// case 2
 			bis.log.Warnw("tx is pending retry", "old", tx, "deposit", deposit)
-			return bis.HandleDeposit(deposit, tx, 0, resetNonce)
+			if !replaceable {
+				return bis.HandleDeposit(deposit, nil)
+			}
+			return bis.HandleDeposit(deposit, tx)
 		}
 	}
 	return err
 }
 
+// HandleReorgedDeposit watch the deposit tx sent before the btc tx is reorged, the tx is not replaced or sent again
+// 1. tx mined, record the result, a minted deposit of the reorged btc tx is a conflict to be reviewed
+// 2. tx pending, keep watching
+// 3. tx not found or the deposit not in the batch deposit tx, set back to pending, sent again if the btc tx is confirmed
+func (bis *BridgeDepositService) HandleReorgedDeposit(deposit model.Deposit) error {
+	txReceipt, err := bis.bridge.TransactionReceipt(deposit.B2TxHash)
+	if err == nil {
+		// case 1
+		status := model.DepositB2TxStatusWaitMinedStatusFailed
+		reason := fmt.Sprintf("btc tx reorged, deposit tx receipt status %d", txReceipt.Status)
+		if txReceipt.Status == 1 {
+			uuids, err := bis.bridge.DepositEventUUIDs(txReceipt)
+			if err != nil {
+				return err
+			}
+			if _, ok := uuids[DepositUUID(deposit)]; !ok {
+				// case 3
+				return bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, model.DepositB2TxStatusPending,
+					"btc tx reorged, deposit event not found in the deposit tx", nil)
+			}
+			status = model.DepositB2TxStatusSuccess
+			reason = "btc tx reorged, deposit tx mined"
+			bis.log.Errorw("reorged btc tx deposited, review the conflict",
+				"btcTxHash", deposit.BtcTxHash, "b2TxHash", deposit.B2TxHash, "deposit", deposit)
+		}
+		return bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, status, reason, nil)
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		return err
+	}
+	_, isPending, err := bis.bridge.TransactionByHash(deposit.B2TxHash)
+	if err == nil {
+		// case 2, the receipt of a just mined tx is checked next time
+		bis.log.Warnw("reorged btc tx deposit tx not confirmed", "isPending", isPending, "deposit", deposit)
+		return nil
+	}
+	if !errors.Is(err, ethereum.NotFound) && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	// case 3
+	return bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, model.DepositB2TxStatusPending,
+		"btc tx reorged, deposit tx not found", nil)
+}
+
 func (bis *BridgeDepositService) HandleEoaTransfer() error {
 	var deposits []model.Deposit
 	err := bis.db.
@@ -480,7 +764,7 @@ func (bis *BridgeDepositService) HandleEoaTransfer() error {
 
 	bis.log.Infow("start handle eoaTransfer deposit", "eoaTransfer deposit batch num", len(deposits))
 	for _, deposit := range deposits {
-		err = bis.EoaTransfer(deposit, nil, 0, false)
+		err = bis.EoaTransfer(deposit, nil)
 		if err != nil {
 			bis.log.Errorw("handle eoaTransfer failed", "error", err, "deposit", deposit)
 			return err
@@ -497,36 +781,53 @@ func (bis *BridgeDepositService) HandleEoaTransfer() error {
 	return nil
 }
 
-func (bis *BridgeDepositService) EoaTransfer(deposit model.Deposit, oldTx *ethTypes.Transaction, nonce uint64, resetNonce bool) error {
+// EoaTransfer send eoa transfer tx, oldTx is replaced if not nil, otherwise the nonce is allocated by the nonce manager
+func (bis *BridgeDepositService) EoaTransfer(deposit model.Deposit, oldTx *ethTypes.Transaction) error {
+	signer := bis.bridge.FromAddress()
+	var nonce uint64
+	if oldTx != nil {
+		// the old tx is replaced by its signer
+		signer = deposit.B2EoaTxFrom
+		nonce = oldTx.Nonce()
+	} else {
+		var err error
+		nonce, err = bis.nonceManager.Allocate(signer, deposit.ID, model.BridgeNonceTxTypeEoaTransfer)
+		if err != nil {
+			return err
+		}
+	}
 	b2EoaTx, fromAddress, err := bis.bridge.Transfer(types.BitcoinFrom{
 		Address: deposit.BtcFrom,
-	}, deposit.BtcValue, oldTx, nonce, resetNonce)
+	}, deposit.BtcMemoAddress, deposit.BtcValue, oldTx, nonce, false)
 	if err != nil {
+		bis.releaseNonce(signer, nonce, oldTx, err)
 		bis.log.Errorw("invoke eoa transfer tx err",
 			"error", err.Error(),
 			"btcTxHash", deposit.BtcTxHash,
 			"data", deposit)
 		switch {
-		case strings.Contains(err.Error(), "nonce too low"):
+		case errors.Is(err, ErrNodeNonceTooLow):
 			deposit.B2EoaTxStatus = model.DepositB2EoaTxStatusNonceToLow
 		default:
 			deposit.B2EoaTxStatus = model.DepositB2EoaTxStatusFailed
 		}
 
-		dbErr := bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-			model.Deposit{}.Column().B2EoaTxStatus: deposit.B2EoaTxStatus,
-		}).Error
+		dbErr := bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2EoaTxStatus, deposit.B2EoaTxStatus,
+			err.Error(), nil)
 		if dbErr != nil {
 			return dbErr
 		}
 		return err
 	}
-	err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-		model.Deposit{}.Column().B2EoaTxHash:   b2EoaTx.Hash().String(),
-		model.Deposit{}.Column().B2EoaTxNonce:  b2EoaTx.Nonce(),
-		model.Deposit{}.Column().B2EoaTxStatus: model.DepositB2EoaTxStatusWaitMined,
-		model.Deposit{}.Column().B2EoaTxFrom:   fromAddress,
-	}).Error
+	if err := bis.nonceManager.MarkSent(signer, b2EoaTx.Nonce(), b2EoaTx.Hash().String()); err != nil {
+		bis.log.Errorw("mark nonce sent err", "error", err, "nonce", b2EoaTx.Nonce())
+	}
+	err = bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2EoaTxStatus, model.DepositB2EoaTxStatusWaitMined,
+		"eoa transfer tx sent", map[string]interface{}{
+			model.Deposit{}.Column().B2EoaTxHash:  b2EoaTx.Hash().String(),
+			model.Deposit{}.Column().B2EoaTxNonce: b2EoaTx.Nonce(),
+			model.Deposit{}.Column().B2EoaTxFrom:  fromAddress,
+		})
 	if err != nil {
 		return err
 	}
@@ -534,7 +835,9 @@ func (bis *BridgeDepositService) EoaTransfer(deposit model.Deposit, oldTx *ethTy
 	ctx2, cancel2 := context.WithTimeout(context.Background(), WaitMinedTimeout)
 	defer cancel2()
 	_, err = bis.bridge.WaitMined(ctx2, b2EoaTx, nil)
+	reason := "eoa transfer tx mined"
 	if err != nil {
+		reason = err.Error()
 		deposit.B2EoaTxStatus = model.DepositB2EoaTxStatusWaitMinedFailed
 		bis.log.Errorw("invoke eoa transfer wait mined err",
 			"error", err.Error(),
@@ -551,18 +854,14 @@ func (bis *BridgeDepositService) EoaTransfer(deposit model.Deposit, oldTx *ethTy
 			"btcTxHash", deposit.BtcTxHash,
 			"data", deposit)
 	}
-	err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-		model.Deposit{}.Column().B2EoaTxStatus: deposit.B2EoaTxStatus,
-	}).Error
-	if err != nil {
-		return err
-	}
-	return nil
+	return bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2EoaTxStatus, deposit.B2EoaTxStatus, reason, nil)
 }
 
 func (bis *BridgeDepositService) WaitMined(ctx1 context.Context, b2Tx *ethTypes.Transaction, deposit model.Deposit) error {
 	b2txReceipt, err := bis.bridge.WaitMined(ctx1, b2Tx, nil)
+	reason := "deposit tx mined"
 	if err != nil {
+		reason = err.Error()
 		switch {
 		case errors.Is(err, ErrBridgeWaitMinedStatus):
 			deposit.B2TxStatus = model.DepositB2TxStatusWaitMinedStatusFailed
@@ -589,9 +888,7 @@ func (bis *BridgeDepositService) WaitMined(ctx1 context.Context, b2Tx *ethTypes.
 	} else {
 		deposit.B2TxStatus = model.DepositB2TxStatusSuccess
 	}
-	err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-		model.Deposit{}.Column().B2TxStatus: deposit.B2TxStatus,
-	}).Error
+	err = bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, deposit.B2TxStatus, reason, nil)
 	if err != nil {
 		return err
 	}
@@ -646,8 +943,8 @@ func (bis *BridgeDepositService) CheckDeposit() {
 				var rollupDeposit model.RollupDeposit
 				err = bis.db.
 					Where(
-						fmt.Sprintf("%s.%s = ?", model.RollupDeposit{}.TableName(), model.Deposit{}.Column().BtcTxHash),
-						deposit.BtcTxHash,
+						fmt.Sprintf("%s.%s = ?", model.RollupDeposit{}.TableName(), model.RollupDeposit{}.Column().BtcTxHash),
+						RollupDepositUUID(deposit),
 					).
 					First(&rollupDeposit).Error
 				if err != nil {
@@ -655,15 +952,18 @@ func (bis *BridgeDepositService) CheckDeposit() {
 					continue
 				}
 				if deposit.B2TxStatus == model.DepositB2TxStatusSuccess {
-					if strings.EqualFold(deposit.BtcFromAAAddress, rollupDeposit.BtcFromAAAddress) &&
-						deposit.BtcValue == rollupDeposit.BtcValue {
+					matched, err := DepositValueMatched(bis.asset, deposit, rollupDeposit)
+					if err != nil {
+						bis.log.Errorw("check deposit value error", "err", err, "deposit", deposit)
+					}
+					reason := "rollup deposit matched"
+					if strings.EqualFold(deposit.BtcFromAAAddress, rollupDeposit.BtcFromAAAddress) && matched {
 						deposit.B2TxCheck = model.B2CheckStatusSuccess
 					} else {
 						deposit.B2TxCheck = model.B2CheckStatusFailed
+						reason = "rollup deposit not matched"
 					}
-					err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-						model.Deposit{}.Column().B2TxCheck: deposit.B2TxCheck,
-					}).Error
+					err = bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxCheck, deposit.B2TxCheck, reason, nil)
 					if err != nil {
 						bis.log.Errorw("update deposit error", "err", err)
 					}
@@ -675,14 +975,20 @@ func (bis *BridgeDepositService) CheckDeposit() {
 						continue
 					}
 					// update tx info from rollup event
-					err = bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(map[string]interface{}{
-						model.Deposit{}.Column().B2TxCheck:        model.B2CheckStatusSuccess,
-						model.Deposit{}.Column().B2TxHash:         rollupDeposit.B2TxHash,
-						model.Deposit{}.Column().BtcFromAAAddress: rollupDeposit.BtcFromAAAddress,
-						model.Deposit{}.Column().B2TxNonce:        tx.Nonce(),
-						model.Deposit{}.Column().B2TxStatus:       model.DepositB2TxStatusSuccess,
-						model.Deposit{}.Column().B2TxFrom:         rollupDeposit.B2TxFrom,
-					}).Error
+					err = model.TransitDeposit(bis.db, deposit.ID, model.DepositTransition{
+						To: map[string]int{
+							model.Deposit{}.Column().B2TxCheck:  model.B2CheckStatusSuccess,
+							model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusSuccess,
+						},
+						Updates: map[string]interface{}{
+							model.Deposit{}.Column().B2TxHash:         rollupDeposit.B2TxHash,
+							model.Deposit{}.Column().BtcFromAAAddress: rollupDeposit.BtcFromAAAddress,
+							model.Deposit{}.Column().B2TxNonce:        tx.Nonce(),
+							model.Deposit{}.Column().B2TxFrom:         rollupDeposit.B2TxFrom,
+						},
+						Actor:  model.DepositActorBridge,
+						Reason: "tx hash exist deposit found by rollup deposit event",
+					})
 					if err != nil {
 						bis.log.Errorw("update deposit error", "err", err)
 					}
@@ -734,22 +1040,18 @@ func (bis *BridgeDepositService) UnconfirmedEoa() error {
 //
 //nolint:dupl
 func (bis *BridgeDepositService) HandleUnconfirmedEoa(deposit model.Deposit) error {
-	resetNonce := false
-	if deposit.B2EoaTxStatus == model.DepositB2EoaTxStatusNonceToLow ||
-		!strings.EqualFold(deposit.B2EoaTxFrom, bis.bridge.FromAddress()) {
-		resetNonce = true
-	}
+	replaceable := deposit.B2EoaTxStatus != model.DepositB2EoaTxStatusNonceToLow &&
+		bis.bridge.HasSigner(deposit.B2EoaTxFrom)
 	txReceipt, err := bis.bridge.TransactionReceipt(deposit.B2EoaTxHash)
 	if err == nil {
 		// case 1
-		updateFields := map[string]interface{}{}
+		status := model.DepositB2EoaTxStatusUnknown
 		if txReceipt.Status == 1 {
-			updateFields[model.Deposit{}.Column().B2EoaTxStatus] = model.DepositB2EoaTxStatusSuccess
-		} else {
-			updateFields[model.Deposit{}.Column().B2EoaTxStatus] = model.DepositB2EoaTxStatusUnknown
+			status = model.DepositB2EoaTxStatusSuccess
 		}
 
-		dbErr := bis.db.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(updateFields).Error
+		dbErr := bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2EoaTxStatus, status,
+			fmt.Sprintf("unconfirmed eoa transfer tx receipt status %d", txReceipt.Status), nil)
 		if dbErr != nil {
 			return dbErr
 		}
@@ -764,15 +1066,64 @@ func (bis *BridgeDepositService) HandleUnconfirmedEoa(deposit model.Deposit) err
 			if errors.Is(err, ethereum.NotFound) || strings.Contains(err.Error(), "not found") {
 				// case 3
 				bis.log.Errorf("eoa TransactionByHash not found, try send tx by nonce")
-				return bis.EoaTransfer(deposit, nil, deposit.B2EoaTxNonce, resetNonce)
+				return bis.EoaTransfer(deposit, nil)
 			}
 			return err
 		}
 		if isPending {
 			// case 2
 			bis.log.Warnw("eoa tx is pending retry", "old", tx, "deposit", deposit)
-			return bis.EoaTransfer(deposit, tx, 0, resetNonce)
+			if !replaceable {
+				return bis.EoaTransfer(deposit, nil)
+			}
+			return bis.EoaTransfer(deposit, tx)
 		}
 	}
 	return err
 }
+
+// transitDeposit change the status column of the deposit by the bridge, updates are saved with the status
+func (bis *BridgeDepositService) transitDeposit(
+	depositID int64,
+	status string,
+	to int,
+	reason string,
+	updates map[string]interface{},
+) error {
+	return model.TransitDeposit(bis.db, depositID, model.DepositTransition{
+		To:      map[string]int{status: to},
+		Updates: updates,
+		Actor:   model.DepositActorBridge,
+		Reason:  reason,
+	})
+}
+
+// releaseNonce release the allocated nonce if the tx is not sent,
+// already known tx is sent, nonce too low nonce is consumed
+func (bis *BridgeDepositService) releaseNonce(signer string, nonce uint64, oldTx *ethTypes.Transaction, err error) {
+	if oldTx != nil ||
+		errors.Is(err, ErrNodeAlreadyKnown) ||
+		errors.Is(err, ErrNodeNonceTooLow) {
+		return
+	}
+	if err := bis.nonceManager.Release(signer, nonce); err != nil {
+		bis.log.Errorw("release nonce err", "error", err, "nonce", nonce)
+	}
+}
+
+// DepositValueMatched whether the deposit value equals the amount of the rollup deposit event,
+// compared in the asset unit, rollup deposits without the event amount are compared in satoshi
+func DepositValueMatched(asset amount.Asset, deposit model.Deposit, rollupDeposit model.RollupDeposit) (bool, error) {
+	if rollupDeposit.B2Value == "" {
+		return deposit.BtcValue == rollupDeposit.BtcValue, nil
+	}
+	eventValue, ok := new(big.Int).SetString(rollupDeposit.B2Value, 10)
+	if !ok {
+		return false, fmt.Errorf("invalid rollup deposit value:%s", rollupDeposit.B2Value)
+	}
+	value, err := asset.ToWei(amount.Sats(deposit.BtcValue))
+	if err != nil {
+		return false, err
+	}
+	return value.Cmp(eventValue) == 0, nil
+}
@@ -0,0 +1,174 @@
+package bitcoin_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/amount"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositValueMatched(t *testing.T) {
+	testCases := []struct {
+		name     string
+		decimals uint
+		deposit  model.Deposit
+		rollup   model.RollupDeposit
+		expected bool
+		err      bool
+	}{
+		{
+			name:     "success: default decimals",
+			deposit:  model.Deposit{BtcValue: 12345},
+			rollup:   model.RollupDeposit{BtcValue: 12345, B2Value: "123450000000000"},
+			expected: true,
+		},
+		{
+			name:     "success: 6 decimals",
+			decimals: 6,
+			deposit:  model.Deposit{BtcValue: 12300},
+			rollup:   model.RollupDeposit{BtcValue: 12300, B2Value: "123"},
+			expected: true,
+		},
+		{
+			name:     "success: fractional satoshi event amount",
+			deposit:  model.Deposit{BtcValue: 12345},
+			rollup:   model.RollupDeposit{B2Value: "123450000000001"},
+			expected: false,
+		},
+		{
+			name:     "success: rollup deposit without event amount",
+			deposit:  model.Deposit{BtcValue: 12345},
+			rollup:   model.RollupDeposit{BtcValue: 12345},
+			expected: true,
+		},
+		{
+			name:     "fail: deposit value precision loss",
+			decimals: 6,
+			deposit:  model.Deposit{BtcValue: 12345},
+			rollup:   model.RollupDeposit{B2Value: "123"},
+			err:      true,
+		},
+		{
+			name:    "fail: invalid event amount",
+			deposit: model.Deposit{BtcValue: 12345},
+			rollup:  model.RollupDeposit{B2Value: "0x10"},
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := amount.NewAsset(tc.decimals)
+			require.NoError(t, err)
+			matched, err := bitcoin.DepositValueMatched(asset, tc.deposit, tc.rollup)
+			if tc.err {
+				require.Error(t, err)
+				require.False(t, matched)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, matched)
+		})
+	}
+}
+
+func TestUnconfirmedReorgedDeposit(t *testing.T) {
+	contract := common.HexToAddress("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	bridge, node := newTestBridge(t, contract)
+	db := newTestDB(t)
+	service := bitcoin.NewBridgeDepositService(bridge, &mockChainIndexer{}, db, log.NewNopLogger())
+
+	newDeposit := func(i int, b2TxHash string) model.Deposit {
+		return model.Deposit{
+			BtcBlockNumber: 100,
+			BtcTxHash:      fmt.Sprintf("%064x", i),
+			BtcFrom:        "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			BtcTo:          "tb1qjda2l5spwyv4ekwe9keddymzuxynea2m2kj0qy",
+			BtcMemoAddress: "0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6",
+			BtcValue:       10000,
+			B2TxHash:       b2TxHash,
+			B2TxStatus:     model.DepositB2TxStatusWaitMined,
+			B2EoaTxStatus:  model.DepositB2EoaTxStatusPending,
+			CallbackStatus: model.CallbackStatusSuccess,
+			ListenerStatus: model.ListenerStatusReorged,
+		}
+	}
+	sendDeposit := func(deposit model.Deposit, nonce uint64) string {
+		tx, _, _, _, err := bridge.Deposit(bitcoin.DepositUUID(deposit).Hex(), types.BitcoinFrom{
+			Address: deposit.BtcFrom,
+		}, deposit.BtcMemoAddress, deposit.BtcValue, nil, nonce, false)
+		require.NoError(t, err)
+		return tx.Hash().String()
+	}
+
+	minted := newDeposit(1, "")
+	minted.B2TxHash = sendDeposit(minted, 0)
+	node.Commit()
+	pending := newDeposit(2, "")
+	pending.B2TxHash = sendDeposit(pending, 1)
+
+	testCases := []struct {
+		name    string
+		deposit model.Deposit
+		status  int
+		reason  string
+	}{
+		{
+			name:    "success: deposit tx mined, conflict recorded",
+			deposit: minted,
+			status:  model.DepositB2TxStatusSuccess,
+			reason:  "btc tx reorged, deposit tx mined",
+		},
+		{
+			name:    "success: deposit tx pending, watched",
+			deposit: pending,
+			status:  model.DepositB2TxStatusWaitMined,
+		},
+		{
+			name:    "success: deposit tx not found, set back to pending",
+			deposit: newDeposit(3, common.HexToHash("0x01").String()),
+			status:  model.DepositB2TxStatusPending,
+			reason:  "btc tx reorged, deposit tx not found",
+		},
+		{
+			name:    "success: deposit event not found, set back to pending",
+			deposit: newDeposit(4, minted.B2TxHash),
+			status:  model.DepositB2TxStatusPending,
+			reason:  "btc tx reorged, deposit event not found in the deposit tx",
+		},
+		{
+			name:    "success: deposit tx not sent, not watched",
+			deposit: newDeposit(5, ""),
+			status:  model.DepositB2TxStatusWaitMined,
+		},
+	}
+	for i := range testCases {
+		require.NoError(t, db.Create(&testCases[i].deposit).Error)
+	}
+
+	require.NoError(t, service.UnconfirmedDeposit())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deposit model.Deposit
+			require.NoError(t, db.First(&deposit, tc.deposit.ID).Error)
+			require.Equal(t, tc.status, deposit.B2TxStatus)
+			require.Equal(t, model.ListenerStatusReorged, deposit.ListenerStatus)
+
+			var history []model.DepositStatusHistory
+			require.NoError(t, db.Where("deposit_id = ?", tc.deposit.ID).Find(&history).Error)
+			if tc.reason == "" {
+				require.Empty(t, history)
+				return
+			}
+			require.Len(t, history, 1)
+			require.Equal(t, model.DepositB2TxStatusWaitMined, history[0].OldState)
+			require.Equal(t, tc.reason, history[0].Reason)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package bitcoin
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	// FeeHistoryBlocks number of recent blocks used to estimate the priority fee
+	FeeHistoryBlocks = 10
+	// FeeHistoryRewardPercentile priority fee percentile of each block
+	FeeHistoryRewardPercentile = 50
+	// BaseFeeMultiple fee cap = base fee * n + tip, the tx stays valid while base fee increases
+	BaseFeeMultiple = 2
+	// DefaultPriceBumpPercent geth txpool min fee bump of replacement txs
+	DefaultPriceBumpPercent = 10
+)
+
+// TxFee gas price of legacy tx, or tip and fee cap of eip-1559 dynamic fee tx
+type TxFee struct {
+	GasPrice  *big.Int
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+}
+
+// IsDynamic whether the fee is eip-1559 dynamic fee
+func (f TxFee) IsDynamic() bool {
+	return f.GasFeeCap != nil
+}
+
+func (f TxFee) callMsg(from common.Address, to *common.Address, value *big.Int, data []byte) ethereum.CallMsg {
+	callMsg := ethereum.CallMsg{
+		From:  from,
+		To:    to,
+		Value: value,
+		Data:  data,
+	}
+	if f.IsDynamic() {
+		callMsg.GasTipCap = f.GasTipCap
+		callMsg.GasFeeCap = f.GasFeeCap
+	} else {
+		callMsg.GasPrice = f.GasPrice
+	}
+	return callMsg
+}
+
+func (f TxFee) txData(chainID *big.Int, nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) types.TxData {
+	if f.IsDynamic() {
+		return &types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce,
+			To:        to,
+			Value:     value,
+			Gas:       gas,
+			GasTipCap: f.GasTipCap,
+			GasFeeCap: f.GasFeeCap,
+			Data:      data,
+		}
+	}
+	return &types.LegacyTx{
+		Nonce:    nonce,
+		To:       to,
+		Value:    value,
+		Gas:      gas,
+		GasPrice: f.GasPrice,
+		Data:     data,
+	}
+}
+
+// suggestFee dynamic fee if enabled and the chain supports london, otherwise legacy gas price
+func (b *Bridge) suggestFee(ctx context.Context, client *ethclient.Client) (TxFee, error) {
+	if !b.enableLegacyTx {
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return TxFee{}, err
+		}
+		if header.BaseFee != nil {
+			tip, err := suggestGasTipCap(ctx, client)
+			if err != nil {
+				return TxFee{}, err
+			}
+			feeCap := new(big.Int).Mul(header.BaseFee, big.NewInt(BaseFeeMultiple))
+			feeCap.Add(feeCap, tip)
+			b.logger.Infow("suggest dynamic fee", "baseFee", header.BaseFee, "tip", tip, "feeCap", feeCap)
+			return TxFee{GasTipCap: tip, GasFeeCap: feeCap}, nil
+		}
+		b.logger.Warnw("latest block without base fee, use legacy tx", "block", header.Number)
+	}
+	gasPrice, err := b.legacyGasPrice(ctx, client)
+	if err != nil {
+		return TxFee{}, err
+	}
+	return TxFee{GasPrice: gasPrice}, nil
+}
+
+// legacyGasPrice first use b2 explorer stats gas price, if fail, use base gas price * multiple
+func (b *Bridge) legacyGasPrice(ctx context.Context, client *ethclient.Client) (*big.Int, error) {
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// TODO: temp fix
+	newGasPrice, err := b.gasPrices()
+	if err != nil {
+		b.logger.Errorf("get price err:%v", err.Error())
+		if b.BaseGasPriceMultiple != 0 {
+			gasPrice.Mul(gasPrice, big.NewInt(b.BaseGasPriceMultiple))
+		}
+	} else {
+		if newGasPrice.Cmp(big.NewInt(0)) == 0 {
+			if b.BaseGasPriceMultiple != 0 {
+				gasPrice.Mul(gasPrice, big.NewInt(b.BaseGasPriceMultiple))
+			}
+		} else {
+			gasPrice = newGasPrice
+		}
+	}
+	return gasPrice, nil
+}
+
+// suggestGasTipCap median of recent blocks priority fee percentile, eth_maxPriorityFeePerGas if no history
+func suggestGasTipCap(ctx context.Context, client *ethclient.Client) (*big.Int, error) {
+	history, err := client.FeeHistory(ctx, FeeHistoryBlocks, nil, []float64{FeeHistoryRewardPercentile})
+	if err != nil {
+		return nil, err
+	}
+	if tip := FeeHistoryTip(history); tip != nil {
+		return tip, nil
+	}
+	return client.SuggestGasTipCap(ctx)
+}
+
+// FeeHistoryTip median of the first reward percentile of each block, nil if no reward,
+// empty blocks report zero reward and are skipped, a zero tip is rejected as underpriced
+func FeeHistoryTip(history *ethereum.FeeHistory) *big.Int {
+	if history == nil {
+		return nil
+	}
+	rewards := make([]*big.Int, 0, len(history.Reward))
+	for _, v := range history.Reward {
+		if len(v) == 0 || v[0] == nil || v[0].Sign() == 0 {
+			continue
+		}
+		rewards = append(rewards, v[0])
+	}
+	if len(rewards) == 0 {
+		return nil
+	}
+	sort.Slice(rewards, func(i, j int) bool {
+		return rewards[i].Cmp(rewards[j]) < 0
+	})
+	return new(big.Int).Set(rewards[len(rewards)/2])
+}
+
+// BumpFee fee of the replacement tx, the node requires both tip and fee cap raised by the bump percent,
+// tip and fee cap of legacy tx are the gas price. the suggested fee is used if higher
+func BumpFee(oldTx *types.Transaction, suggested TxFee, bumpPercent int64) TxFee {
+	if bumpPercent <= 0 {
+		bumpPercent = DefaultPriceBumpPercent
+	}
+	minTip := bumpPrice(oldTx.GasTipCap(), bumpPercent)
+	minFeeCap := bumpPrice(oldTx.GasFeeCap(), bumpPercent)
+	if !suggested.IsDynamic() {
+		return TxFee{GasPrice: maxPrice(suggested.GasPrice, maxPrice(minTip, minFeeCap))}
+	}
+	tip := maxPrice(suggested.GasTipCap, minTip)
+	feeCap := maxPrice(suggested.GasFeeCap, minFeeCap)
+	// fee cap can not be lower than tip
+	feeCap = maxPrice(feeCap, tip)
+	return TxFee{GasTipCap: tip, GasFeeCap: feeCap}
+}
+
+// bumpPrice price * (100 + percent) / 100, rounded up
+func bumpPrice(price *big.Int, percent int64) *big.Int {
+	bumped := new(big.Int).Mul(price, big.NewInt(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxPrice(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return new(big.Int).Set(a)
+	}
+	return new(big.Int).Set(b)
+}
@@ -0,0 +1,122 @@
+package bitcoin_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeHistoryTip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		history *ethereum.FeeHistory
+		tip     *big.Int
+	}{
+		{
+			name:    "success: nil history",
+			history: nil,
+			tip:     nil,
+		},
+		{
+			name:    "success: empty blocks",
+			history: &ethereum.FeeHistory{Reward: [][]*big.Int{{}, {}}},
+			tip:     nil,
+		},
+		{
+			name:    "success: zero reward of empty blocks",
+			history: &ethereum.FeeHistory{Reward: [][]*big.Int{{big.NewInt(0)}, {big.NewInt(0)}}},
+			tip:     nil,
+		},
+		{
+			name: "success: median skip empty blocks",
+			history: &ethereum.FeeHistory{Reward: [][]*big.Int{
+				{big.NewInt(0)},
+				{big.NewInt(300)},
+				{big.NewInt(0)},
+				{big.NewInt(100)},
+			}},
+			tip: big.NewInt(300),
+		},
+		{
+			name: "success: median",
+			history: &ethereum.FeeHistory{Reward: [][]*big.Int{
+				{big.NewInt(300)},
+				{big.NewInt(100)},
+				{},
+				{big.NewInt(200)},
+			}},
+			tip: big.NewInt(200),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.tip, bitcoin.FeeHistoryTip(tc.history))
+		})
+	}
+}
+
+func TestBumpFee(t *testing.T) {
+	to := common.HexToAddress("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	legacyTx := types.NewTx(&types.LegacyTx{
+		To:       &to,
+		GasPrice: big.NewInt(1000),
+	})
+	dynamicTx := types.NewTx(&types.DynamicFeeTx{
+		To:        &to,
+		GasTipCap: big.NewInt(100),
+		GasFeeCap: big.NewInt(1001),
+	})
+
+	testCases := []struct {
+		name        string
+		oldTx       *types.Transaction
+		suggested   bitcoin.TxFee
+		bumpPercent int64
+		fee         bitcoin.TxFee
+	}{
+		{
+			name:      "success: legacy default bump",
+			oldTx:     legacyTx,
+			suggested: bitcoin.TxFee{GasPrice: big.NewInt(900)},
+			fee:       bitcoin.TxFee{GasPrice: big.NewInt(1100)},
+		},
+		{
+			name:      "success: legacy suggested higher",
+			oldTx:     legacyTx,
+			suggested: bitcoin.TxFee{GasPrice: big.NewInt(2000)},
+			fee:       bitcoin.TxFee{GasPrice: big.NewInt(2000)},
+		},
+		{
+			name:        "success: dynamic bump rounded up",
+			oldTx:       dynamicTx,
+			suggested:   bitcoin.TxFee{GasTipCap: big.NewInt(50), GasFeeCap: big.NewInt(500)},
+			bumpPercent: 25,
+			fee:         bitcoin.TxFee{GasTipCap: big.NewInt(125), GasFeeCap: big.NewInt(1252)},
+		},
+		{
+			name:      "success: dynamic replace legacy",
+			oldTx:     legacyTx,
+			suggested: bitcoin.TxFee{GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(5000)},
+			fee:       bitcoin.TxFee{GasTipCap: big.NewInt(1100), GasFeeCap: big.NewInt(5000)},
+		},
+		{
+			name:      "success: legacy replace dynamic",
+			oldTx:     dynamicTx,
+			suggested: bitcoin.TxFee{GasPrice: big.NewInt(900)},
+			fee:       bitcoin.TxFee{GasPrice: big.NewInt(1102)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fee := bitcoin.BumpFee(tc.oldTx, tc.suggested, tc.bumpPercent)
+			require.Equal(t, tc.fee, fee)
+		})
+	}
+}
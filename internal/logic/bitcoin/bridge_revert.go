@@ -0,0 +1,168 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// RevertKindError require/revert with reason, Error(string)
+	RevertKindError = "error"
+	// RevertKindPanic assert failure, Panic(uint256)
+	RevertKindPanic = "panic"
+	// RevertKindCustom custom error of the contract abi
+	RevertKindCustom = "custom"
+	// RevertKindUnknown revert data not in the contract abi
+	RevertKindUnknown = "unknown"
+)
+
+var (
+	ErrBridgeUnauthorized           = errors.New("bridge signer unauthorized")
+	ErrBridgeContractNotInitialized = errors.New("bridge contract not initialized")
+	ErrBridgeContractPanic          = errors.New("bridge contract panic")
+	ErrBridgeContractRevert         = errors.New("bridge contract revert")
+)
+
+var (
+	revertErrorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	revertPanicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// revertReasons maps Error(string) reasons of the deposit contract to bridge errors
+var revertReasons = map[string]error{
+	"non-repeatable processing": ErrBridgeDepositTxHashExist,
+	"insufficient balance":      ErrBridgeDepositContractInsufficientBalance,
+}
+
+// customErrors maps custom errors of the deposit contract to bridge errors
+var customErrors = map[string]error{
+	"AccessControlUnauthorizedAccount": ErrBridgeUnauthorized,
+	"AccessControlBadConfirmation":     ErrBridgeUnauthorized,
+	"InvalidInitialization":            ErrBridgeContractNotInitialized,
+	"NotInitializing":                  ErrBridgeContractNotInitialized,
+}
+
+// RevertError decoded contract revert, unwraps to the mapped bridge error
+type RevertError struct {
+	// Kind RevertKindError, RevertKindPanic, RevertKindCustom or RevertKindUnknown
+	Kind string
+	// Name custom error name
+	Name string
+	// Reason Error(string) reason
+	Reason string
+	// Code Panic(uint256) code
+	Code *big.Int
+	// Args custom error args
+	Args []interface{}
+	// Data raw revert data
+	Data []byte
+
+	err error
+}
+
+func (e *RevertError) Error() string {
+	switch e.Kind {
+	case RevertKindError:
+		return fmt.Sprintf("%s: revert reason: %s", e.err, e.Reason)
+	case RevertKindPanic:
+		return fmt.Sprintf("%s: panic code: 0x%x", e.err, e.Code)
+	case RevertKindCustom:
+		return fmt.Sprintf("%s: custom error: %s%v", e.err, e.Name, e.Args)
+	default:
+		return fmt.Sprintf("%s: data: %s", e.err, hexutil.Encode(e.Data))
+	}
+}
+
+func (e *RevertError) Unwrap() error {
+	return e.err
+}
+
+// DecodeRevert decode revert data with the contract abi,
+// Error(string), Panic(uint256) and custom errors of the abi are supported
+func DecodeRevert(contractAbi abi.ABI, data []byte) (*RevertError, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("revert data too short:%d", len(data))
+	}
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, revertErrorSelector):
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return nil, err
+		}
+		mapped, ok := revertReasons[reason]
+		if !ok {
+			mapped = ErrBridgeContractRevert
+		}
+		return &RevertError{Kind: RevertKindError, Reason: reason, Data: data, err: mapped}, nil
+	case bytes.Equal(selector, revertPanicSelector):
+		if len(data) != 4+32 {
+			return nil, fmt.Errorf("invalid panic data:%s", hexutil.Encode(data))
+		}
+		code := new(big.Int).SetBytes(data[4:])
+		return &RevertError{Kind: RevertKindPanic, Code: code, Data: data, err: ErrBridgeContractPanic}, nil
+	}
+	var id [4]byte
+	copy(id[:], selector)
+	abiErr, err := contractAbi.ErrorByID(id)
+	if err != nil {
+		return &RevertError{Kind: RevertKindUnknown, Data: data, err: ErrBridgeContractRevert}, nil
+	}
+	args, err := abiErr.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("unpack custom error %s args:%w", abiErr.Name, err)
+	}
+	mapped, ok := customErrors[abiErr.Name]
+	if !ok {
+		mapped = ErrBridgeContractRevert
+	}
+	return &RevertError{Kind: RevertKindCustom, Name: abiErr.Name, Args: args, Data: data, err: mapped}, nil
+}
+
+// RevertData returns the revert data of the eth_call or eth_estimateGas rpc err, nil if not found
+func RevertData(err error) ([]byte, error) {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, nil
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok || data == "" {
+		return nil, nil
+	}
+	decoded, decodeErr := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if decodeErr != nil {
+		return nil, fmt.Errorf("revert data not hex:%s", data)
+	}
+	return decoded, nil
+}
+
+// CallError classify the eth_call or eth_estimateGas err, contract reverts are decoded by the abi,
+// nodes without revert data only report the Error(string) reason, other errs are mapped by NodeError
+func CallError(contractAbi abi.ABI, err error) error {
+	if err == nil {
+		return nil
+	}
+	data, dataErr := RevertData(err)
+	if dataErr == nil && len(data) > 0 {
+		revertErr, decodeErr := DecodeRevert(contractAbi, data)
+		if decodeErr == nil {
+			return revertErr
+		}
+	}
+	err = NodeError(err)
+	if reason, ok := nodeRevertReason(err); ok {
+		if mapped, ok := revertReasons[reason]; ok {
+			return &RevertError{Kind: RevertKindError, Reason: reason, err: mapped}
+		}
+	}
+	return err
+}
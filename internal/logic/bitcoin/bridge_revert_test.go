@@ -0,0 +1,255 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcDataError json rpc error with revert data
+type rpcDataError struct {
+	message string
+	data    interface{}
+}
+
+func (e *rpcDataError) Error() string          { return e.message }
+func (e *rpcDataError) ErrorCode() int         { return 3 }
+func (e *rpcDataError) ErrorData() interface{} { return e.data }
+
+// rpcError json rpc error without data
+type rpcError struct {
+	code    int
+	message string
+}
+
+func (e *rpcError) Error() string  { return e.message }
+func (e *rpcError) ErrorCode() int { return e.code }
+
+func revertReason(t *testing.T, reason string) []byte {
+	stringType, err := abi.NewType("string", "", nil)
+	require.NoError(t, err)
+	data, err := abi.Arguments{{Type: stringType}}.Pack(reason)
+	require.NoError(t, err)
+	return append(crypto.Keccak256([]byte("Error(string)"))[:4], data...)
+}
+
+func TestDecodeRevert(t *testing.T) {
+	contractAbi, err := abi.JSON(strings.NewReader(config.DefaultDepositAbi))
+	require.NoError(t, err)
+	account := common.HexToAddress("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	role := common.HexToHash("0x01")
+	unauthorized, err := contractAbi.Errors["AccessControlUnauthorizedAccount"].Inputs.Pack(account, role)
+	require.NoError(t, err)
+	unauthorized = append(contractAbi.Errors["AccessControlUnauthorizedAccount"].ID.Bytes()[:4], unauthorized...)
+	panicData := append(crypto.Keccak256([]byte("Panic(uint256)"))[:4], common.LeftPadBytes([]byte{0x11}, 32)...)
+
+	testCases := []struct {
+		name   string
+		data   []byte
+		kind   string
+		target error
+		check  func(t *testing.T, revertErr *bitcoin.RevertError)
+		err    bool
+	}{
+		{
+			name:   "success: tx hash exist reason",
+			data:   revertReason(t, "non-repeatable processing"),
+			kind:   bitcoin.RevertKindError,
+			target: bitcoin.ErrBridgeDepositTxHashExist,
+			check: func(t *testing.T, revertErr *bitcoin.RevertError) {
+				require.Equal(t, "non-repeatable processing", revertErr.Reason)
+			},
+		},
+		{
+			name:   "success: insufficient balance reason",
+			data:   revertReason(t, "insufficient balance"),
+			kind:   bitcoin.RevertKindError,
+			target: bitcoin.ErrBridgeDepositContractInsufficientBalance,
+		},
+		{
+			name:   "success: unmapped reason",
+			data:   revertReason(t, "paused"),
+			kind:   bitcoin.RevertKindError,
+			target: bitcoin.ErrBridgeContractRevert,
+		},
+		{
+			name:   "success: panic",
+			data:   panicData,
+			kind:   bitcoin.RevertKindPanic,
+			target: bitcoin.ErrBridgeContractPanic,
+			check: func(t *testing.T, revertErr *bitcoin.RevertError) {
+				require.Equal(t, big.NewInt(0x11), revertErr.Code)
+			},
+		},
+		{
+			name:   "success: custom error",
+			data:   unauthorized,
+			kind:   bitcoin.RevertKindCustom,
+			target: bitcoin.ErrBridgeUnauthorized,
+			check: func(t *testing.T, revertErr *bitcoin.RevertError) {
+				require.Equal(t, "AccessControlUnauthorizedAccount", revertErr.Name)
+				require.Equal(t, []interface{}{account, [32]byte(role)}, revertErr.Args)
+			},
+		},
+		{
+			name:   "success: unknown selector",
+			data:   hexutil.MustDecode("0xdeadbeef"),
+			kind:   bitcoin.RevertKindUnknown,
+			target: bitcoin.ErrBridgeContractRevert,
+		},
+		{
+			name: "fail: data too short",
+			data: hexutil.MustDecode("0xdead"),
+			err:  true,
+		},
+		{
+			name: "fail: invalid panic data",
+			data: panicData[:20],
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			revertErr, err := bitcoin.DecodeRevert(contractAbi, tc.data)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.kind, revertErr.Kind)
+			require.ErrorIs(t, revertErr, tc.target)
+			if tc.check != nil {
+				tc.check(t, revertErr)
+			}
+		})
+	}
+}
+
+func TestCallError(t *testing.T) {
+	contractAbi, err := abi.JSON(strings.NewReader(config.DefaultDepositAbi))
+	require.NoError(t, err)
+	nodeErr := errors.New("connection refused")
+
+	testCases := []struct {
+		name   string
+		err    error
+		target error
+		revert bool
+	}{
+		{
+			name: "success: nil",
+		},
+		{
+			name: "success: revert data",
+			err: fmt.Errorf("estimate gas: %w", &rpcDataError{
+				message: "execution reverted",
+				data:    hexutil.Encode(revertReason(t, "non-repeatable processing")),
+			}),
+			target: bitcoin.ErrBridgeDepositTxHashExist,
+			revert: true,
+		},
+		{
+			name:   "success: revert reason message without data",
+			err:    &rpcError{code: 3, message: "execution reverted: insufficient balance"},
+			target: bitcoin.ErrBridgeDepositContractInsufficientBalance,
+			revert: true,
+		},
+		{
+			name:   "success: gas insufficient",
+			err:    &rpcError{code: -32000, message: "gas required exceeds allowance (0)"},
+			target: bitcoin.ErrBridgeFromGasInsufficient,
+		},
+		{
+			name:   "success: invalid revert data",
+			err:    &rpcDataError{message: "execution reverted", data: "0xzz"},
+			target: nil,
+		},
+		{
+			name:   "success: node err",
+			err:    nodeErr,
+			target: nodeErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := bitcoin.CallError(contractAbi, tc.err)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			if tc.target != nil {
+				require.ErrorIs(t, err, tc.target)
+			}
+			var revertErr *bitcoin.RevertError
+			require.Equal(t, tc.revert, errors.As(err, &revertErr))
+		})
+	}
+}
+
+func TestNodeError(t *testing.T) {
+	nodeErr := errors.New("nonce too low")
+
+	testCases := []struct {
+		name   string
+		err    error
+		target error
+	}{
+		{
+			name: "success: nil",
+		},
+		{
+			name:   "success: already known",
+			err:    fmt.Errorf("send tx: %w", &rpcError{code: -32000, message: "already known"}),
+			target: bitcoin.ErrNodeAlreadyKnown,
+		},
+		{
+			name:   "success: nonce too low",
+			err:    &rpcError{code: -32000, message: "nonce too low: address 0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2, tx: 1 state: 2"},
+			target: bitcoin.ErrNodeNonceTooLow,
+		},
+		{
+			name:   "success: replacement underpriced",
+			err:    &rpcError{code: -32000, message: "replacement transaction underpriced"},
+			target: bitcoin.ErrNodeReplaceUnderpriced,
+		},
+		{
+			name:   "success: execution reverted",
+			err:    &rpcError{code: 3, message: "execution reverted: insufficient balance"},
+			target: vm.ErrExecutionReverted,
+		},
+		{
+			name: "fail: not a json rpc error",
+			err:  nodeErr,
+		},
+		{
+			name: "fail: unknown json rpc error",
+			err:  &rpcError{code: -32000, message: "intrinsic gas too low"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := bitcoin.NodeError(tc.err)
+			require.ErrorIs(t, err, tc.err)
+			if tc.target == nil {
+				require.Equal(t, tc.err, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.target)
+		})
+	}
+}
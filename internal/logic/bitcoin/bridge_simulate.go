@@ -0,0 +1,90 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	b2types "github.com/b2network/b2-indexer/internal/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// SimulateDeposit build the deposit tx of the active signer without signing and simulate it by eth_call,
+// the contract revert is returned as the simulation err, other errs are returned and may be retried,
+// hash is the contract deposit uuid
+func (b *Bridge) SimulateDeposit(
+	hash string,
+	bitcoinAddress b2types.BitcoinFrom,
+	evmAddress string,
+	amount int64,
+) (*b2types.DepositSimulation, error) {
+	if bitcoinAddress.Address == "" {
+		return nil, fmt.Errorf("bitcoin address is empty")
+	}
+
+	if hash == "" {
+		return nil, fmt.Errorf("tx id is empty")
+	}
+
+	ctx := context.Background()
+
+	toAddress, err := b.toEthAddress(bitcoinAddress, evmAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.ABIPack(b.ABI, "deposit", common.HexToHash(hash), common.HexToAddress(toAddress), new(big.Int).SetInt64(amount))
+	if err != nil {
+		return nil, fmt.Errorf("abi pack err:%w", err)
+	}
+
+	client := b.client
+	fromAddress := b.activeSigner().Address()
+	simulation := &b2types.DepositSimulation{
+		ToAddress: toAddress,
+		From:      fromAddress.String(),
+	}
+	value := new(big.Int).SetInt64(0)
+	// fee-less call, the signer gas balance does not change the result
+	callMsg := ethereum.CallMsg{
+		From:  fromAddress,
+		To:    &b.ContractAddress,
+		Value: value,
+		Data:  data,
+	}
+	var gas uint64
+	_, err = client.CallContract(ctx, callMsg, nil)
+	if err != nil {
+		callErr := b.callError(err)
+		var revertErr *RevertError
+		if !errors.As(callErr, &revertErr) && !errors.Is(callErr, vm.ErrExecutionReverted) {
+			return nil, callErr
+		}
+		simulation.Err = callErr
+	} else {
+		gas, err = client.EstimateGas(ctx, callMsg)
+		if err != nil {
+			return nil, b.callError(err)
+		}
+		gas *= 2
+	}
+
+	fee, err := b.suggestFee(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := client.PendingNonceAt(ctx, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	simulation.Tx = types.NewTx(fee.txData(chainID, nonce, &b.ContractAddress, value, gas, data))
+	return simulation, nil
+}
@@ -0,0 +1,148 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// testEthService eth json rpc stand-in of deposit simulation, raw txs are recorded
+type testEthService struct {
+	callErr error
+	sent    int
+}
+
+func (s *testEthService) Call(_ map[string]interface{}, _ string) (hexutil.Bytes, error) {
+	if s.callErr != nil {
+		return nil, s.callErr
+	}
+	return hexutil.Bytes{}, nil
+}
+
+func (s *testEthService) EstimateGas(_ map[string]interface{}) (hexutil.Uint64, error) {
+	return 60000, nil
+}
+
+func (s *testEthService) GasPrice() (*hexutil.Big, error) {
+	return (*hexutil.Big)(big.NewInt(1000)), nil
+}
+
+func (s *testEthService) ChainId() (*hexutil.Big, error) { //nolint:revive,stylecheck
+	return (*hexutil.Big)(big.NewInt(1102)), nil
+}
+
+func (s *testEthService) GetTransactionCount(_ common.Address, _ string) (hexutil.Uint64, error) {
+	return 7, nil
+}
+
+func (s *testEthService) SendRawTransaction(_ hexutil.Bytes) (common.Hash, error) {
+	s.sent++
+	return common.Hash{}, nil
+}
+
+func newTestSimulateBridge(t *testing.T, eth *testEthService) *bitcoin.Bridge {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", eth))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	bridge, err := bitcoin.NewBridge(config.BridgeConfig{
+		EthRPCURL:           httpServer.URL,
+		ContractAddress:     "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2",
+		EthPrivKey:          "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		ABI:                 "not_found.json",
+		EnableLegacyTx:      true,
+		AAParticleRPC:       "http://localhost:8545",
+		AAParticleProjectID: "1111",
+		AAParticleChainID:   1102,
+	}, "./testdata", log.NewNopLogger(), &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+	return bridge
+}
+
+func TestSimulateDeposit(t *testing.T) {
+	btcTxHash := "6a9a9e8a4a3f3c6f3e7fbd4d2c5dfe5b0bb8a2f7cd4ad9f04a5bb9f1f2a3b4c5"
+	memoAddress := "0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6"
+	from := types.BitcoinFrom{Address: "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz"}
+
+	testCases := []struct {
+		name       string
+		callErr    error
+		evmAddress string
+		revert     error
+		gas        uint64
+		err        bool
+	}{
+		{
+			name:       "success: deposit would succeed",
+			evmAddress: memoAddress,
+			gas:        120000,
+		},
+		{
+			name: "success: deposit would revert",
+			callErr: &rpcDataError{
+				message: "execution reverted: non-repeatable processing",
+				data:    hexutil.Encode(revertReason(t, "non-repeatable processing")),
+			},
+			evmAddress: memoAddress,
+			revert:     bitcoin.ErrBridgeDepositTxHashExist,
+		},
+		{
+			name:       "fail: node error",
+			callErr:    errors.New("header not found"),
+			evmAddress: memoAddress,
+			err:        true,
+		},
+		{
+			name:       "fail: invalid evm address",
+			evmAddress: "0x1234",
+			err:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eth := &testEthService{callErr: tc.callErr}
+			bridge := newTestSimulateBridge(t, eth)
+			simulation, err := bridge.SimulateDeposit(btcTxHash, from, tc.evmAddress, 10000)
+			require.Zero(t, eth.sent)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.revert != nil {
+				require.ErrorIs(t, simulation.Err, tc.revert)
+			} else {
+				require.NoError(t, simulation.Err)
+			}
+			require.Equal(t, common.HexToAddress(tc.evmAddress).Hex(), simulation.ToAddress)
+			require.Equal(t, bridge.FromAddress(), simulation.From)
+
+			expected, err := bridge.ABIPack(bridge.ABI, "deposit",
+				common.HexToHash(btcTxHash), common.HexToAddress(tc.evmAddress), big.NewInt(10000))
+			require.NoError(t, err)
+			tx := simulation.Tx
+			require.Equal(t, expected, tx.Data())
+			require.Equal(t, bridge.ContractAddress, *tx.To())
+			require.Equal(t, uint64(7), tx.Nonce())
+			require.Equal(t, tc.gas, tx.Gas())
+			// unsigned
+			v, r, s := tx.RawSignatureValues()
+			require.Zero(t, v.Sign()+r.Sign()+s.Sign())
+		})
+	}
+}
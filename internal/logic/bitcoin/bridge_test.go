@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -62,10 +63,47 @@ func TestNewBridge(t *testing.T) {
 	assert.NotNil(t, bridge)
 	assert.Equal(t, bridgeCfg.EthRPCURL, bridge.EthRPCURL)
 	assert.Equal(t, common.HexToAddress("0x123456789abcdef"), bridge.ContractAddress)
-	assert.Equal(t, privateKey, bridge.EthPrivKey)
+	assert.Equal(t, bitcoin.NewLocalSigner(privateKey), bridge.Signer)
 	assert.Equal(t, ABI, bridge.ABI)
 }
 
+func TestNewBridgeSigners(t *testing.T) {
+	keys := []string{
+		"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"0x1123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"2123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	addresses := make([]string, 0, len(keys))
+	for _, v := range keys {
+		privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(v, "0x"))
+		require.NoError(t, err)
+		addresses = append(addresses, crypto.PubkeyToAddress(privateKey.PublicKey).String())
+	}
+	bridgeCfg := config.BridgeConfig{
+		EthRPCURL:       "http://localhost:8545",
+		ContractAddress: "0x123456789abcdef",
+		EthPrivKey:      keys[0],
+		EthPrivKeys:     keys[1:],
+		ActiveSigner:    addresses[2],
+		AAParticleRPC:   "http://localhost:8545",
+	}
+
+	bridge, err := bitcoin.NewBridge(bridgeCfg, "./testdata", log.NewNopLogger(), &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+	require.Equal(t, addresses, bridge.SignerAddresses())
+	require.Equal(t, addresses[2], bridge.FromAddress())
+	require.Equal(t, addresses[2], bridge.RotateSigner())
+	for _, v := range addresses {
+		require.True(t, bridge.HasSigner(v))
+	}
+	require.False(t, bridge.HasSigner("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2"))
+	require.False(t, bridge.HasSigner(""))
+
+	bridgeCfg.ActiveSigner = "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2"
+	_, err = bitcoin.NewBridge(bridgeCfg, "./testdata", log.NewNopLogger(), &chaincfg.TestNet3Params)
+	require.ErrorIs(t, err, bitcoin.ErrSignerNotFound)
+}
+
 // TestLocalTransfer only test in local
 func TestLocalTransfer(t *testing.T) {
 	bridge := bridgeWithConfig(t)
@@ -96,7 +134,7 @@ func TestLocalTransfer(t *testing.T) {
 
 	for _, tc := range testCase {
 		t.Run(tc.name, func(t *testing.T) {
-			hex, _, err := bridge.Transfer(tc.args[0].(b2types.BitcoinFrom), tc.args[1].(int64), nil, 0, false)
+			hex, _, err := bridge.Transfer(tc.args[0].(b2types.BitcoinFrom), "", tc.args[1].(int64), nil, 0, true)
 			if err != nil {
 				assert.Equal(t, tc.err, err)
 			}
@@ -137,7 +175,7 @@ func TestLocalBitcoinAddressToEthAddress(t *testing.T) {
 				return
 			}
 			if !common.IsHexAddress(ethAddress) {
-				t.Errorf("bitcoinAddress: %s, ethAddress: %s", tc.bitcoinAddress, ethAddress)
+				t.Errorf("bitcoinAddress: %s, ethAddress: %s", tc.bitcoinAddress.Address, ethAddress)
 			}
 		})
 	}
@@ -204,17 +242,17 @@ func TestLocalDepositWaitMined(t *testing.T) {
 	bigValue := 11111111111111111
 
 	// params check
-	_, _, _, _, err := bridge.Deposit("", address, int64(value), nil, 0, false)
+	_, _, _, _, err := bridge.Deposit("", address, "", int64(value), nil, 0, true)
 	if err != nil {
 		assert.EqualError(t, errors.New("tx id is empty"), err.Error())
 	}
-	_, _, _, _, err = bridge.Deposit(uuid, b2types.BitcoinFrom{}, int64(value), nil, 0, false)
+	_, _, _, _, err = bridge.Deposit(uuid, b2types.BitcoinFrom{}, "", int64(value), nil, 0, true)
 	if err != nil {
 		assert.EqualError(t, errors.New("bitcoin address is empty"), err.Error())
 	}
 
 	// normal
-	b2Tx, _, _, _, err := bridge.Deposit(uuid, address, int64(value), nil, 0, false)
+	b2Tx, _, _, _, err := bridge.Deposit(uuid, address, "", int64(value), nil, 0, true)
 	if err != nil {
 		assert.NoError(t, err)
 	}
@@ -224,13 +262,13 @@ func TestLocalDepositWaitMined(t *testing.T) {
 	}
 
 	// uuid check
-	_, _, _, _, err = bridge.Deposit(uuid, address, int64(value), nil, 0, false)
+	_, _, _, _, err = bridge.Deposit(uuid, address, "", int64(value), nil, 0, true)
 	if err != nil {
 		assert.EqualError(t, bitcoin.ErrBridgeDepositTxHashExist, err.Error())
 	}
 
 	// insufficient balance
-	_, _, _, _, err = bridge.Deposit(randHash(t), address, int64(bigValue), nil, 0, false)
+	_, _, _, _, err = bridge.Deposit(randHash(t), address, "", int64(bigValue), nil, 0, true)
 	if err != nil {
 		assert.EqualError(t, bitcoin.ErrBridgeDepositContractInsufficientBalance, err.Error())
 	} else {
@@ -238,7 +276,7 @@ func TestLocalDepositWaitMined(t *testing.T) {
 	}
 
 	// context timeout
-	b2Tx2, _, _, _, err := bridge.Deposit(randHash(t), address, int64(value), nil, 0, false)
+	b2Tx2, _, _, _, err := bridge.Deposit(randHash(t), address, "", int64(value), nil, 0, true)
 	if err != nil {
 		assert.NoError(t, err)
 	}
@@ -0,0 +1,257 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// classified tx type
+	TxTypeInscription   = "inscription"    // ordinal inscription reveal
+	TxTypeBrc20Transfer = "brc20_transfer" // brc-20 transfer inscription reveal
+	TxTypeRunes         = "runes"          // runestone in op_return
+)
+
+// BtcTxTypes tx type to deposit_history.btc_tx_type
+var BtcTxTypes = map[string]int{
+	TxTypeTransfer:      model.BtcTxTypeTransfer,
+	TxTypeInscription:   model.BtcTxTypeInscription,
+	TxTypeBrc20Transfer: model.BtcTxTypeBrc20Transfer,
+	TxTypeRunes:         model.BtcTxTypeRunes,
+}
+
+var ErrTxClassifier = errors.New("tx classifier err")
+
+// ordEnvelopeProtocol ordinal inscription envelope protocol id
+var ordEnvelopeProtocol = []byte("ord")
+
+// TxClassifier classify txs paying listened addresses, txs not matched by any classifier are plain transfer
+type TxClassifier interface {
+	// TxType the tx type of matched txs
+	TxType() string
+	// Match whether the tx matches
+	Match(tx *wire.MsgTx) bool
+}
+
+// DefaultTxClassifiers brc-20 before inscription, the first matched classifier is used
+func DefaultTxClassifiers() []TxClassifier {
+	return []TxClassifier{
+		Brc20TransferClassifier{},
+		InscriptionClassifier{},
+		RunesClassifier{},
+	}
+}
+
+// SetTxClassifiers set tx classifiers, the first matched classifier is used
+func (p *txParser) SetTxClassifiers(classifiers ...TxClassifier) {
+	p.classifiers = classifiers
+}
+
+// SetTxTypeActions set action of classified tx types, unset tx types are quarantined
+func (p *txParser) SetTxTypeActions(actions map[string]string) error {
+	for txType, action := range actions {
+		found := false
+		for _, v := range p.classifiers {
+			if v.TxType() == txType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w:unsupported tx type %s", ErrTxClassifier, txType)
+		}
+		switch action {
+		case config.TxTypeActionSkip, config.TxTypeActionQuarantine, config.TxTypeActionDeposit:
+		default:
+			return fmt.Errorf("%w:unsupported action %s", ErrTxClassifier, action)
+		}
+	}
+	p.txTypeActions = actions
+	return nil
+}
+
+// classify returns the tx type and its action
+func (p *txParser) classify(tx *wire.MsgTx) (string, string) {
+	for _, v := range p.classifiers {
+		if !v.Match(tx) {
+			continue
+		}
+		action, ok := p.txTypeActions[v.TxType()]
+		if !ok {
+			action = config.TxTypeActionQuarantine
+		}
+		return v.TxType(), action
+	}
+	return TxTypeTransfer, config.TxTypeActionDeposit
+}
+
+// InscriptionClassifier match ordinal inscription envelope in input tapscript
+type InscriptionClassifier struct{}
+
+func (InscriptionClassifier) TxType() string {
+	return TxTypeInscription
+}
+
+func (InscriptionClassifier) Match(tx *wire.MsgTx) bool {
+	return len(ParseInscriptions(tx)) > 0
+}
+
+// Brc20TransferClassifier match brc-20 transfer inscription
+type Brc20TransferClassifier struct{}
+
+func (Brc20TransferClassifier) TxType() string {
+	return TxTypeBrc20Transfer
+}
+
+func (Brc20TransferClassifier) Match(tx *wire.MsgTx) bool {
+	for _, v := range ParseInscriptions(tx) {
+		var brc20 struct {
+			P  string `json:"p"`
+			Op string `json:"op"`
+		}
+		if err := json.Unmarshal(v.Body, &brc20); err != nil {
+			continue
+		}
+		if strings.EqualFold(brc20.P, "brc-20") && strings.EqualFold(brc20.Op, "transfer") {
+			return true
+		}
+	}
+	return false
+}
+
+// RunesClassifier match runestone, output script OP_RETURN OP_13 ...
+type RunesClassifier struct{}
+
+func (RunesClassifier) TxType() string {
+	return TxTypeRunes
+}
+
+func (RunesClassifier) Match(tx *wire.MsgTx) bool {
+	for _, v := range tx.TxOut {
+		tokenizer := txscript.MakeScriptTokenizer(0, v.PkScript)
+		if !tokenizer.Next() || tokenizer.Opcode() != txscript.OP_RETURN {
+			continue
+		}
+		if tokenizer.Next() && tokenizer.Opcode() == txscript.OP_13 {
+			return true
+		}
+	}
+	return false
+}
+
+// Inscription ordinal inscription parsed from envelope
+type Inscription struct {
+	ContentType string
+	Body        []byte
+}
+
+// ParseInscriptions parse ordinal inscription envelopes in input tapscripts,
+// envelope format: OP_FALSE OP_IF "ord" [tag value]... OP_0 [body]... OP_ENDIF
+func ParseInscriptions(tx *wire.MsgTx) []Inscription {
+	inscriptions := make([]Inscription, 0)
+	for _, v := range tx.TxIn {
+		tapscript := witnessTapscript(v.Witness)
+		if tapscript == nil {
+			continue
+		}
+		inscriptions = append(inscriptions, parseEnvelopes(tapscript)...)
+	}
+	return inscriptions
+}
+
+// witnessTapscript returns the tapscript of script path spend, witness: ... script control_block [annex]
+func witnessTapscript(witness wire.TxWitness) []byte {
+	if len(witness) > 0 {
+		last := witness[len(witness)-1]
+		if len(witness) >= 2 && len(last) > 0 && last[0] == txscript.TaprootAnnexTag {
+			witness = witness[:len(witness)-1]
+		}
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	return witness[len(witness)-2]
+}
+
+func parseEnvelopes(script []byte) []Inscription {
+	inscriptions := make([]Inscription, 0)
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	// last two opcodes, envelope starts after OP_FALSE OP_IF
+	prev2, prev1 := byte(txscript.OP_NOP), byte(txscript.OP_NOP)
+	for tokenizer.Next() {
+		opcode := tokenizer.Opcode()
+		if prev2 == txscript.OP_FALSE && prev1 == txscript.OP_IF && bytes.Equal(tokenizer.Data(), ordEnvelopeProtocol) {
+			if inscription, ok := parseEnvelope(&tokenizer); ok {
+				inscriptions = append(inscriptions, inscription)
+			}
+			prev2, prev1 = txscript.OP_NOP, txscript.OP_NOP
+			continue
+		}
+		prev2, prev1 = prev1, opcode
+	}
+	return inscriptions
+}
+
+// parseEnvelope parse envelope fields after protocol id until OP_ENDIF
+func parseEnvelope(tokenizer *txscript.ScriptTokenizer) (Inscription, bool) {
+	var inscription Inscription
+	inBody := false
+	var tag []byte
+	for tokenizer.Next() {
+		opcode := tokenizer.Opcode()
+		if opcode == txscript.OP_ENDIF {
+			return inscription, true
+		}
+		data := pushedData(opcode, tokenizer.Data())
+		if data == nil {
+			// not a push, invalid envelope
+			return Inscription{}, false
+		}
+		if inBody {
+			inscription.Body = append(inscription.Body, data...)
+			continue
+		}
+		// OP_0 between fields starts the body
+		if tag == nil && len(data) == 0 {
+			inBody = true
+			continue
+		}
+		if tag == nil {
+			tag = data
+			continue
+		}
+		// content type tag 1
+		if bytes.Equal(tag, []byte{1}) {
+			inscription.ContentType = string(data)
+		}
+		tag = nil
+	}
+	return Inscription{}, false
+}
+
+// pushedData returns the pushed data, nil if opcode is not a push
+func pushedData(opcode byte, data []byte) []byte {
+	switch {
+	case opcode == txscript.OP_0:
+		return []byte{}
+	case opcode >= txscript.OP_1 && opcode <= txscript.OP_16:
+		return []byte{opcode - txscript.OP_1 + 1}
+	case opcode == txscript.OP_1NEGATE:
+		return []byte{0x81}
+	case opcode <= txscript.OP_PUSHDATA4:
+		if data == nil {
+			return []byte{}
+		}
+		return data
+	default:
+		return nil
+	}
+}
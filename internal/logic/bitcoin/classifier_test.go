@@ -0,0 +1,165 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// p2wpkh scripts of tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n and the fixture listen address
+	classifierSenderScript = "0014e58d88c091846d49d045c19713108e4625b0a962"
+	classifierListenScript = "00144dee6fc93d960214dddd7978672c9468aa67242b"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// inscriptionWitness taproot script path spend witness with an ord envelope
+func inscriptionWitness(t *testing.T, contentType string, body []byte) wire.TxWitness {
+	script, err := txscript.NewScriptBuilder().
+		AddData(bytes.Repeat([]byte{0x02}, 32)).
+		AddOp(txscript.OP_CHECKSIG).
+		AddOp(txscript.OP_FALSE).
+		AddOp(txscript.OP_IF).
+		AddData([]byte("ord")).
+		AddData([]byte{1}).
+		AddData([]byte(contentType)).
+		AddOp(txscript.OP_0).
+		AddData(body).
+		AddOp(txscript.OP_ENDIF).
+		Script()
+	require.NoError(t, err)
+	controlBlock := append([]byte{0xc0}, bytes.Repeat([]byte{0x03}, 32)...)
+	return wire.TxWitness{bytes.Repeat([]byte{0x01}, 64), script, controlBlock}
+}
+
+func runestoneScript(t *testing.T) []byte {
+	script, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_RETURN).
+		AddOp(txscript.OP_13).
+		AddData([]byte{0x14, 0x01}).
+		Script()
+	require.NoError(t, err)
+	return script
+}
+
+// classifierTx deposit tx spending prevTx, witness and extra outputs are optional
+func classifierTx(t *testing.T, prevTx *wire.MsgTx, witness wire.TxWitness, extraOuts ...*wire.TxOut) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevHash := prevTx.TxHash()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, witness))
+	tx.AddTxOut(wire.NewTxOut(10000, mustDecodeHex(t, classifierListenScript)))
+	for _, v := range extraOuts {
+		tx.AddTxOut(v)
+	}
+	return tx
+}
+
+func TestTxClassifiers(t *testing.T) {
+	prevTx := wire.NewMsgTx(wire.TxVersion)
+	prevTx.AddTxOut(wire.NewTxOut(50000, mustDecodeHex(t, classifierSenderScript)))
+
+	plain := classifierTx(t, prevTx, nil)
+	inscription := classifierTx(t, prevTx, inscriptionWitness(t, "image/png", []byte{0x89, 0x50, 0x4e, 0x47}))
+	brc20 := classifierTx(t, prevTx,
+		inscriptionWitness(t, "text/plain;charset=utf-8", []byte(`{"p":"brc-20","op":"transfer","tick":"ordi","amt":"10"}`)))
+	runes := classifierTx(t, prevTx, nil, wire.NewTxOut(0, runestoneScript(t)))
+
+	testCases := []struct {
+		name        string
+		tx          *wire.MsgTx
+		actions     map[string]string
+		txType      string
+		quarantined bool
+		skipped     bool
+	}{
+		{
+			name:   "plain transfer",
+			tx:     plain,
+			txType: bitcoin.TxTypeTransfer,
+		},
+		{
+			name:        "inscription quarantined by default",
+			tx:          inscription,
+			txType:      bitcoin.TxTypeInscription,
+			quarantined: true,
+		},
+		{
+			name:    "inscription skipped",
+			tx:      inscription,
+			actions: map[string]string{bitcoin.TxTypeInscription: config.TxTypeActionSkip},
+			skipped: true,
+		},
+		{
+			name:        "brc-20 transfer before inscription",
+			tx:          brc20,
+			actions:     map[string]string{bitcoin.TxTypeInscription: config.TxTypeActionSkip},
+			txType:      bitcoin.TxTypeBrc20Transfer,
+			quarantined: true,
+		},
+		{
+			name:    "runes deposit",
+			tx:      runes,
+			actions: map[string]string{bitcoin.TxTypeRunes: config.TxTypeActionDeposit},
+			txType:  bitcoin.TxTypeRunes,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fetcher := &fixtureTxFetcher{txs: map[chainhash.Hash]*wire.MsgTx{prevTx.TxHash(): prevTx}}
+			indexer := fixtureIndexer(t, bitcoin.PipelineConfig{}, fetcher)
+			require.NoError(t, indexer.SetTxTypeActions(tc.actions))
+
+			block := wire.NewMsgBlock(&wire.BlockHeader{})
+			require.NoError(t, block.AddTransaction(tc.tx))
+			results, err := indexer.ParseMsgBlock(block, 100, 0)
+			require.NoError(t, err)
+			if tc.skipped {
+				require.Len(t, results, 0)
+				// skipped before prevouts are fetched
+				require.Equal(t, 0, fetcher.fetched)
+				return
+			}
+			require.Len(t, results, 1)
+			require.Equal(t, tc.txType, results[0].TxType)
+			require.Equal(t, tc.quarantined, results[0].Quarantined)
+			require.Equal(t, int64(10000), results[0].Value)
+		})
+	}
+}
+
+func TestParseInscriptions(t *testing.T) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	witness := inscriptionWitness(t, "text/plain;charset=utf-8", []byte("hello"))
+	// annex is ignored
+	witness = append(witness, []byte{txscript.TaprootAnnexTag, 0x00})
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, witness))
+	// key path spend, no tapscript
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, wire.TxWitness{bytes.Repeat([]byte{0x01}, 64)}))
+
+	inscriptions := bitcoin.ParseInscriptions(tx)
+	require.Len(t, inscriptions, 1)
+	require.Equal(t, "text/plain;charset=utf-8", inscriptions[0].ContentType)
+	require.Equal(t, []byte("hello"), inscriptions[0].Body)
+}
+
+func TestSetTxTypeActions(t *testing.T) {
+	indexer := fixtureIndexer(t, bitcoin.PipelineConfig{}, &fixtureTxFetcher{})
+	err := indexer.SetTxTypeActions(map[string]string{"unknown": config.TxTypeActionSkip})
+	require.True(t, errors.Is(err, bitcoin.ErrTxClassifier), err)
+	err = indexer.SetTxTypeActions(map[string]string{bitcoin.TxTypeRunes: "burn"})
+	require.True(t, errors.Is(err, bitcoin.ErrTxClassifier), err)
+}
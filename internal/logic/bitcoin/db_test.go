@@ -0,0 +1,36 @@
+package bitcoin_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB sqlite stand-in of the postgres db with the bridge tables migrated,
+// one connection so concurrent transactions are serialized
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	require.NoError(t, db.AutoMigrate(
+		&model.Deposit{},
+		&model.DepositStatusHistory{},
+		&model.BridgeNonce{},
+		&model.BtcIndex{},
+		&model.BtcBlock{},
+		&model.RollupDeposit{},
+	))
+	return db
+}
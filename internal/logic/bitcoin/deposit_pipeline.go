@@ -0,0 +1,90 @@
+package bitcoin
+
+import (
+	"sync"
+
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// DepositPipeline submits deposit txs with up to maxInFlight unmined txs,
+// receipts of sent txs are watched in separate goroutines
+type DepositPipeline struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[int64]*ethTypes.Transaction
+	wg       sync.WaitGroup
+}
+
+// NewDepositPipeline returns a new pipeline, maxInFlight less than 1 is 1
+func NewDepositPipeline(maxInFlight int) *DepositPipeline {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	return &DepositPipeline{
+		slots:    make(chan struct{}, maxInFlight),
+		inFlight: make(map[int64]*ethTypes.Transaction),
+	}
+}
+
+// Submit wait for a free slot, send the deposit tx and watch it in a goroutine,
+// the slot is freed when watch returns or send fails
+func (p *DepositPipeline) Submit(
+	stop <-chan struct{},
+	depositID int64,
+	send func() (*ethTypes.Transaction, error),
+	watch func(*ethTypes.Transaction),
+) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-stop:
+		return ErrServerStop
+	}
+	p.mu.Lock()
+	p.inFlight[depositID] = nil
+	p.mu.Unlock()
+
+	tx, err := send()
+	if err != nil {
+		p.done(depositID)
+		return err
+	}
+	p.mu.Lock()
+	p.inFlight[depositID] = tx
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.done(depositID)
+		watch(tx)
+	}()
+	return nil
+}
+
+func (p *DepositPipeline) done(depositID int64) {
+	p.mu.Lock()
+	delete(p.inFlight, depositID)
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// InFlight whether the deposit tx is being sent or watched
+func (p *DepositPipeline) InFlight(depositID int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.inFlight[depositID]
+	return ok
+}
+
+// Len number of in flight deposits
+func (p *DepositPipeline) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.inFlight)
+}
+
+// Wait wait for all watchers to return
+func (p *DepositPipeline) Wait() {
+	p.wg.Wait()
+}
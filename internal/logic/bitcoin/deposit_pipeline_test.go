@@ -0,0 +1,147 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositPipeline(t *testing.T) {
+	const (
+		maxInFlight = 3
+		deposits    = 7
+	)
+	contract := common.HexToAddress("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	bridge, node := newTestBridge(t, contract)
+	db := newTestDB(t)
+	service := bitcoin.NewBridgeDepositService(bridge, &mockChainIndexer{}, db, log.NewNopLogger())
+	service.SetMaxInFlight(maxInFlight)
+
+	for i := 1; i <= deposits; i++ {
+		require.NoError(t, db.Create(&model.Deposit{
+			BtcTxHash:      fmt.Sprintf("%064x", i),
+			BtcFrom:        "tb1qgm39cu009lyvq93afx47pp4h9wxq5x92lxxgnz",
+			BtcTo:          "tb1qjda2l5spwyv4ekwe9keddymzuxynea2m2kj0qy",
+			BtcMemoAddress: "0x67a5bF3F1FE8D5B9a9E2D4C8b3E1F0a2C3d4E5f6",
+			BtcValue:       int64(i) * 10000,
+			B2TxStatus:     model.DepositB2TxStatusPending,
+			B2EoaTxStatus:  model.DepositB2EoaTxStatusPending,
+			CallbackStatus: model.CallbackStatusSuccess,
+			ListenerStatus: model.ListenerStatusSuccess,
+		}).Error)
+	}
+	var pending []model.Deposit
+	require.NoError(t, db.Order("id ASC").Find(&pending).Error)
+	require.Len(t, pending, deposits)
+
+	// the first deposits are sent without waiting mined
+	for _, deposit := range pending[:maxInFlight] {
+		require.NoError(t, service.SubmitDeposit(deposit))
+	}
+	pendingNonce, err := bridge.NonceAt(bridge.FromAddress(), true)
+	require.NoError(t, err)
+	require.Equal(t, uint64(maxInFlight), pendingNonce)
+	latestNonce, err := bridge.NonceAt(bridge.FromAddress(), false)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), latestNonce)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				node.Commit()
+			}
+		}
+	}()
+	// the next deposits wait for the mined deposits freeing their slots
+	for _, deposit := range pending[maxInFlight:] {
+		require.NoError(t, service.SubmitDeposit(deposit))
+	}
+	require.Eventually(t, func() bool {
+		var count int64
+		require.NoError(t, db.Model(&model.Deposit{}).
+			Where("b2_tx_status = ?", model.DepositB2TxStatusSuccess).
+			Count(&count).Error)
+		return count == deposits
+	}, 30*time.Second, 100*time.Millisecond)
+
+	var mined []model.Deposit
+	require.NoError(t, db.Order("b2_tx_nonce ASC").Find(&mined).Error)
+	for i, deposit := range mined {
+		require.Equal(t, uint64(i), deposit.B2TxNonce)
+		require.Equal(t, bridge.FromAddress(), deposit.B2TxFrom)
+		receipt, err := bridge.TransactionReceipt(deposit.B2TxHash)
+		require.NoError(t, err)
+		require.Equal(t, ethTypes.ReceiptStatusSuccessful, receipt.Status)
+	}
+	var nonces []model.BridgeNonce
+	require.NoError(t, db.Order("nonce ASC").Find(&nonces).Error)
+	require.Len(t, nonces, deposits)
+	for i, v := range nonces {
+		require.Equal(t, uint64(i), v.Nonce)
+		require.Equal(t, model.BridgeNonceStatusSent, v.Status)
+		require.Equal(t, mined[i].ID, v.DepositID)
+		require.Equal(t, mined[i].B2TxHash, v.TxHash)
+	}
+	// pending -> wait mined -> success
+	var history []model.DepositStatusHistory
+	require.NoError(t, db.Order("id ASC").Find(&history).Error)
+	require.Len(t, history, 2*deposits)
+}
+
+func TestDepositPipelineSendFailed(t *testing.T) {
+	stop := make(chan struct{})
+	pipeline := bitcoin.NewDepositPipeline(1)
+	sendErr := errors.New("send failed")
+	err := pipeline.Submit(stop, 1,
+		func() (*ethTypes.Transaction, error) {
+			return nil, sendErr
+		},
+		func(_ *ethTypes.Transaction) {
+			t.Fatal("failed tx watched")
+		},
+	)
+	require.ErrorIs(t, err, sendErr)
+	require.False(t, pipeline.InFlight(1))
+
+	// the slot is freed, the watcher holds it until stop
+	watching := make(chan struct{})
+	err = pipeline.Submit(stop, 2,
+		func() (*ethTypes.Transaction, error) {
+			return ethTypes.NewTx(&ethTypes.LegacyTx{}), nil
+		},
+		func(_ *ethTypes.Transaction) {
+			close(watching)
+			<-stop
+		},
+	)
+	require.NoError(t, err)
+	<-watching
+	require.True(t, pipeline.InFlight(2))
+
+	close(stop)
+	err = pipeline.Submit(stop, 3,
+		func() (*ethTypes.Transaction, error) {
+			t.Fatal("sent without free slot")
+			return nil, nil
+		},
+		func(_ *ethTypes.Transaction) {},
+	)
+	require.ErrorIs(t, err, bitcoin.ErrServerStop)
+	pipeline.Wait()
+	require.Equal(t, 0, pipeline.Len())
+}
@@ -0,0 +1,144 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// deposit hold reason
+	HoldReasonBelowMinAmount   = "below min amount"
+	HoldReasonAboveMaxAmount   = "above max amount"
+	HoldReasonDailyCapExceeded = "daily cap exceeded"
+
+	// DepositDailyCapWindow window of the per from address daily cap
+	DepositDailyCapWindow = 24 * time.Hour
+)
+
+// DepositPolicy deposit amount policy in satoshi, zero disables the limit
+type DepositPolicy struct {
+	MinAmount int64
+	MaxAmount int64
+	DailyCap  int64
+}
+
+// Check returns the hold reason of the deposit value, empty if allowed,
+// dailyTotal is the deposited value of the same from address in the cap window
+func (p DepositPolicy) Check(value int64, dailyTotal int64) string {
+	if p.MinAmount > 0 && value < p.MinAmount {
+		return HoldReasonBelowMinAmount
+	}
+	if p.MaxAmount > 0 && value > p.MaxAmount {
+		return HoldReasonAboveMaxAmount
+	}
+	if p.DailyCap > 0 && dailyTotal+value > p.DailyCap {
+		return HoldReasonDailyCapExceeded
+	}
+	return ""
+}
+
+// SetDepositPolicy set deposit amount policy, deposits outside the policy are held
+func (bis *IndexerService) SetDepositPolicy(policy DepositPolicy) {
+	bis.depositPolicy = policy
+}
+
+// applyDepositPolicy hold the confirmed pending deposit if it is outside the policy
+func (bis *IndexerService) applyDepositPolicy(tx *gorm.DB, deposit *model.Deposit) error {
+	if deposit.ListenerStatus != model.ListenerStatusSuccess || deposit.B2TxStatus != model.DepositB2TxStatusPending {
+		return nil
+	}
+	var dailyTotal int64
+	if bis.depositPolicy.DailyCap > 0 {
+		err := tx.Model(&model.Deposit{}).
+			Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", model.Deposit{}.Column().BtcValue)).
+			Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcFrom), deposit.BtcFrom).
+			Where(fmt.Sprintf("%s > ? AND %s <= ?", model.Deposit{}.Column().BtcBlockTime, model.Deposit{}.Column().BtcBlockTime),
+				deposit.BtcBlockTime.Add(-DepositDailyCapWindow), deposit.BtcBlockTime).
+			Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().ListenerStatus), model.ListenerStatusSuccess).
+			// held, rejected and dead letter deposits are not bridged
+			Where(fmt.Sprintf("%s NOT IN ?", model.Deposit{}.Column().B2TxStatus), []int{
+				model.DepositB2TxStatusHeld,
+				model.DepositB2TxStatusRejected,
+				model.DepositB2TxStatusDeadLetter,
+			}).
+			Where(fmt.Sprintf("NOT (%s = ? AND %s = ?)", model.Deposit{}.Column().BtcTxHash, model.Deposit{}.Column().BtcTo),
+				deposit.BtcTxHash, deposit.BtcTo).
+			Scan(&dailyTotal).Error
+		if err != nil {
+			return err
+		}
+	}
+	reason := bis.depositPolicy.Check(deposit.BtcValue, dailyTotal)
+	if reason == "" {
+		return nil
+	}
+	bis.log.Warnw("deposit held by policy", "txId", deposit.BtcTxHash, "to", deposit.BtcTo,
+		"value", deposit.BtcValue, "dailyTotal", dailyTotal, "reason", reason)
+	deposit.B2TxStatus = model.DepositB2TxStatusHeld
+	deposit.HoldReason = reason
+	return nil
+}
+
+// ListHeldDeposits list deposits held by the deposit policy
+func ListHeldDeposits(db *gorm.DB) ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	err := db.
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().B2TxStatus), model.DepositB2TxStatusHeld).
+		Order("id asc").
+		Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+// ReleaseHeldDeposits release held deposits of the btc tx to bridge, all held deposits of the tx if to is empty
+func ReleaseHeldDeposits(db *gorm.DB, txHash string, to string) (int64, error) {
+	return updateHeldDeposits(db, txHash, to, model.DepositB2TxStatusPending, "held deposit released by operator")
+}
+
+// RejectHeldDeposits reject held deposits of the btc tx, all held deposits of the tx if to is empty
+func RejectHeldDeposits(db *gorm.DB, txHash string, to string) (int64, error) {
+	return updateHeldDeposits(db, txHash, to, model.DepositB2TxStatusRejected, "held deposit rejected by operator")
+}
+
+func updateHeldDeposits(db *gorm.DB, txHash string, to string, b2TxStatus int, reason string) (int64, error) {
+	query := db.
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcTxHash), txHash).
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().B2TxStatus), model.DepositB2TxStatusHeld)
+	if to != "" {
+		query = query.Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcTo), to)
+	}
+	var deposits []model.Deposit
+	if err := query.Find(&deposits).Error; err != nil {
+		return 0, err
+	}
+	var updated int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, deposit := range deposits {
+			err := model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To:     map[string]int{model.Deposit{}.Column().B2TxStatus: b2TxStatus},
+				From:   map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusHeld},
+				Actor:  model.DepositActorOperator,
+				Reason: reason,
+			})
+			if errors.Is(err, model.ErrDepositStatusMismatch) {
+				// released or rejected concurrently
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
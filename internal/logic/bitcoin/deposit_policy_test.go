@@ -0,0 +1,137 @@
+package bitcoin_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositPolicyCheck(t *testing.T) {
+	policy := bitcoin.DepositPolicy{
+		MinAmount: 10000,
+		MaxAmount: 100000000,
+		DailyCap:  150000000,
+	}
+
+	testCases := []struct {
+		name       string
+		policy     bitcoin.DepositPolicy
+		value      int64
+		dailyTotal int64
+		reason     string
+	}{
+		{
+			name:   "success: disabled",
+			policy: bitcoin.DepositPolicy{},
+			value:  546,
+		},
+		{
+			name:   "success: min amount",
+			policy: policy,
+			value:  10000,
+		},
+		{
+			name:   "success: max amount",
+			policy: policy,
+			value:  100000000,
+		},
+		{
+			name:       "success: daily cap reached",
+			policy:     policy,
+			value:      50000000,
+			dailyTotal: 100000000,
+		},
+		{
+			name:   "held: dust",
+			policy: policy,
+			value:  546,
+			reason: bitcoin.HoldReasonBelowMinAmount,
+		},
+		{
+			name:   "held: above max amount",
+			policy: policy,
+			value:  100000001,
+			reason: bitcoin.HoldReasonAboveMaxAmount,
+		},
+		{
+			name:       "held: daily cap exceeded",
+			policy:     policy,
+			value:      50000001,
+			dailyTotal: 100000000,
+			reason:     bitcoin.HoldReasonDailyCapExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.reason, tc.policy.Check(tc.value, tc.dailyTotal))
+		})
+	}
+}
+
+func TestDepositPolicyDailyCap(t *testing.T) {
+	blockTime := time.Unix(1700000000, 0)
+	policy := bitcoin.DepositPolicy{MaxAmount: 100000, DailyCap: 150000}
+	recorded := func(txID string, value int64, status int) model.Deposit {
+		return model.Deposit{
+			BtcTxHash:      txID,
+			BtcFrom:        "tb1-from",
+			BtcTo:          fixtureListenAddress,
+			BtcValue:       value,
+			BtcBlockTime:   blockTime.Add(-time.Hour),
+			B2TxStatus:     status,
+			ListenerStatus: model.ListenerStatusSuccess,
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		recorded []model.Deposit
+		status   int
+		reason   string
+	}{
+		{
+			name:   "success: first deposit",
+			status: model.DepositB2TxStatusPending,
+		},
+		{
+			name: "success: held, rejected and dead letter deposits not counted",
+			recorded: []model.Deposit{
+				recorded("h1", 200000, model.DepositB2TxStatusHeld),
+				recorded("r1", 90000, model.DepositB2TxStatusRejected),
+				recorded("d1", 90000, model.DepositB2TxStatusDeadLetter),
+			},
+			status: model.DepositB2TxStatusPending,
+		},
+		{
+			name:     "held: bridged deposits counted",
+			recorded: []model.Deposit{recorded("s1", 90000, model.DepositB2TxStatusWaitMined)},
+			status:   model.DepositB2TxStatusHeld,
+			reason:   bitcoin.HoldReasonDailyCapExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if len(tc.recorded) > 0 {
+				require.NoError(t, db.Create(&tc.recorded).Error)
+			}
+			service := bitcoin.NewIndexerService(&mockChainIndexer{}, db, log.NewNopLogger(), 0)
+			service.SetDepositPolicy(policy)
+
+			result := mempoolResult("n1", bitcoin.TxTypeTransfer)
+			result.Value = 90000
+			require.NoError(t, service.SaveParsedResult(result, 100, model.DepositB2TxStatusPending, blockTime, model.BtcIndex{}))
+
+			var deposit model.Deposit
+			require.NoError(t, db.First(&deposit, "btc_tx_hash = ?", "n1").Error)
+			require.Equal(t, tc.status, deposit.B2TxStatus)
+			require.Equal(t, tc.reason, deposit.HoldReason)
+		})
+	}
+}
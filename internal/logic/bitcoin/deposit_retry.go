@@ -0,0 +1,134 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"gorm.io/gorm"
+)
+
+// DepositMaxRetryBackoff max delay between deposit retries
+const DepositMaxRetryBackoff = time.Hour
+
+// DepositRetryPolicy retry budget of failed deposits, the delay doubles on each retry
+type DepositRetryPolicy struct {
+	// MaxRetry deposits failed more times are dead-lettered
+	MaxRetry int
+	// Backoff delay of the first retry
+	Backoff time.Duration
+}
+
+// NewDepositRetryPolicy returns the retry policy, 0 max retry use DepositRetry, 0 backoff use DepositErrTimeout
+func NewDepositRetryPolicy(maxRetry int, backoff time.Duration) DepositRetryPolicy {
+	if maxRetry <= 0 {
+		maxRetry = DepositRetry
+	}
+	if backoff <= 0 {
+		backoff = DepositErrTimeout
+	}
+	return DepositRetryPolicy{
+		MaxRetry: maxRetry,
+		Backoff:  backoff,
+	}
+}
+
+// Delay returns the delay before the next attempt of a deposit failed retry times
+func (p DepositRetryPolicy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay < DepositMaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > DepositMaxRetryBackoff {
+		delay = DepositMaxRetryBackoff
+	}
+	return delay
+}
+
+// Next returns the b2 tx status and the next attempt time of a deposit failed retry times,
+// dead letter if the retry budget is exhausted
+func (p DepositRetryPolicy) Next(retry int, now time.Time) (int, time.Time) {
+	if retry >= p.MaxRetry {
+		return model.DepositB2TxStatusDeadLetter, now
+	}
+	return model.DepositB2TxStatusPending, now.Add(p.Delay(retry))
+}
+
+// SetRetryPolicy set the retry budget and backoff of failed deposits
+func (bis *BridgeDepositService) SetRetryPolicy(policy DepositRetryPolicy) {
+	bis.retryPolicy = policy
+}
+
+// retryDeposit count the failure of the deposit and schedule the next attempt, dead letter if exhausted
+func (bis *BridgeDepositService) retryDeposit(deposit *model.Deposit, reason string) error {
+	deposit.B2TxRetry++
+	deposit.B2TxStatus, deposit.B2TxNextAttempt = bis.retryPolicy.Next(deposit.B2TxRetry, time.Now())
+	if deposit.B2TxStatus == model.DepositB2TxStatusDeadLetter {
+		bis.log.Errorw("deposit retry budget exhausted, dead letter",
+			"btcTxHash", deposit.BtcTxHash,
+			"retry", deposit.B2TxRetry,
+			"reason", reason)
+		reason = fmt.Sprintf("retry budget exhausted after %d retries: %s", deposit.B2TxRetry, reason)
+	}
+	return bis.transitDeposit(deposit.ID, model.Deposit{}.Column().B2TxStatus, deposit.B2TxStatus,
+		reason, map[string]interface{}{
+			model.Deposit{}.Column().B2TxRetry:       deposit.B2TxRetry,
+			model.Deposit{}.Column().B2TxNextAttempt: deposit.B2TxNextAttempt,
+		})
+}
+
+// ListDeadLetterDeposits list deposits exhausted the retry budget
+func ListDeadLetterDeposits(db *gorm.DB) ([]model.Deposit, error) {
+	var deposits []model.Deposit
+	err := db.
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().B2TxStatus), model.DepositB2TxStatusDeadLetter).
+		Order("id asc").
+		Find(&deposits).Error
+	if err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+// RetryDeadLetterDeposits send dead letter deposits of the btc tx again with a new retry budget,
+// all dead letter deposits of the tx if to is empty
+func RetryDeadLetterDeposits(db *gorm.DB, txHash string, to string) (int64, error) {
+	query := db.
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcTxHash), txHash).
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().B2TxStatus), model.DepositB2TxStatusDeadLetter)
+	if to != "" {
+		query = query.Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcTo), to)
+	}
+	var deposits []model.Deposit
+	if err := query.Find(&deposits).Error; err != nil {
+		return 0, err
+	}
+	var updated int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, deposit := range deposits {
+			err := model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To:   map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusPending},
+				From: map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusDeadLetter},
+				Updates: map[string]interface{}{
+					model.Deposit{}.Column().B2TxRetry:       0,
+					model.Deposit{}.Column().B2TxNextAttempt: time.Now(),
+				},
+				Actor:  model.DepositActorOperator,
+				Reason: "dead letter deposit retried by operator",
+			})
+			if errors.Is(err, model.ErrDepositStatusMismatch) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
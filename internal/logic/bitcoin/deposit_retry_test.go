@@ -0,0 +1,101 @@
+package bitcoin_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDepositRetryPolicy(t *testing.T) {
+	policy := bitcoin.NewDepositRetryPolicy(0, 0)
+	require.Equal(t, bitcoin.DepositRetry, policy.MaxRetry)
+	require.Equal(t, bitcoin.DepositErrTimeout, policy.Backoff)
+
+	policy = bitcoin.NewDepositRetryPolicy(3, time.Minute)
+	require.Equal(t, 3, policy.MaxRetry)
+	require.Equal(t, time.Minute, policy.Backoff)
+}
+
+func TestDepositRetryPolicyDelay(t *testing.T) {
+	policy := bitcoin.NewDepositRetryPolicy(100, 30*time.Second)
+
+	testCases := []struct {
+		name  string
+		retry int
+		delay time.Duration
+	}{
+		{
+			name:  "success: first retry",
+			retry: 1,
+			delay: 30 * time.Second,
+		},
+		{
+			name:  "success: doubled",
+			retry: 2,
+			delay: time.Minute,
+		},
+		{
+			name:  "success: doubled again",
+			retry: 4,
+			delay: 4 * time.Minute,
+		},
+		{
+			name:  "success: capped",
+			retry: 8,
+			delay: bitcoin.DepositMaxRetryBackoff,
+		},
+		{
+			name:  "success: capped without overflow",
+			retry: 99,
+			delay: bitcoin.DepositMaxRetryBackoff,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.delay, policy.Delay(tc.retry))
+		})
+	}
+}
+
+func TestDepositRetryPolicyNext(t *testing.T) {
+	now := time.Now()
+	policy := bitcoin.NewDepositRetryPolicy(3, time.Minute)
+
+	testCases := []struct {
+		name    string
+		retry   int
+		status  int
+		attempt time.Time
+	}{
+		{
+			name:    "success: retried after the backoff",
+			retry:   1,
+			status:  model.DepositB2TxStatusPending,
+			attempt: now.Add(time.Minute),
+		},
+		{
+			name:    "success: last retry",
+			retry:   2,
+			status:  model.DepositB2TxStatusPending,
+			attempt: now.Add(2 * time.Minute),
+		},
+		{
+			name:    "fail: retry budget exhausted",
+			retry:   3,
+			status:  model.DepositB2TxStatusDeadLetter,
+			attempt: now,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, attempt := policy.Next(tc.retry, now)
+			require.Equal(t, tc.status, status)
+			require.Equal(t, tc.attempt, attempt)
+		})
+	}
+}
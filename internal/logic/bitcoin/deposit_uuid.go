@@ -0,0 +1,53 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+)
+
+// NewDepositUUID returns the contract deposit uuid of the btc tx output. A btc tx paying several listened
+// addresses has a deposit for each address, the uuid of the default listened address is the btc
+// tx hash as before multiple listen addresses, others are keccak256(btc tx hash ‖ btc to)
+func NewDepositUUID(btcTxHash, btcTo, btcToLabel string) common.Hash {
+	if btcToLabel == "" || btcToLabel == config.DefaultListenAddressLabel {
+		return common.HexToHash(btcTxHash)
+	}
+	return crypto.Keccak256Hash(common.HexToHash(btcTxHash).Bytes(), []byte(btcTo))
+}
+
+// DepositUUID returns the contract deposit uuid of the deposit, the uuid is stored when the deposit is
+// indexed and not changed by the label. The uuid of deposits not indexed yet is derived from the label
+func DepositUUID(deposit model.Deposit) common.Hash {
+	if deposit.DepositUUID != "" {
+		return common.HexToHash(deposit.DepositUUID)
+	}
+	return NewDepositUUID(deposit.BtcTxHash, deposit.BtcTo, deposit.BtcToLabel)
+}
+
+// RollupDepositUUID returns the deposit uuid as recorded by the rollup indexer, hex without 0x prefix
+func RollupDepositUUID(deposit model.Deposit) string {
+	return DepositUUID(deposit).Hex()[2:]
+}
+
+// BackfillDepositUUID store the uuid of deposits recorded before the uuid column
+func BackfillDepositUUID(db *gorm.DB) error {
+	var deposits []model.Deposit
+	return db.
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().DepositUUID), "").
+		FindInBatches(&deposits, 500, func(_ *gorm.DB, _ int) error {
+			for _, deposit := range deposits {
+				err := db.Model(&model.Deposit{}).
+					Where("id = ?", deposit.ID).
+					Update(model.Deposit{}.Column().DepositUUID, DepositUUID(deposit).Hex()).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
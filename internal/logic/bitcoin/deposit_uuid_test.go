@@ -0,0 +1,123 @@
+package bitcoin_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositUUID(t *testing.T) {
+	txHash := "2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"
+
+	testCases := []struct {
+		name    string
+		deposit model.Deposit
+		uuid    common.Hash
+	}{
+		{
+			name:    "success: default listen address",
+			deposit: model.Deposit{BtcTxHash: txHash, BtcTo: "tb1-default", BtcToLabel: config.DefaultListenAddressLabel},
+			uuid:    common.HexToHash(txHash),
+		},
+		{
+			name:    "success: recorded before listen address labels",
+			deposit: model.Deposit{BtcTxHash: txHash, BtcTo: "tb1-default"},
+			uuid:    common.HexToHash(txHash),
+		},
+		{
+			name:    "success: labeled listen address",
+			deposit: model.Deposit{BtcTxHash: txHash, BtcTo: "tb1-vault", BtcToLabel: "vault"},
+			uuid:    crypto.Keccak256Hash(common.HexToHash(txHash).Bytes(), []byte("tb1-vault")),
+		},
+		{
+			name: "success: stored uuid",
+			deposit: model.Deposit{
+				BtcTxHash:   txHash,
+				BtcTo:       "tb1-vault",
+				BtcToLabel:  "vault",
+				DepositUUID: common.HexToHash(txHash).Hex(),
+			},
+			uuid: common.HexToHash(txHash),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.uuid, bitcoin.DepositUUID(tc.deposit))
+			require.Equal(t, tc.uuid.Hex()[2:], bitcoin.RollupDepositUUID(tc.deposit))
+		})
+	}
+}
+
+func TestDepositUUIDMultipleOutputs(t *testing.T) {
+	txHash := "2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"
+	// one btc tx paying three listened addresses
+	deposits := []model.Deposit{
+		{BtcTxHash: txHash, BtcTo: "tb1-default", BtcToLabel: config.DefaultListenAddressLabel},
+		{BtcTxHash: txHash, BtcTo: "tb1-vault", BtcToLabel: "vault"},
+		{BtcTxHash: txHash, BtcTo: "tb1-cold", BtcToLabel: "cold"},
+	}
+	uuids := make(map[common.Hash]struct{}, len(deposits))
+	for _, deposit := range deposits {
+		uuids[bitcoin.DepositUUID(deposit)] = struct{}{}
+	}
+	require.Len(t, uuids, len(deposits))
+
+	// the batch deposit event of one output does not mark the other outputs deposited
+	succeeded, failed := bitcoin.SplitDepositsByEvent(deposits, map[common.Hash]struct{}{
+		bitcoin.DepositUUID(deposits[1]): {},
+	})
+	require.Equal(t, []model.Deposit{deposits[1]}, succeeded)
+	require.Equal(t, []model.Deposit{deposits[0], deposits[2]}, failed)
+}
+
+func TestDepositUUIDLabelChanged(t *testing.T) {
+	db := newTestDB(t)
+	service := bitcoin.NewIndexerService(&mockChainIndexer{}, db, log.NewNopLogger(), 0)
+	result := mempoolResult("u1", bitcoin.TxTypeTransfer)
+	result.ToLabel = "vault"
+	btcIndex := model.BtcIndex{BtcIndexBlock: 100}
+	require.NoError(t, service.SaveParsedResult(result, 100, model.DepositB2TxStatusPending, time.Now(), btcIndex))
+	var deposit model.Deposit
+	require.NoError(t, db.First(&deposit).Error)
+	uuid := bitcoin.NewDepositUUID("u1", fixtureListenAddress, "vault")
+	require.Equal(t, uuid.Hex(), deposit.DepositUUID)
+
+	// the operator relabels the address, the reorged deposit is indexed again
+	require.NoError(t, db.Model(&deposit).Update(model.Deposit{}.Column().ListenerStatus, model.ListenerStatusReorged).Error)
+	result.ToLabel = config.DefaultListenAddressLabel
+	require.NoError(t, service.SaveParsedResult(result, 101, model.DepositB2TxStatusPending, time.Now(), btcIndex))
+	require.NoError(t, db.First(&deposit, deposit.ID).Error)
+	require.Equal(t, config.DefaultListenAddressLabel, deposit.BtcToLabel)
+	require.Equal(t, uuid, bitcoin.DepositUUID(deposit))
+}
+
+func TestBackfillDepositUUID(t *testing.T) {
+	txHash := "2bc6cb8a1a8ba6b3f1b2f8a8c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f7"
+	db := newTestDB(t)
+	deposits := []model.Deposit{
+		{BtcTxHash: txHash, BtcTo: "tb1-default"},
+		{BtcTxHash: txHash, BtcTo: "tb1-vault", BtcToLabel: "vault"},
+		{BtcTxHash: "b1", BtcTo: "tb1-vault", BtcToLabel: "vault", DepositUUID: common.HexToHash("ff").Hex()},
+	}
+	require.NoError(t, db.Create(&deposits).Error)
+	require.NoError(t, bitcoin.BackfillDepositUUID(db))
+
+	expected := []common.Hash{
+		common.HexToHash(txHash),
+		crypto.Keccak256Hash(common.HexToHash(txHash).Bytes(), []byte("tb1-vault")),
+		common.HexToHash("ff"),
+	}
+	for i, v := range deposits {
+		var deposit model.Deposit
+		require.NoError(t, db.First(&deposit, v.ID).Error)
+		require.Equal(t, expected[i].Hex(), deposit.DepositUUID)
+	}
+}
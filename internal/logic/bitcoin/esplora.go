@@ -0,0 +1,330 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// EsploraTxsPageSize esplora /block/:hash/txs/:start_index page size
+	EsploraTxsPageSize = 25
+
+	EsploraRequestTimeout = 30 * time.Second
+)
+
+var ErrEsploraRequest = errors.New("esplora request err")
+
+// EsploraIndexer bitcoin indexer backed by an esplora compatible rest api,
+// prevouts are included in the tx response, a full node with txindex is not required
+type EsploraIndexer struct {
+	*txParser
+	client              *resty.Client
+	baseURL             string
+	targetConfirmations uint64
+}
+
+type esploraBlock struct {
+	ID      string `json:"id"`
+	Height  int64  `json:"height"`
+	TxCount int64  `json:"tx_count"`
+}
+
+type esploraTx struct {
+	TxID string        `json:"txid"`
+	Vin  []esploraVin  `json:"vin"`
+	Vout []esploraVout `json:"vout"`
+}
+
+type esploraVin struct {
+	TxID       string       `json:"txid"`
+	Vout       uint32       `json:"vout"`
+	Prevout    *esploraVout `json:"prevout"`
+	Witness    []string     `json:"witness"`
+	IsCoinbase bool         `json:"is_coinbase"`
+}
+
+type esploraVout struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+type esploraTxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+}
+
+// NewEsploraIndexer new esplora indexer, baseURL e.g. https://blockstream.info/api
+func NewEsploraIndexer(
+	log log.Logger,
+	baseURL string,
+	chainParams *chaincfg.Params,
+	listenAddresses []config.ListenAddress,
+	targetConfirmations uint64,
+) (*EsploraIndexer, error) {
+	parser, err := newTxParser(log, chainParams, listenAddresses)
+	if err != nil {
+		return nil, err
+	}
+	return &EsploraIndexer{
+		txParser:            parser,
+		client:              resty.New().SetTimeout(EsploraRequestTimeout),
+		baseURL:             strings.TrimSuffix(baseURL, "/"),
+		targetConfirmations: targetConfirmations,
+	}, nil
+}
+
+// ParseBlock parse block data by block height
+func (e *EsploraIndexer) ParseBlock(height int64, txIndex int64) ([]*types.BitcoinTxParseResult, *wire.BlockHeader, error) {
+	blockHash, err := e.BlockHash(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := e.blockHeader(blockHash.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	var block esploraBlock
+	if err := e.getJSON("/block/"+blockHash.String(), &block); err != nil {
+		return nil, nil, err
+	}
+
+	blockParsedResult := make([]*types.BitcoinTxParseResult, 0)
+	// txs are paged, start from the page including txIndex
+	for start := txIndex / EsploraTxsPageSize * EsploraTxsPageSize; start < block.TxCount; start += EsploraTxsPageSize {
+		var txs []esploraTx
+		if err := e.getJSON(fmt.Sprintf("/block/%s/txs/%d", blockHash.String(), start), &txs); err != nil {
+			return nil, nil, err
+		}
+		for k, v := range txs {
+			index := start + int64(k)
+			if index < txIndex {
+				continue
+			}
+			e.logger.Debugw("parse block", "k", index, "height", height, "txIndex", txIndex, "tx", v.TxID)
+			parseTxs, err := e.parseTx(v, int(index))
+			if err != nil {
+				return nil, nil, err
+			}
+			blockParsedResult = append(blockParsedResult, parseTxs...)
+		}
+		if len(txs) < EsploraTxsPageSize {
+			break
+		}
+	}
+	return blockParsedResult, header, nil
+}
+
+// parseTx parse transaction data, one result per matched listened address
+func (e *EsploraIndexer) parseTx(tx esploraTx, index int) ([]*types.BitcoinTxParseResult, error) {
+	msgTx, err := tx.msgTx()
+	if err != nil {
+		return nil, err
+	}
+	return e.parseTxOuts(tx.TxID, index, msgTx, func() ([]types.BitcoinFrom, error) {
+		return e.parseFromAddress(tx)
+	})
+}
+
+// msgTx outputs and input witnesses used by parser and classifiers, scriptsig is not included
+func (tx esploraTx) msgTx() (*wire.MsgTx, error) {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	for _, v := range tx.Vin {
+		witness := make(wire.TxWitness, 0, len(v.Witness))
+		for _, item := range v.Witness {
+			data, err := hex.DecodeString(item)
+			if err != nil {
+				return nil, fmt.Errorf("%w:decode witness %s", ErrEsploraRequest, err.Error())
+			}
+			witness = append(witness, data)
+		}
+		txIn := &wire.TxIn{Witness: witness}
+		if !v.IsCoinbase {
+			prevHash, err := chainhash.NewHashFromStr(v.TxID)
+			if err != nil {
+				return nil, fmt.Errorf("%w:decode prevout txid %s", ErrEsploraRequest, err.Error())
+			}
+			txIn.PreviousOutPoint = *wire.NewOutPoint(prevHash, v.Vout)
+		}
+		msgTx.AddTxIn(txIn)
+	}
+	for _, v := range tx.Vout {
+		pkScript, err := hex.DecodeString(v.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w:decode scriptpubkey %s", ErrEsploraRequest, err.Error())
+		}
+		msgTx.AddTxOut(wire.NewTxOut(v.Value, pkScript))
+	}
+	return msgTx, nil
+}
+
+// parseFromAddress from vin prevout parse from address
+func (e *EsploraIndexer) parseFromAddress(tx esploraTx) (fromAddress []types.BitcoinFrom, err error) {
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase {
+			continue
+		}
+		if vin.Prevout == nil {
+			return nil, fmt.Errorf("vin prevout is null")
+		}
+		vinPKScript, err := hex.DecodeString(vin.Prevout.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w:decode prevout scriptpubkey %s", ErrEsploraRequest, err.Error())
+		}
+		vinPkAddress, err := e.parseAddress(vinPKScript)
+		if err != nil {
+			e.logger.Errorw("vin parse address", "error", err)
+			if errors.Is(err, ErrParsePkScript) || errors.Is(err, ErrParsePkScriptNullData) {
+				continue
+			}
+			return nil, err
+		}
+		fromAddress = append(fromAddress, types.BitcoinFrom{
+			Address: vinPkAddress,
+			Value:   vin.Prevout.Value,
+			TxID:    vin.TxID,
+			Vout:    vin.Vout,
+		})
+	}
+	return fromAddress, nil
+}
+
+// LatestBlock get latest block height in the longest block chain.
+func (e *EsploraIndexer) LatestBlock() (int64, error) {
+	body, err := e.get("/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w:parse tip height %s", ErrEsploraRequest, err.Error())
+	}
+	return height, nil
+}
+
+// BlockHash get block hash by height in the longest block chain.
+func (e *EsploraIndexer) BlockHash(height int64) (*chainhash.Hash, error) {
+	body, err := e.get(fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(strings.TrimSpace(string(body)))
+}
+
+// CheckConfirmations check tx confirmations reach target confirmations
+func (e *EsploraIndexer) CheckConfirmations(hash string) error {
+	var status esploraTxStatus
+	if err := e.getJSON(fmt.Sprintf("/tx/%s/status", hash), &status); err != nil {
+		return err
+	}
+	var confirmations uint64
+	if status.Confirmed {
+		latestBlock, err := e.LatestBlock()
+		if err != nil {
+			return err
+		}
+		confirmations = uint64(latestBlock - status.BlockHeight + 1)
+	}
+	if confirmations < e.targetConfirmations {
+		return fmt.Errorf("%w, current confirmations:%d target confirmations: %d",
+			ErrTargetConfirmations, confirmations, e.targetConfirmations)
+	}
+	return nil
+}
+
+// ParseMempool parse unconfirmed txs paying listened addresses
+func (e *EsploraIndexer) ParseMempool() ([]*types.BitcoinTxParseResult, error) {
+	results := make([]*types.BitcoinTxParseResult, 0)
+	// a tx paying multiple listened addresses is returned for each address
+	parsed := make(map[string]struct{})
+	for _, v := range e.listenAddresses {
+		var txs []esploraTx
+		if err := e.getJSON(fmt.Sprintf("/address/%s/txs/mempool", v.address.EncodeAddress()), &txs); err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			if _, ok := parsed[tx.TxID]; ok {
+				continue
+			}
+			parsed[tx.TxID] = struct{}{}
+			parseTxs, err := e.parseTx(tx, 0)
+			if err != nil {
+				e.logger.Warnw("mempool parse tx", "txId", tx.TxID, "error", err)
+				continue
+			}
+			results = append(results, parseTxs...)
+		}
+	}
+	return results, nil
+}
+
+// TxExists whether the tx is in mempool or block chain
+func (e *EsploraIndexer) TxExists(hash string) (bool, error) {
+	resp, err := e.client.R().Get(fmt.Sprintf("%s/tx/%s/status", e.baseURL, hash))
+	if err != nil {
+		return false, fmt.Errorf("%w:%s", ErrEsploraRequest, err.Error())
+	}
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w:tx status code: %d", ErrEsploraRequest, resp.StatusCode())
+	}
+}
+
+func (e *EsploraIndexer) blockHeader(blockHash string) (*wire.BlockHeader, error) {
+	body, err := e.get(fmt.Sprintf("/block/%s/header", blockHash))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("%w:decode block header %s", ErrEsploraRequest, err.Error())
+	}
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("%w:deserialize block header %s", ErrEsploraRequest, err.Error())
+	}
+	return &header, nil
+}
+
+func (e *EsploraIndexer) getJSON(path string, v interface{}) error {
+	body, err := e.get(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w:unmarshal %s %s", ErrEsploraRequest, path, err.Error())
+	}
+	return nil
+}
+
+func (e *EsploraIndexer) get(path string) ([]byte, error) {
+	resp, err := e.client.R().Get(e.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("%w:%s", ErrEsploraRequest, err.Error())
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("%w:%s status code: %d, body: %s",
+			ErrEsploraRequest, path, resp.StatusCode(), strings.TrimSpace(string(resp.Body())))
+	}
+	return resp.Body(), nil
+}
@@ -0,0 +1,157 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	esploraBlockHash   = "258344bb00f4a21ffc218c73834fe8298fae7a57a7f3536a51430b148481aeb9"
+	esploraBlockHeight = 200000
+	esploraDepositTx   = "bb00000000000000000000000000000000000000000000000000000000000002"
+	esploraPendingTx   = "dd00000000000000000000000000000000000000000000000000000000000004"
+)
+
+// mockEsploraServer serves recorded esplora responses, file name is the request path joined by _
+func mockEsploraServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/"), "/", "_")
+		data, err := os.ReadFile(filepath.Join("testdata", "esplora", name))
+		if err != nil {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mockEsploraIndexer(t *testing.T, targetConfirmations uint64) *bitcoin.EsploraIndexer {
+	server := mockEsploraServer(t)
+	indexer, err := bitcoin.NewEsploraIndexer(
+		log.NewNopLogger(),
+		server.URL+"/",
+		&chaincfg.SigNetParams,
+		[]config.ListenAddress{{Label: config.DefaultListenAddressLabel, Address: fixtureListenAddress}},
+		targetConfirmations,
+	)
+	require.NoError(t, err)
+	return indexer
+}
+
+func TestEsploraIndexerParseBlock(t *testing.T) {
+	indexer := mockEsploraIndexer(t, 1)
+
+	latest, err := indexer.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, int64(esploraBlockHeight+5), latest)
+
+	hash, err := indexer.BlockHash(esploraBlockHeight)
+	require.NoError(t, err)
+	require.Equal(t, esploraBlockHash, hash.String())
+
+	results, header, err := indexer.ParseBlock(esploraBlockHeight, 0)
+	require.NoError(t, err)
+	require.Equal(t, esploraBlockHash, header.BlockHash().String())
+	require.Len(t, results, 1)
+	require.Equal(t, esploraDepositTx, results[0].TxID)
+	require.Equal(t, int64(1), results[0].Index)
+	require.Equal(t, bitcoin.TxTypeTransfer, results[0].TxType)
+	require.Equal(t, int64(150000), results[0].Value)
+	require.Equal(t, fixtureListenAddress, results[0].To)
+	require.Equal(t, config.DefaultListenAddressLabel, results[0].ToLabel)
+	require.Equal(t, "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", results[0].From[0].Address)
+	require.Equal(t, int64(500000), results[0].From[0].Value)
+	require.Equal(t, "cc00000000000000000000000000000000000000000000000000000000000003", results[0].From[0].TxID)
+	require.Equal(t, uint32(1), results[0].From[0].Vout)
+	require.Equal(t, "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", results[0].FromAddress)
+	require.Equal(t, config.FromAttributionFirst, results[0].FromAttribution)
+	require.Equal(t, "0x1212121212121212121212121212121212121212", results[0].MemoAddress)
+	require.Len(t, results[0].Tos, 2)
+
+	// start after the deposit tx
+	results, _, err = indexer.ParseBlock(esploraBlockHeight, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+
+	_, _, err = indexer.ParseBlock(esploraBlockHeight+1, 0)
+	require.True(t, errors.Is(err, bitcoin.ErrEsploraRequest))
+}
+
+func TestEsploraIndexerCheckConfirmations(t *testing.T) {
+	testCases := []struct {
+		name                string
+		txHash              string
+		targetConfirmations uint64
+		err                 error
+	}{
+		{
+			name:                "success",
+			txHash:              esploraDepositTx,
+			targetConfirmations: 6,
+		},
+		{
+			name:                "fail: confirmations not reached",
+			txHash:              esploraDepositTx,
+			targetConfirmations: 7,
+			err:                 bitcoin.ErrTargetConfirmations,
+		},
+		{
+			name:                "fail: unconfirmed",
+			txHash:              esploraPendingTx,
+			targetConfirmations: 1,
+			err:                 bitcoin.ErrTargetConfirmations,
+		},
+		{
+			name:                "fail: tx not found",
+			txHash:              esploraBlockHash,
+			targetConfirmations: 1,
+			err:                 bitcoin.ErrEsploraRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := mockEsploraIndexer(t, tc.targetConfirmations).CheckConfirmations(tc.txHash)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEsploraIndexerParseMempool(t *testing.T) {
+	indexer := mockEsploraIndexer(t, 1)
+
+	results, err := indexer.ParseMempool()
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, esploraPendingTx, results[0].TxID)
+	require.Equal(t, int64(80000), results[0].Value)
+	require.Equal(t, fixtureListenAddress, results[0].To)
+	require.Equal(t, "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n", results[0].From[0].Address)
+	require.Equal(t, "", results[0].MemoAddress)
+
+	exists, err := indexer.TxExists(esploraPendingTx)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// evicted or replaced
+	exists, err = indexer.TxExists(esploraBlockHash)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
@@ -0,0 +1,295 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// DefaultEthRPCTimeout timeout of a single rpc request attempt
+	DefaultEthRPCTimeout = 30 * time.Second
+	// EthRPCHealthCheckInterval unhealthy endpoints are probed again after the interval
+	EthRPCHealthCheckInterval = 30 * time.Second
+	// EthRPCRetryBackoff wait before retrying a failed request
+	EthRPCRetryBackoff = 200 * time.Millisecond
+)
+
+var (
+	ErrEthRPCUnavailable       = errors.New("eth rpc unavailable")
+	ErrEthRPCWebsocketFailover = errors.New("websocket eth rpc url does not support failover, use http urls or remove eth-rpc-urls")
+)
+
+// nonIdempotentMethods json rpc methods not retried, a retried tx may be sent twice or fail as already known
+var nonIdempotentMethods = map[string]struct{}{
+	"eth_sendRawTransaction": {},
+	"eth_sendTransaction":    {},
+}
+
+// healthCheckBody eth_blockNumber request probing endpoint health
+var healthCheckBody = []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+
+// NewEthClient returns a long-lived eth client of the bridge rpc urls,
+// requests fail over between endpoints and transient transport errors are retried.
+// a websocket url is dialed directly without failover, timeout and retry
+func NewEthClient(bridgeCfg config.BridgeConfig, logger log.Logger) (*ethclient.Client, error) {
+	urls := append([]string{bridgeCfg.EthRPCURL}, bridgeCfg.EthRPCURLs...)
+	for _, v := range urls {
+		if !isWebsocketURL(v) {
+			continue
+		}
+		if len(urls) > 1 {
+			return nil, fmt.Errorf("%w: %s", ErrEthRPCWebsocketFailover, v)
+		}
+		logger.Warnw("websocket eth rpc url, requests are not failed over or retried", "url", v)
+		return ethclient.Dial(v)
+	}
+	timeout := time.Duration(bridgeCfg.EthRPCTimeout) * time.Second
+	transport, err := NewEthRPCTransport(urls, timeout, bridgeCfg.EthRPCRetry, logger)
+	if err != nil {
+		return nil, err
+	}
+	rpcClient, err := rpc.DialOptions(context.Background(), urls[0], rpc.WithHTTPClient(&http.Client{
+		Transport: transport,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
+type ethRPCEndpoint struct {
+	url      *url.URL
+	healthy  bool
+	failedAt time.Time
+}
+
+// EthRPCTransport http transport of the eth json rpc, requests are sent to the first healthy endpoint,
+// endpoints failing with transient errors are skipped until a health check passes
+type EthRPCTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+	retry   int
+	log     log.Logger
+
+	mu        sync.Mutex
+	endpoints []*ethRPCEndpoint
+}
+
+// NewEthRPCTransport returns a new transport of the http endpoints, timeout is per attempt
+func NewEthRPCTransport(urls []string, timeout time.Duration, retry int, logger log.Logger) (*EthRPCTransport, error) {
+	if timeout <= 0 {
+		timeout = DefaultEthRPCTimeout
+	}
+	if retry < 0 {
+		retry = 0
+	}
+	t := &EthRPCTransport{
+		base:    http.DefaultTransport,
+		timeout: timeout,
+		retry:   retry,
+		log:     logger,
+	}
+	for _, v := range urls {
+		endpoint, err := url.ParseRequestURI(v)
+		if err != nil {
+			return nil, err
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, fmt.Errorf("unsupported eth rpc url scheme:%s", v)
+		}
+		t.endpoints = append(t.endpoints, &ethRPCEndpoint{url: endpoint, healthy: true})
+	}
+	if len(t.endpoints) == 0 {
+		return nil, fmt.Errorf("%w: no endpoint", ErrEthRPCUnavailable)
+	}
+	return t, nil
+}
+
+// RoundTrip send the request to a healthy endpoint, transient errors are retried on the next endpoint,
+// requests of non-idempotent methods are sent once
+func (t *EthRPCTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	retry := t.retry
+	if !IdempotentRequest(body) {
+		retry = 0
+	}
+	var lastErr error
+	for attempt := 0; attempt <= retry; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-time.After(EthRPCRetryBackoff):
+			}
+		}
+		endpoint := t.endpoint(req.Context())
+		resp, err := t.send(req, endpoint.url, body)
+		if err == nil && !transientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, req.Context().Err()
+		}
+		if err == nil {
+			err = fmt.Errorf("status %s", resp.Status)
+			resp.Body.Close()
+		}
+		lastErr = err
+		t.markFailed(endpoint, err)
+	}
+	return nil, fmt.Errorf("%w: %w", ErrEthRPCUnavailable, lastErr)
+}
+
+// send the request to the endpoint with the attempt timeout, the timeout is canceled when the body is closed
+func (t *EthRPCTransport) send(req *http.Request, endpoint *url.URL, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	r := req.Clone(ctx)
+	r.URL = endpoint
+	r.Host = ""
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// endpoint returns the first healthy endpoint, unhealthy endpoints are health checked after the interval,
+// the longest failed endpoint is returned if all are unhealthy
+func (t *EthRPCTransport) endpoint(ctx context.Context) *ethRPCEndpoint {
+	t.mu.Lock()
+	endpoints := make([]*ethRPCEndpoint, len(t.endpoints))
+	copy(endpoints, t.endpoints)
+	t.mu.Unlock()
+
+	var oldest *ethRPCEndpoint
+	for _, endpoint := range endpoints {
+		t.mu.Lock()
+		healthy, failedAt := endpoint.healthy, endpoint.failedAt
+		t.mu.Unlock()
+		if healthy {
+			return endpoint
+		}
+		if time.Since(failedAt) >= EthRPCHealthCheckInterval && t.healthCheck(ctx, endpoint) {
+			return endpoint
+		}
+		if oldest == nil || failedAt.Before(oldest.failedAt) {
+			oldest = endpoint
+		}
+	}
+	return oldest
+}
+
+// healthCheck probe the endpoint by eth_blockNumber
+func (t *EthRPCTransport) healthCheck(ctx context.Context, endpoint *ethRPCEndpoint) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.url.String(), bytes.NewReader(healthCheckBody))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.send(req, endpoint.url, healthCheckBody)
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+	}
+	if err != nil {
+		t.markFailed(endpoint, err)
+		return false
+	}
+	t.mu.Lock()
+	endpoint.healthy = true
+	t.mu.Unlock()
+	t.log.Infow("eth rpc endpoint recovered", "url", endpoint.url.Redacted())
+	return true
+}
+
+func (t *EthRPCTransport) markFailed(endpoint *ethRPCEndpoint, err error) {
+	t.mu.Lock()
+	endpoint.healthy = false
+	endpoint.failedAt = time.Now()
+	t.mu.Unlock()
+	t.log.Warnw("eth rpc endpoint failed", "url", endpoint.url.Redacted(), "error", err.Error())
+}
+
+// Healthy returns whether the endpoints are healthy, in the configured order
+func (t *EthRPCTransport) Healthy() []bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	healthy := make([]bool, 0, len(t.endpoints))
+	for _, endpoint := range t.endpoints {
+		healthy = append(healthy, endpoint.healthy)
+	}
+	return healthy
+}
+
+// IdempotentRequest whether the json rpc request or batch request may be retried, unknown requests are not
+func IdempotentRequest(body []byte) bool {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	if err := json.Unmarshal(body, &calls); err != nil {
+		var single call
+		if err := json.Unmarshal(body, &single); err != nil {
+			return false
+		}
+		calls = []call{single}
+	}
+	for _, v := range calls {
+		if _, ok := nonIdempotentMethods[v.Method]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isWebsocketURL(rawURL string) bool {
+	endpoint, err := url.Parse(rawURL)
+	return err == nil && (endpoint.Scheme == "ws" || endpoint.Scheme == "wss")
+}
+
+// transientStatus rate limited or server errors, json rpc errors are returned with status ok
+func transientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,266 @@
+package bitcoin_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// rpcStub json rpc stub answering eth_blockNumber, the first failures requests fail with status
+type rpcStub struct {
+	server   *httptest.Server
+	requests atomic.Int64
+	failures int64
+	status   int
+	delay    time.Duration
+	rpcError bool
+}
+
+func newRPCStub(t *testing.T, stub *rpcStub) *rpcStub {
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := stub.requests.Add(1)
+		if stub.delay > 0 {
+			select {
+			case <-time.After(stub.delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if n <= stub.failures {
+			w.WriteHeader(stub.status)
+			return
+		}
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if stub.rpcError {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":"execution reverted"}}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x10"}`, req.ID)
+	}))
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func newTestEthClient(t *testing.T, timeout time.Duration, retry int, stubs ...*rpcStub) (*ethclient.Client, *bitcoin.EthRPCTransport) {
+	urls := make([]string, 0, len(stubs))
+	for _, v := range stubs {
+		urls = append(urls, v.server.URL)
+	}
+	transport, err := bitcoin.NewEthRPCTransport(urls, timeout, retry, log.NewNopLogger())
+	require.NoError(t, err)
+	rpcClient, err := rpc.DialOptions(context.Background(), urls[0], rpc.WithHTTPClient(&http.Client{Transport: transport}))
+	require.NoError(t, err)
+	client := ethclient.NewClient(rpcClient)
+	t.Cleanup(client.Close)
+	return client, transport
+}
+
+func TestEthRPCTransport(t *testing.T) {
+	testCases := []struct {
+		name     string
+		stubs    []*rpcStub
+		timeout  time.Duration
+		retry    int
+		healthy  []bool
+		requests []int64
+		err      error
+		rpcErr   bool
+	}{
+		{
+			name:     "success: primary",
+			stubs:    []*rpcStub{{}, {}},
+			retry:    1,
+			healthy:  []bool{true, true},
+			requests: []int64{2, 0},
+		},
+		{
+			name:     "success: failover",
+			stubs:    []*rpcStub{{failures: 100, status: http.StatusServiceUnavailable}, {}},
+			retry:    1,
+			healthy:  []bool{false, true},
+			requests: []int64{1, 2},
+		},
+		{
+			name:     "success: retry transient error",
+			stubs:    []*rpcStub{{failures: 2, status: http.StatusTooManyRequests}},
+			retry:    2,
+			healthy:  []bool{false},
+			requests: []int64{4},
+		},
+		{
+			name:     "success: timeout failover",
+			stubs:    []*rpcStub{{delay: time.Second}, {}},
+			timeout:  50 * time.Millisecond,
+			retry:    1,
+			healthy:  []bool{false, true},
+			requests: []int64{1, 2},
+		},
+		{
+			name:     "fail: retry exhausted",
+			stubs:    []*rpcStub{{failures: 100, status: http.StatusBadGateway}},
+			retry:    1,
+			healthy:  []bool{false},
+			requests: []int64{2},
+			err:      bitcoin.ErrEthRPCUnavailable,
+		},
+		{
+			name:     "fail: json rpc error not retried",
+			stubs:    []*rpcStub{{rpcError: true}, {}},
+			retry:    2,
+			healthy:  []bool{true, true},
+			requests: []int64{1, 0},
+			rpcErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, v := range tc.stubs {
+				newRPCStub(t, v)
+			}
+			client, transport := newTestEthClient(t, tc.timeout, tc.retry, tc.stubs...)
+			blockNumber, err := client.BlockNumber(context.Background())
+			switch {
+			case tc.err != nil:
+				require.ErrorIs(t, err, tc.err)
+			case tc.rpcErr:
+				require.ErrorContains(t, err, "execution reverted")
+			default:
+				require.NoError(t, err)
+				require.Equal(t, uint64(16), blockNumber)
+				// the next request goes to the healthy endpoint directly
+				_, err = client.BlockNumber(context.Background())
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.healthy, transport.Healthy())
+			for i, v := range tc.stubs {
+				require.Equal(t, tc.requests[i], v.requests.Load(), "endpoint %d", i)
+			}
+		})
+	}
+}
+
+func TestNewEthRPCTransport(t *testing.T) {
+	_, err := bitcoin.NewEthRPCTransport(nil, 0, 0, log.NewNopLogger())
+	require.ErrorIs(t, err, bitcoin.ErrEthRPCUnavailable)
+	_, err = bitcoin.NewEthRPCTransport([]string{"ws://127.0.0.1:8546"}, 0, 0, log.NewNopLogger())
+	require.Error(t, err)
+	_, err = bitcoin.NewEthRPCTransport([]string{"localhost"}, 0, 0, log.NewNopLogger())
+	require.Error(t, err)
+}
+
+func TestIdempotentRequest(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		idempotent bool
+	}{
+		{
+			name:       "success: call",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`,
+			idempotent: true,
+		},
+		{
+			name:       "success: batch",
+			body:       `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}]`,
+			idempotent: true,
+		},
+		{
+			name: "fail: send raw transaction",
+			body: `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`,
+		},
+		{
+			name: "fail: send raw transaction in batch",
+			body: `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":2,"method":"eth_sendRawTransaction"}]`,
+		},
+		{
+			name: "fail: unknown request",
+			body: `not json`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.idempotent, bitcoin.IdempotentRequest([]byte(tc.body)))
+		})
+	}
+}
+
+func TestEthRPCTransportSendRawTransaction(t *testing.T) {
+	primary := newRPCStub(t, &rpcStub{failures: 100, status: http.StatusServiceUnavailable})
+	failover := newRPCStub(t, &rpcStub{})
+	client, transport := newTestEthClient(t, 0, 2, primary, failover)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1337)), &types.LegacyTx{
+		Nonce:    1,
+		Gas:      21000,
+		GasPrice: big.NewInt(1),
+	})
+	require.NoError(t, err)
+
+	// the tx may have reached the node, it is not sent again to the failover endpoint
+	err = client.SendTransaction(context.Background(), tx)
+	require.ErrorIs(t, err, bitcoin.ErrEthRPCUnavailable)
+	require.Equal(t, int64(1), primary.requests.Load())
+	require.Equal(t, int64(0), failover.requests.Load())
+	require.Equal(t, []bool{false, true}, transport.Healthy())
+}
+
+type wsEthService struct{}
+
+func (wsEthService) BlockNumber() hexutil.Uint64 {
+	return 16
+}
+
+func TestNewEthClientWebsocket(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", wsEthService{}))
+	t.Cleanup(server.Stop)
+	wsServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	t.Cleanup(wsServer.Close)
+	wsURL := "ws" + strings.TrimPrefix(wsServer.URL, "http")
+
+	client, err := bitcoin.NewEthClient(config.BridgeConfig{EthRPCURL: wsURL}, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	blockNumber, err := client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(16), blockNumber)
+
+	_, err = bitcoin.NewEthClient(config.BridgeConfig{
+		EthRPCURL:  wsURL,
+		EthRPCURLs: []string{"http://127.0.0.1:8545"},
+	}, log.NewNopLogger())
+	require.ErrorIs(t, err, bitcoin.ErrEthRPCWebsocketFailover)
+	_, err = bitcoin.NewEthClient(config.BridgeConfig{
+		EthRPCURL:  "http://127.0.0.1:8545",
+		EthRPCURLs: []string{wsURL},
+	}, log.NewNopLogger())
+	require.ErrorIs(t, err, bitcoin.ErrEthRPCWebsocketFailover)
+}
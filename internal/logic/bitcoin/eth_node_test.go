@@ -0,0 +1,377 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testEthChainID = 1337
+	// testEthBaseFee base fee of every block
+	testEthBaseFee = 1_000_000_000
+	// testEthGas gas estimated and used by every tx
+	testEthGas = 100_000
+)
+
+// mockEthNode eth json-rpc stand-in, sent txs are pooled until Commit mines them.
+// every mined call to the contract succeeds and emits DepositEvent of deposit(bytes32,address,uint256)
+type mockEthNode struct {
+	contract common.Address
+	eventID  common.Hash
+	signer   ethTypes.Signer
+
+	mu      sync.Mutex
+	headers []*ethTypes.Header
+	pool    []*ethTypes.Transaction
+	// mined txs and receipts by tx hash
+	txs      map[common.Hash]*ethTypes.Transaction
+	receipts map[common.Hash]*ethTypes.Receipt
+	// nonces mined nonce by sender
+	nonces map[common.Address]uint64
+}
+
+func newMockEthNode(t *testing.T, contract common.Address) *mockEthNode {
+	contractAbi, err := abi.JSON(strings.NewReader(config.DefaultDepositAbi))
+	require.NoError(t, err)
+	event, ok := contractAbi.Events[bitcoin.DepositEventName]
+	require.True(t, ok)
+	return &mockEthNode{
+		contract: contract,
+		eventID:  event.ID,
+		signer:   ethTypes.LatestSignerForChainID(big.NewInt(testEthChainID)),
+		headers: []*ethTypes.Header{{
+			Number:     big.NewInt(0),
+			Difficulty: big.NewInt(0),
+			GasLimit:   30_000_000,
+			Time:       uint64(time.Now().Unix()),
+			BaseFee:    big.NewInt(testEthBaseFee),
+		}},
+		txs:      make(map[common.Hash]*ethTypes.Transaction),
+		receipts: make(map[common.Hash]*ethTypes.Receipt),
+		nonces:   make(map[common.Address]uint64),
+	}
+}
+
+// Commit mine the pooled txs in a new block
+func (m *mockEthNode) Commit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	parent := m.headers[len(m.headers)-1]
+	header := &ethTypes.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, big.NewInt(1)),
+		Difficulty: big.NewInt(0),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		BaseFee:    big.NewInt(testEthBaseFee),
+	}
+	// txs are mined in nonce order, nonce gaps are left in the pool
+	sort.SliceStable(m.pool, func(i, j int) bool {
+		return m.pool[i].Nonce() < m.pool[j].Nonce()
+	})
+	var (
+		pool    []*ethTypes.Transaction
+		mined   []*ethTypes.Transaction
+		gasUsed uint64
+	)
+	for _, tx := range m.pool {
+		from, _ := ethTypes.Sender(m.signer, tx)
+		if tx.Nonce() != m.nonces[from] {
+			pool = append(pool, tx)
+			continue
+		}
+		m.nonces[from]++
+		mined = append(mined, tx)
+		gasUsed += testEthGas
+	}
+	header.GasUsed = gasUsed
+	blockHash := header.Hash()
+	for i, tx := range mined {
+		from, _ := ethTypes.Sender(m.signer, tx)
+		receipt := &ethTypes.Receipt{
+			Type:              tx.Type(),
+			Status:            ethTypes.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(i+1) * testEthGas,
+			TxHash:            tx.Hash(),
+			GasUsed:           testEthGas,
+			EffectiveGasPrice: m.effectiveGasPrice(tx),
+			BlockHash:         blockHash,
+			BlockNumber:       header.Number,
+			TransactionIndex:  uint(i),
+			Logs:              []*ethTypes.Log{},
+		}
+		data := tx.Data()
+		if tx.To() != nil && *tx.To() == m.contract && len(data) >= 4+3*32 {
+			receipt.Logs = append(receipt.Logs, &ethTypes.Log{
+				Address:     m.contract,
+				Topics:      []common.Hash{m.eventID, common.BytesToHash(from.Bytes()), common.BytesToHash(data[4+32 : 4+64])},
+				Data:        append(append([]byte{}, data[4+64:4+96]...), data[4:4+32]...),
+				BlockNumber: header.Number.Uint64(),
+				TxHash:      tx.Hash(),
+				TxIndex:     uint(i),
+				BlockHash:   blockHash,
+			})
+		}
+		receipt.Bloom = ethTypes.CreateBloom(ethTypes.Receipts{receipt})
+		m.txs[tx.Hash()] = tx
+		m.receipts[tx.Hash()] = receipt
+	}
+	m.pool = pool
+	m.headers = append(m.headers, header)
+}
+
+func (m *mockEthNode) effectiveGasPrice(tx *ethTypes.Transaction) *big.Int {
+	if tx.Type() == ethTypes.LegacyTxType {
+		return tx.GasPrice()
+	}
+	price := new(big.Int).Add(tx.GasTipCap(), big.NewInt(testEthBaseFee))
+	if price.Cmp(tx.GasFeeCap()) > 0 {
+		return tx.GasFeeCap()
+	}
+	return price
+}
+
+// pendingNonce next nonce of the sender including pooled txs
+func (m *mockEthNode) pendingNonce(from common.Address) uint64 {
+	nonce := m.nonces[from]
+	for {
+		found := false
+		for _, tx := range m.pool {
+			sender, _ := ethTypes.Sender(m.signer, tx)
+			if sender == from && tx.Nonce() == nonce {
+				nonce++
+				found = true
+			}
+		}
+		if !found {
+			return nonce
+		}
+	}
+}
+
+type mockEthRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type mockEthError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type mockEthResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *mockEthError   `json:"error,omitempty"`
+}
+
+func (m *mockEthNode) handle(req mockEthRequest) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	param := func(i int, v interface{}) error {
+		if i >= len(req.Params) {
+			return fmt.Errorf("missing param %d", i)
+		}
+		return json.Unmarshal(req.Params[i], v)
+	}
+	switch req.Method {
+	case "eth_chainId":
+		return hexutil.Uint64(testEthChainID), nil
+	case "eth_blockNumber":
+		return hexutil.Uint64(len(m.headers) - 1), nil
+	case "eth_getBlockByNumber":
+		var tag string
+		if err := param(0, &tag); err != nil {
+			return nil, err
+		}
+		header := m.headers[len(m.headers)-1]
+		if number, err := hexutil.DecodeUint64(tag); err == nil {
+			if number >= uint64(len(m.headers)) {
+				return nil, nil
+			}
+			header = m.headers[number]
+		}
+		return header, nil
+	case "eth_getTransactionCount":
+		var (
+			from common.Address
+			tag  string
+		)
+		if err := param(0, &from); err != nil {
+			return nil, err
+		}
+		if err := param(1, &tag); err != nil {
+			return nil, err
+		}
+		if tag == "pending" {
+			return hexutil.Uint64(m.pendingNonce(from)), nil
+		}
+		return hexutil.Uint64(m.nonces[from]), nil
+	case "eth_getBalance":
+		return (*hexutil.Big)(big.NewInt(1e18)), nil
+	case "eth_gasPrice":
+		return (*hexutil.Big)(big.NewInt(2 * testEthBaseFee)), nil
+	case "eth_maxPriorityFeePerGas":
+		return (*hexutil.Big)(big.NewInt(testEthBaseFee)), nil
+	case "eth_feeHistory":
+		return map[string]interface{}{
+			"oldestBlock":   (*hexutil.Big)(big.NewInt(int64(len(m.headers) - 1))),
+			"baseFeePerGas": []*hexutil.Big{(*hexutil.Big)(big.NewInt(testEthBaseFee)), (*hexutil.Big)(big.NewInt(testEthBaseFee))},
+			"gasUsedRatio":  []float64{0.5},
+			"reward":        [][]*hexutil.Big{{(*hexutil.Big)(big.NewInt(testEthBaseFee))}},
+		}, nil
+	case "eth_estimateGas":
+		return hexutil.Uint64(testEthGas), nil
+	case "eth_call":
+		return hexutil.Bytes{}, nil
+	case "eth_sendRawTransaction":
+		var raw hexutil.Bytes
+		if err := param(0, &raw); err != nil {
+			return nil, err
+		}
+		tx := new(ethTypes.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		from, err := ethTypes.Sender(m.signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.txs[tx.Hash()]; ok {
+			return nil, fmt.Errorf("already known")
+		}
+		for i, pooled := range m.pool {
+			if pooled.Hash() == tx.Hash() {
+				return nil, fmt.Errorf("already known")
+			}
+			sender, _ := ethTypes.Sender(m.signer, pooled)
+			// replacement of the pooled tx with the same nonce
+			if sender == from && pooled.Nonce() == tx.Nonce() {
+				m.pool[i] = tx
+				return tx.Hash(), nil
+			}
+		}
+		if tx.Nonce() < m.nonces[from] {
+			return nil, fmt.Errorf("nonce too low: address %s, tx: %d state: %d", from.Hex(), tx.Nonce(), m.nonces[from])
+		}
+		m.pool = append(m.pool, tx)
+		return tx.Hash(), nil
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		if err := param(0, &hash); err != nil {
+			return nil, err
+		}
+		receipt, ok := m.receipts[hash]
+		if !ok {
+			return nil, nil
+		}
+		return receipt, nil
+	case "eth_getTransactionByHash":
+		var hash common.Hash
+		if err := param(0, &hash); err != nil {
+			return nil, err
+		}
+		tx, ok := m.txs[hash]
+		receipt := m.receipts[hash]
+		if !ok {
+			for _, pooled := range m.pool {
+				if pooled.Hash() == hash {
+					tx = pooled
+				}
+			}
+		}
+		if tx == nil {
+			return nil, nil
+		}
+		return m.rpcTransaction(tx, receipt)
+	default:
+		return nil, fmt.Errorf("the method %s does not exist/is not available", req.Method)
+	}
+}
+
+// rpcTransaction tx json with block fields, block fields are null if pending
+func (m *mockEthNode) rpcTransaction(tx *ethTypes.Transaction, receipt *ethTypes.Receipt) (json.RawMessage, error) {
+	data, err := tx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	from, _ := ethTypes.Sender(m.signer, tx)
+	fields["from"] = from
+	fields["blockHash"] = nil
+	fields["blockNumber"] = nil
+	fields["transactionIndex"] = nil
+	if receipt != nil {
+		fields["blockHash"] = receipt.BlockHash
+		fields["blockNumber"] = (*hexutil.Big)(receipt.BlockNumber)
+		fields["transactionIndex"] = hexutil.Uint64(receipt.TransactionIndex)
+	}
+	return json.Marshal(fields)
+}
+
+func (m *mockEthNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req mockEthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := mockEthResponse{Version: "2.0", ID: req.ID}
+	result, err := m.handle(req)
+	if err != nil {
+		resp.Error = &mockEthError{Code: -32000, Message: err.Error()}
+	} else {
+		resp.Result = result
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// newTestBridge bridge sending to the mock eth node
+func newTestBridge(t *testing.T, contract common.Address) (*bitcoin.Bridge, *mockEthNode) {
+	key, err := crypto.HexToECDSA("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	node := newMockEthNode(t, contract)
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+	bridge, err := bitcoin.NewBridge(config.BridgeConfig{
+		EthRPCURL:           server.URL,
+		ContractAddress:     contract.Hex(),
+		EthPrivKey:          hexutil.Encode(crypto.FromECDSA(key)),
+		ABI:                 "not_found.json",
+		AAParticleRPC:       "http://localhost:8545",
+		AAParticleProjectID: "1111",
+		AAParticleChainID:   testEthChainID,
+	}, "./testdata", log.NewNopLogger(), &chaincfg.TestNet3Params)
+	require.NoError(t, err)
+	return bridge, node
+}
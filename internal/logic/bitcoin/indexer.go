@@ -1,9 +1,12 @@
 package bitcoin
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/b2network/b2-indexer/internal/config"
 	"github.com/b2network/b2-indexer/internal/types"
 	"github.com/b2network/b2-indexer/pkg/log"
 	"github.com/btcsuite/btcd/btcjson"
@@ -13,6 +16,7 @@ import (
 	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 var (
@@ -21,6 +25,7 @@ var (
 	ErrTargetConfirmations   = errors.New("target confirmation number was not reached")
 	ErrParsePubKey           = errors.New("parse pubkey failed, not found pubkey or nonsupport ")
 	ErrParsePkScriptNullData = errors.New("parse pkscript null data err")
+	ErrParseEvmAddressMemo   = errors.New("parse evm address memo err")
 )
 
 const (
@@ -29,13 +34,70 @@ const (
 	TxTypeWithdraw = "withdraw" // btc withdraw
 )
 
+// EvmAddressMemoMagic op_return memo prefix, memo format: magic + 20 bytes evm address
+var EvmAddressMemoMagic = []byte("b2")
+
 // Indexer bitcoin indexer, parse and forward data
 type Indexer struct {
+	*txParser
 	client              *rpcclient.Client // call bitcoin rpc client
-	chainParams         *chaincfg.Params  // bitcoin network params, e.g. mainnet, testnet, etc.
-	listenAddress       btcutil.Address   // need listened bitcoin address
 	targetConfirmations uint64
-	logger              log.Logger
+
+	txFetcher      TxFetcher // prevout tx fetcher
+	txCache        *txCache  // recently seen txs, nil if disabled
+	prefetchBlocks int       // number of blocks fetched ahead
+	prefetchMu     sync.Mutex
+	prefetched     map[int64]*blockFetch
+	// prefetchClients clients of the prefetch goroutines, nil if blocks are prefetched by client
+	prefetchClients chan *rpcclient.Client
+	blockPrevouts   bool // fetch blocks with prevouts by getblock verbosity 3
+	prevoutsMu      sync.Mutex
+	prevouts        map[wire.OutPoint]*wire.TxOut // prevouts of the last fetched block
+
+	// mempoolParsed parsed mempool txs, value is nil if not paying listened addresses
+	mempoolParsed map[chainhash.Hash][]*types.BitcoinTxParseResult
+}
+
+// listenAddress decoded listened bitcoin address and its label
+type listenAddress struct {
+	label   string
+	address btcutil.Address
+}
+
+// txParser match tx outputs with listened addresses, shared by indexer backends
+type txParser struct {
+	chainParams     *chaincfg.Params  // bitcoin network params, e.g. mainnet, testnet, etc.
+	listenAddresses []listenAddress   // need listened bitcoin addresses
+	fromAttribution string            // l2 user attribution policy of tx inputs
+	classifiers     []TxClassifier    // tx classifiers, the first matched is used
+	txTypeActions   map[string]string // action of classified tx types
+	logger          log.Logger
+}
+
+func newTxParser(log log.Logger, chainParams *chaincfg.Params, listenAddresses []config.ListenAddress) (*txParser, error) {
+	if len(listenAddresses) == 0 {
+		return nil, fmt.Errorf("%w:%s", ErrDecodeListenAddress, "listen address is empty")
+	}
+	// check listenAddresses
+	addresses := make([]listenAddress, 0, len(listenAddresses))
+	for _, v := range listenAddresses {
+		address, err := btcutil.DecodeAddress(v.Address, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("%w:%s", ErrDecodeListenAddress, err.Error())
+		}
+		addresses = append(addresses, listenAddress{
+			label:   v.Label,
+			address: address,
+		})
+	}
+	return &txParser{
+		chainParams:     chainParams,
+		listenAddresses: addresses,
+		fromAttribution: config.FromAttributionFirst,
+		classifiers:     DefaultTxClassifiers(),
+		txTypeActions:   map[string]string{},
+		logger:          log,
+	}, nil
 }
 
 // NewBitcoinIndexer new bitcoin indexer
@@ -43,30 +105,41 @@ func NewBitcoinIndexer(
 	log log.Logger,
 	client *rpcclient.Client,
 	chainParams *chaincfg.Params,
-	listenAddress string,
+	listenAddresses []config.ListenAddress,
 	targetConfirmations uint64,
 ) (*Indexer, error) {
-	// check listenAddress
-	address, err := btcutil.DecodeAddress(listenAddress, chainParams)
+	parser, err := newTxParser(log, chainParams, listenAddresses)
 	if err != nil {
-		return nil, fmt.Errorf("%w:%s", ErrDecodeListenAddress, err.Error())
+		return nil, err
 	}
 	return &Indexer{
-		logger:              log,
+		txParser:            parser,
 		client:              client,
-		chainParams:         chainParams,
-		listenAddress:       address,
 		targetConfirmations: targetConfirmations,
+		txFetcher:           &rpcTxFetcher{client: client},
+		prefetched:          make(map[int64]*blockFetch),
+		mempoolParsed:       make(map[chainhash.Hash][]*types.BitcoinTxParseResult),
 	}, nil
 }
 
 // ParseBlock parse block data by block height
 // NOTE: Currently, only transfer transactions are supported.
 func (b *Indexer) ParseBlock(height int64, txIndex int64) ([]*types.BitcoinTxParseResult, *wire.BlockHeader, error) {
-	blockResult, err := b.getBlockByHeight(height)
+	blockResult, err := b.getBlock(height)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockParsedResult, err := b.ParseMsgBlock(blockResult, height, txIndex)
 	if err != nil {
 		return nil, nil, err
 	}
+	return blockParsedResult, &blockResult.Header, nil
+}
+
+// ParseMsgBlock parse block txs from txIndex
+func (b *Indexer) ParseMsgBlock(blockResult *wire.MsgBlock, height int64, txIndex int64) ([]*types.BitcoinTxParseResult, error) {
+	b.cacheBlockTxs(blockResult)
 
 	blockParsedResult := make([]*types.BitcoinTxParseResult, 0)
 	for k, v := range blockResult.Transactions {
@@ -78,26 +151,21 @@ func (b *Indexer) ParseBlock(height int64, txIndex int64) ([]*types.BitcoinTxPar
 
 		parseTxs, err := b.parseTx(v, k)
 		if err != nil {
-			return nil, nil, err
-		}
-		if parseTxs != nil {
-			blockParsedResult = append(blockParsedResult, parseTxs)
+			return nil, err
 		}
+		blockParsedResult = append(blockParsedResult, parseTxs...)
 	}
 
-	return blockParsedResult, &blockResult.Header, nil
+	return blockParsedResult, nil
 }
 
 // getBlockByHeight returns a raw block from the server given its height
 func (b *Indexer) getBlockByHeight(height int64) (*wire.MsgBlock, error) {
-	blockhash, err := b.client.GetBlockHash(height)
-	if err != nil {
-		return nil, err
-	}
-	msgBlock, err := b.client.GetBlock(blockhash)
+	msgBlock, prevouts, err := b.fetchBlock(b.client, height)
 	if err != nil {
 		return nil, err
 	}
+	b.setPrevouts(prevouts)
 	return msgBlock, nil
 }
 
@@ -118,13 +186,36 @@ func (b *Indexer) CheckConfirmations(hash string) error {
 	return nil
 }
 
-// parseTx parse transaction data
-func (b *Indexer) parseTx(txResult *wire.MsgTx, index int) (*types.BitcoinTxParseResult, error) {
-	listenAddress := false
-	var totalValue int64
+// parseTx parse transaction data, one result per matched listened address
+func (b *Indexer) parseTx(txResult *wire.MsgTx, index int) ([]*types.BitcoinTxParseResult, error) {
+	return b.parseTxOuts(txResult.TxHash().String(), index, txResult,
+		func() ([]types.BitcoinFrom, error) {
+			return b.parseFromAddress(txResult)
+		})
+}
+
+// parseTxOuts match tx outputs with listened addresses, parseFrom is only called if matched
+func (p *txParser) parseTxOuts(
+	txID string,
+	index int,
+	tx *wire.MsgTx,
+	parseFrom func() ([]types.BitcoinFrom, error),
+) ([]*types.BitcoinTxParseResult, error) {
+	// matched listened addresses in output order, with total value
+	matched := make([]listenAddress, 0)
+	totalValues := make(map[string]int64)
+	var memoAddress string
 	tos := make([]types.BitcoinTo, 0)
-	for _, v := range txResult.TxOut {
-		pkAddress, err := b.parseAddress(v.PkScript)
+	for _, v := range tx.TxOut {
+		// op_return output, try parse evm address memo, the first valid memo is used
+		if memoAddress == "" && txscript.GetScriptClass(v.PkScript) == txscript.NullDataTy {
+			address, err := ParseEvmAddressMemo(v.PkScript)
+			if err != nil {
+				p.logger.Debugw("parse evm address memo", "txId", txID, "error", err)
+			}
+			memoAddress = address
+		}
+		pkAddress, err := p.parseAddress(v.PkScript)
 		if err != nil {
 			if errors.Is(err, ErrParsePkScript) {
 				continue
@@ -141,53 +232,120 @@ func (b *Indexer) parseTx(txResult *wire.MsgTx, index int) (*types.BitcoinTxPars
 		}
 		tos = append(tos, parseTo)
 		// if pk address eq dest listened address, after parse from address by vin prev tx
-		if pkAddress == b.listenAddress.EncodeAddress() {
-			listenAddress = true
-			totalValue += v.Value
+		listened, ok := p.listenedAddress(pkAddress)
+		if !ok {
+			continue
 		}
-	}
-	if listenAddress {
-		fromAddress, err := b.parseFromAddress(txResult)
-		if err != nil {
-			return nil, fmt.Errorf("vin parse err:%w", err)
+		if _, ok := totalValues[pkAddress]; !ok {
+			matched = append(matched, listened)
 		}
+		totalValues[pkAddress] += v.Value
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	// inscriptions, runes etc. are not plain btc deposits
+	txType, action := p.classify(tx)
+	if action == config.TxTypeActionSkip {
+		p.logger.Warnw("classified tx skipped", "txId", txID, "txType", txType)
+		return nil, nil
+	}
+	if action == config.TxTypeActionQuarantine {
+		p.logger.Warnw("classified tx quarantined", "txId", txID, "txType", txType)
+	}
 
-		// TODO: temp fix, if from is listened address, continue
-		if len(fromAddress) == 0 {
-			b.logger.Warnw("parse from address empty or nonsupport tx type",
-				"txId", txResult.TxHash().String(),
-				"listenAddress", b.listenAddress.EncodeAddress())
+	fromAddress, err := parseFrom()
+	if err != nil {
+		return nil, fmt.Errorf("vin parse err:%w", err)
+	}
+
+	// TODO: temp fix, if from is listened address, continue
+	if len(fromAddress) == 0 {
+		p.logger.Warnw("parse from address empty or nonsupport tx type",
+			"txId", txID)
+		return nil, nil
+	}
+
+	// transfer between listened addresses, e.g. vault migration, is not a deposit
+	for _, v := range fromAddress {
+		if _, ok := p.listenedAddress(v.Address); ok {
+			p.logger.Warnw("from is listened address",
+				"txId", txID,
+				"from", v.Address)
 			return nil, nil
 		}
+	}
 
-		return &types.BitcoinTxParseResult{
-			TxID:   txResult.TxHash().String(),
-			TxType: TxTypeTransfer,
-			Index:  int64(index),
-			Value:  totalValue,
-			From:   fromAddress,
-			To:     b.listenAddress.EncodeAddress(),
-			Tos:    tos,
-		}, nil
+	attributedFrom, attribution, err := AttributeFrom(p.fromAttribution, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	if attribution == FromAttributionRejected {
+		p.logger.Warnw("multiple distinct from addresses, deposit rejected",
+			"txId", txID,
+			"froms", fromAddress)
+	}
+
+	results := make([]*types.BitcoinTxParseResult, 0, len(matched))
+	for _, v := range matched {
+		results = append(results, &types.BitcoinTxParseResult{
+			TxID:            txID,
+			TxType:          txType,
+			Quarantined:     action == config.TxTypeActionQuarantine,
+			Index:           int64(index),
+			Value:           totalValues[v.address.EncodeAddress()],
+			From:            fromAddress,
+			To:              v.address.EncodeAddress(),
+			ToLabel:         v.label,
+			Tos:             tos,
+			MemoAddress:     memoAddress,
+			FromAddress:     attributedFrom,
+			FromAttribution: attribution,
+		})
 	}
-	return nil, nil
+	return results, nil
+}
+
+// listenedAddress returns the listened address matched the encoded address
+func (p *txParser) listenedAddress(address string) (listenAddress, bool) {
+	for _, v := range p.listenAddresses {
+		if v.address.EncodeAddress() == address {
+			return v, true
+		}
+	}
+	return listenAddress{}, false
 }
 
 // parseFromAddress from vin parse from address
-// return all possible values parsed from address
-// TODO: at present, it is assumed that it is a single from, and multiple from needs to be tested later
+// return all inputs with spent value and out point, the l2 user is attributed by the attribution policy
 func (b *Indexer) parseFromAddress(txResult *wire.MsgTx) (fromAddress []types.BitcoinFrom, err error) {
+	// prevouts returned with the block are used, other prev txs are resolved at once, batched and cached
+	blockPrevouts := b.getPrevouts(txResult.TxIn)
+	prevTxIDs := make([]chainhash.Hash, 0, len(txResult.TxIn))
 	for _, vin := range txResult.TxIn {
-		// get prev tx hash
-		prevTxID := vin.PreviousOutPoint.Hash
-		vinResult, err := b.client.GetRawTransaction(&prevTxID)
-		if err != nil {
-			return nil, fmt.Errorf("vin get raw transaction err:%w", err)
+		if _, ok := blockPrevouts[vin.PreviousOutPoint]; ok {
+			continue
 		}
-		if len(vinResult.MsgTx().TxOut) == 0 {
-			return nil, fmt.Errorf("vin txOut is null")
+		prevTxIDs = append(prevTxIDs, vin.PreviousOutPoint.Hash)
+	}
+	prevTxs, err := b.fetchTxs(prevTxIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, vin := range txResult.TxIn {
+		prevOut, ok := blockPrevouts[vin.PreviousOutPoint]
+		if !ok {
+			vinResult := prevTxs[vin.PreviousOutPoint.Hash]
+			if len(vinResult.TxOut) == 0 {
+				return nil, fmt.Errorf("vin txOut is null")
+			}
+			if int(vin.PreviousOutPoint.Index) >= len(vinResult.TxOut) {
+				return nil, fmt.Errorf("vin prev out index %d out of range", vin.PreviousOutPoint.Index)
+			}
+			prevOut = vinResult.TxOut[vin.PreviousOutPoint.Index]
 		}
-		vinPKScript := vinResult.MsgTx().TxOut[vin.PreviousOutPoint.Index].PkScript
+		vinPKScript := prevOut.PkScript
 		//  script to address
 		vinPkAddress, err := b.parseAddress(vinPKScript)
 		if err != nil {
@@ -200,6 +358,9 @@ func (b *Indexer) parseFromAddress(txResult *wire.MsgTx) (fromAddress []types.Bi
 
 		fromAddress = append(fromAddress, types.BitcoinFrom{
 			Address: vinPkAddress,
+			Value:   prevOut.Value,
+			TxID:    vin.PreviousOutPoint.Hash.String(),
+			Vout:    vin.PreviousOutPoint.Index,
 		})
 	}
 	return fromAddress, nil
@@ -224,8 +385,32 @@ func (b *Indexer) parseNullData(pkScript []byte) (string, error) {
 	return pk.String(), nil
 }
 
+// ParseEvmAddressMemo from op_return pkscript parse the deposit destination evm address
+func ParseEvmAddressMemo(pkScript []byte) (string, error) {
+	if txscript.GetScriptClass(pkScript) != txscript.NullDataTy {
+		return "", fmt.Errorf("%w:not null data type", ErrParseEvmAddressMemo)
+	}
+	pushes, err := txscript.PushedData(pkScript)
+	if err != nil {
+		return "", fmt.Errorf("%w:%s", ErrParseEvmAddressMemo, err.Error())
+	}
+	if len(pushes) != 1 {
+		return "", fmt.Errorf("%w:unexpected data pushes %d", ErrParseEvmAddressMemo, len(pushes))
+	}
+	memo := pushes[0]
+	if len(memo) != len(EvmAddressMemoMagic)+common.AddressLength ||
+		!bytes.HasPrefix(memo, EvmAddressMemoMagic) {
+		return "", fmt.Errorf("%w:magic or length mismatch", ErrParseEvmAddressMemo)
+	}
+	address := common.BytesToAddress(memo[len(EvmAddressMemoMagic):])
+	if address == (common.Address{}) {
+		return "", fmt.Errorf("%w:zero address", ErrParseEvmAddressMemo)
+	}
+	return address.Hex(), nil
+}
+
 // parseAddress from pkscript parse address
-func (b *Indexer) parseAddress(pkScript []byte) (string, error) {
+func (p *txParser) parseAddress(pkScript []byte) (string, error) {
 	pk, err := txscript.ParsePkScript(pkScript)
 	if err != nil {
 		return "", fmt.Errorf("%w:%s", ErrParsePkScript, err.Error())
@@ -236,7 +421,7 @@ func (b *Indexer) parseAddress(pkScript []byte) (string, error) {
 	}
 
 	//  encodes the script into an address for the given chain.
-	pkAddress, err := pk.Address(b.chainParams)
+	pkAddress, err := pk.Address(p.chainParams)
 	if err != nil {
 		return "", fmt.Errorf("PKScript to address err:%w", err)
 	}
@@ -248,6 +433,60 @@ func (b *Indexer) LatestBlock() (int64, error) {
 	return b.client.GetBlockCount()
 }
 
+// BlockHash get block hash by height in the longest block chain.
+func (b *Indexer) BlockHash(height int64) (*chainhash.Hash, error) {
+	return b.client.GetBlockHash(height)
+}
+
+// ParseMempool parse unconfirmed txs paying listened addresses,
+// only txs newly entered the mempool are fetched and parsed
+func (b *Indexer) ParseMempool() ([]*types.BitcoinTxParseResult, error) {
+	txHashes, err := b.client.GetRawMempool()
+	if err != nil {
+		return nil, err
+	}
+	mempoolParsed := make(map[chainhash.Hash][]*types.BitcoinTxParseResult, len(txHashes))
+	results := make([]*types.BitcoinTxParseResult, 0)
+	for _, txHash := range txHashes {
+		parsed, ok := b.mempoolParsed[*txHash]
+		if !ok {
+			tx, err := b.client.GetRawTransaction(txHash)
+			if err != nil {
+				// evicted or mined since getrawmempool
+				b.logger.Warnw("mempool get raw transaction", "txId", txHash.String(), "error", err)
+				continue
+			}
+			parsed, err = b.parseTx(tx.MsgTx(), 0)
+			if err != nil {
+				b.logger.Warnw("mempool parse tx", "txId", txHash.String(), "error", err)
+				continue
+			}
+		}
+		mempoolParsed[*txHash] = parsed
+		results = append(results, parsed...)
+	}
+	// txs left mempool are dropped from the parsed set
+	b.mempoolParsed = mempoolParsed
+	return results, nil
+}
+
+// TxExists whether the tx is in mempool or block chain, requires txindex
+func (b *Indexer) TxExists(hash string) (bool, error) {
+	txHash, err := chainhash.NewHashFromStr(hash)
+	if err != nil {
+		return false, err
+	}
+	_, err = b.client.GetRawTransaction(txHash)
+	if err != nil {
+		var rpcErr *btcjson.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // BlockChainInfo get block chain info
 func (b *Indexer) BlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
 	return b.client.GetBlockChainInfo()
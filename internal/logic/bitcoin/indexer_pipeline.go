@@ -0,0 +1,407 @@
+package bitcoin
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PipelineConfig defines the indexer block prefetch and prevout resolution settings
+type PipelineConfig struct {
+	// PrefetchBlocks number of blocks fetched ahead concurrently, 0 disable prefetch
+	PrefetchBlocks int
+	// BatchSize prevout txs fetched per batched json-rpc request, 0 disable batch
+	BatchSize int
+	// TxCacheSize number of recently seen txs cached, 0 disable cache
+	TxCacheSize int
+	// BlockPrevouts fetch blocks by getblock verbosity 3, the prevouts of the block txs are
+	// returned with the block and not fetched, requires bitcoin core 23.0 or later
+	BlockPrevouts bool
+}
+
+// TxFetcher fetch transactions by tx hash, the result order is the same as hashes
+type TxFetcher interface {
+	FetchTxs(hashes []chainhash.Hash) ([]*wire.MsgTx, error)
+}
+
+// rpcTxFetcher fetch transactions one by one
+type rpcTxFetcher struct {
+	client *rpcclient.Client
+}
+
+func (f *rpcTxFetcher) FetchTxs(hashes []chainhash.Hash) ([]*wire.MsgTx, error) {
+	txs := make([]*wire.MsgTx, 0, len(hashes))
+	for i := range hashes {
+		tx, err := f.client.GetRawTransaction(&hashes[i])
+		if err != nil {
+			return nil, fmt.Errorf("vin get raw transaction err:%w", err)
+		}
+		txs = append(txs, tx.MsgTx())
+	}
+	return txs, nil
+}
+
+// batchTxFetcher fetch transactions by batched json-rpc, client must be created by rpcclient.NewBatch
+type batchTxFetcher struct {
+	client    *rpcclient.Client
+	batchSize int
+	// batch client queues requests in a shared list, send one batch at a time
+	mu sync.Mutex
+}
+
+// NewBatchTxFetcher new batched json-rpc tx fetcher
+func NewBatchTxFetcher(client *rpcclient.Client, batchSize int) TxFetcher {
+	return &batchTxFetcher{
+		client:    client,
+		batchSize: batchSize,
+	}
+}
+
+func (f *batchTxFetcher) FetchTxs(hashes []chainhash.Hash) ([]*wire.MsgTx, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	txs := make([]*wire.MsgTx, 0, len(hashes))
+	for start := 0; start < len(hashes); start += f.batchSize {
+		end := start + f.batchSize
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		futures := make([]rpcclient.FutureGetRawTransactionResult, 0, end-start)
+		for i := start; i < end; i++ {
+			futures = append(futures, f.client.GetRawTransactionAsync(&hashes[i]))
+		}
+		if err := f.client.Send(); err != nil {
+			return nil, fmt.Errorf("vin batch get raw transaction err:%w", err)
+		}
+		for _, future := range futures {
+			tx, err := future.Receive()
+			if err != nil {
+				return nil, fmt.Errorf("vin get raw transaction err:%w", err)
+			}
+			txs = append(txs, tx.MsgTx())
+		}
+	}
+	return txs, nil
+}
+
+// txCache lru cache of recently seen transactions
+type txCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[chainhash.Hash]*list.Element
+}
+
+type txCacheEntry struct {
+	hash chainhash.Hash
+	tx   *wire.MsgTx
+}
+
+func newTxCache(size int) *txCache {
+	return &txCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[chainhash.Hash]*list.Element, size),
+	}
+}
+
+func (c *txCache) Get(hash chainhash.Hash) (*wire.MsgTx, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[hash]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*txCacheEntry).tx, true
+	}
+	return nil, false
+}
+
+func (c *txCache) Add(hash chainhash.Hash, tx *wire.MsgTx) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[hash]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*txCacheEntry).tx = tx
+		return
+	}
+	c.items[hash] = c.ll.PushFront(&txCacheEntry{hash: hash, tx: tx})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*txCacheEntry).hash)
+	}
+}
+
+// blockFetch in-flight or finished block prefetch
+type blockFetch struct {
+	done     chan struct{}
+	block    *wire.MsgBlock
+	prevouts map[wire.OutPoint]*wire.TxOut
+	err      error
+}
+
+// SetPipeline enable block prefetch, batched prevout resolution and tx cache
+// batchClient is required if cfg.BatchSize > 0, it must be created by rpcclient.NewBatch.
+// rpc requests of a http post mode client are sent one at a time, the prefetch goroutines share
+// prefetchClients, blocks are prefetched by the indexer client if no prefetch client
+func (b *Indexer) SetPipeline(cfg PipelineConfig, batchClient *rpcclient.Client, prefetchClients ...*rpcclient.Client) {
+	b.prefetchBlocks = cfg.PrefetchBlocks
+	if cfg.BatchSize > 0 && batchClient != nil {
+		b.txFetcher = NewBatchTxFetcher(batchClient, cfg.BatchSize)
+	}
+	if cfg.TxCacheSize > 0 {
+		b.txCache = newTxCache(cfg.TxCacheSize)
+	}
+	b.blockPrevouts = cfg.BlockPrevouts
+	if len(prefetchClients) > 0 {
+		b.prefetchClients = make(chan *rpcclient.Client, len(prefetchClients))
+		for _, client := range prefetchClients {
+			b.prefetchClients <- client
+		}
+	}
+}
+
+// ResetPrefetch drop the prefetched blocks, the blocks after a reorg fork are fetched again
+func (b *Indexer) ResetPrefetch() {
+	b.prefetchMu.Lock()
+	defer b.prefetchMu.Unlock()
+	b.prefetched = make(map[int64]*blockFetch)
+}
+
+// SetTxFetcher replace the prevout tx fetcher
+func (b *Indexer) SetTxFetcher(fetcher TxFetcher) {
+	b.txFetcher = fetcher
+}
+
+// getBlock returns the block at height, blocks after height are prefetched concurrently
+func (b *Indexer) getBlock(height int64) (*wire.MsgBlock, error) {
+	if b.prefetchBlocks <= 0 {
+		return b.getBlockByHeight(height)
+	}
+
+	b.prefetchMu.Lock()
+	// drop stale blocks, e.g. index restarted from a lower height after reorg rollback
+	for k := range b.prefetched {
+		if k < height || k > height+int64(b.prefetchBlocks) {
+			delete(b.prefetched, k)
+		}
+	}
+	for i := height; i <= height+int64(b.prefetchBlocks); i++ {
+		if _, ok := b.prefetched[i]; ok {
+			continue
+		}
+		fetch := &blockFetch{done: make(chan struct{})}
+		b.prefetched[i] = fetch
+		go func(height int64, fetch *blockFetch) {
+			client := b.client
+			if b.prefetchClients != nil {
+				client = <-b.prefetchClients
+				defer func() { b.prefetchClients <- client }()
+			}
+			fetch.block, fetch.prevouts, fetch.err = b.fetchBlock(client, height)
+			close(fetch.done)
+		}(i, fetch)
+	}
+	fetch := b.prefetched[height]
+	delete(b.prefetched, height)
+	b.prefetchMu.Unlock()
+
+	<-fetch.done
+	if fetch.err != nil {
+		// prefetched before the block was mined or rpc failed, fetch again
+		return b.getBlockByHeight(height)
+	}
+	b.setPrevouts(fetch.prevouts)
+	return fetch.block, nil
+}
+
+// fetchBlock returns the block at height and the prevouts of its txs, prevouts are nil
+// if not fetched by getblock verbosity 3
+func (b *Indexer) fetchBlock(client *rpcclient.Client, height int64) (*wire.MsgBlock, map[wire.OutPoint]*wire.TxOut, error) {
+	blockhash, err := client.GetBlockHash(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !b.blockPrevouts {
+		msgBlock, err := client.GetBlock(blockhash)
+		return msgBlock, nil, err
+	}
+	return getBlockWithPrevouts(client, blockhash)
+}
+
+// blockWithPrevouts getblock verbosity 3 result
+type blockWithPrevouts struct {
+	Version           int32  `json:"version"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	MerkleRoot        string `json:"merkleroot"`
+	Time              int64  `json:"time"`
+	Bits              string `json:"bits"`
+	Nonce             uint32 `json:"nonce"`
+	Tx                []struct {
+		Hex string `json:"hex"`
+		Vin []struct {
+			Txid    string `json:"txid"`
+			Vout    uint32 `json:"vout"`
+			Prevout *struct {
+				Value        float64 `json:"value"`
+				ScriptPubKey struct {
+					Hex string `json:"hex"`
+				} `json:"scriptPubKey"`
+			} `json:"prevout"`
+		} `json:"vin"`
+	} `json:"tx"`
+}
+
+// getBlockWithPrevouts returns the block and the prevouts of its txs by getblock verbosity 3
+func getBlockWithPrevouts(client *rpcclient.Client, blockhash *chainhash.Hash) (*wire.MsgBlock, map[wire.OutPoint]*wire.TxOut, error) {
+	hashParam, err := json.Marshal(blockhash.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	rawBlock, err := client.RawRequest("getblock", []json.RawMessage{hashParam, json.RawMessage("3")})
+	if err != nil {
+		return nil, nil, fmt.Errorf("getblock verbosity 3 err:%w", err)
+	}
+	var result blockWithPrevouts
+	if err := json.Unmarshal(rawBlock, &result); err != nil {
+		return nil, nil, fmt.Errorf("decode getblock verbosity 3 err:%w", err)
+	}
+
+	var header wire.BlockHeader
+	header.Version = result.Version
+	header.Timestamp = time.Unix(result.Time, 0)
+	header.Nonce = result.Nonce
+	bits, err := strconv.ParseUint(result.Bits, 16, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode block bits err:%w", err)
+	}
+	header.Bits = uint32(bits)
+	if result.PreviousBlockHash != "" {
+		prevBlock, err := chainhash.NewHashFromStr(result.PreviousBlockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.PrevBlock = *prevBlock
+	}
+	merkleRoot, err := chainhash.NewHashFromStr(result.MerkleRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	header.MerkleRoot = *merkleRoot
+	if header.BlockHash() != *blockhash {
+		return nil, nil, fmt.Errorf("getblock verbosity 3 header hash %s, want %s", header.BlockHash(), blockhash)
+	}
+
+	msgBlock := wire.NewMsgBlock(&header)
+	prevouts := make(map[wire.OutPoint]*wire.TxOut)
+	for _, tx := range result.Tx {
+		txBytes, err := hex.DecodeString(tx.Hex)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decode block tx err:%w", err)
+		}
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, nil, fmt.Errorf("decode block tx err:%w", err)
+		}
+		if err := msgBlock.AddTransaction(&msgTx); err != nil {
+			return nil, nil, err
+		}
+		for _, vin := range tx.Vin {
+			// coinbase input
+			if vin.Prevout == nil {
+				continue
+			}
+			prevHash, err := chainhash.NewHashFromStr(vin.Txid)
+			if err != nil {
+				return nil, nil, err
+			}
+			value, err := btcutil.NewAmount(vin.Prevout.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			pkScript, err := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decode prevout script err:%w", err)
+			}
+			prevouts[*wire.NewOutPoint(prevHash, vin.Vout)] = wire.NewTxOut(int64(value), pkScript)
+		}
+	}
+	return msgBlock, prevouts, nil
+}
+
+// setPrevouts replace the prevouts of the last fetched block
+func (b *Indexer) setPrevouts(prevouts map[wire.OutPoint]*wire.TxOut) {
+	b.prevoutsMu.Lock()
+	defer b.prevoutsMu.Unlock()
+	b.prevouts = prevouts
+}
+
+// getPrevouts returns the prevouts of the inputs returned with the last fetched block
+func (b *Indexer) getPrevouts(txIns []*wire.TxIn) map[wire.OutPoint]*wire.TxOut {
+	b.prevoutsMu.Lock()
+	defer b.prevoutsMu.Unlock()
+	prevouts := make(map[wire.OutPoint]*wire.TxOut, len(txIns))
+	if b.prevouts == nil {
+		return prevouts
+	}
+	for _, vin := range txIns {
+		if prevOut, ok := b.prevouts[vin.PreviousOutPoint]; ok {
+			prevouts[vin.PreviousOutPoint] = prevOut
+		}
+	}
+	return prevouts
+}
+
+// fetchTxs returns txs by hash, cached txs are not fetched again
+func (b *Indexer) fetchTxs(hashes []chainhash.Hash) (map[chainhash.Hash]*wire.MsgTx, error) {
+	txs := make(map[chainhash.Hash]*wire.MsgTx, len(hashes))
+	missing := make([]chainhash.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if _, ok := txs[hash]; ok {
+			continue
+		}
+		if b.txCache != nil {
+			if tx, ok := b.txCache.Get(hash); ok {
+				txs[hash] = tx
+				continue
+			}
+		}
+		txs[hash] = nil
+		missing = append(missing, hash)
+	}
+	if len(missing) == 0 {
+		return txs, nil
+	}
+
+	fetched, err := b.txFetcher.FetchTxs(missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range fetched {
+		txs[missing[i]] = tx
+		if b.txCache != nil {
+			b.txCache.Add(missing[i], tx)
+		}
+	}
+	return txs, nil
+}
+
+// cacheBlockTxs add block txs to cache, prevouts often spend outputs of recent blocks
+func (b *Indexer) cacheBlockTxs(block *wire.MsgBlock) {
+	if b.txCache == nil {
+		return
+	}
+	for _, tx := range block.Transactions {
+		b.txCache.Add(tx.TxHash(), tx)
+	}
+}
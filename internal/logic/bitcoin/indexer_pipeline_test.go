@@ -0,0 +1,506 @@
+package bitcoin_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// fixtureListenAddress signet listen address of the synthetic txs
+	fixtureListenAddress = "tb1qfhhxljfajcppfhwa09uxwty5dz4xwfptnqmvtv"
+	// fixtureBlockHash mainnet block 277647, recorded with its spent outputs in btcd blockchain test data
+	fixtureBlockHash = "0000000000000000054a714e580b16c583701712ab91060e92dbde6eb1e052a8"
+	// blockFixtureListenAddress paid by 25 txs of the fixture block
+	blockFixtureListenAddress = "1LuckyR1fFHEsXYyx5QK4UFzv3PEAepPMK"
+)
+
+// fixtureTxFetcher serves prevout txs of the block fixture
+type fixtureTxFetcher struct {
+	txs     map[chainhash.Hash]*wire.MsgTx
+	fetched int
+}
+
+func (f *fixtureTxFetcher) FetchTxs(hashes []chainhash.Hash) ([]*wire.MsgTx, error) {
+	txs := make([]*wire.MsgTx, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, ok := f.txs[hash]
+		if !ok {
+			return nil, fmt.Errorf("tx %s not found", hash)
+		}
+		txs = append(txs, tx)
+	}
+	f.fetched += len(hashes)
+	return txs, nil
+}
+
+// loadBlockFixture load the recorded block, prevout txs are rebuilt from the recorded spent outputs,
+// outputs not spent by the block are empty
+func loadBlockFixture(t testing.TB) (*wire.MsgBlock, *fixtureTxFetcher) {
+	data, err := os.ReadFile("testdata/block_277647.json")
+	require.NoError(t, err)
+	var fixture struct {
+		Block    string `json:"block"`
+		Prevouts []struct {
+			TxHash   string `json:"tx_hash"`
+			Index    uint32 `json:"index"`
+			Value    int64  `json:"value"`
+			PkScript string `json:"pk_script"`
+		} `json:"prevouts"`
+	}
+	require.NoError(t, json.Unmarshal(data, &fixture))
+
+	raw, err := hex.DecodeString(fixture.Block)
+	require.NoError(t, err)
+	var block wire.MsgBlock
+	require.NoError(t, block.Deserialize(bytes.NewReader(raw)))
+	require.Equal(t, fixtureBlockHash, block.BlockHash().String())
+
+	fetcher := &fixtureTxFetcher{txs: make(map[chainhash.Hash]*wire.MsgTx)}
+	for _, v := range fixture.Prevouts {
+		hash, err := chainhash.NewHashFromStr(v.TxHash)
+		require.NoError(t, err)
+		pkScript, err := hex.DecodeString(v.PkScript)
+		require.NoError(t, err)
+		tx, ok := fetcher.txs[*hash]
+		if !ok {
+			tx = wire.NewMsgTx(wire.TxVersion)
+			// txs without inputs are decoded as segwit txs
+			tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+			fetcher.txs[*hash] = tx
+		}
+		for uint32(len(tx.TxOut)) <= v.Index {
+			tx.AddTxOut(wire.NewTxOut(0, nil))
+		}
+		tx.TxOut[v.Index] = wire.NewTxOut(v.Value, pkScript)
+	}
+	return &block, fetcher
+}
+
+func fixtureIndexer(t testing.TB, cfg bitcoin.PipelineConfig, fetcher bitcoin.TxFetcher) *bitcoin.Indexer {
+	indexer, err := bitcoin.NewBitcoinIndexer(
+		log.NewNopLogger(),
+		nil,
+		&chaincfg.SigNetParams,
+		[]config.ListenAddress{{Label: config.DefaultListenAddressLabel, Address: fixtureListenAddress}},
+		1,
+	)
+	require.NoError(t, err)
+	indexer.SetPipeline(cfg, nil)
+	indexer.SetTxFetcher(fetcher)
+	return indexer
+}
+
+// blockFixtureIndexer mainnet indexer listening the address paid in the block fixture
+func blockFixtureIndexer(t testing.TB, client *rpcclient.Client) *bitcoin.Indexer {
+	indexer, err := bitcoin.NewBitcoinIndexer(
+		log.NewNopLogger(),
+		client,
+		&chaincfg.MainNetParams,
+		[]config.ListenAddress{{Label: config.DefaultListenAddressLabel, Address: blockFixtureListenAddress}},
+		1,
+	)
+	require.NoError(t, err)
+	return indexer
+}
+
+func TestParseMsgBlock(t *testing.T) {
+	block, fetcher := loadBlockFixture(t)
+	indexer := blockFixtureIndexer(t, nil)
+	indexer.SetPipeline(bitcoin.PipelineConfig{TxCacheSize: 1000}, nil)
+	indexer.SetTxFetcher(fetcher)
+
+	results, err := indexer.ParseMsgBlock(block, 277647, 0)
+	require.NoError(t, err)
+	// 25 txs pay the listen address, 13 of them spend its outputs and are not deposits
+	require.Len(t, results, 12)
+	var total int64
+	for _, v := range results {
+		require.Equal(t, blockFixtureListenAddress, v.To)
+		require.Equal(t, config.DefaultListenAddressLabel, v.ToLabel)
+		require.Equal(t, "1Bqbu2rgJVWfw1aAw3VM98JBkNdE9Cuw4G", v.From[0].Address)
+		require.Empty(t, v.MemoAddress)
+		total += v.Value
+	}
+	require.Equal(t, int64(2400000), total)
+	require.Equal(t, int64(175), results[0].Index)
+	require.Equal(t, "ca4b3083997c86ee25bf4ba30ed8ffcbd0592a3d25475a1a0d9b599655260fd8", results[0].TxID)
+	// prevouts of the 25 matched txs, 13 unique prev txs
+	require.Equal(t, 13, fetcher.fetched)
+
+	// prevouts are cached, parse again without fetch
+	results, err = indexer.ParseMsgBlock(block, 277647, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 12)
+	require.Equal(t, 13, fetcher.fetched)
+
+	// start from tx index
+	last := results[len(results)-1]
+	results, err = indexer.ParseMsgBlock(block, 277647, last.Index)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, last.TxID, results[0].TxID)
+}
+
+// mockBitcoinRPC bitcoind json-rpc stand-in serving the block fixture at every height,
+// each http request waits latency, a batched request waits once, getblock waits blockLatency more
+type mockBitcoinRPC struct {
+	txs          map[string]string
+	prevTxs      map[chainhash.Hash]*wire.MsgTx
+	latency      time.Duration
+	blockLatency time.Duration
+
+	mu sync.Mutex
+	// block served at every height, verbose is the getblock verbosity 3 result
+	block   string
+	hash    string
+	verbose map[string]interface{}
+	// mempool tx ids returned by getrawmempool
+	mempool []string
+	// calls number of requests per method
+	calls map[string]int
+}
+
+func newMockBitcoinRPC(t testing.TB, latency, blockLatency time.Duration) *mockBitcoinRPC {
+	block, fetcher := loadBlockFixture(t)
+	m := &mockBitcoinRPC{
+		txs:          make(map[string]string, len(fetcher.txs)),
+		prevTxs:      make(map[chainhash.Hash]*wire.MsgTx, len(fetcher.txs)),
+		latency:      latency,
+		blockLatency: blockLatency,
+		calls:        make(map[string]int),
+	}
+	// block txs are served too, e.g. as mempool txs, prevouts spent in the block are the block txs
+	var buf bytes.Buffer
+	for _, tx := range block.Transactions {
+		buf.Reset()
+		require.NoError(t, tx.Serialize(&buf))
+		m.txs[tx.TxHash().String()] = hex.EncodeToString(buf.Bytes())
+		m.prevTxs[tx.TxHash()] = tx
+	}
+	for hash, tx := range fetcher.txs {
+		if _, ok := m.txs[hash.String()]; ok {
+			continue
+		}
+		buf.Reset()
+		require.NoError(t, tx.Serialize(&buf))
+		m.txs[hash.String()] = hex.EncodeToString(buf.Bytes())
+		m.prevTxs[hash] = tx
+	}
+	m.setBlock(t, block)
+	return m
+}
+
+// setBlock replace the block served at every height
+func (m *mockBitcoinRPC) setBlock(t testing.TB, block *wire.MsgBlock) {
+	var buf bytes.Buffer
+	require.NoError(t, block.Serialize(&buf))
+	txs := make([]map[string]interface{}, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		buf.Reset()
+		require.NoError(t, tx.Serialize(&buf))
+		vins := make([]map[string]interface{}, 0, len(tx.TxIn))
+		for _, vin := range tx.TxIn {
+			prevTx, ok := m.prevTxs[vin.PreviousOutPoint.Hash]
+			if !ok {
+				// coinbase
+				vins = append(vins, map[string]interface{}{"coinbase": hex.EncodeToString(vin.SignatureScript)})
+				continue
+			}
+			prevOut := prevTx.TxOut[vin.PreviousOutPoint.Index]
+			vins = append(vins, map[string]interface{}{
+				"txid": vin.PreviousOutPoint.Hash.String(),
+				"vout": vin.PreviousOutPoint.Index,
+				"prevout": map[string]interface{}{
+					"value":        float64(prevOut.Value) / 1e8,
+					"scriptPubKey": map[string]interface{}{"hex": hex.EncodeToString(prevOut.PkScript)},
+				},
+			})
+		}
+		txs = append(txs, map[string]interface{}{
+			"txid": tx.TxHash().String(),
+			"hex":  hex.EncodeToString(buf.Bytes()),
+			"vin":  vins,
+		})
+	}
+	verbose := map[string]interface{}{
+		"hash":              block.BlockHash().String(),
+		"version":           block.Header.Version,
+		"previousblockhash": block.Header.PrevBlock.String(),
+		"merkleroot":        block.Header.MerkleRoot.String(),
+		"time":              block.Header.Timestamp.Unix(),
+		"bits":              fmt.Sprintf("%08x", block.Header.Bits),
+		"nonce":             block.Header.Nonce,
+		"tx":                txs,
+	}
+	buf.Reset()
+	require.NoError(t, block.Serialize(&buf))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.block = hex.EncodeToString(buf.Bytes())
+	m.hash = block.BlockHash().String()
+	m.verbose = verbose
+}
+
+// setMempool replace the mempool tx ids
+func (m *mockBitcoinRPC) setMempool(txIDs []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mempool = txIDs
+}
+
+// callCount number of requests of the method
+func (m *mockBitcoinRPC) callCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[method]
+}
+
+type mockRPCRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type mockRPCResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+func (m *mockBitcoinRPC) handle(req mockRPCRequest) mockRPCResponse {
+	resp := mockRPCResponse{ID: req.ID}
+	m.mu.Lock()
+	m.calls[req.Method]++
+	m.mu.Unlock()
+	switch req.Method {
+	case "getblockhash":
+		m.mu.Lock()
+		resp.Result = m.hash
+		m.mu.Unlock()
+	case "getblock":
+		time.Sleep(m.blockLatency)
+		m.mu.Lock()
+		resp.Result = m.block
+		if len(req.Params) > 1 && string(req.Params[1]) == "3" {
+			resp.Result = m.verbose
+		}
+		m.mu.Unlock()
+	case "getrawmempool":
+		m.mu.Lock()
+		resp.Result = append([]string{}, m.mempool...)
+		m.mu.Unlock()
+	case "getrawtransaction":
+		var txID string
+		if len(req.Params) > 0 {
+			_ = json.Unmarshal(req.Params[0], &txID)
+		}
+		tx, ok := m.txs[txID]
+		if !ok {
+			resp.Error = map[string]interface{}{"code": -5, "message": "No such mempool or blockchain transaction"}
+			break
+		}
+		resp.Result = tx
+	default:
+		resp.Error = map[string]interface{}{"code": -32601, "message": "Method not found"}
+	}
+	return resp
+}
+
+func (m *mockBitcoinRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(m.latency)
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var reqs []mockRPCRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resps := make([]mockRPCResponse, 0, len(reqs))
+		for _, req := range reqs {
+			resps = append(resps, m.handle(req))
+		}
+		result = resps
+	} else {
+		var req mockRPCRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = m.handle(req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// mockRPCIndexer indexer fetching blocks and prevouts from the mock rpc with the pipeline settings
+func mockRPCIndexer(t testing.TB, rpc *mockBitcoinRPC, cfg bitcoin.PipelineConfig) *bitcoin.Indexer {
+	server := httptest.NewServer(rpc)
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	connCfg := &rpcclient.ConnConfig{
+		Host:         serverURL.Host,
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}
+	client, err := rpcclient.New(connCfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(client.Shutdown)
+	var batchClient *rpcclient.Client
+	if cfg.BatchSize > 0 {
+		batchClient, err = rpcclient.NewBatch(connCfg)
+		require.NoError(t, err)
+		t.Cleanup(batchClient.Shutdown)
+	}
+	prefetchClients := make([]*rpcclient.Client, 0, cfg.PrefetchBlocks)
+	for i := 0; i < cfg.PrefetchBlocks; i++ {
+		prefetchClient, err := rpcclient.New(connCfg, nil)
+		require.NoError(t, err)
+		t.Cleanup(prefetchClient.Shutdown)
+		prefetchClients = append(prefetchClients, prefetchClient)
+	}
+	indexer := blockFixtureIndexer(t, client)
+	indexer.SetPipeline(cfg, batchClient, prefetchClients...)
+	return indexer
+}
+
+var pipelineBenchmarks = []struct {
+	name string
+	cfg  bitcoin.PipelineConfig
+}{
+	{"sequential", bitcoin.PipelineConfig{}},
+	{"prefetch", bitcoin.PipelineConfig{PrefetchBlocks: 4}},
+	{"batch", bitcoin.PipelineConfig{BatchSize: 100}},
+	{"prefetch and batch", bitcoin.PipelineConfig{PrefetchBlocks: 4, BatchSize: 100}},
+	{"block prevouts", bitcoin.PipelineConfig{BlockPrevouts: true}},
+	{"prefetch and block prevouts", bitcoin.PipelineConfig{PrefetchBlocks: 4, BlockPrevouts: true}},
+}
+
+func TestParseBlockPipeline(t *testing.T) {
+	rpc := newMockBitcoinRPC(t, 0, 0)
+	var expected []*types.BitcoinTxParseResult
+	for _, tc := range pipelineBenchmarks {
+		t.Run("success: "+tc.name, func(t *testing.T) {
+			indexer := mockRPCIndexer(t, rpc, tc.cfg)
+			for height := int64(1); height <= 3; height++ {
+				results, header, err := indexer.ParseBlock(height, 0)
+				require.NoError(t, err)
+				require.Equal(t, fixtureBlockHash, header.BlockHash().String())
+				require.Len(t, results, 12)
+				if expected == nil {
+					expected = results
+				}
+				require.Equal(t, expected, results)
+			}
+		})
+	}
+}
+
+func TestParseBlockPrevouts(t *testing.T) {
+	rpc := newMockBitcoinRPC(t, 0, 0)
+	indexer := mockRPCIndexer(t, rpc, bitcoin.PipelineConfig{BlockPrevouts: true})
+	results, header, err := indexer.ParseBlock(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, fixtureBlockHash, header.BlockHash().String())
+	require.Len(t, results, 12)
+	require.Equal(t, "1Bqbu2rgJVWfw1aAw3VM98JBkNdE9Cuw4G", results[0].From[0].Address)
+	// prevouts are returned with the block
+	require.Equal(t, 1, rpc.callCount("getblock"))
+	require.Equal(t, 0, rpc.callCount("getrawtransaction"))
+}
+
+func TestResetPrefetch(t *testing.T) {
+	rpc := newMockBitcoinRPC(t, 0, 0)
+	indexer := mockRPCIndexer(t, rpc, bitcoin.PipelineConfig{PrefetchBlocks: 2})
+	_, header, err := indexer.ParseBlock(1, 0)
+	require.NoError(t, err)
+	require.Equal(t, fixtureBlockHash, header.BlockHash().String())
+	// blocks 2 and 3 are prefetched
+	require.Eventually(t, func() bool { return rpc.callCount("getblock") == 3 }, time.Second, time.Millisecond)
+
+	// the prefetched blocks are orphaned by a reorg
+	block, _ := loadBlockFixture(t)
+	block.Header.Nonce++
+	rpc.setBlock(t, block)
+	indexer.ResetPrefetch()
+	_, header, err = indexer.ParseBlock(2, 0)
+	require.NoError(t, err)
+	require.Equal(t, block.BlockHash(), header.BlockHash())
+}
+
+// BenchmarkParseBlock index consecutive blocks from a bitcoind with rpc latency and block transfer time,
+// batch sends the prevouts of a tx in one request, prefetch overlaps block fetches with prevout resolution,
+// block prevouts fetch no prevout txs
+func BenchmarkParseBlock(b *testing.B) {
+	const blocks = 4
+	rpc := newMockBitcoinRPC(b, 2*time.Millisecond, 20*time.Millisecond)
+	for _, bm := range pipelineBenchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			indexer := mockRPCIndexer(b, rpc, bm.cfg)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				start := int64(i * blocks)
+				for height := start; height < start+blocks; height++ {
+					_, _, err := indexer.ParseBlock(height, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseMsgBlock(b *testing.B) {
+	block, fetcher := loadBlockFixture(b)
+	benchmarks := []struct {
+		name string
+		cfg  bitcoin.PipelineConfig
+	}{
+		{"no cache", bitcoin.PipelineConfig{}},
+		{"tx cache", bitcoin.PipelineConfig{TxCacheSize: 10000}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			indexer := blockFixtureIndexer(b, nil)
+			indexer.SetPipeline(bm.cfg, nil)
+			indexer.SetTxFetcher(fetcher)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := indexer.ParseMsgBlock(block, 277647, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
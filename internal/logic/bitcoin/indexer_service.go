@@ -9,9 +9,11 @@ import (
 	"github.com/b2network/b2-indexer/internal/model"
 	"github.com/b2network/b2-indexer/internal/types"
 	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/cometbft/cometbft/libs/service"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -21,13 +23,39 @@ const (
 
 	IndexTxTimeout    = 100 * time.Millisecond
 	IndexBlockTimeout = 2 * time.Second
+
+	// ReorgMaxDepth the max number of blocks walked back to find the fork point
+	ReorgMaxDepth = 100
+
+	// DepositBtcTxHashIndex legacy deposit btc tx hash unique index
+	DepositBtcTxHashIndex = "idx_deposit_history_btc_tx_hash"
+	// DepositBtcTxHashToIndex deposit (btc tx hash, btc to) unique index
+	DepositBtcTxHashToIndex = "idx_deposit_history_btc_tx_hash_to"
+	// RollupDepositB2TxHashIndex legacy rollup deposit b2 tx hash unique index
+	RollupDepositB2TxHashIndex = "idx_rollup_deposit_history_b2_tx_hash"
+	// RollupDepositB2TxHashLogIndex rollup deposit (b2 tx hash, b2 log index) unique index
+	RollupDepositB2TxHashLogIndex = "idx_rollup_deposit_history_b2_tx_hash_log"
 )
 
+var ErrReorgTooDeep = errors.New("reorg deeper than max depth, need manual handling")
+
 // IndexerService indexes transactions for json-rpc service.
+// blockPrefetcher indexer fetching blocks ahead of the index
+type blockPrefetcher interface {
+	// ResetPrefetch drop the prefetched blocks
+	ResetPrefetch()
+}
+
 type IndexerService struct {
 	service.BaseService
 
 	txIdxr types.BITCOINTxIndexer
+	// blockInterval wait after each indexed block, limit the bitcoin rpc load
+	blockInterval time.Duration
+	// newBlock wakes the loop on new block, e.g. zmq notification, nil if polling only
+	newBlock <-chan struct{}
+	// depositPolicy deposits outside the amount policy are held for operator review
+	depositPolicy DepositPolicy
 
 	db  *gorm.DB
 	log log.Logger
@@ -39,12 +67,18 @@ func NewIndexerService(
 	// bridge types.BITCOINBridge,
 	db *gorm.DB,
 	logger log.Logger,
+	blockInterval time.Duration,
 ) *IndexerService {
-	is := &IndexerService{txIdxr: txIdxr, db: db, log: logger}
+	is := &IndexerService{txIdxr: txIdxr, db: db, log: logger, blockInterval: blockInterval}
 	is.BaseService = *service.NewBaseService(nil, ServiceName, is)
 	return is
 }
 
+// SetNewBlockNotify wake the indexer loop on new block instead of waiting NewBlockWaitTimeout
+func (bis *IndexerService) SetNewBlockNotify(newBlock <-chan struct{}) {
+	bis.newBlock = newBlock
+}
+
 // OnStart
 func (bis *IndexerService) OnStart() error {
 	latestBlock, err := bis.txIdxr.LatestBlock()
@@ -65,6 +99,100 @@ func (bis *IndexerService) OnStart() error {
 		}
 	}
 
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().BtcMemoAddress) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().BtcMemoAddress)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().BtcToLabel) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().BtcToLabel)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().DepositUUID) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().DepositUUID)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+		err = BackfillDepositUUID(bis.db)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer backfill deposit uuid", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().BtcConfirmations) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().BtcConfirmations)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().BtcFromPolicy) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().BtcFromPolicy)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().HoldReason) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().HoldReason)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().B2DryRunTx) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().B2DryRunTx)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().B2DryRunResult) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().B2DryRunResult)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasColumn(&model.Deposit{}, model.Deposit{}.Column().B2TxNextAttempt) {
+		err = bis.db.Migrator().AddColumn(&model.Deposit{}, model.Deposit{}.Column().B2TxNextAttempt)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	// one btc tx may deposit to multiple listened addresses, btc tx hash unique index
+	// is replaced by (btc tx hash, btc to) unique index
+	if bis.db.Migrator().HasIndex(&model.Deposit{}, DepositBtcTxHashIndex) {
+		err = bis.db.Migrator().DropIndex(&model.Deposit{}, DepositBtcTxHashIndex)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer drop index", "error", err.Error())
+			return err
+		}
+	}
+	if !bis.db.Migrator().HasIndex(&model.Deposit{}, DepositBtcTxHashToIndex) {
+		err = bis.db.Migrator().CreateIndex(&model.Deposit{}, DepositBtcTxHashToIndex)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create index", "error", err.Error())
+			return err
+		}
+	}
+
 	if !bis.db.Migrator().HasTable(&model.BtcIndex{}) {
 		err = bis.db.AutoMigrate(&model.BtcIndex{})
 		if err != nil {
@@ -73,6 +201,14 @@ func (bis *IndexerService) OnStart() error {
 		}
 	}
 
+	if !bis.db.Migrator().HasTable(&model.BtcBlock{}) {
+		err = bis.db.AutoMigrate(&model.BtcBlock{})
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create table", "error", err.Error())
+			return err
+		}
+	}
+
 	if !bis.db.Migrator().HasTable(&model.Sinohope{}) {
 		err = bis.db.AutoMigrate(&model.Sinohope{})
 		if err != nil {
@@ -89,6 +225,55 @@ func (bis *IndexerService) OnStart() error {
 		}
 	}
 
+	if !bis.db.Migrator().HasColumn(&model.RollupDeposit{}, model.RollupDeposit{}.Column().B2Value) {
+		err = bis.db.Migrator().AddColumn(&model.RollupDeposit{}, model.RollupDeposit{}.Column().B2Value)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer add column", "error", err.Error())
+			return err
+		}
+	}
+
+	// a batch deposit tx emits a deposit event for each deposit, b2 tx hash unique index
+	// is replaced by (b2 tx hash, b2 log index) unique index
+	if bis.db.Migrator().HasIndex(&model.RollupDeposit{}, RollupDepositB2TxHashIndex) {
+		err = bis.db.Migrator().DropIndex(&model.RollupDeposit{}, RollupDepositB2TxHashIndex)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer drop index", "error", err.Error())
+			return err
+		}
+	}
+	if !bis.db.Migrator().HasIndex(&model.RollupDeposit{}, RollupDepositB2TxHashLogIndex) {
+		err = bis.db.Migrator().CreateIndex(&model.RollupDeposit{}, RollupDepositB2TxHashLogIndex)
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create index", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasTable(&model.BridgeNonce{}) {
+		err = bis.db.AutoMigrate(&model.BridgeNonce{})
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create table", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasTable(&model.AAAddress{}) {
+		err = bis.db.AutoMigrate(&model.AAAddress{})
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create table", "error", err.Error())
+			return err
+		}
+	}
+
+	if !bis.db.Migrator().HasTable(&model.DepositStatusHistory{}) {
+		err = bis.db.AutoMigrate(&model.DepositStatusHistory{})
+		if err != nil {
+			bis.log.Errorw("bitcoin indexer create table", "error", err.Error())
+			return err
+		}
+	}
+
 	var btcIndex model.BtcIndex
 	if err := bis.db.First(&btcIndex, 1).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -119,7 +304,11 @@ func (bis *IndexerService) OnStart() error {
 			latestBlock, "currentBlock", currentBlock, "currentTxIndex", currentTxIndex)
 
 		if latestBlock <= currentBlock {
-			<-ticker.C
+			// polling is kept as fallback if notifications are lost
+			select {
+			case <-ticker.C:
+			case <-bis.newBlock:
+			}
 			ticker.Reset(NewBlockWaitTimeout)
 
 			// update latest block
@@ -156,6 +345,36 @@ func (bis *IndexerService) OnStart() error {
 
 				break
 			}
+			// the parsed block must extend the recorded chain, otherwise rollback to the fork point
+			forkBlock, reorged, err := bis.CheckReorg(i, blockHeader)
+			if err != nil {
+				bis.log.Errorw("check reorg err", "error", err.Error(), "currentBlock", i, "currentTxIndex", currentTxIndex)
+				if currentTxIndex == 0 {
+					currentBlock = i - 1
+				} else {
+					currentBlock = i
+					currentTxIndex--
+				}
+				time.Sleep(NewBlockWaitTimeout)
+				break
+			}
+			if reorged {
+				bis.log.Warnw("bitcoin chain reorg detected", "currentBlock", i, "forkBlock", forkBlock)
+				if err := bis.RollbackToFork(forkBlock, &btcIndex); err != nil {
+					bis.log.Errorw("failed to rollback reorged blocks", "error", err, "currentBlock", i, "forkBlock", forkBlock)
+					if currentTxIndex == 0 {
+						currentBlock = i - 1
+					} else {
+						currentBlock = i
+						currentTxIndex--
+					}
+					break
+				}
+				// re-index the new branch from fork block + 1
+				currentBlock = forkBlock
+				currentTxIndex = 0
+				break
+			}
 			if len(txResults) > 0 {
 				currentBlock, currentTxIndex, err = bis.HandleResults(txResults, btcIndex, blockHeader.Timestamp, i)
 				if err != nil {
@@ -185,7 +404,7 @@ func (bis *IndexerService) OnStart() error {
 			currentTxIndex = 0
 			btcIndex.BtcIndexBlock = currentBlock
 			btcIndex.BtcIndexTx = currentTxIndex
-			if err := bis.db.Save(&btcIndex).Error; err != nil {
+			if err := bis.SaveBlock(i, blockHeader, btcIndex); err != nil {
 				bis.log.Errorw("failed to save bitcoin index block", "error", err, "currentBlock", i,
 					"currentTxIndex", currentTxIndex, "latestBlock", latestBlock)
 				// rollback
@@ -194,9 +413,183 @@ func (bis *IndexerService) OnStart() error {
 			}
 			bis.log.Infow("bitcoin indexer parsed", "currentBlock", i,
 				"currentTxIndex", currentTxIndex, "latestBlock", latestBlock)
-			time.Sleep(IndexBlockTimeout)
+			time.Sleep(bis.blockInterval)
+		}
+		if err := bis.UpdateConfirmations(latestBlock); err != nil {
+			bis.log.Errorw("failed to update deposit confirmations", "error", err, "latestBlock", latestBlock)
+		}
+	}
+}
+
+// UpdateConfirmations update confirmations of deposits in recent blocks, deeper deposits are final
+func (bis *IndexerService) UpdateConfirmations(latestBlock int64) error {
+	return bis.db.Model(&model.Deposit{}).
+		Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().ListenerStatus), model.ListenerStatusSuccess).
+		Where(fmt.Sprintf("%s > ?", model.Deposit{}.Column().BtcBlockNumber), latestBlock-ReorgMaxDepth).
+		Where(fmt.Sprintf("%s <= ?", model.Deposit{}.Column().BtcBlockNumber), latestBlock).
+		Update(model.Deposit{}.Column().BtcConfirmations,
+			gorm.Expr(fmt.Sprintf("? - %s + 1", model.Deposit{}.Column().BtcBlockNumber), latestBlock)).Error
+}
+
+// SaveBlock save index block and record block hash, used to detect reorg
+func (bis *IndexerService) SaveBlock(height int64, header *wire.BlockHeader, btcIndex model.BtcIndex) error {
+	return bis.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&btcIndex).Error; err != nil {
+			return err
+		}
+		block := model.BtcBlock{
+			Height:        height,
+			BlockHash:     header.BlockHash().String(),
+			PrevBlockHash: header.PrevBlock.String(),
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: model.BtcBlock{}.Column().Height}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				model.BtcBlock{}.Column().BlockHash,
+				model.BtcBlock{}.Column().PrevBlockHash,
+			}),
+		}).Create(&block).Error
+	})
+}
+
+// CheckReorg check whether the block at height extends the recorded chain.
+// if not, return the fork block, the highest recorded block still in the longest chain
+func (bis *IndexerService) CheckReorg(height int64, header *wire.BlockHeader) (int64, bool, error) {
+	var prevBlock model.BtcBlock
+	err := bis.db.
+		Where(fmt.Sprintf("%s = ?", model.BtcBlock{}.Column().Height), height-1).
+		First(&prevBlock).Error
+	if err != nil {
+		// prev block not recorded, nothing to compare
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if prevBlock.BlockHash == header.PrevBlock.String() {
+		return 0, false, nil
+	}
+
+	var blocks []model.BtcBlock
+	err = bis.db.
+		Where(fmt.Sprintf("%s > ?", model.BtcBlock{}.Column().Height), height-1-ReorgMaxDepth).
+		Where(fmt.Sprintf("%s < ?", model.BtcBlock{}.Column().Height), height).
+		Find(&blocks).Error
+	if err != nil {
+		return 0, false, err
+	}
+	recorded := make(map[int64]string, len(blocks))
+	for _, v := range blocks {
+		recorded[v.Height] = v.BlockHash
+	}
+	forkBlock, err := FindForkBlock(bis.txIdxr, recorded, height-1)
+	if err != nil {
+		return 0, false, err
+	}
+	return forkBlock, true, nil
+}
+
+// FindForkBlock walk back from height, return the highest block whose recorded hash
+// still matches the longest chain. an unrecorded block is treated as the fork block.
+func FindForkBlock(txIdxr types.BITCOINTxIndexer, recorded map[int64]string, height int64) (int64, error) {
+	for i := height; i > height-ReorgMaxDepth && i >= 0; i-- {
+		recordedHash, ok := recorded[i]
+		if !ok {
+			return i, nil
+		}
+		blockHash, err := txIdxr.BlockHash(i)
+		if err != nil {
+			return 0, err
+		}
+		if blockHash.String() == recordedHash {
+			return i, nil
 		}
 	}
+	return 0, fmt.Errorf("%w, height:%d max depth:%d", ErrReorgTooDeep, height, ReorgMaxDepth)
+}
+
+// depositMinted whether the deposit has been minted or its deposit tx broadcast
+func depositMinted(deposit model.Deposit) bool {
+	switch deposit.B2TxStatus {
+	case model.DepositB2TxStatusSuccess, model.DepositB2TxStatusTxHashExist:
+		return true
+	case model.DepositB2TxStatusWaitMined, model.DepositB2TxStatusIsPending:
+		return deposit.B2TxHash != ""
+	default:
+		return false
+	}
+}
+
+// RollbackToFork mark deposits after the fork block as reorged, remove orphaned block
+// records and rollback the index to the fork block. Minted deposits of orphaned blocks
+// are alerted, the minted amount is not backed by the btc tx until it is mined again
+func (bis *IndexerService) RollbackToFork(forkBlock int64, btcIndex *model.BtcIndex) error {
+	var reorged []model.Deposit
+	err := bis.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where(fmt.Sprintf("%s > ?", model.Deposit{}.Column().BtcBlockNumber), forkBlock).
+			Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().ListenerStatus), model.ListenerStatusSuccess).
+			Find(&reorged).Error
+		if err != nil {
+			return err
+		}
+		for _, deposit := range reorged {
+			reason := fmt.Sprintf("btc block %d orphaned, fork block %d", deposit.BtcBlockNumber, forkBlock)
+			if depositMinted(deposit) {
+				reason = fmt.Sprintf("btc block %d orphaned after minted, fork block %d", deposit.BtcBlockNumber, forkBlock)
+			}
+			err = model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To: map[string]int{
+					model.Deposit{}.Column().ListenerStatus: model.ListenerStatusReorged,
+				},
+				Updates: map[string]interface{}{
+					model.Deposit{}.Column().BtcConfirmations: 0,
+				},
+				Actor:  model.DepositActorIndexer,
+				Reason: reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Unscoped().
+			Where(fmt.Sprintf("%s > ?", model.BtcBlock{}.Column().Height), forkBlock).
+			Delete(&model.BtcBlock{}).Error
+		if err != nil {
+			return err
+		}
+		rollbackIndex := *btcIndex
+		rollbackIndex.BtcIndexBlock = forkBlock
+		rollbackIndex.BtcIndexTx = 0
+		return tx.Save(&rollbackIndex).Error
+	})
+	if err != nil {
+		return err
+	}
+	btcIndex.BtcIndexBlock = forkBlock
+	btcIndex.BtcIndexTx = 0
+	minted := 0
+	for _, deposit := range reorged {
+		if !depositMinted(deposit) {
+			continue
+		}
+		minted++
+		bis.log.Errorw("minted deposit orphaned alert",
+			"btcTxHash", deposit.BtcTxHash,
+			"btcTo", deposit.BtcTo,
+			"btcBlockNumber", deposit.BtcBlockNumber,
+			"btcValue", deposit.BtcValue,
+			"b2TxHash", deposit.B2TxHash,
+			"b2TxStatus", deposit.B2TxStatus,
+			"forkBlock", forkBlock)
+	}
+	bis.log.Warnw("rollback to fork block", "forkBlock", forkBlock,
+		"reorged", len(reorged)-minted, "mintedReorged", minted)
+	// blocks prefetched before the reorg may be orphaned
+	if prefetcher, ok := bis.txIdxr.(blockPrefetcher); ok {
+		prefetcher.ResetPrefetch()
+	}
+	return nil
 }
 
 // save index tx to db
@@ -209,19 +602,7 @@ func (bis *IndexerService) SaveParsedResult(
 ) error {
 	// write db
 	err := bis.db.Transaction(func(tx *gorm.DB) error {
-		if len(parseResult.From) == 0 {
-			return fmt.Errorf("parse result from empty")
-		}
-
-		if len(parseResult.To) == 0 {
-			return fmt.Errorf("parse result to empty")
-		}
-
-		froms, err := json.Marshal(parseResult.From)
-		if err != nil {
-			return err
-		}
-		tos, err := json.Marshal(parseResult.Tos)
+		parsedDeposit, err := parsedDeposit(parseResult, btcBlockNumber, b2TxStatus, btcBlockTime)
 		if err != nil {
 			return err
 		}
@@ -229,44 +610,64 @@ func (bis *IndexerService) SaveParsedResult(
 		// if existed, update deposit record
 		var deposit model.Deposit
 		err = tx.First(&deposit,
-			fmt.Sprintf("%s = ?", model.Deposit{}.Column().BtcTxHash),
-			parseResult.TxID).Error
+			fmt.Sprintf("%s = ? AND %s = ?", model.Deposit{}.Column().BtcTxHash, model.Deposit{}.Column().BtcTo),
+			parseResult.TxID, parseResult.To).Error
 		if err != nil {
 			if !errors.Is(err, gorm.ErrRecordNotFound) {
 				return err
 			}
-			deposit := model.Deposit{
-				BtcBlockNumber: btcBlockNumber,
-				BtcTxIndex:     parseResult.Index,
-				BtcTxHash:      parseResult.TxID,
-				BtcFrom:        parseResult.From[0].Address,
-				BtcTos:         string(tos),
-				BtcTo:          parseResult.To,
-				BtcValue:       parseResult.Value,
-				BtcFroms:       string(froms),
-				B2TxStatus:     b2TxStatus,
-				BtcBlockTime:   btcBlockTime,
-				B2TxRetry:      0,
-				ListenerStatus: model.ListenerStatusSuccess,
-				CallbackStatus: model.CallbackStatusPending,
+			err = bis.applyDepositPolicy(tx, &parsedDeposit)
+			if err != nil {
+				return err
 			}
-			err = tx.Create(&deposit).Error
+			err = tx.Create(&parsedDeposit).Error
 			if err != nil {
 				bis.log.Errorw("failed to save tx parsed result", "error", err)
 				return err
 			}
-		} else if deposit.CallbackStatus == model.CallbackStatusSuccess &&
-			deposit.ListenerStatus == model.ListenerStatusPending {
+		} else if (deposit.CallbackStatus == model.CallbackStatusSuccess &&
+			deposit.ListenerStatus == model.ListenerStatusPending) ||
+			deposit.ListenerStatus == model.ListenerStatusReorged ||
+			deposit.ListenerStatus == model.ListenerStatusMempool ||
+			deposit.ListenerStatus == model.ListenerStatusDropped {
 			// if existed, update deposit record
+			// reorged deposit is included again by the new branch
+			// mempool deposit is confirmed, dropped deposit may be mined by other node before eviction
 			updateFields := map[string]interface{}{
-				model.Deposit{}.Column().BtcBlockNumber: btcBlockNumber,
-				model.Deposit{}.Column().BtcTxIndex:     parseResult.Index,
-				model.Deposit{}.Column().BtcFroms:       string(froms),
-				model.Deposit{}.Column().BtcTos:         string(tos),
-				model.Deposit{}.Column().BtcBlockTime:   btcBlockTime,
-				model.Deposit{}.Column().ListenerStatus: model.ListenerStatusSuccess,
+				model.Deposit{}.Column().BtcBlockNumber:   parsedDeposit.BtcBlockNumber,
+				model.Deposit{}.Column().BtcTxIndex:       parsedDeposit.BtcTxIndex,
+				model.Deposit{}.Column().BtcFroms:         parsedDeposit.BtcFroms,
+				model.Deposit{}.Column().BtcTos:           parsedDeposit.BtcTos,
+				model.Deposit{}.Column().BtcMemoAddress:   parsedDeposit.BtcMemoAddress,
+				model.Deposit{}.Column().BtcToLabel:       parsedDeposit.BtcToLabel,
+				model.Deposit{}.Column().BtcBlockTime:     parsedDeposit.BtcBlockTime,
+				model.Deposit{}.Column().BtcConfirmations: parsedDeposit.BtcConfirmations,
+				model.Deposit{}.Column().BtcFrom:          parsedDeposit.BtcFrom,
+				model.Deposit{}.Column().BtcFromPolicy:    parsedDeposit.BtcFromPolicy,
+				model.Deposit{}.Column().BtcTxType:        parsedDeposit.BtcTxType,
+			}
+			// the uuid is not changed by the label, deposits created by the notify api are set here
+			if deposit.DepositUUID == "" {
+				updateFields[model.Deposit{}.Column().DepositUUID] = parsedDeposit.DepositUUID
 			}
-			err = tx.Model(&model.Deposit{}).Where("id = ?", deposit.ID).Updates(updateFields).Error
+			states := map[string]int{
+				model.Deposit{}.Column().ListenerStatus: parsedDeposit.ListenerStatus,
+			}
+			// reorged deposit was checked when first confirmed, it may have been released already
+			if deposit.ListenerStatus != model.ListenerStatusReorged && deposit.B2TxStatus == model.DepositB2TxStatusPending {
+				err = bis.applyDepositPolicy(tx, &parsedDeposit)
+				if err != nil {
+					return err
+				}
+				states[model.Deposit{}.Column().B2TxStatus] = parsedDeposit.B2TxStatus
+				updateFields[model.Deposit{}.Column().HoldReason] = parsedDeposit.HoldReason
+			}
+			err = model.TransitDeposit(tx, deposit.ID, model.DepositTransition{
+				To:      states,
+				Updates: updateFields,
+				Actor:   model.DepositActorIndexer,
+				Reason:  fmt.Sprintf("btc tx indexed in block %d", btcBlockNumber),
+			})
 			if err != nil {
 				bis.log.Errorw("failed to update tx parsed result", "error", err)
 				return err
@@ -282,6 +683,66 @@ func (bis *IndexerService) SaveParsedResult(
 	return err
 }
 
+// parsedDeposit new deposit record of the parsed result
+func parsedDeposit(
+	parseResult *types.BitcoinTxParseResult,
+	btcBlockNumber int64,
+	b2TxStatus int,
+	btcBlockTime time.Time,
+) (model.Deposit, error) {
+	if len(parseResult.From) == 0 {
+		return model.Deposit{}, fmt.Errorf("parse result from empty")
+	}
+
+	if len(parseResult.To) == 0 {
+		return model.Deposit{}, fmt.Errorf("parse result to empty")
+	}
+
+	froms, err := json.Marshal(parseResult.From)
+	if err != nil {
+		return model.Deposit{}, err
+	}
+	tos, err := json.Marshal(parseResult.Tos)
+	if err != nil {
+		return model.Deposit{}, err
+	}
+
+	btcTxType, ok := BtcTxTypes[parseResult.TxType]
+	if !ok {
+		return model.Deposit{}, fmt.Errorf("unknown tx type %s", parseResult.TxType)
+	}
+	// deposit rejected by the attribution policy or quarantined tx type is recorded for review, not processed
+	listenerStatus := model.ListenerStatusSuccess
+	if parseResult.FromAttribution == FromAttributionRejected {
+		listenerStatus = model.ListenerStatusFromRejected
+	}
+	if parseResult.Quarantined {
+		listenerStatus = model.ListenerStatusQuarantined
+	}
+
+	return model.Deposit{
+		BtcBlockNumber:   btcBlockNumber,
+		BtcTxIndex:       parseResult.Index,
+		BtcTxHash:        parseResult.TxID,
+		BtcTxType:        btcTxType,
+		BtcFrom:          parseResult.FromAddress,
+		BtcFromPolicy:    parseResult.FromAttribution,
+		BtcTos:           string(tos),
+		BtcTo:            parseResult.To,
+		BtcToLabel:       parseResult.ToLabel,
+		DepositUUID:      NewDepositUUID(parseResult.TxID, parseResult.To, parseResult.ToLabel).Hex(),
+		BtcValue:         parseResult.Value,
+		BtcFroms:         string(froms),
+		BtcMemoAddress:   parseResult.MemoAddress,
+		B2TxStatus:       b2TxStatus,
+		BtcBlockTime:     btcBlockTime,
+		B2TxRetry:        0,
+		BtcConfirmations: 1,
+		ListenerStatus:   listenerStatus,
+		CallbackStatus:   model.CallbackStatusPending,
+	}, nil
+}
+
 func (bis *IndexerService) HandleResults(
 	txResults []*types.BitcoinTxParseResult,
 	btcIndex model.BtcIndex,
@@ -0,0 +1,255 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// mockChainIndexer serves block headers from the active chain, the active
+// chain can be switched to simulate a reorg
+type mockChainIndexer struct {
+	active map[int64]*wire.BlockHeader
+	// results parsed deposits by height
+	results map[int64][]*types.BitcoinTxParseResult
+	// resets number of prefetch resets
+	resets int
+}
+
+func (m *mockChainIndexer) ResetPrefetch() {
+	m.resets++
+}
+
+func (m *mockChainIndexer) ParseBlock(height int64, _ int64) ([]*types.BitcoinTxParseResult, *wire.BlockHeader, error) {
+	header, ok := m.active[height]
+	if !ok {
+		return nil, nil, fmt.Errorf("block %d not found", height)
+	}
+	return m.results[height], header, nil
+}
+
+func (m *mockChainIndexer) LatestBlock() (int64, error) {
+	var latest int64
+	for k := range m.active {
+		if k > latest {
+			latest = k
+		}
+	}
+	return latest, nil
+}
+
+func (m *mockChainIndexer) BlockHash(height int64) (*chainhash.Hash, error) {
+	header, ok := m.active[height]
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	hash := header.BlockHash()
+	return &hash, nil
+}
+
+func (m *mockChainIndexer) CheckConfirmations(_ string) error {
+	return nil
+}
+
+// mockChain build headers [from, to] on top of parent, nonce distinguishes branches
+func mockChain(parent chainhash.Hash, from, to int64, nonce uint32) map[int64]*wire.BlockHeader {
+	chain := make(map[int64]*wire.BlockHeader)
+	prev := parent
+	for i := from; i <= to; i++ {
+		header := wire.NewBlockHeader(1, &prev, &chainhash.Hash{}, 0, nonce)
+		header.Timestamp = time.Unix(1700000000+i, 0)
+		chain[i] = header
+		prev = header.BlockHash()
+	}
+	return chain
+}
+
+func TestFindForkBlock(t *testing.T) {
+	// common chain 100..102, chain a 103..105, chain b 103..106
+	common := mockChain(chainhash.Hash{}, 100, 102, 0)
+	chainA := mockChain(common[102].BlockHash(), 103, 105, 1)
+	chainB := mockChain(common[102].BlockHash(), 103, 106, 2)
+
+	recorded := make(map[int64]string)
+	for k, v := range common {
+		recorded[k] = v.BlockHash().String()
+	}
+	for k, v := range chainA {
+		recorded[k] = v.BlockHash().String()
+	}
+
+	active := make(map[int64]*wire.BlockHeader)
+	for k, v := range common {
+		active[k] = v
+	}
+	for k, v := range chainB {
+		active[k] = v
+	}
+	indexer := &mockChainIndexer{active: active}
+
+	testCases := []struct {
+		name      string
+		recorded  map[int64]string
+		height    int64
+		forkBlock int64
+		err       error
+	}{
+		{
+			name:      "success: fork at common ancestor",
+			recorded:  recorded,
+			height:    105,
+			forkBlock: 102,
+		},
+		{
+			name:      "success: no reorg",
+			recorded:  recorded,
+			height:    102,
+			forkBlock: 102,
+		},
+		{
+			name:      "success: unrecorded block",
+			recorded:  map[int64]string{105: recorded[105]},
+			height:    105,
+			forkBlock: 104,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			forkBlock, err := bitcoin.FindForkBlock(indexer, tc.recorded, tc.height)
+			require.NoError(t, err)
+			require.Equal(t, tc.forkBlock, forkBlock)
+		})
+	}
+
+	t.Run("fail: reorg too deep", func(t *testing.T) {
+		deepA := mockChain(chainhash.Hash{}, 0, bitcoin.ReorgMaxDepth+1, 1)
+		deepB := mockChain(chainhash.Hash{}, 0, bitcoin.ReorgMaxDepth+1, 2)
+		deepRecorded := make(map[int64]string)
+		for k, v := range deepA {
+			deepRecorded[k] = v.BlockHash().String()
+		}
+		_, err := bitcoin.FindForkBlock(&mockChainIndexer{active: deepB}, deepRecorded, bitcoin.ReorgMaxDepth+1)
+		require.True(t, errors.Is(err, bitcoin.ErrReorgTooDeep))
+	})
+
+	t.Run("success: new branch extends fork block", func(t *testing.T) {
+		_, header, err := indexer.ParseBlock(103, 0)
+		require.NoError(t, err)
+		require.NotEqual(t, recorded[103], header.BlockHash().String())
+		require.Equal(t, recorded[102], header.PrevBlock.String())
+	})
+}
+
+func TestCheckReorgRollbackToFork(t *testing.T) {
+	// common chain 100..102, chain a 103..105 indexed, chain b 103..106 becomes the longest chain
+	common := mockChain(chainhash.Hash{}, 100, 102, 0)
+	chainA := mockChain(common[102].BlockHash(), 103, 105, 1)
+	chainB := mockChain(common[102].BlockHash(), 103, 106, 2)
+	active := make(map[int64]*wire.BlockHeader)
+	for k, v := range common {
+		active[k] = v
+	}
+	for k, v := range chainA {
+		active[k] = v
+	}
+	indexer := &mockChainIndexer{active: active}
+	db := newTestDB(t)
+	service := bitcoin.NewIndexerService(indexer, db, log.NewNopLogger(), 0)
+
+	btcIndex := model.BtcIndex{}
+	for height := int64(100); height <= 105; height++ {
+		btcIndex.BtcIndexBlock = height
+		require.NoError(t, service.SaveBlock(height, active[height], btcIndex))
+		require.NoError(t, db.First(&btcIndex).Error)
+	}
+	deposits := []model.Deposit{
+		{BtcBlockNumber: 101, BtcTxHash: "a1", ListenerStatus: model.ListenerStatusSuccess},
+		{BtcBlockNumber: 103, BtcTxHash: "a3", ListenerStatus: model.ListenerStatusSuccess, BtcConfirmations: 3},
+		{BtcBlockNumber: 105, BtcTxHash: "a5", ListenerStatus: model.ListenerStatusSuccess, BtcConfirmations: 1},
+		{BtcBlockNumber: 104, BtcTxHash: "a4", ListenerStatus: model.ListenerStatusQuarantined},
+		// minted, or the deposit tx broadcast, before the reorg
+		{BtcBlockNumber: 103, BtcTxHash: "m3", ListenerStatus: model.ListenerStatusSuccess, B2TxHash: "0xm3"},
+		{BtcBlockNumber: 104, BtcTxHash: "m4", ListenerStatus: model.ListenerStatusSuccess,
+			B2TxStatus: model.DepositB2TxStatusWaitMined, B2TxHash: "0xm4"},
+		{BtcBlockNumber: 104, BtcTxHash: "w4", ListenerStatus: model.ListenerStatusSuccess,
+			B2TxStatus: model.DepositB2TxStatusWaitMined},
+	}
+	require.NoError(t, db.Create(&deposits).Error)
+	// the success status is the zero value, skipped on create
+	require.NoError(t, db.Model(&deposits[4]).Update(model.Deposit{}.Column().B2TxStatus, model.DepositB2TxStatusSuccess).Error)
+
+	// the next block of chain a extends the recorded chain
+	_, reorg, err := service.CheckReorg(105, chainA[105])
+	require.NoError(t, err)
+	require.False(t, reorg)
+
+	// chain b wins
+	for k := range chainA {
+		delete(active, k)
+	}
+	for k, v := range chainB {
+		active[k] = v
+	}
+	forkBlock, reorg, err := service.CheckReorg(106, chainB[106])
+	require.NoError(t, err)
+	require.True(t, reorg)
+	require.Equal(t, int64(102), forkBlock)
+
+	require.NoError(t, service.RollbackToFork(forkBlock, &btcIndex))
+	require.Equal(t, int64(102), btcIndex.BtcIndexBlock)
+	require.Equal(t, 1, indexer.resets)
+	var savedIndex model.BtcIndex
+	require.NoError(t, db.First(&savedIndex).Error)
+	require.Equal(t, int64(102), savedIndex.BtcIndexBlock)
+	require.Equal(t, int64(0), savedIndex.BtcIndexTx)
+
+	var blocks []model.BtcBlock
+	require.NoError(t, db.Order("height ASC").Find(&blocks).Error)
+	require.Len(t, blocks, 3)
+	require.Equal(t, int64(102), blocks[2].Height)
+
+	expected := map[string]int{
+		"a1": model.ListenerStatusSuccess,
+		"a3": model.ListenerStatusReorged,
+		"a5": model.ListenerStatusReorged,
+		"a4": model.ListenerStatusQuarantined,
+		"m3": model.ListenerStatusReorged,
+		"m4": model.ListenerStatusReorged,
+		"w4": model.ListenerStatusReorged,
+	}
+	minted := map[string]bool{"m3": true, "m4": true}
+	for _, v := range deposits {
+		var deposit model.Deposit
+		require.NoError(t, db.First(&deposit, v.ID).Error)
+		require.Equal(t, expected[v.BtcTxHash], deposit.ListenerStatus, v.BtcTxHash)
+		var history []model.DepositStatusHistory
+		require.NoError(t, db.Where("deposit_id = ?", v.ID).Find(&history).Error)
+		if expected[v.BtcTxHash] != model.ListenerStatusReorged {
+			require.Empty(t, history)
+			continue
+		}
+		require.Equal(t, int64(0), deposit.BtcConfirmations)
+		require.Len(t, history, 1)
+		require.Equal(t, model.DepositActorIndexer, history[0].Actor)
+		if minted[v.BtcTxHash] {
+			require.Equal(t, fmt.Sprintf("btc block %d orphaned after minted, fork block 102", v.BtcBlockNumber), history[0].Reason)
+			continue
+		}
+		require.Equal(t, fmt.Sprintf("btc block %d orphaned, fork block 102", v.BtcBlockNumber), history[0].Reason)
+	}
+
+	// chain b is indexed from the fork block
+	_, reorg, err = service.CheckReorg(103, chainB[103])
+	require.NoError(t, err)
+	require.False(t, reorg)
+}
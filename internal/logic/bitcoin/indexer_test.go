@@ -7,9 +7,11 @@ import (
 	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
 
 	// tmlog "github.com/cometbft/cometbft/libs/log"
 	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/stretchr/testify/require"
 )
@@ -62,7 +64,7 @@ func TestNewBitcoinIndexer(t *testing.T) {
 			log.NewNopLogger(),
 			mockRpcClient(t),
 			&chaincfg.MainNetParams, // chainParams Do not affect the address
-			tc.listendAddress,
+			[]config.ListenAddress{{Label: config.DefaultListenAddressLabel, Address: tc.listendAddress}},
 			1,
 		)
 		if err != nil {
@@ -136,6 +138,67 @@ func TestParseAddress(t *testing.T) {
 	}
 }
 
+func TestParseEvmAddressMemo(t *testing.T) {
+	evmAddress := common.HexToAddress("0x1234567890AbcdEF1234567890aBcdef12345678")
+	nullData := func(data []byte) []byte {
+		script, err := txscript.NullDataScript(data)
+		require.NoError(t, err)
+		return script
+	}
+	p2wpkh, err := hexutil.Decode("0x0014c7d5a5d4d6b1f7bb7fb9c3b5e10e6a4aa4b0d8a1")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		pkScript []byte
+		address  string
+		errMsg   string
+	}{
+		{
+			"success",
+			nullData(append(append([]byte{}, bitcoin.EvmAddressMemoMagic...), evmAddress.Bytes()...)),
+			evmAddress.Hex(),
+			"",
+		},
+		{
+			"fail: magic mismatch",
+			nullData(append([]byte("b3"), evmAddress.Bytes()...)),
+			"",
+			"parse evm address memo err:magic or length mismatch",
+		},
+		{
+			"fail: length mismatch",
+			nullData(append(append([]byte{}, bitcoin.EvmAddressMemoMagic...), evmAddress.Bytes()[:19]...)),
+			"",
+			"parse evm address memo err:magic or length mismatch",
+		},
+		{
+			"fail: zero address",
+			nullData(append(append([]byte{}, bitcoin.EvmAddressMemoMagic...), make([]byte, 20)...)),
+			"",
+			"parse evm address memo err:zero address",
+		},
+		{
+			"fail: not null data",
+			p2wpkh,
+			"",
+			"parse evm address memo err:not null data type",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			address, err := bitcoin.ParseEvmAddressMemo(tc.pkScript)
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.address, address)
+		})
+	}
+}
+
 // TestLocalParseTx only test in local
 // data source: testnet network
 func TestLocalParseTx(t *testing.T) {
@@ -202,11 +265,14 @@ func mockRpcClient(t *testing.T) *rpcclient.Client {
 
 func mockBitcoinIndexer(t *testing.T, chainParams *chaincfg.Params) *bitcoin.Indexer {
 	cfg, err := config.LoadBitcoinConfig("../../config/testdata")
+	require.NoError(t, err)
+	listenAddresses, err := cfg.ListenAddresses()
+	require.NoError(t, err)
 	indexer, err := bitcoin.NewBitcoinIndexer(
 		log.NewNopLogger(),
 		mockRpcClient(t),
 		chainParams,
-		cfg.IndexerListenAddress,
+		listenAddresses,
 		cfg.IndexerListenTargetConfirmations)
 	require.NoError(t, err)
 	return indexer
@@ -232,7 +298,7 @@ func bitcoinIndexerWithConfig(t *testing.T, indexListenAddress string) *bitcoin.
 		log.NewNopLogger(),
 		client,
 		bitcoinParam,
-		indexListenAddress,
+		[]config.ListenAddress{{Label: config.DefaultListenAddressLabel, Address: indexListenAddress}},
 		cfg.IndexerListenTargetConfirmations,
 	)
 	require.NoError(t, err)
@@ -0,0 +1,185 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/cometbft/cometbft/libs/service"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MempoolServiceName = "BitcoinMempoolService"
+
+	MempoolWaitTimeout = 10 * time.Second
+)
+
+// MempoolService records unconfirmed deposits, the block indexer confirms them,
+// deposits evicted from mempool or replaced are marked dropped
+type MempoolService struct {
+	service.BaseService
+
+	mempoolIdxr types.BITCOINMempoolIndexer
+
+	db       *gorm.DB
+	log      log.Logger
+	stopChan chan struct{}
+}
+
+// NewMempoolService returns a new service instance.
+func NewMempoolService(
+	mempoolIdxr types.BITCOINMempoolIndexer,
+	db *gorm.DB,
+	logger log.Logger,
+) *MempoolService {
+	is := &MempoolService{
+		mempoolIdxr: mempoolIdxr,
+		db:          db,
+		log:         logger,
+		stopChan:    make(chan struct{}),
+	}
+	is.BaseService = *service.NewBaseService(nil, MempoolServiceName, is)
+	return is
+}
+
+// OnStart
+func (bis *MempoolService) OnStart() error {
+	ticker := time.NewTicker(MempoolWaitTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bis.stopChan:
+			bis.log.Warnf("mempool stopping...")
+			return nil
+		case <-ticker.C:
+			if err := bis.HandleMempool(); err != nil {
+				bis.log.Errorw("handle mempool", "error", err)
+			}
+		}
+	}
+}
+
+func (bis *MempoolService) OnStop() {
+	bis.log.Warnf("bitcoin mempool service stoping...")
+	close(bis.stopChan)
+}
+
+// HandleMempool save unconfirmed deposits, mark deposits left mempool without confirmation as dropped
+func (bis *MempoolService) HandleMempool() error {
+	results, err := bis.mempoolIdxr.ParseMempool()
+	if err != nil {
+		return err
+	}
+	inMempool := make(map[string]struct{}, len(results))
+	for _, v := range results {
+		inMempool[v.TxID] = struct{}{}
+		if err := bis.SaveMempoolResult(v); err != nil {
+			bis.log.Errorw("failed to save mempool result", "error", err, "txId", v.TxID)
+		}
+	}
+
+	var deposits []model.Deposit
+	err = bis.db.
+		Where(
+			fmt.Sprintf("%s.%s = ?", model.Deposit{}.TableName(), model.Deposit{}.Column().ListenerStatus),
+			model.ListenerStatusMempool,
+		).
+		Find(&deposits).Error
+	if err != nil {
+		return err
+	}
+	for _, deposit := range deposits {
+		if _, ok := inMempool[deposit.BtcTxHash]; ok {
+			continue
+		}
+		// mined txs left mempool too, they are confirmed by the block indexer
+		exists, err := bis.mempoolIdxr.TxExists(deposit.BtcTxHash)
+		if err != nil {
+			bis.log.Errorw("check mempool tx exists", "error", err, "txId", deposit.BtcTxHash)
+			continue
+		}
+		if exists {
+			continue
+		}
+		bis.log.Warnw("mempool tx dropped", "txId", deposit.BtcTxHash, "to", deposit.BtcTo)
+		err = model.TransitDeposit(bis.db, deposit.ID, model.DepositTransition{
+			To:     map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusDropped},
+			From:   map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusMempool},
+			Actor:  model.DepositActorMempool,
+			Reason: "btc tx evicted from mempool or replaced",
+		})
+		// confirmed by the block indexer meanwhile
+		if err != nil && !errors.Is(err, model.ErrDepositStatusMismatch) {
+			bis.log.Errorw("failed to mark mempool tx dropped", "error", err, "txId", deposit.BtcTxHash)
+		}
+	}
+	return nil
+}
+
+// SaveMempoolResult save unconfirmed deposit, indexed deposits are not changed
+func (bis *MempoolService) SaveMempoolResult(parseResult *types.BitcoinTxParseResult) error {
+	froms, err := json.Marshal(parseResult.From)
+	if err != nil {
+		return err
+	}
+	tos, err := json.Marshal(parseResult.Tos)
+	if err != nil {
+		return err
+	}
+	btcTxType, ok := BtcTxTypes[parseResult.TxType]
+	if !ok {
+		return fmt.Errorf("unknown tx type %s", parseResult.TxType)
+	}
+	deposit := model.Deposit{
+		BtcTxHash:      parseResult.TxID,
+		BtcTxType:      btcTxType,
+		BtcFrom:        parseResult.FromAddress,
+		BtcFromPolicy:  parseResult.FromAttribution,
+		BtcTos:         string(tos),
+		BtcTo:          parseResult.To,
+		BtcToLabel:     parseResult.ToLabel,
+		DepositUUID:    NewDepositUUID(parseResult.TxID, parseResult.To, parseResult.ToLabel).Hex(),
+		BtcValue:       parseResult.Value,
+		BtcFroms:       string(froms),
+		BtcMemoAddress: parseResult.MemoAddress,
+		B2TxStatus:     model.DepositB2TxStatusPending,
+		ListenerStatus: model.ListenerStatusMempool,
+		CallbackStatus: model.CallbackStatusPending,
+	}
+	return bis.db.Transaction(func(tx *gorm.DB) error {
+		// the block indexer may save the deposit first
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&deposit)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			bis.log.Infow("mempool deposit detected", "txId", parseResult.TxID, "to", parseResult.To)
+			return nil
+		}
+		// dropped tx back to mempool, e.g. rebroadcast
+		var dropped model.Deposit
+		err := tx.
+			Where(fmt.Sprintf("%s = ? AND %s = ?", model.Deposit{}.Column().BtcTxHash, model.Deposit{}.Column().BtcTo),
+				parseResult.TxID, parseResult.To).
+			Where(fmt.Sprintf("%s = ?", model.Deposit{}.Column().ListenerStatus), model.ListenerStatusDropped).
+			First(&dropped).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return model.TransitDeposit(tx, dropped.ID, model.DepositTransition{
+			To:     map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusMempool},
+			From:   map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusDropped},
+			Actor:  model.DepositActorMempool,
+			Reason: "btc tx back to mempool",
+		})
+	})
+}
@@ -0,0 +1,324 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/stretchr/testify/require"
+)
+
+// mockMempoolIndexer serves fixed mempool results, txs not in exists are evicted
+type mockMempoolIndexer struct {
+	results   []*types.BitcoinTxParseResult
+	err       error
+	exists    map[string]bool
+	existsErr error
+}
+
+func (m *mockMempoolIndexer) ParseMempool() ([]*types.BitcoinTxParseResult, error) {
+	return m.results, m.err
+}
+
+func (m *mockMempoolIndexer) TxExists(hash string) (bool, error) {
+	if m.existsErr != nil {
+		return false, m.existsErr
+	}
+	return m.exists[hash], nil
+}
+
+func mempoolResult(txID string, txType string) *types.BitcoinTxParseResult {
+	return &types.BitcoinTxParseResult{
+		TxID:        txID,
+		TxType:      txType,
+		Value:       10000,
+		From:        []types.BitcoinFrom{{Address: "tb1-from", Value: 20000, TxID: "prev", Vout: 1}},
+		To:          fixtureListenAddress,
+		Tos:         []types.BitcoinTo{{Address: fixtureListenAddress, Value: 10000}},
+		FromAddress: "tb1-from",
+	}
+}
+
+func mempoolDeposit(txID string, listenerStatus int) model.Deposit {
+	return model.Deposit{
+		BtcTxHash:      txID,
+		BtcTo:          fixtureListenAddress,
+		BtcValue:       10000,
+		B2TxStatus:     model.DepositB2TxStatusPending,
+		ListenerStatus: listenerStatus,
+	}
+}
+
+func TestHandleMempool(t *testing.T) {
+	testCases := []struct {
+		name      string
+		deposits  []model.Deposit
+		results   []*types.BitcoinTxParseResult
+		parseErr  error
+		exists    map[string]bool
+		existsErr error
+		err       bool
+		// listener status by tx id, missing tx id is not saved
+		expected map[string]int
+		// status history reason by tx id
+		reasons map[string]string
+	}{
+		{
+			name:     "success: unconfirmed deposits saved",
+			results:  []*types.BitcoinTxParseResult{mempoolResult("m1", bitcoin.TxTypeTransfer), mempoolResult("m2", bitcoin.TxTypeTransfer)},
+			expected: map[string]int{"m1": model.ListenerStatusMempool, "m2": model.ListenerStatusMempool},
+		},
+		{
+			name:     "success: indexed deposit not changed",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusSuccess)},
+			results:  []*types.BitcoinTxParseResult{mempoolResult("m1", bitcoin.TxTypeTransfer)},
+			expected: map[string]int{"m1": model.ListenerStatusSuccess},
+		},
+		{
+			name:     "success: evicted deposit dropped",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusMempool)},
+			exists:   map[string]bool{},
+			expected: map[string]int{"m1": model.ListenerStatusDropped},
+			reasons:  map[string]string{"m1": "btc tx evicted from mempool or replaced"},
+		},
+		{
+			name:     "success: mined deposit left for the block indexer",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusMempool)},
+			exists:   map[string]bool{"m1": true},
+			expected: map[string]int{"m1": model.ListenerStatusMempool},
+		},
+		{
+			name:     "success: dropped deposit back to mempool",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusDropped)},
+			results:  []*types.BitcoinTxParseResult{mempoolResult("m1", bitcoin.TxTypeTransfer)},
+			expected: map[string]int{"m1": model.ListenerStatusMempool},
+			reasons:  map[string]string{"m1": "btc tx back to mempool"},
+		},
+		{
+			name:     "success: dropped deposit stays dropped",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusDropped)},
+			expected: map[string]int{"m1": model.ListenerStatusDropped},
+		},
+		{
+			name:     "fail: unknown tx type not saved",
+			results:  []*types.BitcoinTxParseResult{mempoolResult("m1", "unknown"), mempoolResult("m2", bitcoin.TxTypeTransfer)},
+			expected: map[string]int{"m2": model.ListenerStatusMempool},
+		},
+		{
+			name:      "fail: tx exists check, deposit kept",
+			deposits:  []model.Deposit{mempoolDeposit("m1", model.ListenerStatusMempool)},
+			existsErr: errors.New("rpc unavailable"),
+			expected:  map[string]int{"m1": model.ListenerStatusMempool},
+		},
+		{
+			name:     "fail: parse mempool",
+			deposits: []model.Deposit{mempoolDeposit("m1", model.ListenerStatusMempool)},
+			parseErr: errors.New("rpc unavailable"),
+			err:      true,
+			expected: map[string]int{"m1": model.ListenerStatusMempool},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if len(tc.deposits) > 0 {
+				require.NoError(t, db.Create(&tc.deposits).Error)
+			}
+			service := bitcoin.NewMempoolService(&mockMempoolIndexer{
+				results:   tc.results,
+				err:       tc.parseErr,
+				exists:    tc.exists,
+				existsErr: tc.existsErr,
+			}, db, log.NewNopLogger())
+
+			err := service.HandleMempool()
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var deposits []model.Deposit
+			require.NoError(t, db.Find(&deposits).Error)
+			require.Len(t, deposits, len(tc.expected))
+			for _, deposit := range deposits {
+				status, ok := tc.expected[deposit.BtcTxHash]
+				require.True(t, ok, deposit.BtcTxHash)
+				require.Equal(t, status, deposit.ListenerStatus, deposit.BtcTxHash)
+				require.Equal(t, model.DepositB2TxStatusPending, deposit.B2TxStatus)
+
+				var history []model.DepositStatusHistory
+				require.NoError(t, db.Where("deposit_id = ?", deposit.ID).Find(&history).Error)
+				reason, ok := tc.reasons[deposit.BtcTxHash]
+				if !ok {
+					require.Empty(t, history, deposit.BtcTxHash)
+					continue
+				}
+				require.Len(t, history, 1)
+				require.Equal(t, model.DepositActorMempool, history[0].Actor)
+				require.Equal(t, reason, history[0].Reason)
+			}
+		})
+	}
+}
+
+func TestSaveMempoolResult(t *testing.T) {
+	db := newTestDB(t)
+	service := bitcoin.NewMempoolService(&mockMempoolIndexer{}, db, log.NewNopLogger())
+
+	result := mempoolResult("m1", bitcoin.TxTypeTransfer)
+	result.MemoAddress = "0x1111111111111111111111111111111111111111"
+	result.ToLabel = "vault"
+	require.NoError(t, service.SaveMempoolResult(result))
+	// saved once, seen again in the next poll
+	require.NoError(t, service.SaveMempoolResult(result))
+
+	var deposits []model.Deposit
+	require.NoError(t, db.Find(&deposits).Error)
+	require.Len(t, deposits, 1)
+	deposit := deposits[0]
+	require.Equal(t, model.ListenerStatusMempool, deposit.ListenerStatus)
+	require.Equal(t, model.DepositB2TxStatusPending, deposit.B2TxStatus)
+	require.Equal(t, model.CallbackStatusPending, deposit.CallbackStatus)
+	require.Equal(t, int64(10000), deposit.BtcValue)
+	require.Equal(t, "tb1-from", deposit.BtcFrom)
+	require.Equal(t, "vault", deposit.BtcToLabel)
+	require.Equal(t, result.MemoAddress, deposit.BtcMemoAddress)
+	require.Equal(t, int64(0), deposit.BtcBlockNumber)
+	require.JSONEq(t, `[{"Address":"tb1-from","Value":20000,"TxID":"prev","Vout":1}]`, deposit.BtcFroms)
+
+	require.Error(t, service.SaveMempoolResult(mempoolResult("m2", "unknown")))
+}
+
+func TestSaveParsedResultConfirmsMempoolDeposit(t *testing.T) {
+	blockTime := time.Unix(1700000000, 0)
+
+	testCases := []struct {
+		name     string
+		deposit  model.Deposit
+		expected int
+		// confirmed deposit is updated by the indexer with a status history
+		confirmed bool
+	}{
+		{
+			name:      "success: mempool deposit confirmed",
+			deposit:   mempoolDeposit("m1", model.ListenerStatusMempool),
+			expected:  model.ListenerStatusSuccess,
+			confirmed: true,
+		},
+		{
+			name:      "success: dropped deposit mined before eviction",
+			deposit:   mempoolDeposit("m1", model.ListenerStatusDropped),
+			expected:  model.ListenerStatusSuccess,
+			confirmed: true,
+		},
+		{
+			name:     "success: indexed deposit not changed",
+			deposit:  mempoolDeposit("m1", model.ListenerStatusSuccess),
+			expected: model.ListenerStatusSuccess,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			require.NoError(t, db.Create(&tc.deposit).Error)
+			service := bitcoin.NewIndexerService(&mockChainIndexer{}, db, log.NewNopLogger(), 0)
+
+			result := mempoolResult("m1", bitcoin.TxTypeTransfer)
+			result.Index = 7
+			err := service.SaveParsedResult(result, 100, model.DepositB2TxStatusPending, blockTime,
+				model.BtcIndex{BtcIndexBlock: 100, BtcIndexTx: 7})
+			require.NoError(t, err)
+
+			var deposit model.Deposit
+			require.NoError(t, db.First(&deposit, tc.deposit.ID).Error)
+			require.Equal(t, tc.expected, deposit.ListenerStatus)
+			require.Equal(t, model.DepositB2TxStatusPending, deposit.B2TxStatus)
+
+			var history []model.DepositStatusHistory
+			require.NoError(t, db.Where("deposit_id = ?", deposit.ID).Find(&history).Error)
+			if !tc.confirmed {
+				require.Equal(t, int64(0), deposit.BtcBlockNumber)
+				require.Empty(t, history)
+				return
+			}
+			require.Equal(t, int64(100), deposit.BtcBlockNumber)
+			require.Equal(t, int64(7), deposit.BtcTxIndex)
+			require.Equal(t, int64(1), deposit.BtcConfirmations)
+			require.True(t, blockTime.Equal(deposit.BtcBlockTime))
+			require.Len(t, history, 1)
+			require.Equal(t, model.DepositActorIndexer, history[0].Actor)
+			require.Equal(t, "btc tx indexed in block 100", history[0].Reason)
+		})
+	}
+}
+
+func TestIndexerParseMempool(t *testing.T) {
+	rpc := newMockBitcoinRPC(t, 0, 0)
+	block, _ := loadBlockFixture(t)
+	// the fixture block txs are unconfirmed, coinbase excluded
+	mempool := make([]string, 0, len(block.Transactions)-1)
+	for _, tx := range block.Transactions[1:] {
+		mempool = append(mempool, tx.TxHash().String())
+	}
+	rpc.setMempool(mempool)
+	indexer := mockRPCIndexer(t, rpc, bitcoin.PipelineConfig{})
+
+	expected, _, err := indexer.ParseBlock(0, 0)
+	require.NoError(t, err)
+	require.Len(t, expected, 12)
+
+	results, err := indexer.ParseMempool()
+	require.NoError(t, err)
+	require.Len(t, results, len(expected))
+	for i, v := range results {
+		require.Equal(t, expected[i].TxID, v.TxID)
+		require.Equal(t, expected[i].Value, v.Value)
+		require.Equal(t, expected[i].From, v.From)
+		require.Equal(t, int64(0), v.Index)
+	}
+
+	// parsed txs are not fetched again
+	fetched := rpc.callCount("getrawtransaction")
+	results, err = indexer.ParseMempool()
+	require.NoError(t, err)
+	require.Len(t, results, len(expected))
+	require.Equal(t, fetched, rpc.callCount("getrawtransaction"))
+
+	// the first deposit tx evicted
+	evicted := expected[0].TxID
+	remaining := make([]string, 0, len(mempool))
+	for _, v := range mempool {
+		if v != evicted {
+			remaining = append(remaining, v)
+		}
+	}
+	rpc.setMempool(remaining)
+	results, err = indexer.ParseMempool()
+	require.NoError(t, err)
+	require.Len(t, results, len(expected)-1)
+	for _, v := range results {
+		require.NotEqual(t, evicted, v.TxID)
+	}
+	require.Equal(t, fetched, rpc.callCount("getrawtransaction"))
+
+	// rebroadcast, parsed again
+	rpc.setMempool(mempool)
+	results, err = indexer.ParseMempool()
+	require.NoError(t, err)
+	require.Len(t, results, len(expected))
+	require.Greater(t, rpc.callCount("getrawtransaction"), fetched)
+
+	exists, err := indexer.TxExists(evicted)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = indexer.TxExists("00000000000000000000000000000000000000000000000000000000000000aa")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
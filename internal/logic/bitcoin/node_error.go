@@ -0,0 +1,61 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// node errors with the messages of the go-ethereum txpool and core errors, importing those packages
+// would link the whole node database into the bridge
+var (
+	// ErrNodeAlreadyKnown txpool.ErrAlreadyKnown, the tx is already in the pool
+	ErrNodeAlreadyKnown = errors.New("already known")
+	// ErrNodeReplaceUnderpriced txpool.ErrReplaceUnderpriced
+	ErrNodeReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	// ErrNodeNonceTooLow core.ErrNonceTooLow
+	ErrNodeNonceTooLow = errors.New("nonce too low")
+	// ErrNodeInsufficientFunds core.ErrInsufficientFunds
+	ErrNodeInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+)
+
+// nodeErrors errors reported by the eth node, json-rpc errors only carry the error message,
+// which starts with the message of the go-ethereum error
+var nodeErrors = []error{
+	ErrNodeAlreadyKnown,
+	ErrNodeReplaceUnderpriced,
+	ErrNodeNonceTooLow,
+	ErrNodeInsufficientFunds,
+	vm.ErrExecutionReverted,
+	ErrBridgeFromGasInsufficient,
+}
+
+// NodeError maps the json-rpc err of the node to the node error, e.g. errors.Is(err, ErrNodeNonceTooLow),
+// other errs are returned as is
+func NodeError(err error) error {
+	var rpcErr rpc.Error
+	if err == nil || !errors.As(err, &rpcErr) {
+		return err
+	}
+	for _, nodeErr := range nodeErrors {
+		if errors.Is(err, nodeErr) {
+			return err
+		}
+		if strings.HasPrefix(rpcErr.Error(), nodeErr.Error()) {
+			return fmt.Errorf("%w: %w", nodeErr, err)
+		}
+	}
+	return err
+}
+
+// nodeRevertReason returns the Error(string) reason of the revert reported by the node without revert data
+func nodeRevertReason(err error) (string, bool) {
+	var rpcErr rpc.Error
+	if !errors.Is(err, vm.ErrExecutionReverted) || !errors.As(err, &rpcErr) {
+		return "", false
+	}
+	return strings.CutPrefix(rpcErr.Error(), vm.ErrExecutionReverted.Error()+": ")
+}
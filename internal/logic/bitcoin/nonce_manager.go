@@ -0,0 +1,268 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	// NonceAllocateRetry retry times of concurrent allocations of the same nonce
+	NonceAllocateRetry = 3
+	// NonceGapTimeout allocated nonce not sent in the timeout is a gap
+	NonceGapTimeout = 5 * time.Minute
+)
+
+var ErrNonceAllocate = errors.New("nonce allocate err")
+
+// NonceManager allocates nonces of bridge signer addresses and records the deposit owning each nonce,
+// nonces are persisted in bridge_nonce, gaps below the highest sent nonce are filled by self transfer
+type NonceManager struct {
+	bridge types.BITCOINBridge
+	db     *gorm.DB
+	log    log.Logger
+}
+
+// NewNonceManager returns a new nonce manager
+func NewNonceManager(bridge types.BITCOINBridge, db *gorm.DB, logger log.Logger) *NonceManager {
+	return &NonceManager{
+		bridge: bridge,
+		db:     db,
+		log:    logger,
+	}
+}
+
+// Allocate returns the unmined nonce owned by the deposit tx, otherwise a released nonce or the next nonce
+func (m *NonceManager) Allocate(address string, depositID int64, txType int) (uint64, error) {
+	latestNonce, err := m.bridge.NonceAt(address, false)
+	if err != nil {
+		return 0, err
+	}
+	pendingNonce, err := m.bridge.NonceAt(address, true)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < NonceAllocateRetry; i++ {
+		nonce, err := m.allocate(address, depositID, txType, latestNonce, pendingNonce)
+		if err == nil {
+			return nonce, nil
+		}
+		var pgErr *pgconn.PgError
+		// 23505 duplicate key value violates unique constraint, allocated concurrently
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			continue
+		}
+		return 0, err
+	}
+	return 0, fmt.Errorf("%w:address %s deposit %d", ErrNonceAllocate, address, depositID)
+}
+
+func (m *NonceManager) allocate(address string, depositID int64, txType int, latestNonce uint64, pendingNonce uint64) (uint64, error) {
+	var nonce uint64
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var owned model.BridgeNonce
+		err := tx.
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Address), address).
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().DepositID), depositID).
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().TxType), txType).
+			Where(fmt.Sprintf("%s IN (?)", model.BridgeNonce{}.Column().Status),
+				[]int{model.BridgeNonceStatusAllocated, model.BridgeNonceStatusSent}).
+			Order(fmt.Sprintf("%s DESC", model.BridgeNonce{}.Column().Nonce)).
+			First(&owned).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if owned.Nonce >= latestNonce {
+				nonce = owned.Nonce
+				return nil
+			}
+			// the owner tx is not mined, the nonce is used by another tx
+			m.log.Warnw("owned nonce consumed", "address", address, "nonce", owned.Nonce, "depositID", depositID)
+			err = tx.Model(&model.BridgeNonce{}).Where("id = ?", owned.ID).
+				Update(model.BridgeNonce{}.Column().Status, model.BridgeNonceStatusConsumed).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var released model.BridgeNonce
+		err = tx.
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Address), address).
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Status), model.BridgeNonceStatusReleased).
+			Where(fmt.Sprintf("%s >= ?", model.BridgeNonce{}.Column().Nonce), latestNonce).
+			Order(fmt.Sprintf("%s ASC", model.BridgeNonce{}.Column().Nonce)).
+			First(&released).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			result := tx.Model(&model.BridgeNonce{}).
+				Where("id = ?", released.ID).
+				Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Status), model.BridgeNonceStatusReleased).
+				Updates(map[string]interface{}{
+					model.BridgeNonce{}.Column().DepositID: depositID,
+					model.BridgeNonce{}.Column().TxType:    txType,
+					model.BridgeNonce{}.Column().TxHash:    "",
+					model.BridgeNonce{}.Column().Status:    model.BridgeNonceStatusAllocated,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				nonce = released.Nonce
+				return nil
+			}
+		}
+
+		// next nonce, txs sent by others are not replaced
+		var maxNonce struct {
+			Nonce *uint64
+		}
+		err = tx.Model(&model.BridgeNonce{}).
+			Select(fmt.Sprintf("MAX(%s) AS nonce", model.BridgeNonce{}.Column().Nonce)).
+			Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Address), address).
+			Scan(&maxNonce).Error
+		if err != nil {
+			return err
+		}
+		nonce = pendingNonce
+		if maxNonce.Nonce != nil && *maxNonce.Nonce+1 > nonce {
+			nonce = *maxNonce.Nonce + 1
+		}
+		return tx.Create(&model.BridgeNonce{
+			Address:   address,
+			Nonce:     nonce,
+			DepositID: depositID,
+			TxType:    txType,
+			Status:    model.BridgeNonceStatusAllocated,
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	m.log.Infow("nonce allocated", "address", address, "nonce", nonce, "depositID", depositID, "txType", txType)
+	return nonce, nil
+}
+
+// MarkSent record the tx sent with the nonce
+func (m *NonceManager) MarkSent(address string, nonce uint64, txHash string) error {
+	return m.db.Model(&model.BridgeNonce{}).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", model.BridgeNonce{}.Column().Address, model.BridgeNonce{}.Column().Nonce),
+			address, nonce).
+		Updates(map[string]interface{}{
+			model.BridgeNonce{}.Column().TxHash: txHash,
+			model.BridgeNonce{}.Column().Status: model.BridgeNonceStatusSent,
+		}).Error
+}
+
+// Owner returns the nonce record of the address, e.g. the deposit owning a batch deposit tx
+func (m *NonceManager) Owner(address string, nonce uint64) (model.BridgeNonce, error) {
+	var owner model.BridgeNonce
+	err := m.db.
+		Where(fmt.Sprintf("%s = ? AND %s = ?", model.BridgeNonce{}.Column().Address, model.BridgeNonce{}.Column().Nonce),
+			address, nonce).
+		First(&owner).Error
+	return owner, err
+}
+
+// Release release the allocated nonce whose tx is not sent, the nonce is allocated again first
+func (m *NonceManager) Release(address string, nonce uint64) error {
+	return m.db.Model(&model.BridgeNonce{}).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", model.BridgeNonce{}.Column().Address, model.BridgeNonce{}.Column().Nonce),
+			address, nonce).
+		Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Status), model.BridgeNonceStatusAllocated).
+		Update(model.BridgeNonce{}.Column().Status, model.BridgeNonceStatusReleased).Error
+}
+
+// FillGaps fill unsent nonces between the latest nonce and the highest sent nonce with no-op self transfer,
+// txs of sent nonces dropped from the node are replaced by the owner deposit
+func (m *NonceManager) FillGaps(address string) (int, error) {
+	latestNonce, err := m.bridge.NonceAt(address, false)
+	if err != nil {
+		return 0, err
+	}
+	var nonces []model.BridgeNonce
+	err = m.db.
+		Where(fmt.Sprintf("%s = ?", model.BridgeNonce{}.Column().Address), address).
+		Where(fmt.Sprintf("%s >= ?", model.BridgeNonce{}.Column().Nonce), latestNonce).
+		Where(fmt.Sprintf("%s <> ?", model.BridgeNonce{}.Column().Status), model.BridgeNonceStatusConsumed).
+		Order(fmt.Sprintf("%s ASC", model.BridgeNonce{}.Column().Nonce)).
+		Find(&nonces).Error
+	if err != nil {
+		return 0, err
+	}
+	gaps := NonceGaps(latestNonce, nonces, time.Now())
+	for _, nonce := range gaps {
+		m.log.Warnw("nonce gap detected", "address", address, "nonce", nonce, "latestNonce", latestNonce)
+		tx, err := m.bridge.SelfTransfer(address, nonce)
+		if err != nil {
+			return 0, fmt.Errorf("fill nonce %d gap: %w", nonce, err)
+		}
+		// the owner of the unsent nonce allocates a new nonce
+		err = m.db.Transaction(func(dbTx *gorm.DB) error {
+			result := dbTx.Model(&model.BridgeNonce{}).
+				Where(fmt.Sprintf("%s = ? AND %s = ?", model.BridgeNonce{}.Column().Address, model.BridgeNonce{}.Column().Nonce),
+					address, nonce).
+				Updates(map[string]interface{}{
+					model.BridgeNonce{}.Column().DepositID: 0,
+					model.BridgeNonce{}.Column().TxType:    model.BridgeNonceTxTypeGapFill,
+					model.BridgeNonce{}.Column().TxHash:    tx.Hash().String(),
+					model.BridgeNonce{}.Column().Status:    model.BridgeNonceStatusSent,
+				})
+			if result.Error != nil || result.RowsAffected > 0 {
+				return result.Error
+			}
+			return dbTx.Create(&model.BridgeNonce{
+				Address: address,
+				Nonce:   nonce,
+				TxType:  model.BridgeNonceTxTypeGapFill,
+				TxHash:  tx.Hash().String(),
+				Status:  model.BridgeNonceStatusSent,
+			}).Error
+		})
+		if err != nil {
+			return 0, err
+		}
+		m.log.Infow("nonce gap filled", "address", address, "nonce", nonce, "txHash", tx.Hash().String())
+	}
+	return len(gaps), nil
+}
+
+// NonceGaps returns nonces in [latestNonce, highest sent nonce) without sent tx,
+// allocated nonces are in flight until NonceGapTimeout. nonces are sorted ascending
+func NonceGaps(latestNonce uint64, nonces []model.BridgeNonce, now time.Time) []uint64 {
+	gaps := make([]uint64, 0)
+	highestSent := latestNonce
+	hasSent := false
+	for _, v := range nonces {
+		if v.Status == model.BridgeNonceStatusSent && v.Nonce >= highestSent {
+			highestSent = v.Nonce
+			hasSent = true
+		}
+	}
+	if !hasSent {
+		return gaps
+	}
+	byNonce := make(map[uint64]model.BridgeNonce, len(nonces))
+	for _, v := range nonces {
+		byNonce[v.Nonce] = v
+	}
+	for nonce := latestNonce; nonce < highestSent; nonce++ {
+		v, ok := byNonce[nonce]
+		if ok && v.Status == model.BridgeNonceStatusSent {
+			continue
+		}
+		if ok && v.Status == model.BridgeNonceStatusAllocated && now.Sub(v.UpdatedAt) < NonceGapTimeout {
+			continue
+		}
+		gaps = append(gaps, nonce)
+	}
+	return gaps
+}
@@ -0,0 +1,83 @@
+package bitcoin_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceGaps(t *testing.T) {
+	now := time.Now()
+	nonce := func(n uint64, status int, updatedAt time.Time) model.BridgeNonce {
+		v := model.BridgeNonce{Nonce: n, Status: status}
+		v.UpdatedAt = updatedAt
+		return v
+	}
+
+	testCases := []struct {
+		name        string
+		latestNonce uint64
+		nonces      []model.BridgeNonce
+		gaps        []uint64
+	}{
+		{
+			name:        "success: no nonce",
+			latestNonce: 5,
+			gaps:        []uint64{},
+		},
+		{
+			name:        "success: continuous sent",
+			latestNonce: 5,
+			nonces: []model.BridgeNonce{
+				nonce(5, model.BridgeNonceStatusSent, now),
+				nonce(6, model.BridgeNonceStatusSent, now),
+			},
+			gaps: []uint64{},
+		},
+		{
+			name:        "success: released and missing below sent",
+			latestNonce: 5,
+			nonces: []model.BridgeNonce{
+				nonce(5, model.BridgeNonceStatusReleased, now),
+				nonce(7, model.BridgeNonceStatusSent, now),
+			},
+			gaps: []uint64{5, 6},
+		},
+		{
+			name:        "success: allocated in flight",
+			latestNonce: 5,
+			nonces: []model.BridgeNonce{
+				nonce(5, model.BridgeNonceStatusAllocated, now.Add(-time.Minute)),
+				nonce(6, model.BridgeNonceStatusSent, now),
+			},
+			gaps: []uint64{},
+		},
+		{
+			name:        "success: allocated timeout",
+			latestNonce: 5,
+			nonces: []model.BridgeNonce{
+				nonce(5, model.BridgeNonceStatusAllocated, now.Add(-bitcoin.NonceGapTimeout)),
+				nonce(6, model.BridgeNonceStatusSent, now),
+			},
+			gaps: []uint64{5},
+		},
+		{
+			name:        "success: released above sent",
+			latestNonce: 5,
+			nonces: []model.BridgeNonce{
+				nonce(5, model.BridgeNonceStatusSent, now),
+				nonce(6, model.BridgeNonceStatusReleased, now),
+			},
+			gaps: []uint64{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.gaps, bitcoin.NonceGaps(tc.latestNonce, tc.nonces, now))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrReindexRange = errors.New("reindex range err")
+
+// ReindexDeposit deposit found by reindex
+type ReindexDeposit struct {
+	Height int64  `json:"height"`
+	TxHash string `json:"tx_hash"`
+	To     string `json:"to"`
+	Value  int64  `json:"value"`
+	// Mismatch mismatched columns of the recorded deposit
+	Mismatch []string `json:"mismatch,omitempty"`
+}
+
+// ReindexReport deposits in the reindexed range compared with recorded deposits
+type ReindexReport struct {
+	From       int64            `json:"from"`
+	To         int64            `json:"to"`
+	Existing   int              `json:"existing"`
+	New        []ReindexDeposit `json:"new"`
+	Mismatched []ReindexDeposit `json:"mismatched"`
+	Inserted   int              `json:"inserted"`
+}
+
+// Reindex parse blocks in [from, to] and compare with recorded deposits, the live index cursor is not moved.
+// missing deposits are inserted if insert is true, recorded deposits are never changed.
+// the deposit tables are created by the indexer service
+func (bis *IndexerService) Reindex(from int64, to int64, insert bool) (*ReindexReport, error) {
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("%w:invalid range %d-%d", ErrReindexRange, from, to)
+	}
+	latestBlock, err := bis.txIdxr.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if to > latestBlock {
+		return nil, fmt.Errorf("%w:to %d is greater than latest block %d", ErrReindexRange, to, latestBlock)
+	}
+
+	report := &ReindexReport{
+		From:       from,
+		To:         to,
+		New:        make([]ReindexDeposit, 0),
+		Mismatched: make([]ReindexDeposit, 0),
+	}
+	for height := from; height <= to; height++ {
+		txResults, blockHeader, err := bis.txIdxr.ParseBlock(height, 0)
+		if err != nil {
+			return report, fmt.Errorf("parse block %d: %w", height, err)
+		}
+		for _, v := range txResults {
+			// if from is listen address, skip
+			if bis.ToInFroms(v.From, v.To) {
+				continue
+			}
+			parsedDeposit, err := parsedDeposit(v, height, model.DepositB2TxStatusPending, blockHeader.Timestamp)
+			if err != nil {
+				return report, err
+			}
+			reindexDeposit := ReindexDeposit{
+				Height: height,
+				TxHash: v.TxID,
+				To:     v.To,
+				Value:  v.Value,
+			}
+
+			var deposit model.Deposit
+			err = bis.db.First(&deposit,
+				fmt.Sprintf("%s = ? AND %s = ?", model.Deposit{}.Column().BtcTxHash, model.Deposit{}.Column().BtcTo),
+				v.TxID, v.To).Error
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return report, err
+				}
+				report.New = append(report.New, reindexDeposit)
+				if !insert {
+					continue
+				}
+				if err := bis.applyDepositPolicy(bis.db, &parsedDeposit); err != nil {
+					return report, err
+				}
+				// the live indexer may insert it concurrently
+				result := bis.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&parsedDeposit)
+				if result.Error != nil {
+					return report, result.Error
+				}
+				report.Inserted += int(result.RowsAffected)
+				bis.log.Infow("reindex insert deposit", "height", height, "txId", v.TxID, "to", v.To)
+				continue
+			}
+
+			mismatch := DepositMismatch(deposit, parsedDeposit)
+			if len(mismatch) > 0 {
+				reindexDeposit.Mismatch = mismatch
+				report.Mismatched = append(report.Mismatched, reindexDeposit)
+				bis.log.Warnw("reindex deposit mismatched", "height", height, "txId", v.TxID, "to", v.To, "mismatch", mismatch)
+				continue
+			}
+			report.Existing++
+		}
+		bis.log.Infow("reindex block parsed", "height", height, "to", to)
+	}
+	return report, nil
+}
+
+// DepositMismatch returns columns of the recorded deposit differing from the parsed deposit
+func DepositMismatch(recorded model.Deposit, parsed model.Deposit) []string {
+	mismatch := make([]string, 0)
+	if recorded.BtcBlockNumber != parsed.BtcBlockNumber {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcBlockNumber)
+	}
+	if recorded.BtcTxIndex != parsed.BtcTxIndex {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcTxIndex)
+	}
+	if recorded.BtcValue != parsed.BtcValue {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcValue)
+	}
+	if recorded.BtcFrom != parsed.BtcFrom {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcFrom)
+	}
+	if recorded.BtcMemoAddress != parsed.BtcMemoAddress {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcMemoAddress)
+	}
+	if recorded.BtcTxType != parsed.BtcTxType {
+		mismatch = append(mismatch, model.Deposit{}.Column().BtcTxType)
+	}
+	if recorded.ListenerStatus != parsed.ListenerStatus {
+		mismatch = append(mismatch, model.Deposit{}.Column().ListenerStatus)
+	}
+	return mismatch
+}
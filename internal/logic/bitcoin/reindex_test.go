@@ -0,0 +1,255 @@
+package bitcoin_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReindexRange(t *testing.T) {
+	testCases := []struct {
+		name string
+		from int64
+		to   int64
+	}{
+		{
+			name: "fail: from greater than to",
+			from: esploraBlockHeight + 1,
+			to:   esploraBlockHeight,
+		},
+		{
+			name: "fail: negative from",
+			from: -1,
+			to:   esploraBlockHeight,
+		},
+		{
+			name: "fail: to greater than latest block",
+			from: esploraBlockHeight,
+			to:   esploraBlockHeight + 6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bis := bitcoin.NewIndexerService(mockEsploraIndexer(t, 1), nil, log.NewNopLogger(), 0)
+			_, err := bis.Reindex(tc.from, tc.to, false)
+			require.True(t, errors.Is(err, bitcoin.ErrReindexRange), err)
+		})
+	}
+}
+
+func TestDepositMismatch(t *testing.T) {
+	parsed := model.Deposit{
+		BtcBlockNumber: esploraBlockHeight,
+		BtcTxIndex:     1,
+		BtcTxHash:      esploraDepositTx,
+		BtcFrom:        "tb1qukxc3sy3s3k5n5z9cxt3xyywgcjmp2tzudlz2n",
+		BtcTo:          fixtureListenAddress,
+		BtcValue:       150000,
+		BtcMemoAddress: "0x1212121212121212121212121212121212121212",
+		ListenerStatus: model.ListenerStatusSuccess,
+	}
+
+	recorded := parsed
+	// processed deposits are not mismatched
+	recorded.B2TxStatus = model.DepositB2TxStatusSuccess
+	recorded.CallbackStatus = model.CallbackStatusSuccess
+	require.Empty(t, bitcoin.DepositMismatch(recorded, parsed))
+
+	// recorded from mempool and never confirmed
+	recorded.BtcBlockNumber = 0
+	recorded.BtcTxIndex = 0
+	recorded.ListenerStatus = model.ListenerStatusDropped
+	recorded.BtcValue = 100000
+	require.Equal(t, []string{
+		model.Deposit{}.Column().BtcBlockNumber,
+		model.Deposit{}.Column().BtcTxIndex,
+		model.Deposit{}.Column().BtcValue,
+		model.Deposit{}.Column().ListenerStatus,
+	}, bitcoin.DepositMismatch(recorded, parsed))
+}
+
+// reindexResult parsed deposit of the listen address
+func reindexResult(txID string, index int64, value int64) *types.BitcoinTxParseResult {
+	result := mempoolResult(txID, bitcoin.TxTypeTransfer)
+	result.Index = index
+	result.Value = value
+	return result
+}
+
+// reindexDeposit recorded deposit of the reindex result
+func reindexDeposit(height int64, result *types.BitcoinTxParseResult) model.Deposit {
+	return model.Deposit{
+		BtcBlockNumber: height,
+		BtcTxIndex:     result.Index,
+		BtcTxHash:      result.TxID,
+		BtcTxType:      model.BtcTxTypeTransfer,
+		BtcFrom:        result.FromAddress,
+		BtcTo:          result.To,
+		BtcValue:       result.Value,
+		B2TxStatus:     model.DepositB2TxStatusSuccess,
+		ListenerStatus: model.ListenerStatusSuccess,
+		CallbackStatus: model.CallbackStatusSuccess,
+	}
+}
+
+func TestReindex(t *testing.T) {
+	chain := mockChain(chainhash.Hash{}, 100, 103, 0)
+	recorded := reindexResult("a1", 1, 10000)
+	changed := reindexResult("a2", 2, 20000)
+	missing := reindexResult("a3", 1, 30000)
+	quarantined := reindexResult("a4", 3, 40000)
+	quarantined.Quarantined = true
+	// payout of the listen address, not a deposit
+	payout := reindexResult("a5", 4, 50000)
+	payout.From = append(payout.From, types.BitcoinFrom{Address: fixtureListenAddress, Value: 60000})
+	results := map[int64][]*types.BitcoinTxParseResult{
+		100: {recorded, changed},
+		101: {missing, quarantined, payout},
+	}
+
+	testCases := []struct {
+		name     string
+		from     int64
+		to       int64
+		insert   bool
+		policy   bitcoin.DepositPolicy
+		err      bool
+		existing int
+		new      []string
+		inserted int
+		// b2 tx status of the missing deposit after reindex, zero if not inserted
+		missingStatus int
+	}{
+		{
+			name:     "success: dry run reports without insert",
+			from:     100,
+			to:       103,
+			existing: 1,
+			new:      []string{"a3", "a4"},
+		},
+		{
+			name:          "success: missing deposits inserted",
+			from:          100,
+			to:            103,
+			insert:        true,
+			existing:      1,
+			new:           []string{"a3", "a4"},
+			inserted:      2,
+			missingStatus: model.DepositB2TxStatusPending,
+		},
+		{
+			name:          "success: inserted deposit held by policy",
+			from:          101,
+			to:            101,
+			insert:        true,
+			policy:        bitcoin.DepositPolicy{MaxAmount: 25000},
+			new:           []string{"a3", "a4"},
+			inserted:      2,
+			missingStatus: model.DepositB2TxStatusHeld,
+		},
+		{
+			name:     "fail: block not found",
+			from:     100,
+			to:       104,
+			err:      true,
+			existing: 1,
+			new:      []string{"a3", "a4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			active := mockChain(chainhash.Hash{}, 100, 103, 0)
+			if tc.to > 103 {
+				// latest block is beyond the range, the block is not served
+				active[105] = chain[103]
+			}
+			db := newTestDB(t)
+			deposits := []model.Deposit{
+				reindexDeposit(100, recorded),
+				reindexDeposit(100, changed),
+			}
+			// recorded before the value was corrected
+			deposits[1].BtcValue = 10000
+			require.NoError(t, db.Create(&deposits).Error)
+			require.NoError(t, db.Find(&deposits).Error)
+			btcIndex := model.BtcIndex{BtcIndexBlock: 103, BtcIndexTx: 2}
+			require.NoError(t, db.Create(&btcIndex).Error)
+
+			service := bitcoin.NewIndexerService(&mockChainIndexer{active: active, results: results}, db, log.NewNopLogger(), 0)
+			service.SetDepositPolicy(tc.policy)
+			report, err := service.Reindex(tc.from, tc.to, tc.insert)
+			if tc.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NotNil(t, report)
+			require.Equal(t, tc.from, report.From)
+			require.Equal(t, tc.to, report.To)
+			require.Equal(t, tc.existing, report.Existing)
+			require.Equal(t, tc.inserted, report.Inserted)
+			newTxs := make([]string, 0, len(report.New))
+			for _, v := range report.New {
+				require.Equal(t, int64(101), v.Height)
+				newTxs = append(newTxs, v.TxHash)
+			}
+			require.Equal(t, tc.new, newTxs)
+			if tc.from <= 100 {
+				require.Equal(t, []bitcoin.ReindexDeposit{{
+					Height:   100,
+					TxHash:   "a2",
+					To:       fixtureListenAddress,
+					Value:    20000,
+					Mismatch: []string{model.Deposit{}.Column().BtcValue},
+				}}, report.Mismatched)
+			} else {
+				require.Empty(t, report.Mismatched)
+			}
+
+			// recorded deposits and the index cursor are not changed
+			for _, v := range deposits {
+				var deposit model.Deposit
+				require.NoError(t, db.First(&deposit, v.ID).Error)
+				require.Equal(t, v, deposit)
+			}
+			var savedIndex model.BtcIndex
+			require.NoError(t, db.First(&savedIndex).Error)
+			require.Equal(t, int64(103), savedIndex.BtcIndexBlock)
+			require.Equal(t, int64(2), savedIndex.BtcIndexTx)
+
+			var count int64
+			require.NoError(t, db.Model(&model.Deposit{}).Count(&count).Error)
+			require.Equal(t, int64(len(deposits)+tc.inserted), count)
+			if tc.inserted == 0 {
+				return
+			}
+			var deposit model.Deposit
+			require.NoError(t, db.First(&deposit, "btc_tx_hash = ?", "a3").Error)
+			require.Equal(t, tc.missingStatus, deposit.B2TxStatus)
+			require.Equal(t, model.ListenerStatusSuccess, deposit.ListenerStatus)
+			require.Equal(t, int64(101), deposit.BtcBlockNumber)
+			require.Equal(t, int64(30000), deposit.BtcValue)
+			require.True(t, active[101].Timestamp.Equal(deposit.BtcBlockTime))
+			var quarantinedDeposit model.Deposit
+			require.NoError(t, db.First(&quarantinedDeposit, "btc_tx_hash = ?", "a4").Error)
+			require.Equal(t, model.ListenerStatusQuarantined, quarantinedDeposit.ListenerStatus)
+			require.Equal(t, model.DepositB2TxStatusPending, quarantinedDeposit.B2TxStatus)
+
+			// inserted deposits are existing in the next reindex
+			report, err = service.Reindex(101, 101, tc.insert)
+			require.NoError(t, err)
+			require.Equal(t, 2, report.Existing)
+			require.Empty(t, report.New)
+			require.Empty(t, report.Mismatched)
+			require.Equal(t, 0, report.Inserted)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package bitcoin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	ErrSignerMismatch = errors.New("signed tx mismatch")
+	ErrSignerNotFound = errors.New("signer not found")
+)
+
+// Signer signs bridge txs, the key may be kept out of process
+type Signer interface {
+	// Address returns the signer address
+	Address() common.Address
+	// SignTx returns the signed tx
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// LocalSigner signs txs with the in memory private key
+type LocalSigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewLocalSigner returns a new local signer
+func NewLocalSigner(key *ecdsa.PrivateKey) *LocalSigner {
+	return &LocalSigner{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}
+}
+
+// Address returns the address of the private key
+func (s *LocalSigner) Address() common.Address {
+	return s.address
+}
+
+// SignTx sign the tx with the private key
+func (s *LocalSigner) SignTx(_ context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// RemoteSigner signs txs with eth_signTransaction of a remote signer, e.g. web3signer, clef
+type RemoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// NewRemoteSigner returns a new remote signer of the address
+func NewRemoteSigner(url string, address string) (*RemoteSigner, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid remote signer address:%s", address)
+	}
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteSigner{
+		client:  client,
+		address: common.HexToAddress(address),
+	}, nil
+}
+
+// Address returns the remote signer address
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+// signTxArgs eth_signTransaction args
+type signTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to,omitempty"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// SignTx sign the tx by eth_signTransaction, the signed tx must be the tx signed by the address
+func (s *RemoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := signTxArgs{
+		From:    s.address,
+		To:      tx.To(),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   (*hexutil.Big)(tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    tx.Data(),
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	}
+	var result json.RawMessage
+	err := s.client.CallContext(ctx, &result, "eth_signTransaction", args)
+	if err != nil {
+		return nil, fmt.Errorf("eth_signTransaction err:%w", err)
+	}
+	raw, err := signTxResultRaw(result)
+	if err != nil {
+		return nil, err
+	}
+	signedTx := new(types.Transaction)
+	err = signedTx.UnmarshalBinary(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode signed tx err:%w", err)
+	}
+
+	// the remote signer must not change the tx
+	signer := types.LatestSignerForChainID(chainID)
+	if signer.Hash(signedTx) != signer.Hash(tx) {
+		return nil, fmt.Errorf("%w: tx fields changed", ErrSignerMismatch)
+	}
+	from, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if from != s.address {
+		return nil, fmt.Errorf("%w: signed by %s", ErrSignerMismatch, from)
+	}
+	return signedTx, nil
+}
+
+// signTxResultRaw returns the raw signed tx, web3signer returns the raw tx hex,
+// clef and geth return {"raw": hex, "tx": {...}}
+func signTxResultRaw(result json.RawMessage) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err == nil {
+		return raw, nil
+	}
+	var signed struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := json.Unmarshal(result, &signed); err != nil {
+		return nil, fmt.Errorf("unmarshal eth_signTransaction result err:%w", err)
+	}
+	if len(signed.Raw) == 0 {
+		return nil, fmt.Errorf("eth_signTransaction result without raw tx")
+	}
+	return signed.Raw, nil
+}
+
+// SignerSet bridge signers, new txs are signed by the active signer,
+// retired signers only replace their in-flight txs and fill their nonce gaps
+type SignerSet struct {
+	// signers in rotation order
+	signers   []Signer
+	byAddress map[common.Address]Signer
+	// active manual cutover signer, zero if scheduled
+	active common.Address
+	// interval rotation interval, the first signer is always active if zero
+	interval time.Duration
+}
+
+// NewSignerSet returns a new signer set, active is the manual cutover signer address
+func NewSignerSet(signers []Signer, active string, interval time.Duration) (*SignerSet, error) {
+	if len(signers) == 0 {
+		return nil, ErrSignerNotFound
+	}
+	s := &SignerSet{
+		signers:   signers,
+		byAddress: make(map[common.Address]Signer, len(signers)),
+		interval:  interval,
+	}
+	for _, signer := range signers {
+		if _, ok := s.byAddress[signer.Address()]; ok {
+			return nil, fmt.Errorf("duplicate signer:%s", signer.Address())
+		}
+		s.byAddress[signer.Address()] = signer
+	}
+	if active != "" {
+		if !common.IsHexAddress(active) {
+			return nil, fmt.Errorf("invalid active signer:%s", active)
+		}
+		s.active = common.HexToAddress(active)
+		if _, ok := s.byAddress[s.active]; !ok {
+			return nil, fmt.Errorf("%w: active signer %s", ErrSignerNotFound, active)
+		}
+	}
+	return s, nil
+}
+
+// Active returns the signer of new txs at the time, the manual cutover signer takes precedence
+// over the rotation schedule, signers take turns every interval since unix epoch
+func (s *SignerSet) Active(now time.Time) Signer {
+	if s.active != (common.Address{}) {
+		return s.byAddress[s.active]
+	}
+	if s.interval <= 0 {
+		return s.signers[0]
+	}
+	slot := now.UnixNano() / int64(s.interval)
+	return s.signers[slot%int64(len(s.signers))]
+}
+
+// Signer returns the signer of the address
+func (s *SignerSet) Signer(address common.Address) (Signer, bool) {
+	signer, ok := s.byAddress[address]
+	return signer, ok
+}
+
+// Signers returns all signers in rotation order
+func (s *SignerSet) Signers() []Signer {
+	return s.signers
+}
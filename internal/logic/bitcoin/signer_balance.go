@@ -0,0 +1,57 @@
+package bitcoin
+
+import (
+	"time"
+
+	"github.com/b2network/b2-indexer/pkg/amount"
+)
+
+// SignerBalanceAlertInterval min interval of low balance alerts of a signer
+const SignerBalanceAlertInterval = 10 * time.Minute
+
+// SetSignerMinBalance set the signer gas balance alert threshold in satoshi, 0 disable,
+// the gas balance is in wei of the native btc
+func (bis *BridgeDepositService) SetSignerMinBalance(minBalance int64) {
+	if minBalance <= 0 {
+		bis.signerMinBalance = nil
+		return
+	}
+	minBalanceWei, err := amount.DefaultAsset.ToWei(amount.Sats(minBalance))
+	if err != nil {
+		bis.log.Errorw("signer min balance err, alert disabled", "error", err)
+		bis.signerMinBalance = nil
+		return
+	}
+	bis.signerMinBalance = minBalanceWei
+}
+
+// CheckSignerBalance alert signers whose gas balance is below the min balance,
+// returns the alerted signer addresses
+func (bis *BridgeDepositService) CheckSignerBalance(now time.Time) []string {
+	alerted := make([]string, 0)
+	if bis.signerMinBalance == nil {
+		return alerted
+	}
+	for _, address := range bis.bridge.SignerAddresses() {
+		balance, err := bis.bridge.BalanceAt(address)
+		if err != nil {
+			bis.log.Errorw("get signer balance err", "error", err, "address", address)
+			continue
+		}
+		if balance.Cmp(bis.signerMinBalance) >= 0 {
+			delete(bis.balanceAlerts, address)
+			continue
+		}
+		if last, ok := bis.balanceAlerts[address]; ok && now.Sub(last) < SignerBalanceAlertInterval {
+			continue
+		}
+		bis.balanceAlerts[address] = now
+		bis.log.Errorw("signer gas balance low alert",
+			"address", address,
+			"balance", balance.String(),
+			"minBalance", bis.signerMinBalance.String(),
+			"active", address == bis.bridge.FromAddress())
+		alerted = append(alerted, address)
+	}
+	return alerted
+}
@@ -0,0 +1,60 @@
+package bitcoin_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/internal/types"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/stretchr/testify/require"
+)
+
+// balanceBridge bridge with signer balances
+type balanceBridge struct {
+	types.BITCOINBridge
+	active   string
+	balances map[string]*big.Int
+}
+
+func (b *balanceBridge) SignerAddresses() []string {
+	return []string{"0x01", "0x02"}
+}
+
+func (b *balanceBridge) BalanceAt(address string) (*big.Int, error) {
+	return b.balances[address], nil
+}
+
+func (b *balanceBridge) FromAddress() string {
+	return b.active
+}
+
+func TestCheckSignerBalance(t *testing.T) {
+	bridge := &balanceBridge{
+		active: "0x02",
+		balances: map[string]*big.Int{
+			"0x01": big.NewInt(2e14),
+			"0x02": big.NewInt(5e13),
+		},
+	}
+	service := bitcoin.NewBridgeDepositService(bridge, nil, nil, log.NewNopLogger())
+	now := time.Now()
+
+	// disabled
+	require.Empty(t, service.CheckSignerBalance(now))
+
+	// 10000 sat = 1e14 wei
+	service.SetSignerMinBalance(10000)
+	require.Equal(t, []string{"0x02"}, service.CheckSignerBalance(now))
+	// throttled
+	require.Empty(t, service.CheckSignerBalance(now.Add(time.Minute)))
+	require.Equal(t, []string{"0x02"}, service.CheckSignerBalance(now.Add(bitcoin.SignerBalanceAlertInterval)))
+
+	// recovered and low again
+	bridge.balances["0x02"] = big.NewInt(1e14)
+	require.Empty(t, service.CheckSignerBalance(now.Add(bitcoin.SignerBalanceAlertInterval+time.Minute)))
+	bridge.balances["0x02"] = big.NewInt(1)
+	bridge.balances["0x01"] = big.NewInt(1)
+	require.Equal(t, []string{"0x01", "0x02"}, service.CheckSignerBalance(now.Add(bitcoin.SignerBalanceAlertInterval+2*time.Minute)))
+}
@@ -0,0 +1,248 @@
+package bitcoin_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+type testSignTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// testRemoteSigner eth_signTransaction stand-in of web3signer and clef
+type testRemoteSigner struct {
+	key *ecdsa.PrivateKey
+	// clef returns {"raw": hex, "tx": {...}}
+	clef bool
+	// tamper changes the nonce before signing
+	tamper bool
+}
+
+type testSignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (s *testRemoteSigner) SignTransaction(args testSignTxArgs) (interface{}, error) {
+	nonce := uint64(args.Nonce)
+	if s.tamper {
+		nonce++
+	}
+	var txData types.TxData
+	if args.MaxFeePerGas != nil {
+		txData = &types.DynamicFeeTx{
+			ChainID:   args.ChainID.ToInt(),
+			Nonce:     nonce,
+			GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			Gas:       uint64(args.Gas),
+			To:        args.To,
+			Value:     args.Value.ToInt(),
+			Data:      args.Data,
+		}
+	} else {
+		txData = &types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: args.GasPrice.ToInt(),
+			Gas:      uint64(args.Gas),
+			To:       args.To,
+			Value:    args.Value.ToInt(),
+			Data:     args.Data,
+		}
+	}
+	tx, err := types.SignNewTx(s.key, types.LatestSignerForChainID(args.ChainID.ToInt()), txData)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if s.clef {
+		return testSignTxResult{Raw: raw, Tx: tx}, nil
+	}
+	return hexutil.Bytes(raw), nil
+}
+
+func newTestRemoteSigner(t *testing.T, remote *testRemoteSigner) string {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", remote))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer.URL
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(1102)
+	to := common.HexToAddress("0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2")
+	dynamicTx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(100),
+		GasFeeCap: big.NewInt(2000),
+		Gas:       50000,
+		To:        &to,
+		Value:     big.NewInt(0),
+		Data:      []byte{0x01, 0x02},
+	})
+	legacyTx := types.NewTx(&types.LegacyTx{
+		Nonce:    8,
+		GasPrice: big.NewInt(1000),
+		Gas:      21000,
+		To:       &to,
+		Value:    big.NewInt(10),
+	})
+
+	testCases := []struct {
+		name   string
+		remote *testRemoteSigner
+		tx     *types.Transaction
+		err    error
+	}{
+		{
+			name:   "success: web3signer dynamic fee tx",
+			remote: &testRemoteSigner{key: key},
+			tx:     dynamicTx,
+		},
+		{
+			name:   "success: clef legacy tx",
+			remote: &testRemoteSigner{key: key, clef: true},
+			tx:     legacyTx,
+		},
+		{
+			name:   "fail: tx changed",
+			remote: &testRemoteSigner{key: key, tamper: true},
+			tx:     dynamicTx,
+			err:    bitcoin.ErrSignerMismatch,
+		},
+		{
+			name:   "fail: signed by other key",
+			remote: &testRemoteSigner{key: otherKey},
+			tx:     dynamicTx,
+			err:    bitcoin.ErrSignerMismatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer, err := bitcoin.NewRemoteSigner(newTestRemoteSigner(t, tc.remote), address.Hex())
+			require.NoError(t, err)
+			require.Equal(t, address, signer.Address())
+			signedTx, err := signer.SignTx(context.Background(), tc.tx, chainID)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			localTx, err := bitcoin.NewLocalSigner(key).SignTx(context.Background(), tc.tx, chainID)
+			require.NoError(t, err)
+			require.Equal(t, localTx.Hash(), signedTx.Hash())
+		})
+	}
+
+	_, err = bitcoin.NewRemoteSigner("http://127.0.0.1:9000", "invalid")
+	require.Error(t, err)
+}
+
+func TestSignerSet(t *testing.T) {
+	signers := make([]bitcoin.Signer, 0, 3)
+	for i := 0; i < 3; i++ {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		signers = append(signers, bitcoin.NewLocalSigner(key))
+	}
+	epoch := time.Unix(0, 0)
+
+	testCases := []struct {
+		name     string
+		signers  []bitcoin.Signer
+		active   string
+		interval time.Duration
+		now      time.Time
+		expected bitcoin.Signer
+		err      bool
+	}{
+		{
+			name:     "success: first signer without rotation",
+			signers:  signers,
+			now:      epoch.Add(100 * time.Hour),
+			expected: signers[0],
+		},
+		{
+			name:     "success: rotation schedule",
+			signers:  signers,
+			interval: time.Hour,
+			now:      epoch.Add(4*time.Hour + time.Minute),
+			expected: signers[1],
+		},
+		{
+			name:     "success: manual cutover over schedule",
+			signers:  signers,
+			active:   signers[2].Address().Hex(),
+			interval: time.Hour,
+			now:      epoch,
+			expected: signers[2],
+		},
+		{
+			name:    "fail: active signer not found",
+			signers: signers,
+			active:  "0xB457BF68D71a17Fa5030269Fb895e29e6cD2DFF2",
+			err:     true,
+		},
+		{
+			name:    "fail: duplicate signer",
+			signers: []bitcoin.Signer{signers[0], signers[0]},
+			err:     true,
+		},
+		{
+			name: "fail: no signer",
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			set, err := bitcoin.NewSignerSet(tc.signers, tc.active, tc.interval)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, set.Active(tc.now))
+			for _, v := range tc.signers {
+				signer, ok := set.Signer(v.Address())
+				require.True(t, ok)
+				require.Equal(t, v, signer)
+			}
+		})
+	}
+}
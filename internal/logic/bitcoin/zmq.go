@@ -0,0 +1,106 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/go-zeromq/zmq4"
+)
+
+const (
+	// ZMQTopicHashBlock bitcoin core zmqpubhashblock topic
+	ZMQTopicHashBlock = "hashblock"
+
+	ZMQReconnectInterval = 5 * time.Second
+)
+
+var ErrZMQMessage = errors.New("zmq message err")
+
+// ZMQSubscriber subscribe bitcoin core zmq notifications, a new block wakes the indexer loop,
+// the indexer falls back to polling while disconnected
+type ZMQSubscriber struct {
+	endpoint          string
+	reconnectInterval time.Duration
+	newBlock          chan struct{}
+	logger            log.Logger
+}
+
+// NewZMQSubscriber new zmq subscriber, endpoint e.g. tcp://127.0.0.1:28332
+func NewZMQSubscriber(endpoint string, logger log.Logger) *ZMQSubscriber {
+	return &ZMQSubscriber{
+		endpoint:          endpoint,
+		reconnectInterval: ZMQReconnectInterval,
+		newBlock:          make(chan struct{}, 1),
+		logger:            logger,
+	}
+}
+
+// NewBlock notified on new block, multiple blocks between reads are merged into one notification
+func (s *ZMQSubscriber) NewBlock() <-chan struct{} {
+	return s.newBlock
+}
+
+// Run subscribe until ctx done, reconnect on disconnect
+func (s *ZMQSubscriber) Run(ctx context.Context) {
+	for {
+		err := s.subscribe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warnw("zmq subscriber disconnected, fallback to polling",
+			"endpoint", s.endpoint, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.reconnectInterval):
+		}
+	}
+}
+
+func (s *ZMQSubscriber) subscribe(ctx context.Context) error {
+	sub := zmq4.NewSub(ctx, zmq4.WithDialerMaxRetries(1), zmq4.WithDialerRetry(s.reconnectInterval))
+	defer sub.Close()
+
+	if err := sub.Dial(s.endpoint); err != nil {
+		return err
+	}
+	if err := sub.SetOption(zmq4.OptionSubscribe, ZMQTopicHashBlock); err != nil {
+		return err
+	}
+	s.logger.Infow("zmq subscriber connected", "endpoint", s.endpoint)
+
+	for {
+		msg, err := sub.Recv()
+		if err != nil {
+			return err
+		}
+		hash, err := ParseZMQHashBlock(msg.Frames)
+		if err != nil {
+			s.logger.Warnw("zmq subscriber parse message", "error", err)
+			continue
+		}
+		s.logger.Infow("zmq new block", "hash", hash)
+		select {
+		case s.newBlock <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ParseZMQHashBlock parse bitcoin core hashblock message, frames: topic, 32 bytes block hash, 4 bytes sequence
+func ParseZMQHashBlock(frames [][]byte) (string, error) {
+	if len(frames) < 2 {
+		return "", fmt.Errorf("%w:unexpected frames %d", ErrZMQMessage, len(frames))
+	}
+	if string(frames[0]) != ZMQTopicHashBlock {
+		return "", fmt.Errorf("%w:unexpected topic %s", ErrZMQMessage, frames[0])
+	}
+	if len(frames[1]) != 32 {
+		return "", fmt.Errorf("%w:unexpected block hash length %d", ErrZMQMessage, len(frames[1]))
+	}
+	return hex.EncodeToString(frames[1]), nil
+}
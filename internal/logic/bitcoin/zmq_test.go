@@ -0,0 +1,117 @@
+package bitcoin_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	"github.com/b2network/b2-indexer/pkg/log"
+	"github.com/go-zeromq/zmq4"
+	"github.com/stretchr/testify/require"
+)
+
+// zmqPublisher bitcoin core zmqpubhashblock stand-in
+func zmqPublisher(t *testing.T, endpoint string) zmq4.Socket {
+	pub := zmq4.NewPub(context.Background())
+	require.NoError(t, pub.Listen(endpoint))
+	return pub
+}
+
+func hashBlockMsg(seq byte) zmq4.Msg {
+	hash := make([]byte, 32)
+	hash[31] = seq
+	return zmq4.NewMsgFrom([]byte(bitcoin.ZMQTopicHashBlock), hash, []byte{seq, 0, 0, 0})
+}
+
+// waitNewBlock publish until notified, the subscriber may not be connected yet
+func waitNewBlock(t *testing.T, pub zmq4.Socket, newBlock <-chan struct{}, timeout time.Duration) {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	var seq byte
+	for {
+		select {
+		case <-newBlock:
+			return
+		case <-deadline:
+			t.Fatal("new block not notified")
+		case <-ticker.C:
+			seq++
+			require.NoError(t, pub.Send(hashBlockMsg(seq)))
+		}
+	}
+}
+
+func freeEndpoint(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return "tcp://" + l.Addr().String()
+}
+
+func TestZMQSubscriber(t *testing.T) {
+	endpoint := freeEndpoint(t)
+	pub := zmqPublisher(t, endpoint)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	subscriber := bitcoin.NewZMQSubscriber(endpoint, log.NewNopLogger())
+	go subscriber.Run(ctx)
+
+	waitNewBlock(t, pub, subscriber.NewBlock(), 10*time.Second)
+
+	// publisher restarted, e.g. bitcoin core restart, subscriber reconnects
+	require.NoError(t, pub.Close())
+	pub = zmqPublisher(t, endpoint)
+	defer pub.Close()
+	// drain notifications sent before restart
+	select {
+	case <-subscriber.NewBlock():
+	default:
+	}
+	waitNewBlock(t, pub, subscriber.NewBlock(), 2*bitcoin.ZMQReconnectInterval+5*time.Second)
+}
+
+func TestParseZMQHashBlock(t *testing.T) {
+	testCases := []struct {
+		name   string
+		frames [][]byte
+		hash   string
+		errMsg string
+	}{
+		{
+			name:   "success",
+			frames: hashBlockMsg(1).Frames,
+			hash:   "0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name:   "fail: unexpected frames",
+			frames: [][]byte{[]byte(bitcoin.ZMQTopicHashBlock)},
+			errMsg: "zmq message err:unexpected frames 1",
+		},
+		{
+			name:   "fail: unexpected topic",
+			frames: [][]byte{[]byte("rawtx"), make([]byte, 32)},
+			errMsg: "zmq message err:unexpected topic rawtx",
+		},
+		{
+			name:   "fail: unexpected hash length",
+			frames: [][]byte{[]byte(bitcoin.ZMQTopicHashBlock), make([]byte, 31)},
+			errMsg: "zmq message err:unexpected block hash length 31",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := bitcoin.ParseZMQHashBlock(tc.frames)
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.hash, hash)
+		})
+	}
+}
@@ -3,16 +3,17 @@ package rollup
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/b2network/b2-indexer/pkg/event"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/shopspring/decimal"
 
 	"github.com/b2network/b2-indexer/internal/config"
 	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/amount"
 	"github.com/b2network/b2-indexer/pkg/log"
 	"github.com/cometbft/cometbft/libs/service"
 	"gorm.io/gorm"
@@ -57,6 +58,10 @@ func (bis *IndexerService) OnStart() error {
 			bis.log.Errorw("BridgeWithdrawService panic", "error", r)
 		}
 	}()
+	asset, err := amount.NewAsset(bis.config.Bridge.AssetDecimals)
+	if err != nil {
+		return err
+	}
 	if !bis.db.Migrator().HasTable(&model.RollupIndex{}) {
 		err := bis.db.AutoMigrate(&model.RollupIndex{})
 		if err != nil {
@@ -145,7 +150,7 @@ func (bis *IndexerService) OnStart() error {
 				// }
 				if eventHash == common.HexToHash(bis.config.Bridge.Deposit) {
 					bis.log.Warnw("vlog", "vlog", vlog)
-					err = handelDepositEvent(vlog, bis.db)
+					err = handelDepositEvent(vlog, bis.db, asset)
 					if err != nil {
 						bis.log.Errorw("IndexerService handelDepositEvent err: ", "error", err)
 						continue
@@ -189,7 +194,9 @@ func handelWithdrawEvent(vlog ethtypes.Log, db *gorm.DB, listenAddress string) e
 	return nil
 }
 
-func handelDepositEvent(vlog ethtypes.Log, db *gorm.DB) error {
+// handelDepositEvent record the deposit event, the amount of fractional satoshi is rejected and
+// the event is not recorded
+func handelDepositEvent(vlog ethtypes.Log, db *gorm.DB, asset amount.Asset) error {
 	Caller := event.TopicToAddress(vlog, 1).Hex()
 	ToAddress := event.TopicToAddress(vlog, 2).Hex()
 	Amount := event.DataToDecimal(vlog, 0, 0)
@@ -197,10 +204,16 @@ func handelDepositEvent(vlog ethtypes.Log, db *gorm.DB) error {
 
 	log.Errorw("deposit event ", "Caller", Caller, "ToAddress", ToAddress, "Amount", Amount.String(), "TxHash", TxHash.String())
 
+	btcValue, err := asset.ToSats(Amount.BigInt())
+	if err != nil {
+		return fmt.Errorf("deposit event amount %s in tx %s to satoshi: %w", Amount.String(), TxHash.String(), err)
+	}
+
 	depositData := model.RollupDeposit{
 		BtcTxHash:        remove0xPrefix(TxHash.String()),
 		BtcFromAAAddress: ToAddress,
-		BtcValue:         Amount.Div(decimal.NewFromInt(10000000000)).BigInt().Int64(),
+		BtcValue:         btcValue.Int64(),
+		B2Value:          Amount.String(),
 		B2TxFrom:         Caller,
 		B2BlockNumber:    vlog.BlockNumber,
 		B2BlockHash:      vlog.BlockHash.String(),
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+const (
+	AAAddressStatusFound    = iota // smart account resolved
+	AAAddressStatusNotFound        // pubkey not registered, resolved again after expired
+)
+
+// AAAddress cached smart account of the bitcoin address, the mapping is deterministic
+type AAAddress struct {
+	Base
+	BtcAddress   string    `json:"btc_address" gorm:"type:varchar(64);not null;default:'';uniqueIndex;comment:bitcoin address"`
+	Pubkey       string    `json:"pubkey" gorm:"type:varchar(130);not null;default:'';comment:bitcoin pubkey of the address"`
+	SmartAccount string    `json:"smart_account" gorm:"type:varchar(42);not null;default:'';comment:b2 aa smart account address"`
+	Status       int       `json:"status" gorm:"type:SMALLINT;default:0"`
+	ExpiredAt    time.Time `json:"expired_at" gorm:"comment:expire time of not found address"`
+}
+
+type AAAddressColumns struct {
+	BtcAddress   string
+	Pubkey       string
+	SmartAccount string
+	Status       string
+	ExpiredAt    string
+}
+
+func (AAAddress) TableName() string {
+	return "aa_address"
+}
+
+func (AAAddress) Column() AAAddressColumns {
+	return AAAddressColumns{
+		BtcAddress:   "btc_address",
+		Pubkey:       "pubkey",
+		SmartAccount: "smart_account",
+		Status:       "status",
+		ExpiredAt:    "expired_at",
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+const (
+	BridgeNonceTxTypeDeposit      = iota // deposit contract call
+	BridgeNonceTxTypeEoaTransfer         // eoa transfer
+	BridgeNonceTxTypeGapFill             // no-op self transfer filling a nonce gap
+	BridgeNonceTxTypeBatchDeposit        // batch deposit contract call, owned by the first deposit of the batch
+)
+
+const (
+	BridgeNonceStatusAllocated = iota // allocated, tx not sent
+	BridgeNonceStatusSent             // tx sent
+	BridgeNonceStatusReleased         // tx not sent, the nonce is allocated again
+	BridgeNonceStatusConsumed         // nonce used by another tx, the owner allocates a new nonce
+)
+
+// BridgeNonce nonce of the bridge signer address and the deposit owning it
+type BridgeNonce struct {
+	Base
+	Address   string `json:"address" gorm:"type:varchar(42);not null;default:'';uniqueIndex:idx_bridge_nonce_address_nonce,priority:1;comment:signer address"`
+	Nonce     uint64 `json:"nonce" gorm:"not null;default:0;uniqueIndex:idx_bridge_nonce_address_nonce,priority:2;comment:signer nonce"`
+	DepositID int64  `json:"deposit_id" gorm:"index;default:0;comment:deposit owning the nonce, 0 if gap fill"`
+	TxType    int    `json:"tx_type" gorm:"type:SMALLINT;default:0;comment:deposit, eoa transfer or gap fill"`
+	TxHash    string `json:"tx_hash" gorm:"type:varchar(66);not null;default:'';comment:b2 network tx hash"`
+	Status    int    `json:"status" gorm:"type:SMALLINT;default:0"`
+}
+
+type BridgeNonceColumns struct {
+	Address   string
+	Nonce     string
+	DepositID string
+	TxType    string
+	TxHash    string
+	Status    string
+}
+
+func (BridgeNonce) TableName() string {
+	return "bridge_nonce"
+}
+
+func (BridgeNonce) Column() BridgeNonceColumns {
+	return BridgeNonceColumns{
+		Address:   "address",
+		Nonce:     "nonce",
+		DepositID: "deposit_id",
+		TxType:    "tx_type",
+		TxHash:    "tx_hash",
+		Status:    "status",
+	}
+}
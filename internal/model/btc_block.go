@@ -0,0 +1,26 @@
+package model
+
+type BtcBlock struct {
+	Base
+	Height        int64  `json:"height" gorm:"uniqueIndex;comment:bitcoin block height"`
+	BlockHash     string `json:"block_hash" gorm:"type:varchar(64);not null;default:'';comment:bitcoin block hash"`
+	PrevBlockHash string `json:"prev_block_hash" gorm:"type:varchar(64);not null;default:'';comment:bitcoin prev block hash"`
+}
+
+type BtcBlockColumns struct {
+	Height        string
+	BlockHash     string
+	PrevBlockHash string
+}
+
+func (BtcBlock) TableName() string {
+	return "btc_block"
+}
+
+func (BtcBlock) Column() BtcBlockColumns {
+	return BtcBlockColumns{
+		Height:        "height",
+		BlockHash:     "block_hash",
+		PrevBlockHash: "prev_block_hash",
+	}
+}
@@ -5,7 +5,10 @@ import (
 )
 
 const (
-	BtcTxTypeTransfer = iota // transfer
+	BtcTxTypeTransfer      = iota // transfer
+	BtcTxTypeInscription          // ordinal inscription
+	BtcTxTypeBrc20Transfer        // brc-20 transfer inscription
+	BtcTxTypeRunes                // runestone
 )
 
 const (
@@ -23,6 +26,9 @@ const (
 	DepositB2TxStatusAAAddressNotFound                 // aa address not found,  Start process processing separately
 	DepositB2TxStatusIsPending
 	DepositB2TxStatusNonceToLow
+	DepositB2TxStatusHeld       // held by the deposit amount policy, wait operator release or reject
+	DepositB2TxStatusRejected   // held deposit rejected by operator, not processed
+	DepositB2TxStatusDeadLetter // retry budget exhausted, wait operator retry
 )
 
 const (
@@ -46,6 +52,11 @@ const (
 const (
 	ListenerStatusSuccess = iota
 	ListenerStatusPending
+	ListenerStatusReorged      // btc block orphaned by a chain reorganization, wait re-index
+	ListenerStatusMempool      // btc tx unconfirmed, detected in mempool
+	ListenerStatusDropped      // btc tx evicted from mempool or replaced, e.g. rbf or double spend
+	ListenerStatusFromRejected // btc from rejected by the attribution policy, review manually
+	ListenerStatusQuarantined  // btc tx classified as quarantined tx type, e.g. inscription or runes, review manually
 )
 
 const (
@@ -58,27 +69,36 @@ type Deposit struct {
 	Base
 	BtcBlockNumber   int64     `json:"btc_block_number" gorm:"index;comment:bitcoin block number"`
 	BtcTxIndex       int64     `json:"btc_tx_index" gorm:"comment:bitcoin tx index"`
-	BtcTxHash        string    `json:"btc_tx_hash" gorm:"type:varchar(64);not null;default:'';uniqueIndex;comment:bitcoin tx hash"`
+	BtcTxHash        string    `json:"btc_tx_hash" gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_deposit_history_btc_tx_hash_to,priority:1;comment:bitcoin tx hash"`
 	BtcTxType        int       `json:"btc_tx_type" gorm:"type:SMALLINT;default:0;comment:btc tx type"`
 	BtcFroms         string    `json:"btc_froms" gorm:"type:jsonb;comment:bitcoin transfer, from may be multiple"`
 	BtcFrom          string    `json:"btc_from" gorm:"type:varchar(64);not null;default:'';index"`
+	BtcFromPolicy    string    `json:"btc_from_policy" gorm:"type:varchar(32);default:'';comment:btc from attribution decision, policy or rejected"`
 	BtcTos           string    `json:"btc_tos" gorm:"type:jsonb;comment:bitcoin transfer, to may be multiple"`
-	BtcTo            string    `json:"btc_to" gorm:"type:varchar(64);not null;default:'';index"`
+	BtcTo            string    `json:"btc_to" gorm:"type:varchar(64);not null;default:'';index;uniqueIndex:idx_deposit_history_btc_tx_hash_to,priority:2"`
+	BtcToLabel       string    `json:"btc_to_label" gorm:"type:varchar(64);default:'';comment:label of the listened btc to address"`
+	DepositUUID      string    `json:"deposit_uuid" gorm:"type:varchar(66);default:'';comment:contract deposit uuid, set once when indexed"`
 	BtcFromAAAddress string    `json:"btc_from_aa_address" gorm:"type:varchar(42);default:'';comment:from aa address"`
+	BtcMemoAddress   string    `json:"btc_memo_address" gorm:"type:varchar(42);default:'';comment:deposit evm address from op_return memo"`
 	BtcValue         int64     `json:"btc_value" gorm:"default:0;comment:bitcoin transfer value"`
 	B2TxFrom         string    `json:"b2_tx_from" gorm:"type:varchar(42);default:'';comment:from address"`
 	B2TxHash         string    `json:"b2_tx_hash" gorm:"type:varchar(66);not null;default:'';index;comment:b2 network tx hash"`
 	B2TxNonce        uint64    `json:"b2_tx_nonce" gorm:"default:0"`
 	B2TxStatus       int       `json:"b2_tx_status" gorm:"type:SMALLINT;default:1"`
 	B2TxRetry        int       `json:"b2_tx_retry" gorm:"type:SMALLINT;default:0"`
+	B2TxNextAttempt  time.Time `json:"b2_tx_next_attempt" gorm:"comment:next attempt of the failed deposit, retry backoff"`
 	B2EoaTxFrom      string    `json:"b2_eoa_tx_from" gorm:"type:varchar(42);default:'';comment:from address"`
 	B2EoaTxNonce     uint64    `json:"b2_eoa_tx_nonce" gorm:"default:0"`
 	B2EoaTxHash      string    `json:"b2_eoa_tx_hash" gorm:"type:varchar(66);not null;default:'';comment:b2 network eoa tx hash"`
 	B2EoaTxStatus    int       `json:"b2_eoa_tx_status" gorm:"type:SMALLINT;default:1"`
 	BtcBlockTime     time.Time `json:"btc_block_time"`
+	BtcConfirmations int64     `json:"btc_confirmations" gorm:"default:0;comment:bitcoin tx confirmations"`
 	CallbackStatus   int       `json:"callback_status" gorm:"type:SMALLINT;default:0"`
 	ListenerStatus   int       `json:"listener_status" gorm:"type:SMALLINT;default:0"`
 	B2TxCheck        int       `json:"b2_tx_check" gorm:"type:SMALLINT;default:1"`
+	HoldReason       string    `json:"hold_reason" gorm:"type:varchar(64);default:'';comment:reason held by the deposit amount policy"`
+	B2DryRunTx       string    `json:"b2_dry_run_tx" gorm:"type:jsonb;default:'{}';comment:would-be deposit tx of dry run mode, not signed or broadcast"`
+	B2DryRunResult   string    `json:"b2_dry_run_result" gorm:"type:text;default:'';comment:eth_call simulation result of dry run mode"`
 }
 
 type DepositColumns struct {
@@ -88,23 +108,32 @@ type DepositColumns struct {
 	BtcTxType        string
 	BtcFroms         string
 	BtcFrom          string
+	BtcFromPolicy    string
 	BtcTos           string
 	BtcTo            string
+	BtcToLabel       string
+	DepositUUID      string
 	BtcFromAAAddress string
+	BtcMemoAddress   string
 	BtcValue         string
 	B2TxFrom         string
 	B2TxHash         string
 	B2TxNonce        string
 	B2TxStatus       string
 	B2TxRetry        string
+	B2TxNextAttempt  string
 	B2EoaTxFrom      string
 	B2EoaTxNonce     string
 	B2EoaTxHash      string
 	B2EoaTxStatus    string
 	BtcBlockTime     string
+	BtcConfirmations string
 	CallbackStatus   string
 	ListenerStatus   string
 	B2TxCheck        string
+	HoldReason       string
+	B2DryRunTx       string
+	B2DryRunResult   string
 }
 
 func (Deposit) TableName() string {
@@ -119,9 +148,13 @@ func (Deposit) Column() DepositColumns {
 		BtcTxType:        "btc_tx_type",
 		BtcFroms:         "btc_froms",
 		BtcFrom:          "btc_from",
+		BtcFromPolicy:    "btc_from_policy",
 		BtcTos:           "btc_tos",
 		BtcTo:            "btc_to",
+		BtcToLabel:       "btc_to_label",
+		DepositUUID:      "deposit_uuid",
 		BtcFromAAAddress: "btc_from_aa_address",
+		BtcMemoAddress:   "btc_memo_address",
 		BtcValue:         "btc_value",
 		B2TxFrom:         "b2_tx_from",
 		B2TxHash:         "b2_tx_hash",
@@ -132,9 +165,14 @@ func (Deposit) Column() DepositColumns {
 		B2EoaTxHash:      "b2_eoa_tx_hash",
 		B2EoaTxStatus:    "b2_eoa_tx_status",
 		BtcBlockTime:     "btc_block_time",
+		BtcConfirmations: "btc_confirmations",
 		B2TxRetry:        "b2_tx_retry",
+		B2TxNextAttempt:  "b2_tx_next_attempt",
 		CallbackStatus:   "callback_status",
 		ListenerStatus:   "listener_status",
 		B2TxCheck:        "b2_tx_check",
+		HoldReason:       "hold_reason",
+		B2DryRunTx:       "b2_dry_run_tx",
+		B2DryRunResult:   "b2_dry_run_result",
 	}
 }
@@ -0,0 +1,322 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIllegalDepositTransition = errors.New("illegal deposit status transition")
+	ErrDepositStatusMismatch    = errors.New("deposit status mismatch")
+	ErrUnknownDepositStatus     = errors.New("unknown deposit status")
+)
+
+const (
+	DepositActorIndexer  = "indexer"  // btc block indexer
+	DepositActorMempool  = "mempool"  // btc mempool tracker
+	DepositActorCallback = "callback" // custody callback api
+	DepositActorBridge   = "bridge"   // bridge deposit service
+	DepositActorOperator = "operator" // operator command
+)
+
+// depositStates allowed transitions of the status column, from -> to
+type depositStates map[int][]int
+
+var (
+	// b2 tx states picked by the deposit loop
+	b2TxSendable = []int{
+		DepositB2TxStatusPending,
+		DepositB2TxStatusInsufficientBalance,
+		DepositB2TxStatusFromAccountGasInsufficient,
+		DepositB2TxStatusAAAddressNotFound,
+	}
+	// b2 tx states picked by the unconfirmed deposit loop
+	b2TxUnconfirmed = []int{
+		DepositB2TxStatusWaitMined,
+		DepositB2TxStatusWaitMinedFailed,
+		DepositB2TxStatusContextDeadlineExceeded,
+		DepositB2TxStatusIsPending,
+		DepositB2TxStatusNonceToLow,
+	}
+	// b2 tx states after sending the deposit tx or checking its receipt
+	b2TxSent = []int{
+		DepositB2TxStatusPending,
+		DepositB2TxStatusWaitMined,
+		DepositB2TxStatusTxHashExist,
+		DepositB2TxStatusSuccess,
+		DepositB2TxStatusWaitMinedStatusFailed,
+		DepositB2TxStatusInsufficientBalance,
+		DepositB2TxStatusFromAccountGasInsufficient,
+		DepositB2TxStatusAAAddressNotFound,
+		DepositB2TxStatusIsPending,
+		DepositB2TxStatusNonceToLow,
+		DepositB2TxStatusDeadLetter,
+	}
+	// eoa transfer states picked by the eoa loops
+	b2EoaTxSendable = []int{
+		DepositB2EoaTxStatusPending,
+		DepositB2EoaTxStatusFailed,
+		DepositB2EoaTxStatusNonceToLow,
+		DepositB2EoaTxStatusContextDeadlineExceeded,
+		DepositB2EoaTxStatusWaitMined,
+	}
+	// btc listener states not confirmed by the block indexer
+	listenerUnconfirmed = []int{
+		ListenerStatusPending,
+		ListenerStatusReorged,
+		ListenerStatusMempool,
+		ListenerStatusDropped,
+	}
+)
+
+// depositStateMachine allowed transitions by status column, states without transitions are final
+var depositStateMachine = map[string]depositStates{
+	Deposit{}.Column().B2TxStatus: newDepositStates(
+		transitions(b2TxSendable, b2TxSent...),
+		transitions(b2TxUnconfirmed, b2TxSent...),
+		transitions([]int{DepositB2TxStatusWaitMined},
+			DepositB2TxStatusWaitMinedFailed,
+			DepositB2TxStatusContextDeadlineExceeded),
+		// tx hash exist deposit is checked by the rollup deposit event
+		transitions([]int{DepositB2TxStatusTxHashExist}, DepositB2TxStatusSuccess),
+		// held by the deposit amount policy when confirmed, released or rejected by operator
+		transitions([]int{DepositB2TxStatusPending}, DepositB2TxStatusHeld),
+		transitions([]int{DepositB2TxStatusHeld}, DepositB2TxStatusPending, DepositB2TxStatusRejected),
+		// dead letter deposit is retried by operator
+		transitions([]int{DepositB2TxStatusDeadLetter}, DepositB2TxStatusPending),
+	),
+	Deposit{}.Column().B2EoaTxStatus: newDepositStates(
+		transitions(b2EoaTxSendable,
+			DepositB2EoaTxStatusFailed,
+			DepositB2EoaTxStatusNonceToLow,
+			DepositB2EoaTxStatusWaitMined),
+		transitions([]int{DepositB2EoaTxStatusWaitMined},
+			DepositB2EoaTxStatusWaitMinedFailed,
+			DepositB2EoaTxStatusContextDeadlineExceeded),
+		// receipt of the sent eoa transfer
+		transitions([]int{
+			DepositB2EoaTxStatusFailed,
+			DepositB2EoaTxStatusNonceToLow,
+			DepositB2EoaTxStatusContextDeadlineExceeded,
+			DepositB2EoaTxStatusWaitMined,
+		},
+			DepositB2EoaTxStatusSuccess,
+			DepositB2EoaTxStatusUnknown),
+	),
+	Deposit{}.Column().CallbackStatus: newDepositStates(
+		transitions([]int{CallbackStatusPending}, CallbackStatusSuccess),
+	),
+	Deposit{}.Column().ListenerStatus: newDepositStates(
+		transitions(listenerUnconfirmed,
+			ListenerStatusSuccess,
+			ListenerStatusFromRejected,
+			ListenerStatusQuarantined),
+		transitions([]int{ListenerStatusSuccess}, ListenerStatusReorged),
+		transitions([]int{ListenerStatusMempool}, ListenerStatusDropped),
+		transitions([]int{ListenerStatusDropped}, ListenerStatusMempool),
+	),
+	Deposit{}.Column().B2TxCheck: newDepositStates(
+		transitions([]int{B2CheckStatusPending}, B2CheckStatusSuccess, B2CheckStatusFailed),
+	),
+}
+
+func transitions(from []int, to ...int) depositStates {
+	states := make(depositStates, len(from))
+	for _, v := range from {
+		states[v] = to
+	}
+	return states
+}
+
+func newDepositStates(list ...depositStates) depositStates {
+	states := depositStates{}
+	for _, v := range list {
+		for from, to := range v {
+			states[from] = append(states[from], to...)
+		}
+	}
+	return states
+}
+
+// DepositTransitionAllowed whether the status column may change from to
+func DepositTransitionAllowed(status string, from, to int) (bool, error) {
+	states, ok := depositStateMachine[status]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownDepositStatus, status)
+	}
+	for _, v := range states[from] {
+		if v == to {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DepositStatusHistory status transitions of the deposit
+type DepositStatusHistory struct {
+	Base
+	DepositID int64  `json:"deposit_id" gorm:"index;not null;default:0"`
+	Status    string `json:"status" gorm:"type:varchar(32);not null;default:'';comment:status column of deposit_history"`
+	OldState  int    `json:"old_state" gorm:"type:SMALLINT;default:0"`
+	NewState  int    `json:"new_state" gorm:"type:SMALLINT;default:0"`
+	Actor     string `json:"actor" gorm:"type:varchar(32);not null;default:'';comment:service changing the status"`
+	Reason    string `json:"reason" gorm:"type:text;default:''"`
+}
+
+type DepositStatusHistoryColumns struct {
+	DepositID string
+	Status    string
+	OldState  string
+	NewState  string
+	Actor     string
+	Reason    string
+}
+
+func (DepositStatusHistory) TableName() string {
+	return "deposit_status_history"
+}
+
+func (DepositStatusHistory) Column() DepositStatusHistoryColumns {
+	return DepositStatusHistoryColumns{
+		DepositID: "deposit_id",
+		Status:    "status",
+		OldState:  "old_state",
+		NewState:  "new_state",
+		Actor:     "actor",
+		Reason:    "reason",
+	}
+}
+
+// DepositTransition status changes of a deposit
+type DepositTransition struct {
+	// To new states by status column
+	To map[string]int
+	// From expected current states by status column, ErrDepositStatusMismatch if not matched
+	From map[string]int
+	// Updates other columns updated with the states, status columns are not allowed
+	Updates map[string]interface{}
+	Actor   string
+	Reason  string
+}
+
+// Status returns the state of the status column
+func (d Deposit) Status(status string) (int, error) {
+	switch status {
+	case d.Column().B2TxStatus:
+		return d.B2TxStatus, nil
+	case d.Column().B2EoaTxStatus:
+		return d.B2EoaTxStatus, nil
+	case d.Column().CallbackStatus:
+		return d.CallbackStatus, nil
+	case d.Column().ListenerStatus:
+		return d.ListenerStatus, nil
+	case d.Column().B2TxCheck:
+		return d.B2TxCheck, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnknownDepositStatus, status)
+	}
+}
+
+// DepositStatusChanges validate the transition of the deposit, returns the history of changed status columns,
+// unchanged status columns are not recorded
+func DepositStatusChanges(deposit Deposit, transition DepositTransition) ([]DepositStatusHistory, error) {
+	for column := range transition.Updates {
+		if _, ok := depositStateMachine[column]; ok {
+			return nil, fmt.Errorf("status %s is changed by the transition states", column)
+		}
+	}
+	for status := range transition.To {
+		if _, ok := depositStateMachine[status]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDepositStatus, status)
+		}
+	}
+	for status, expected := range transition.From {
+		current, err := deposit.Status(status)
+		if err != nil {
+			return nil, err
+		}
+		if current != expected {
+			return nil, fmt.Errorf("%w: deposit %d %s is %d, expected %d",
+				ErrDepositStatusMismatch, deposit.ID, status, current, expected)
+		}
+	}
+	history := make([]DepositStatusHistory, 0, len(transition.To))
+	// columns in the state machine order, the history is deterministic
+	for _, status := range depositStatuses() {
+		to, ok := transition.To[status]
+		if !ok {
+			continue
+		}
+		from, err := deposit.Status(status)
+		if err != nil {
+			return nil, err
+		}
+		if from == to {
+			continue
+		}
+		allowed, err := DepositTransitionAllowed(status, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: deposit %d %s %d -> %d",
+				ErrIllegalDepositTransition, deposit.ID, status, from, to)
+		}
+		history = append(history, DepositStatusHistory{
+			DepositID: deposit.ID,
+			Status:    status,
+			OldState:  from,
+			NewState:  to,
+			Actor:     transition.Actor,
+			Reason:    transition.Reason,
+		})
+	}
+	return history, nil
+}
+
+func depositStatuses() []string {
+	return []string{
+		Deposit{}.Column().ListenerStatus,
+		Deposit{}.Column().CallbackStatus,
+		Deposit{}.Column().B2TxStatus,
+		Deposit{}.Column().B2EoaTxStatus,
+		Deposit{}.Column().B2TxCheck,
+	}
+}
+
+// TransitDeposit change the states of the deposit and record the history, the deposit row is locked,
+// all deposit status changes go through the transition
+func TransitDeposit(db *gorm.DB, depositID int64, transition DepositTransition) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var deposit Deposit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, depositID).Error
+		if err != nil {
+			return err
+		}
+		history, err := DepositStatusChanges(deposit, transition)
+		if err != nil {
+			return err
+		}
+		updates := make(map[string]interface{}, len(transition.Updates)+len(history))
+		for column, value := range transition.Updates {
+			updates[column] = value
+		}
+		for _, v := range history {
+			updates[v.Status] = v.NewState
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		err = tx.Model(&Deposit{}).Where("id = ?", depositID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 {
+			return nil
+		}
+		return tx.Create(&history).Error
+	})
+}
@@ -0,0 +1,263 @@
+package model_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/b2network/b2-indexer/internal/model"
+	"github.com/b2network/b2-indexer/pkg/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDepositStatusHistoryColumn(t *testing.T) {
+	var d model.DepositStatusHistory
+	dc := model.DepositStatusHistory{}.Column()
+
+	dFields := reflect.TypeOf(d)
+	dcValues := reflect.ValueOf(dc)
+
+	dJSONTags := []string{}
+	for i := 0; i < dFields.NumField(); i++ {
+		dField := dFields.Field(i)
+		dJSONTag := dField.Tag.Get("json")
+		dJSONTags = append(dJSONTags, dJSONTag)
+	}
+
+	for i := 0; i < dcValues.NumField(); i++ {
+		dcValue := dcValues.Field(i).String()
+		if !utils.StrInArray(dJSONTags, dcValue) {
+			t.Fatalf("depositStatusHistoryColumn field %s not found in deposit_status_history %s", dcValue, dJSONTags)
+		}
+	}
+}
+
+func TestDepositTransitionAllowed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  string
+		from    int
+		to      int
+		allowed bool
+		err     bool
+	}{
+		{
+			name:    "success: pending to wait mined",
+			status:  model.Deposit{}.Column().B2TxStatus,
+			from:    model.DepositB2TxStatusPending,
+			to:      model.DepositB2TxStatusWaitMined,
+			allowed: true,
+		},
+		{
+			name:    "success: wait mined to success",
+			status:  model.Deposit{}.Column().B2TxStatus,
+			from:    model.DepositB2TxStatusWaitMined,
+			to:      model.DepositB2TxStatusSuccess,
+			allowed: true,
+		},
+		{
+			name:    "success: aa address not found retried",
+			status:  model.Deposit{}.Column().B2TxStatus,
+			from:    model.DepositB2TxStatusAAAddressNotFound,
+			to:      model.DepositB2TxStatusWaitMined,
+			allowed: true,
+		},
+		{
+			name:    "success: held released",
+			status:  model.Deposit{}.Column().B2TxStatus,
+			from:    model.DepositB2TxStatusHeld,
+			to:      model.DepositB2TxStatusPending,
+			allowed: true,
+		},
+		{
+			name:    "success: dead letter retried",
+			status:  model.Deposit{}.Column().B2TxStatus,
+			from:    model.DepositB2TxStatusDeadLetter,
+			to:      model.DepositB2TxStatusPending,
+			allowed: true,
+		},
+		{
+			name:    "success: reorged confirmed again",
+			status:  model.Deposit{}.Column().ListenerStatus,
+			from:    model.ListenerStatusReorged,
+			to:      model.ListenerStatusSuccess,
+			allowed: true,
+		},
+		{
+			name:    "success: eoa wait mined to success",
+			status:  model.Deposit{}.Column().B2EoaTxStatus,
+			from:    model.DepositB2EoaTxStatusWaitMined,
+			to:      model.DepositB2EoaTxStatusSuccess,
+			allowed: true,
+		},
+		{
+			name:   "fail: success is final",
+			status: model.Deposit{}.Column().B2TxStatus,
+			from:   model.DepositB2TxStatusSuccess,
+			to:     model.DepositB2TxStatusPending,
+		},
+		{
+			name:   "fail: rejected is final",
+			status: model.Deposit{}.Column().B2TxStatus,
+			from:   model.DepositB2TxStatusRejected,
+			to:     model.DepositB2TxStatusPending,
+		},
+		{
+			name:   "fail: held not sent",
+			status: model.Deposit{}.Column().B2TxStatus,
+			from:   model.DepositB2TxStatusHeld,
+			to:     model.DepositB2TxStatusWaitMined,
+		},
+		{
+			name:   "fail: dead letter not sent",
+			status: model.Deposit{}.Column().B2TxStatus,
+			from:   model.DepositB2TxStatusDeadLetter,
+			to:     model.DepositB2TxStatusWaitMined,
+		},
+		{
+			name:   "fail: mempool not reorged",
+			status: model.Deposit{}.Column().ListenerStatus,
+			from:   model.ListenerStatusMempool,
+			to:     model.ListenerStatusReorged,
+		},
+		{
+			name:   "fail: check failed is final",
+			status: model.Deposit{}.Column().B2TxCheck,
+			from:   model.B2CheckStatusFailed,
+			to:     model.B2CheckStatusSuccess,
+		},
+		{
+			name:   "fail: unknown status",
+			status: model.Deposit{}.Column().BtcValue,
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			allowed, err := model.DepositTransitionAllowed(tc.status, tc.from, tc.to)
+			if tc.err {
+				require.ErrorIs(t, err, model.ErrUnknownDepositStatus)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.allowed, allowed)
+		})
+	}
+}
+
+func TestDepositStatusChanges(t *testing.T) {
+	deposit := model.Deposit{
+		B2TxStatus:     model.DepositB2TxStatusPending,
+		ListenerStatus: model.ListenerStatusMempool,
+		CallbackStatus: model.CallbackStatusPending,
+	}
+	deposit.ID = 7
+
+	testCases := []struct {
+		name       string
+		transition model.DepositTransition
+		history    []model.DepositStatusHistory
+		err        error
+	}{
+		{
+			name: "success: confirmed and held",
+			transition: model.DepositTransition{
+				To: map[string]int{
+					model.Deposit{}.Column().B2TxStatus:     model.DepositB2TxStatusHeld,
+					model.Deposit{}.Column().ListenerStatus: model.ListenerStatusSuccess,
+				},
+				Updates: map[string]interface{}{model.Deposit{}.Column().HoldReason: "above max amount"},
+				Actor:   model.DepositActorIndexer,
+				Reason:  "confirmed",
+			},
+			history: []model.DepositStatusHistory{
+				{
+					DepositID: 7,
+					Status:    model.Deposit{}.Column().ListenerStatus,
+					OldState:  model.ListenerStatusMempool,
+					NewState:  model.ListenerStatusSuccess,
+					Actor:     model.DepositActorIndexer,
+					Reason:    "confirmed",
+				},
+				{
+					DepositID: 7,
+					Status:    model.Deposit{}.Column().B2TxStatus,
+					OldState:  model.DepositB2TxStatusPending,
+					NewState:  model.DepositB2TxStatusHeld,
+					Actor:     model.DepositActorIndexer,
+					Reason:    "confirmed",
+				},
+			},
+		},
+		{
+			name: "success: unchanged status not recorded",
+			transition: model.DepositTransition{
+				To: map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusPending},
+				Updates: map[string]interface{}{
+					model.Deposit{}.Column().B2TxRetry: 1,
+				},
+			},
+			history: []model.DepositStatusHistory{},
+		},
+		{
+			name: "success: expected status matched",
+			transition: model.DepositTransition{
+				To:    map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusDropped},
+				From:  map[string]int{model.Deposit{}.Column().ListenerStatus: model.ListenerStatusMempool},
+				Actor: model.DepositActorMempool,
+			},
+			history: []model.DepositStatusHistory{
+				{
+					DepositID: 7,
+					Status:    model.Deposit{}.Column().ListenerStatus,
+					OldState:  model.ListenerStatusMempool,
+					NewState:  model.ListenerStatusDropped,
+					Actor:     model.DepositActorMempool,
+				},
+			},
+		},
+		{
+			name: "fail: illegal transition",
+			transition: model.DepositTransition{
+				To: map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusRejected},
+			},
+			err: model.ErrIllegalDepositTransition,
+		},
+		{
+			name: "fail: expected status mismatch",
+			transition: model.DepositTransition{
+				To:   map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusPending},
+				From: map[string]int{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusHeld},
+			},
+			err: model.ErrDepositStatusMismatch,
+		},
+		{
+			name: "fail: unknown status",
+			transition: model.DepositTransition{
+				To: map[string]int{model.Deposit{}.Column().BtcValue: 1},
+			},
+			err: model.ErrUnknownDepositStatus,
+		},
+		{
+			name: "fail: status in updates",
+			transition: model.DepositTransition{
+				Updates: map[string]interface{}{model.Deposit{}.Column().B2TxStatus: model.DepositB2TxStatusSuccess},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			history, err := model.DepositStatusChanges(deposit, tc.transition)
+			if tc.history == nil {
+				require.Error(t, err)
+				if tc.err != nil {
+					require.ErrorIs(t, err, tc.err)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.history, history)
+		})
+	}
+}
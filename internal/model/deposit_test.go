@@ -2,10 +2,14 @@ package model_test
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/b2network/b2-indexer/internal/model"
 	"github.com/b2network/b2-indexer/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 func TestValidateDepositColumn(t *testing.T) {
@@ -29,3 +33,40 @@ func TestValidateDepositColumn(t *testing.T) {
 		}
 	}
 }
+
+func TestDepositCreateSQL(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+
+	stmt := db.Create(&model.Deposit{
+		BtcTxHash: "hash",
+		BtcFroms:  "{}",
+		BtcTos:    "{}",
+	}).Statement
+	require.NoError(t, stmt.Error)
+
+	sql := stmt.SQL.String()
+	start := strings.Index(sql, "(")
+	end := strings.Index(sql, ")")
+	require.True(t, start > 0 && end > start, sql)
+	columns := strings.Split(sql[start+1:end], ",")
+	require.Len(t, stmt.Vars, len(columns))
+	values := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		values[strings.Trim(column, `" `)] = stmt.Vars[i]
+	}
+	// jsonb columns reject empty string input
+	for _, column := range []string{
+		model.Deposit{}.Column().BtcFroms,
+		model.Deposit{}.Column().BtcTos,
+		model.Deposit{}.Column().B2DryRunTx,
+	} {
+		value, ok := values[column]
+		require.True(t, ok, "%s not inserted: %s", column, sql)
+		require.Equal(t, "{}", value, column)
+	}
+}
@@ -5,12 +5,13 @@ type RollupDeposit struct {
 	BtcTxHash        string `json:"btc_tx_hash" gorm:"type:varchar(64);not null;default:'';comment:bitcoin tx hash"`
 	BtcFromAAAddress string `json:"btc_from_aa_address" gorm:"type:varchar(42);default:'';comment:from aa address"`
 	BtcValue         int64  `json:"btc_value" gorm:"type:bigint;default:0;comment:bitcoin transfer value"`
+	B2Value          string `json:"b2_value" gorm:"type:varchar(80);default:'';comment:deposit event amount in the smallest unit of the asset"`
 	B2BlockNumber    uint64 `json:"b2_block_number" gorm:"type:bigint;comment:b2 block number"`
 	B2BlockHash      string `json:"b2_block_hash" gorm:"type:varchar(256);comment:b2 block hash"`
 	B2TxFrom         string `json:"b2_tx_from" gorm:"type:varchar(42);default:'';comment:from address"`
-	B2TxHash         string `json:"b2_tx_hash" gorm:"type:varchar(256);default:'';uniqueIndex;comment:b2 network tx hash"`
+	B2TxHash         string `json:"b2_tx_hash" gorm:"type:varchar(256);default:'';uniqueIndex:idx_rollup_deposit_history_b2_tx_hash_log,priority:1;comment:b2 network tx hash"`
 	B2TxIndex        uint   `json:"b2_tx_index" gorm:"type:bigint;comment:b2 tx index"`
-	B2LogIndex       uint   `json:"b2_log_index" gorm:"type:int;comment:b2 log index"`
+	B2LogIndex       uint   `json:"b2_log_index" gorm:"type:int;uniqueIndex:idx_rollup_deposit_history_b2_tx_hash_log,priority:2;comment:b2 log index"`
 	Status           int    `json:"status" gorm:"type:smallint;default:1"`
 }
 
@@ -22,6 +23,7 @@ type RollupDepositColumns struct {
 	BtcTxHash        string
 	BtcFromAAAddress string
 	BtcValue         string
+	B2Value          string
 	B2TxFrom         string
 	B2BlockNumber    string
 	B2BlockHash      string
@@ -36,6 +38,7 @@ func (RollupDeposit) Column() RollupDepositColumns {
 		BtcTxHash:        "btc_tx_hash",
 		BtcFromAAAddress: "btc_from_aa_address",
 		BtcValue:         "btc_value",
+		B2Value:          "b2_value",
 		B2TxFrom:         "b2_tx_from",
 		B2TxHash:         "b2_tx_hash",
 		B2BlockNumber:    "b2_block_number",
@@ -2,10 +2,13 @@ package model_test
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/b2network/b2-indexer/internal/model"
 	"github.com/b2network/b2-indexer/pkg/utils"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/schema"
 )
 
 func TestValidateRollupDepositColumn(t *testing.T) {
@@ -29,3 +32,19 @@ func TestValidateRollupDepositColumn(t *testing.T) {
 		}
 	}
 }
+
+func TestRollupDepositUniqueIndex(t *testing.T) {
+	s, err := schema.Parse(&model.RollupDeposit{}, &sync.Map{}, schema.NamingStrategy{})
+	require.NoError(t, err)
+
+	// deposit events of a batch deposit tx share the b2 tx hash
+	require.Nil(t, s.LookIndex("idx_rollup_deposit_history_b2_tx_hash"))
+	index := s.LookIndex("idx_rollup_deposit_history_b2_tx_hash_log")
+	require.NotNil(t, index)
+	require.Equal(t, "UNIQUE", index.Class)
+	fields := make([]string, 0, len(index.Fields))
+	for _, v := range index.Fields {
+		fields = append(fields, v.DBName)
+	}
+	require.Equal(t, []string{model.RollupDeposit{}.Column().B2TxHash, model.RollupDeposit{}.Column().B2LogIndex}, fields)
+}
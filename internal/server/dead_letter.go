@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	logger "github.com/b2network/b2-indexer/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// ListDeadLetter print deposits exhausted the retry budget
+func ListDeadLetter(cmd *cobra.Command) error {
+	db, err := GetDBContextFromCmd(cmd)
+	if err != nil {
+		logger.Errorw("failed to get db context", "error", err.Error())
+		return err
+	}
+	deposits, err := bitcoin.ListDeadLetterDeposits(db)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(deposits, "", "  ")
+	if err != nil {
+		return err
+	}
+	cmd.Println(string(out))
+	return nil
+}
+
+// RetryDeadLetter send dead letter deposits of the btc tx again
+func RetryDeadLetter(cmd *cobra.Command, txHash string, to string) error {
+	db, err := GetDBContextFromCmd(cmd)
+	if err != nil {
+		logger.Errorw("failed to get db context", "error", err.Error())
+		return err
+	}
+	affected, err := bitcoin.RetryDeadLetterDeposits(db, txHash, to)
+	if err != nil {
+		return err
+	}
+	logger.Infow("dead letter deposits retried", "txId", txHash, "to", to, "affected", affected)
+	cmd.Printf("%d dead letter deposits retried\n", affected)
+	return nil
+}
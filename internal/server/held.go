@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/b2network/b2-indexer/internal/config"
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	logger "github.com/b2network/b2-indexer/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+func depositPolicy(bitcoinCfg *config.BitcoinConfig) bitcoin.DepositPolicy {
+	return bitcoin.DepositPolicy{
+		MinAmount: bitcoinCfg.Bridge.DepositMinAmount,
+		MaxAmount: bitcoinCfg.Bridge.DepositMaxAmount,
+		DailyCap:  bitcoinCfg.Bridge.DepositDailyCap,
+	}
+}
+
+// ListHeld print deposits held by the deposit policy
+func ListHeld(cmd *cobra.Command) error {
+	db, err := GetDBContextFromCmd(cmd)
+	if err != nil {
+		logger.Errorw("failed to get db context", "error", err.Error())
+		return err
+	}
+	deposits, err := bitcoin.ListHeldDeposits(db)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(deposits, "", "  ")
+	if err != nil {
+		return err
+	}
+	cmd.Println(string(out))
+	return nil
+}
+
+// ReleaseHeld release held deposits of the btc tx, reject them if reject is true
+func ReleaseHeld(cmd *cobra.Command, txHash string, to string, reject bool) error {
+	db, err := GetDBContextFromCmd(cmd)
+	if err != nil {
+		logger.Errorw("failed to get db context", "error", err.Error())
+		return err
+	}
+	var affected int64
+	if reject {
+		affected, err = bitcoin.RejectHeldDeposits(db, txHash, to)
+	} else {
+		affected, err = bitcoin.ReleaseHeldDeposits(db, txHash, to)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infow("held deposits updated", "txId", txHash, "to", to, "reject", reject, "affected", affected)
+	cmd.Printf("%d held deposits updated\n", affected)
+	return nil
+}
@@ -13,10 +13,14 @@ import (
 )
 
 func Run(ctx context.Context, serverCtx *Context, db *gorm.DB) (err error) {
-	if serverCtx.BitcoinConfig.IndexerListenAddress == "" {
+	listenAddresses, err := serverCtx.BitcoinConfig.ListenAddresses()
+	if err != nil {
+		log.Panicf(err.Error())
+	}
+	if len(listenAddresses) == 0 {
 		log.Panic("listen address empty")
 	}
-	grpcOpts := GrpcOpts(serverCtx.BitcoinConfig.IndexerListenAddress, serverCtx.HTTPConfig, db)
+	grpcOpts := GrpcOpts(listenAddresses, serverCtx.HTTPConfig, db)
 	err = grpc.Run(ctx, serverCtx.HTTPConfig, grpcOpts, service.RegisterGrpcFunc(), service.RegisterGateway)
 	if err != nil {
 		log.Panicf(err.Error())
@@ -24,11 +28,11 @@ func Run(ctx context.Context, serverCtx *Context, db *gorm.DB) (err error) {
 	return nil
 }
 
-func GrpcOpts(listenAddress string, httpConfig *config.HTTPConfig, db *gorm.DB) googleGrpc.ServerOption {
+func GrpcOpts(listenAddresses []config.ListenAddress, httpConfig *config.HTTPConfig, db *gorm.DB) googleGrpc.ServerOption {
 	grpcOpt := googleGrpc.UnaryInterceptor(googleGrpc.UnaryServerInterceptor(
 		func(ctx context.Context, req interface{}, _ *googleGrpc.UnaryServerInfo, handler googleGrpc.UnaryHandler) (resp interface{}, err error) {
 			ctx = context.WithValue(ctx, types.DBContextKey, db)
-			ctx = context.WithValue(ctx, types.ListenAddressContextKey, listenAddress)
+			ctx = context.WithValue(ctx, types.ListenAddressContextKey, listenAddresses)
 			ctx = context.WithValue(ctx, types.HTTPConfigContextKey, httpConfig)
 			return handler(ctx, req)
 		}))
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/b2network/b2-indexer/internal/logic/bitcoin"
+	logger "github.com/b2network/b2-indexer/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// Reindex parse blocks in [from, to] and report new, existing and mismatched deposits,
+// missing deposits are inserted unless dry run, the live index cursor is not moved
+func Reindex(ctx *Context, cmd *cobra.Command, from int64, to int64, dryRun bool) error {
+	bitcoinCfg := ctx.BitcoinConfig
+	bidxLogger := newLogger(ctx, "[bitcoin-reindex]")
+	bidxer, shutdown, err := newTxIndexer(bitcoinCfg, bidxLogger)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	db, err := GetDBContextFromCmd(cmd)
+	if err != nil {
+		logger.Errorw("failed to get db context", "error", err.Error())
+		return err
+	}
+
+	bindexerService := bitcoin.NewIndexerService(bidxer, db, bidxLogger, 0)
+	bindexerService.SetDepositPolicy(depositPolicy(bitcoinCfg))
+	report, err := bindexerService.Reindex(from, to, !dryRun)
+	if report != nil {
+		out, jsonErr := json.MarshalIndent(report, "", "  ")
+		if jsonErr != nil {
+			return jsonErr
+		}
+		cmd.Println(string(out))
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -26,34 +27,14 @@ func Start(ctx *Context, cmd *cobra.Command) (err error) {
 	bitcoinCfg := ctx.BitcoinConfig
 	if bitcoinCfg.EnableIndexer {
 		logger.Infow("bitcoin index service starting!!!")
-		bclient, err := rpcclient.New(&rpcclient.ConnConfig{
-			Host:         bitcoinCfg.RPCHost + ":" + bitcoinCfg.RPCPort,
-			User:         bitcoinCfg.RPCUser,
-			Pass:         bitcoinCfg.RPCPass,
-			HTTPPostMode: true,                  // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   bitcoinCfg.DisableTLS, // Bitcoin core does not provide TLS by default
-		}, nil)
-		if err != nil {
-			logger.Errorw("failed to create bitcoin client", "error", err.Error())
-			return err
-		}
-		defer func() {
-			bclient.Shutdown()
-		}()
 		bitcoinParam := config.ChainParams(bitcoinCfg.NetworkName)
 
 		bidxLogger := newLogger(ctx, "[bitcoin-indexer]")
-		bidxer, err := bitcoin.NewBitcoinIndexer(bidxLogger, bclient, bitcoinParam, bitcoinCfg.IndexerListenAddress, bitcoinCfg.IndexerListenTargetConfirmations)
-		if err != nil {
-			logger.Errorw("failed to new bitcoin indexer indexer", "error", err.Error())
-			return err
-		}
-		// check bitcoin core status, whether the request succeed
-		_, err = bidxer.BlockChainInfo()
+		bidxer, shutdown, err := newTxIndexer(bitcoinCfg, bidxLogger)
 		if err != nil {
-			logger.Errorw("failed to get bitcoin core status", "error", err.Error())
 			return err
 		}
+		defer shutdown()
 
 		db, err := GetDBContextFromCmd(cmd)
 		if err != nil {
@@ -61,7 +42,20 @@ func Start(ctx *Context, cmd *cobra.Command) (err error) {
 			return err
 		}
 
-		bindexerService := bitcoin.NewIndexerService(bidxer, db, bidxLogger)
+		// prefetched blocks are not throttled, the prefetch window limits the rpc load
+		blockInterval := bitcoin.IndexBlockTimeout
+		if bitcoinCfg.IndexerPrefetchBlocks > 0 {
+			blockInterval = 0
+		}
+		bindexerService := bitcoin.NewIndexerService(bidxer, db, bidxLogger, blockInterval)
+		bindexerService.SetDepositPolicy(depositPolicy(bitcoinCfg))
+		if bitcoinCfg.IndexerZMQBlockEndpoint != "" {
+			zmqCtx, zmqCancel := context.WithCancel(context.Background())
+			defer zmqCancel()
+			zmqSubscriber := bitcoin.NewZMQSubscriber(bitcoinCfg.IndexerZMQBlockEndpoint, newLogger(ctx, "[bitcoin-zmq]"))
+			go zmqSubscriber.Run(zmqCtx)
+			bindexerService.SetNewBlockNotify(zmqSubscriber.NewBlock())
+		}
 
 		errCh := make(chan error)
 		go func() {
@@ -76,6 +70,32 @@ func Start(ctx *Context, cmd *cobra.Command) (err error) {
 		case <-time.After(5 * time.Second): // assume server started successfully
 		}
 
+		if bitcoinCfg.IndexerEnableMempool {
+			mempoolIdxr, ok := bidxer.(types.BITCOINMempoolIndexer)
+			if !ok {
+				return fmt.Errorf("bitcoin backend %s not support mempool", bitcoinCfg.Backend)
+			}
+			mempoolService := bitcoin.NewMempoolService(mempoolIdxr, db, newLogger(ctx, "[bitcoin-mempool]"))
+			mempoolErrCh := make(chan error)
+			go func() {
+				if err := mempoolService.Start(); err != nil {
+					mempoolErrCh <- err
+				}
+			}()
+
+			select {
+			case err := <-mempoolErrCh:
+				return err
+			case <-time.After(5 * time.Second): // assume server started successfully
+			}
+
+			defer func() {
+				if err = mempoolService.Stop(); err != nil {
+					logger.Errorf("stop err:%v", err.Error())
+				}
+			}()
+		}
+
 		// start l1->l2 bridge service
 		bridgeLogger := newLogger(ctx, "[bridge-deposit]")
 		bridge, err := bitcoin.NewBridge(bitcoinCfg.Bridge, path.Join(home, "config"), bridgeLogger, bitcoinParam)
@@ -83,8 +103,17 @@ func Start(ctx *Context, cmd *cobra.Command) (err error) {
 			logger.Errorw("failed to create bitcoin bridge", "error", err.Error())
 			return err
 		}
+		bridge.SetAddressResolver(bitcoin.NewCachedAddressResolver(bridge.AddressResolver(), db,
+			time.Duration(bitcoinCfg.Bridge.AANotFoundTTL)*time.Second, bridgeLogger))
 
 		bridgeService := bitcoin.NewBridgeDepositService(bridge, bidxer, db, bridgeLogger)
+		bridgeService.SetMaxInFlight(bitcoinCfg.Bridge.DepositMaxInFlight)
+		bridgeService.SetBatchSize(bitcoinCfg.Bridge.DepositBatchSize)
+		bridgeService.SetSignerMinBalance(bitcoinCfg.Bridge.SignerMinBalance)
+		bridgeService.SetDryRun(bitcoinCfg.Bridge.DryRun)
+		bridgeService.SetAsset(bridge.Asset())
+		bridgeService.SetRetryPolicy(bitcoin.NewDepositRetryPolicy(bitcoinCfg.Bridge.DepositMaxRetry,
+			time.Duration(bitcoinCfg.Bridge.DepositRetryBackoff)*time.Second))
 		bridgeErrCh := make(chan error)
 		go func() {
 			if err := bridgeService.Start(); err != nil {
@@ -247,6 +276,144 @@ func Start(ctx *Context, cmd *cobra.Command) (err error) {
 	return nil
 }
 
+// newRPCIndexer new bitcoin core json-rpc indexer, shutdown closes the rpc clients
+func newRPCIndexer(
+	bitcoinCfg *config.BitcoinConfig,
+	bidxLogger logger.Logger,
+	listenAddresses []config.ListenAddress,
+) (*bitcoin.Indexer, func(), error) {
+	bclient, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         bitcoinCfg.RPCHost + ":" + bitcoinCfg.RPCPort,
+		User:         bitcoinCfg.RPCUser,
+		Pass:         bitcoinCfg.RPCPass,
+		HTTPPostMode: true,                  // Bitcoin core only supports HTTP POST mode
+		DisableTLS:   bitcoinCfg.DisableTLS, // Bitcoin core does not provide TLS by default
+	}, nil)
+	if err != nil {
+		logger.Errorw("failed to create bitcoin client", "error", err.Error())
+		return nil, nil, err
+	}
+	shutdown := func() {
+		bclient.Shutdown()
+	}
+	bitcoinParam := config.ChainParams(bitcoinCfg.NetworkName)
+	bidxer, err := bitcoin.NewBitcoinIndexer(bidxLogger, bclient, bitcoinParam, listenAddresses, bitcoinCfg.IndexerListenTargetConfirmations)
+	if err != nil {
+		logger.Errorw("failed to new bitcoin indexer indexer", "error", err.Error())
+		shutdown()
+		return nil, nil, err
+	}
+	if err := setTxParser(bidxer, bitcoinCfg); err != nil {
+		shutdown()
+		return nil, nil, err
+	}
+	// prevout txs batched json-rpc client and block prefetch clients,
+	// requests of a http post mode client are sent one at a time
+	var bbatchClient *rpcclient.Client
+	bprefetchClients := make([]*rpcclient.Client, 0, bitcoinCfg.IndexerPrefetchBlocks)
+	shutdown = func() {
+		bclient.Shutdown()
+		if bbatchClient != nil {
+			bbatchClient.Shutdown()
+		}
+		for _, client := range bprefetchClients {
+			client.Shutdown()
+		}
+	}
+	if bitcoinCfg.IndexerRPCBatchSize > 0 {
+		bbatchClient, err = rpcclient.NewBatch(&rpcclient.ConnConfig{
+			Host:         bitcoinCfg.RPCHost + ":" + bitcoinCfg.RPCPort,
+			User:         bitcoinCfg.RPCUser,
+			Pass:         bitcoinCfg.RPCPass,
+			HTTPPostMode: true,
+			DisableTLS:   bitcoinCfg.DisableTLS,
+		})
+		if err != nil {
+			logger.Errorw("failed to create bitcoin batch client", "error", err.Error())
+			shutdown()
+			return nil, nil, err
+		}
+	}
+	for i := 0; i < bitcoinCfg.IndexerPrefetchBlocks; i++ {
+		bprefetchClient, err := rpcclient.New(&rpcclient.ConnConfig{
+			Host:         bitcoinCfg.RPCHost + ":" + bitcoinCfg.RPCPort,
+			User:         bitcoinCfg.RPCUser,
+			Pass:         bitcoinCfg.RPCPass,
+			HTTPPostMode: true,
+			DisableTLS:   bitcoinCfg.DisableTLS,
+		}, nil)
+		if err != nil {
+			logger.Errorw("failed to create bitcoin prefetch client", "error", err.Error())
+			shutdown()
+			return nil, nil, err
+		}
+		bprefetchClients = append(bprefetchClients, bprefetchClient)
+	}
+	bidxer.SetPipeline(bitcoin.PipelineConfig{
+		PrefetchBlocks: bitcoinCfg.IndexerPrefetchBlocks,
+		BatchSize:      bitcoinCfg.IndexerRPCBatchSize,
+		TxCacheSize:    bitcoinCfg.IndexerTxCacheSize,
+		BlockPrevouts:  bitcoinCfg.IndexerBlockPrevouts,
+	}, bbatchClient, bprefetchClients...)
+	// check bitcoin core status, whether the request succeed
+	_, err = bidxer.BlockChainInfo()
+	if err != nil {
+		logger.Errorw("failed to get bitcoin core status", "error", err.Error())
+		shutdown()
+		return nil, nil, err
+	}
+	return bidxer, shutdown, nil
+}
+
+// newTxIndexer new bitcoin indexer of the configured backend
+func newTxIndexer(bitcoinCfg *config.BitcoinConfig, bidxLogger logger.Logger) (types.BITCOINTxIndexer, func(), error) {
+	listenAddresses, err := bitcoinCfg.ListenAddresses()
+	if err != nil {
+		logger.Errorw("failed to get listen addresses", "error", err.Error())
+		return nil, nil, err
+	}
+	switch bitcoinCfg.Backend {
+	case config.BitcoinBackendEsplora:
+		eidxer, err := bitcoin.NewEsploraIndexer(bidxLogger, bitcoinCfg.EsploraURL, config.ChainParams(bitcoinCfg.NetworkName),
+			listenAddresses, bitcoinCfg.IndexerListenTargetConfirmations)
+		if err != nil {
+			logger.Errorw("failed to new bitcoin esplora indexer", "error", err.Error())
+			return nil, nil, err
+		}
+		if err := setTxParser(eidxer, bitcoinCfg); err != nil {
+			return nil, nil, err
+		}
+		// check esplora status, whether the request succeed
+		_, err = eidxer.LatestBlock()
+		if err != nil {
+			logger.Errorw("failed to get esplora status", "error", err.Error())
+			return nil, nil, err
+		}
+		return eidxer, func() {}, nil
+	case "", config.BitcoinBackendRPC:
+		return newRPCIndexer(bitcoinCfg, bidxLogger, listenAddresses)
+	default:
+		return nil, nil, fmt.Errorf("unsupported bitcoin backend %s", bitcoinCfg.Backend)
+	}
+}
+
+// txParserSetter tx parser settings shared by indexer backends
+type txParserSetter interface {
+	SetFromAttribution(policy string) error
+	SetTxTypeActions(actions map[string]string) error
+}
+
+func setTxParser(idxr txParserSetter, bitcoinCfg *config.BitcoinConfig) error {
+	if err := idxr.SetFromAttribution(bitcoinCfg.IndexerFromAttribution); err != nil {
+		return err
+	}
+	txTypeActions, err := bitcoinCfg.TxTypeActions()
+	if err != nil {
+		return err
+	}
+	return idxr.SetTxTypeActions(txTypeActions)
+}
+
 func GetDBContextFromCmd(cmd *cobra.Command) (*gorm.DB, error) {
 	if v := cmd.Context().Value(types.DBContextKey); v != nil {
 		db := v.(*gorm.DB)
@@ -2,16 +2,19 @@ package types
 
 import (
 	"context"
+	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
 // BITCOINBridge defines the interface of custom bitcoin bridge.
 type BITCOINBridge interface {
 	// Deposit transfers amout to address
-	Deposit(string, BitcoinFrom, int64, *types.Transaction, uint64, bool) (*types.Transaction, []byte, string, string, error)
+	// if the evm address is empty, the address is resolved from the bitcoin address by aa
+	Deposit(string, BitcoinFrom, string, int64, *types.Transaction, uint64, bool) (*types.Transaction, []byte, string, string, error)
 	// Transfer amount to address
-	Transfer(BitcoinFrom, int64, *types.Transaction, uint64, bool) (*types.Transaction, string, error)
+	Transfer(BitcoinFrom, string, int64, *types.Transaction, uint64, bool) (*types.Transaction, string, error)
 	// WaitMined wait mined
 	WaitMined(context.Context, *types.Transaction, []byte) (*types.Receipt, error)
 	// TransactionReceipt
@@ -21,4 +24,53 @@ type BITCOINBridge interface {
 	//  EnableEoaTransfer
 	EnableEoaTransfer() bool
 	FromAddress() string
+	// NonceAt returns the nonce of the address at the latest block, including pending txs if pending
+	NonceAt(address string, pending bool) (uint64, error)
+	// SelfTransfer send no-op self transfer of the signer address with the nonce
+	SelfTransfer(address string, nonce uint64) (*types.Transaction, error)
+	// RotateSigner switch the active signer of new txs, returns the active signer address
+	RotateSigner() string
+	// HasSigner whether the address is a bridge signer, retired signers replace their in-flight txs
+	HasSigner(address string) bool
+	// SignerAddresses returns addresses of all bridge signers
+	SignerAddresses() []string
+	// BalanceAt returns the gas balance of the address
+	BalanceAt(address string) (*big.Int, error)
+	// SupportBatchDeposit whether the contract abi has the batch deposit method
+	SupportBatchDeposit() bool
+	// BatchDeposit deposit items in one tx with the nonce, items failed to resolve the to address are skipped
+	BatchDeposit([]*BatchDepositItem, uint64) (*types.Transaction, string, error)
+	// ReplaceTransaction replace the pending tx by its signer with a raised fee, returns the signer address
+	ReplaceTransaction(*types.Transaction) (*types.Transaction, string, error)
+	// DepositEventUUIDs returns the deposit uuids of the deposit events in the receipt
+	DepositEventUUIDs(*types.Receipt) (map[common.Hash]struct{}, error)
+	// ResolveAddresses resolve aa addresses of the bitcoin addresses in one batch, returns the resolve errs
+	ResolveAddresses([]string) map[string]error
+	// SimulateDeposit build the deposit tx without signing and simulate it by eth_call
+	SimulateDeposit(string, BitcoinFrom, string, int64) (*DepositSimulation, error)
+}
+
+// BatchDepositItem deposit of the batch deposit tx
+type BatchDepositItem struct {
+	// UUID contract deposit uuid of the deposit
+	UUID       common.Hash
+	From       BitcoinFrom
+	EvmAddress string
+	Amount     int64
+	// ToAddress resolved b2 address, set by BatchDeposit
+	ToAddress string
+	// Err to address resolve err, the item is not in the batch
+	Err error
+}
+
+// DepositSimulation would-be deposit tx and its eth_call result, the tx is not signed or broadcast
+type DepositSimulation struct {
+	// ToAddress resolved b2 address
+	ToAddress string
+	// From signer address of the tx
+	From string
+	// Tx unsigned deposit tx
+	Tx *types.Transaction
+	// Err contract revert of the simulation, nil if the deposit would succeed
+	Err error
 }
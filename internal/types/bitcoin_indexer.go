@@ -1,6 +1,7 @@
 package types
 
 import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 )
 
@@ -10,29 +11,54 @@ type BITCOINTxIndexer interface {
 	ParseBlock(int64, int64) ([]*BitcoinTxParseResult, *wire.BlockHeader, error)
 	// LatestBlock get latest block height in the longest block chain.
 	LatestBlock() (int64, error)
+	// BlockHash get block hash by height in the longest block chain.
+	BlockHash(int64) (*chainhash.Hash, error)
 	// CheckConfirmations get tx detail info
 	CheckConfirmations(txHash string) error
 }
 
+// BITCOINMempoolIndexer defines the interface of custom bitcoin mempool tx indexer.
+type BITCOINMempoolIndexer interface {
+	// ParseMempool parse unconfirmed txs paying listened addresses
+	ParseMempool() ([]*BitcoinTxParseResult, error)
+	// TxExists whether the tx is in mempool or block chain
+	TxExists(txHash string) (bool, error)
+}
+
 type BitcoinTxParseResult struct {
 	// from is l2 user address, by parse bitcoin get the address
 	From []BitcoinFrom
 	// to is listening address
 	To string
+	// to_label is the label of the matched listening address
+	ToLabel string
 	// value is from transfer amount
 	Value int64
 	// tx_id is the btc transaction id
 	TxID string
 	// tx_type is the type of the transaction, eg. "brc20_transfer","transfer"
 	TxType string
+	// quarantined is true if the tx type is quarantined, recorded but not processed
+	Quarantined bool
 	// index is the index of the transaction in the block
 	Index int64
 	// tos tx all to info
 	Tos []BitcoinTo
+	// memo_address is the deposit destination evm address parsed from op_return memo, optional
+	MemoAddress string
+	// from_address is the l2 user address attributed from the inputs by the attribution policy
+	FromAddress string
+	// from_attribution is the attribution policy applied, or rejected
+	FromAttribution string
 }
 
 type BitcoinFrom struct {
 	Address string
+	// value is the spent prev out value
+	Value int64
+	// tx_id and vout is the spent prev out point
+	TxID string
+	Vout uint32
 }
 
 type BitcoinTo struct {
@@ -0,0 +1,119 @@
+package aa
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrInvalidPubKey            = errors.New("invalid btc public key")
+	ErrInvalidFactoryAddress    = errors.New("invalid aa factory address")
+	ErrInvalidImplementation    = errors.New("invalid aa account implementation")
+	ErrInvalidProxyCreationCode = errors.New("invalid aa proxy creation code")
+)
+
+// initializeSelector selector of the account initialize(address owner)
+var initializeSelector = crypto.Keccak256([]byte("initialize(address)"))[:4]
+
+// SmartAccount counterfactual smart account of the account factory, the factory deploys an
+// erc1967 proxy of the implementation by create2 with the index as salt, initialized by initialize(owner)
+type SmartAccount struct {
+	factory           common.Address
+	implementation    common.Address
+	proxyCreationCode []byte
+	index             *big.Int
+}
+
+// NewSmartAccount returns the smart account params, proxyCreationCode is the hex proxy creation bytecode
+func NewSmartAccount(factory, implementation, proxyCreationCode string, index int64) (*SmartAccount, error) {
+	if !common.IsHexAddress(factory) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFactoryAddress, factory)
+	}
+	if !common.IsHexAddress(implementation) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImplementation, implementation)
+	}
+	creationCode, err := hexutil.Decode(with0xPrefix(proxyCreationCode))
+	if err != nil || len(creationCode) == 0 {
+		return nil, ErrInvalidProxyCreationCode
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("invalid aa account index: %d", index)
+	}
+	return &SmartAccount{
+		factory:           common.HexToAddress(factory),
+		implementation:    common.HexToAddress(implementation),
+		proxyCreationCode: creationCode,
+		index:             big.NewInt(index),
+	}, nil
+}
+
+// OwnerAddress returns the evm owner address of the compressed or uncompressed hex btc public key
+func OwnerAddress(btcPubKey string) (common.Address, error) {
+	pubKeyBytes, err := hexutil.Decode(with0xPrefix(btcPubKey))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidPubKey, btcPubKey)
+	}
+	switch len(pubKeyBytes) {
+	case 33:
+		pubKey, err := crypto.DecompressPubkey(pubKeyBytes)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidPubKey, err)
+		}
+		return crypto.PubkeyToAddress(*pubKey), nil
+	case 65:
+		pubKey, err := crypto.UnmarshalPubkey(pubKeyBytes)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidPubKey, err)
+		}
+		return crypto.PubkeyToAddress(*pubKey), nil
+	default:
+		return common.Address{}, fmt.Errorf("%w: %s", ErrInvalidPubKey, btcPubKey)
+	}
+}
+
+// Address returns the smart account address of the btc public key
+func (a *SmartAccount) Address(btcPubKey string) (common.Address, error) {
+	owner, err := OwnerAddress(btcPubKey)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return a.AddressOf(owner)
+}
+
+// AddressOf returns the create2 address of the smart account owned by the owner
+func (a *SmartAccount) AddressOf(owner common.Address) (common.Address, error) {
+	addressType, err := abi.NewType("address", "", nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+	bytesType, err := abi.NewType("bytes", "", nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+	initData, err := abi.Arguments{{Type: addressType}}.Pack(owner)
+	if err != nil {
+		return common.Address{}, err
+	}
+	constructorArgs, err := abi.Arguments{{Type: addressType}, {Type: bytesType}}.
+		Pack(a.implementation, append(append([]byte{}, initializeSelector...), initData...))
+	if err != nil {
+		return common.Address{}, err
+	}
+	initCode := append(append([]byte{}, a.proxyCreationCode...), constructorArgs...)
+	salt := common.BigToHash(a.index)
+	return crypto.CreateAddress2(a.factory, salt, crypto.Keccak256(initCode)), nil
+}
+
+func with0xPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return s
+	}
+	return "0x" + s
+}
@@ -0,0 +1,161 @@
+package aa_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/b2network/b2-indexer/pkg/aa"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testPubKey public key of the private key 1
+	testPubKey             = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	testPubKeyUncompressed = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" +
+		"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	testOwner          = "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"
+	testFactory        = "0x0000000000000000000000000000000000000022"
+	testImplementation = "0x0000000000000000000000000000000000000011"
+)
+
+func TestOwnerAddress(t *testing.T) {
+	testCases := []struct {
+		name   string
+		pubKey string
+		err    bool
+	}{
+		{
+			name:   "success: compressed",
+			pubKey: testPubKey,
+		},
+		{
+			name:   "success: 0x prefix",
+			pubKey: "0x" + testPubKey,
+		},
+		{
+			name:   "success: uncompressed",
+			pubKey: testPubKeyUncompressed,
+		},
+		{
+			name:   "fail: x only",
+			pubKey: testPubKey[2:],
+			err:    true,
+		},
+		{
+			name:   "fail: not on curve",
+			pubKey: "02" + strings.Repeat("00", 32),
+			err:    true,
+		},
+		{
+			name:   "fail: not hex",
+			pubKey: "pubkey",
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			owner, err := aa.OwnerAddress(tc.pubKey)
+			if tc.err {
+				require.ErrorIs(t, err, aa.ErrInvalidPubKey)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, common.HexToAddress(testOwner), owner)
+		})
+	}
+}
+
+func TestSmartAccountAddress(t *testing.T) {
+	account, err := aa.NewSmartAccount(testFactory, testImplementation, "0x6080", 1)
+	require.NoError(t, err)
+
+	// creation code ++ abi.encode(implementation, initialize(owner))
+	initCode := common.FromHex("6080" +
+		"0000000000000000000000000000000000000000000000000000000000000011" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000024" +
+		"c4d66de8" + "0000000000000000000000007e5f4552091a69125d5dfcb7b8c2659029395bdf" +
+		strings.Repeat("00", 28))
+	salt := common.BigToHash(common.Big1)
+	expected := crypto.CreateAddress2(common.HexToAddress(testFactory), salt, crypto.Keccak256(initCode))
+
+	address, err := account.Address(testPubKey)
+	require.NoError(t, err)
+	require.Equal(t, expected, address)
+
+	address, err = account.AddressOf(common.HexToAddress(testOwner))
+	require.NoError(t, err)
+	require.Equal(t, expected, address)
+
+	// the index is the salt
+	other, err := aa.NewSmartAccount(testFactory, testImplementation, "0x6080", 0)
+	require.NoError(t, err)
+	otherAddress, err := other.Address(testPubKey)
+	require.NoError(t, err)
+	require.NotEqual(t, expected, otherAddress)
+
+	_, err = account.Address("pubkey")
+	require.ErrorIs(t, err, aa.ErrInvalidPubKey)
+}
+
+func TestNewSmartAccount(t *testing.T) {
+	testCases := []struct {
+		name              string
+		factory           string
+		implementation    string
+		proxyCreationCode string
+		index             int64
+		err               error
+	}{
+		{
+			name:              "success",
+			factory:           testFactory,
+			implementation:    testImplementation,
+			proxyCreationCode: "6080",
+		},
+		{
+			name:              "fail: factory",
+			factory:           "0x22",
+			implementation:    testImplementation,
+			proxyCreationCode: "0x6080",
+			err:               aa.ErrInvalidFactoryAddress,
+		},
+		{
+			name:              "fail: implementation",
+			factory:           testFactory,
+			proxyCreationCode: "0x6080",
+			err:               aa.ErrInvalidImplementation,
+		},
+		{
+			name:           "fail: empty proxy creation code",
+			factory:        testFactory,
+			implementation: testImplementation,
+			err:            aa.ErrInvalidProxyCreationCode,
+		},
+		{
+			name:              "fail: negative index",
+			factory:           testFactory,
+			implementation:    testImplementation,
+			proxyCreationCode: "0x6080",
+			index:             -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := aa.NewSmartAccount(tc.factory, tc.implementation, tc.proxyCreationCode, tc.index)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			if tc.index < 0 {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
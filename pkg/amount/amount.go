@@ -0,0 +1,115 @@
+package amount
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// BtcDecimals decimals of bitcoin amounts in satoshi
+	BtcDecimals = 8
+	// DefaultDecimals decimals of the b2 native btc
+	DefaultDecimals = 18
+	// MaxDecimals max decimals of the bridged asset
+	MaxDecimals = 77
+)
+
+var (
+	ErrPrecisionLoss   = errors.New("amount conversion loses precision")
+	ErrSatsOverflow    = errors.New("amount overflows satoshi")
+	ErrNegativeAmount  = errors.New("negative amount")
+	ErrInvalidDecimals = errors.New("invalid asset decimals")
+)
+
+// Sats bitcoin amount in satoshi
+type Sats int64
+
+// Int64 returns the satoshi
+func (s Sats) Int64() int64 {
+	return int64(s)
+}
+
+func (s Sats) String() string {
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Asset bridged asset on b2, amounts are in the smallest unit of the asset (wei)
+type Asset struct {
+	decimals uint
+	// scale 10^|decimals - 8|
+	scale *big.Int
+}
+
+// DefaultAsset b2 native btc, 1 satoshi = 1e10 wei
+var DefaultAsset = MustNewAsset(DefaultDecimals)
+
+// NewAsset returns the asset of the decimals, 0 use the default decimals
+func NewAsset(decimals uint) (Asset, error) {
+	if decimals == 0 {
+		decimals = DefaultDecimals
+	}
+	if decimals > MaxDecimals {
+		return Asset{}, fmt.Errorf("%w: %d", ErrInvalidDecimals, decimals)
+	}
+	exp := int64(decimals) - BtcDecimals
+	if exp < 0 {
+		exp = -exp
+	}
+	return Asset{
+		decimals: decimals,
+		scale:    new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil),
+	}, nil
+}
+
+// MustNewAsset returns the asset of the decimals, panics if invalid
+func MustNewAsset(decimals uint) Asset {
+	asset, err := NewAsset(decimals)
+	if err != nil {
+		panic(err)
+	}
+	return asset
+}
+
+// Decimals returns the asset decimals
+func (a Asset) Decimals() uint {
+	return a.decimals
+}
+
+// ToWei convert satoshi to the asset amount, satoshi not representable by fewer decimals are rejected
+func (a Asset) ToWei(sats Sats) (*big.Int, error) {
+	if sats < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrNegativeAmount, sats)
+	}
+	value := big.NewInt(sats.Int64())
+	if a.decimals >= BtcDecimals {
+		return value.Mul(value, a.scale), nil
+	}
+	quo, rem := new(big.Int).QuoRem(value, a.scale, new(big.Int))
+	if rem.Sign() != 0 {
+		return nil, fmt.Errorf("%w: %d satoshi to %d decimals", ErrPrecisionLoss, sats, a.decimals)
+	}
+	return quo, nil
+}
+
+// ToSats convert the asset amount to satoshi, amounts with fractional satoshi are rejected
+func (a Asset) ToSats(wei *big.Int) (Sats, error) {
+	if wei.Sign() < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNegativeAmount, wei)
+	}
+	var sats *big.Int
+	if a.decimals >= BtcDecimals {
+		var rem *big.Int
+		sats, rem = new(big.Int).QuoRem(wei, a.scale, new(big.Int))
+		if rem.Sign() != 0 {
+			return 0, fmt.Errorf("%w: %s of %d decimals to satoshi", ErrPrecisionLoss, wei, a.decimals)
+		}
+	} else {
+		sats = new(big.Int).Mul(wei, a.scale)
+	}
+	if !sats.IsInt64() {
+		return 0, fmt.Errorf("%w: %s", ErrSatsOverflow, sats)
+	}
+	return Sats(sats.Int64()), nil
+}
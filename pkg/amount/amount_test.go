@@ -0,0 +1,130 @@
+package amount_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/b2network/b2-indexer/pkg/amount"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToWei(t *testing.T) {
+	testCases := []struct {
+		name     string
+		decimals uint
+		sats     amount.Sats
+		expected string
+		err      error
+	}{
+		{
+			name:     "success: default decimals",
+			sats:     12345,
+			expected: "123450000000000",
+		},
+		{
+			name:     "success: 18 decimals",
+			decimals: 18,
+			sats:     1,
+			expected: "10000000000",
+		},
+		{
+			name:     "success: 8 decimals",
+			decimals: 8,
+			sats:     12345,
+			expected: "12345",
+		},
+		{
+			name:     "success: 6 decimals",
+			decimals: 6,
+			sats:     12300,
+			expected: "123",
+		},
+		{
+			name:     "fail: 6 decimals precision loss",
+			decimals: 6,
+			sats:     12345,
+			err:      amount.ErrPrecisionLoss,
+		},
+		{
+			name: "fail: negative",
+			sats: -1,
+			err:  amount.ErrNegativeAmount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := amount.NewAsset(tc.decimals)
+			require.NoError(t, err)
+			wei, err := asset.ToWei(tc.sats)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, wei.String())
+		})
+	}
+}
+
+func TestToSats(t *testing.T) {
+	overflow, ok := new(big.Int).SetString("100000000000000000000000000000000000000", 10)
+	require.True(t, ok)
+
+	testCases := []struct {
+		name     string
+		decimals uint
+		wei      *big.Int
+		expected amount.Sats
+		err      error
+	}{
+		{
+			name:     "success: default decimals",
+			wei:      big.NewInt(123450000000000),
+			expected: 12345,
+		},
+		{
+			name:     "success: 6 decimals",
+			decimals: 6,
+			wei:      big.NewInt(123),
+			expected: 12300,
+		},
+		{
+			name: "fail: fractional satoshi",
+			wei:  big.NewInt(123450000000001),
+			err:  amount.ErrPrecisionLoss,
+		},
+		{
+			name: "fail: overflow",
+			wei:  overflow,
+			err:  amount.ErrSatsOverflow,
+		},
+		{
+			name: "fail: negative",
+			wei:  big.NewInt(-1),
+			err:  amount.ErrNegativeAmount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset, err := amount.NewAsset(tc.decimals)
+			require.NoError(t, err)
+			sats, err := asset.ToSats(tc.wei)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, sats)
+		})
+	}
+}
+
+func TestNewAsset(t *testing.T) {
+	asset, err := amount.NewAsset(0)
+	require.NoError(t, err)
+	require.Equal(t, uint(amount.DefaultDecimals), asset.Decimals())
+	_, err = amount.NewAsset(amount.MaxDecimals + 1)
+	require.ErrorIs(t, err, amount.ErrInvalidDecimals)
+}